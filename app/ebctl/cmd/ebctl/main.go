@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tianping526/eventbridge/app/ebctl/internal/command"
+)
+
+// go build -ldflags "-X main.Version=x.y.z"
+var (
+	// Version is the version of the compiled software.
+	Version string
+)
+
+func main() {
+	err := command.NewRootCommand(Version).Execute()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, command.FormatError(err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+package command
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newBusCommand(o *options) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "bus",
+		Short: "Manage the buses",
+	}
+	c.AddCommand(newBusListCommand(o), newBusCreateCommand(o), newBusUpdateCommand(o), newBusDeleteCommand(o))
+	return c
+}
+
+func newBusListCommand(o *options) *cobra.Command {
+	req := &v1.ListBusRequest{}
+	var prefix string
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List the buses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if cmd.Flags().Changed("prefix") {
+				req.Prefix = &prefix
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListBus(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("NAME", "MODE", "STATUS", "SOURCE", "SOURCE DELAY", "TARGET EXP DECAY", "TARGET BACKOFF")
+				for _, b := range resp.Buses {
+					t.row(
+						b.Name,
+						enumName(b.Mode.String(), "BUS_WORK_MODE_"),
+						enumName(b.Status.String(), "BUS_STATUS_"),
+						topicCell(b.Source),
+						topicCell(b.SourceDelay),
+						topicCell(b.TargetExpDecay),
+						topicCell(b.TargetBackoff),
+					)
+				}
+				if resp.NextToken > 0 {
+					t.row("next token: " + strconv.FormatUint(resp.NextToken, 10))
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().StringVar(&prefix, "prefix", "", "prefix of the bus names")
+	c.Flags().Int32Var(&req.Limit, "limit", 0, "max number of the buses, 100 if it is 0")
+	c.Flags().Uint64Var(&req.NextToken, "next-token", 0, "next token of the previous page")
+	return c
+}
+
+func newBusCreateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create a bus from the CreateBusRequest in the file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.CreateBusRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.CreateBus(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("bus %s created, id: %d", req.Name, resp.Id))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newBusUpdateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "update -f FILE",
+		Short: "Update a bus by the UpdateBusRequest in the file, the unset fields are not updated",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.UpdateBusRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.UpdateBus(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("bus %s updated", req.Name))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newBusDeleteCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a bus",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.DeleteBus(ctx, &v1.DeleteBusRequest{Name: args[0]})
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("bus %s deleted", args[0]))
+			})
+		},
+	}
+}
+
+// topicCell is the topic with its endpoints, such as EBInterBusDefault@127.0.0.1:8081.
+func topicCell(t *v1.MQTopic) string {
+	if t == nil {
+		return ""
+	}
+	return t.Topic + "@" + strings.Join(t.Endpoints, ";")
+}
@@ -0,0 +1,427 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/middleware/auth/jwt"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	jwtV5 "github.com/golang-jwt/jwt/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+const testKey = "testKey"
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// fakeServer returns the canned responses and records the last request.
+type fakeServer struct {
+	v1.UnimplementedEventBridgeServiceServer
+
+	last proto.Message
+}
+
+func testTopic(topic string) *v1.MQTopic {
+	return &v1.MQTopic{
+		MqType:    v1.MQType_MQ_TYPE_ROCKETMQ,
+		Endpoints: []string{"127.0.0.1:8081"},
+		Topic:     topic,
+	}
+}
+
+func testTargets() []*v1.Target {
+	return []*v1.Target{
+		{
+			Id:   1,
+			Type: "HTTPDispatcher",
+			Params: []*v1.TargetParam{
+				{Key: "url", Form: "CONSTANT", Value: "http://127.0.0.1:8080/events"},
+			},
+			RetryStrategy: v1.RetryStrategy_RETRY_STRATEGY_BACKOFF,
+		},
+	}
+}
+
+var testTime = timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+func (s *fakeServer) PostEvent(_ context.Context, req *v1.PostEventRequest) (*v1.PostEventResponse, error) {
+	s.last = req
+	if req.BusName != nil {
+		return &v1.PostEventResponse{Id: 42, MessageKey: "k1", TraceId: "t1", Quarantined: true}, nil
+	}
+	if len(req.BusNames) > 0 {
+		results := make([]*v1.PostEventResponse_BusResult, 0, len(req.BusNames))
+		for i, name := range req.BusNames {
+			results = append(results, &v1.PostEventResponse_BusResult{BusName: name, MessageId: fmt.Sprintf("m%d", i+1)})
+		}
+		return &v1.PostEventResponse{Id: 42, MessageId: "m1", MessageKey: "k1", TraceId: "t1", Results: results}, nil
+	}
+	return &v1.PostEventResponse{Id: 42, MessageId: "m1", MessageKey: "k1", TraceId: "t1"}, nil
+}
+
+func (s *fakeServer) ListSchema(_ context.Context, req *v1.ListSchemaRequest) (*v1.ListSchemaResponse, error) {
+	s.last = req
+	return &v1.ListSchemaResponse{
+		Schemas: []*v1.Schema{
+			{
+				Source:        "testSource",
+				Type:          "testSourceType",
+				BusName:       "Default",
+				ExtraBusNames: []string{"Orders"},
+				Spec:          `{"type":"object"}`,
+				Time:          testTime,
+				Version:       2,
+				Compatibility: v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_BACKWARD,
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer) ListSchemaVersions(
+	_ context.Context, req *v1.ListSchemaVersionsRequest,
+) (*v1.ListSchemaVersionsResponse, error) {
+	s.last = req
+	return &v1.ListSchemaVersionsResponse{
+		Versions: []*v1.SchemaVersion{
+			{
+				Version:       2,
+				BusName:       "Default",
+				Spec:          `{"type":"object"}`,
+				Compatibility: v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_BACKWARD,
+				Time:          testTime,
+			},
+			{
+				Version:       1,
+				BusName:       "Default",
+				Spec:          `{"type":"object","required":["a"]}`,
+				Compatibility: v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE,
+				Time:          testTime,
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer) GetSchemaVersion(
+	_ context.Context, req *v1.GetSchemaVersionRequest,
+) (*v1.GetSchemaVersionResponse, error) {
+	s.last = req
+	return &v1.GetSchemaVersionResponse{
+		Version: &v1.SchemaVersion{
+			Version:       req.Version,
+			BusName:       "Default",
+			Spec:          `{"type":"object","required":["a"]}`,
+			Compatibility: v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE,
+			Time:          testTime,
+		},
+	}, nil
+}
+
+func (s *fakeServer) ListDiscoveredSchemas(
+	_ context.Context, req *v1.ListDiscoveredSchemasRequest,
+) (*v1.ListDiscoveredSchemasResponse, error) {
+	s.last = req
+	return &v1.ListDiscoveredSchemasResponse{
+		Schemas: []*v1.DiscoveredSchema{
+			{
+				Source:    "newSource",
+				Type:      "newSourceType",
+				BusName:   "Default",
+				Spec:      `{"properties":{"a":{"type":"string"}},"required":["a"],"type":"object"}`,
+				Samples:   3,
+				FirstTime: testTime,
+				LastTime:  testTime,
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer) PromoteDiscoveredSchema(
+	_ context.Context, req *v1.PromoteDiscoveredSchemaRequest,
+) (*v1.PromoteDiscoveredSchemaResponse, error) {
+	s.last = req
+	return &v1.PromoteDiscoveredSchemaResponse{Posted: 2, Dropped: 1}, nil
+}
+
+func (s *fakeServer) GenerateSchemaCode(
+	_ context.Context, req *v1.GenerateSchemaCodeRequest,
+) (*v1.GenerateSchemaCodeResponse, error) {
+	s.last = req
+	return &v1.GenerateSchemaCodeResponse{Code: "{\n  \"openapi\": \"3.1.0\"\n}\n"}, nil
+}
+
+func (s *fakeServer) ListBus(_ context.Context, req *v1.ListBusRequest) (*v1.ListBusResponse, error) {
+	s.last = req
+	return &v1.ListBusResponse{
+		Buses: []*v1.ListBusResponse_Bus{
+			{
+				Name:           "Default",
+				Mode:           v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+				Source:         testTopic("EBInterBusDefault"),
+				SourceDelay:    testTopic("EBInterDelayBusDefault"),
+				TargetExpDecay: testTopic("EBInterTargetExpDecayBusDefault"),
+				TargetBackoff:  testTopic("EBInterTargetBackoffBusDefault"),
+				Status:         v1.BusStatus_BUS_STATUS_ACTIVE,
+			},
+		},
+		NextToken: 2,
+	}, nil
+}
+
+func (s *fakeServer) CreateBus(_ context.Context, req *v1.CreateBusRequest) (*v1.CreateBusResponse, error) {
+	s.last = req
+	return &v1.CreateBusResponse{Id: 3}, nil
+}
+
+func (s *fakeServer) DeleteBus(_ context.Context, req *v1.DeleteBusRequest) (*v1.DeleteBusResponse, error) {
+	s.last = req
+	return &v1.DeleteBusResponse{}, nil
+}
+
+func (s *fakeServer) ListRule(_ context.Context, req *v1.ListRuleRequest) (*v1.ListRuleResponse, error) {
+	s.last = req
+	return &v1.ListRuleResponse{
+		Rules: []*v1.ListRuleResponse_Rule{
+			{
+				Name:    "testRule",
+				BusName: req.BusName,
+				Status:  v1.RuleStatus_RULE_STATUS_ENABLE,
+				Pattern: `{"source":["testSource"]}`,
+				Targets: testTargets(),
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer) CreateRule(_ context.Context, req *v1.CreateRuleRequest) (*v1.CreateRuleResponse, error) {
+	s.last = req
+	return &v1.CreateRuleResponse{Id: 5}, nil
+}
+
+func (s *fakeServer) DeleteRule(_ context.Context, req *v1.DeleteRuleRequest) (*v1.DeleteRuleResponse, error) {
+	s.last = req
+	if req.Name != "testRule" {
+		return nil, v1.ErrorRuleNotFound("rule(%s:%s) not found", req.BusName, req.Name)
+	}
+	return &v1.DeleteRuleResponse{}, nil
+}
+
+func (s *fakeServer) DeleteTargets(
+	_ context.Context, req *v1.DeleteTargetsRequest,
+) (*v1.DeleteTargetsResponse, error) {
+	s.last = req
+	return &v1.DeleteTargetsResponse{}, nil
+}
+
+func (s *fakeServer) ListRuleRevisions(
+	_ context.Context, req *v1.ListRuleRevisionsRequest,
+) (*v1.ListRuleRevisionsResponse, error) {
+	s.last = req
+	return &v1.ListRuleRevisionsResponse{
+		Revisions: []*v1.ListRuleRevisionsResponse_RuleRevision{
+			{
+				Revision:  2,
+				Operation: "UPDATE",
+				Status:    v1.RuleStatus_RULE_STATUS_DISABLE,
+				Pattern:   `{"source":["testSource"]}`,
+				Targets:   testTargets(),
+				Time:      testTime,
+			},
+			{
+				Revision:  1,
+				Operation: "CREATE",
+				Status:    v1.RuleStatus_RULE_STATUS_ENABLE,
+				Pattern:   `{"source":["testSource"]}`,
+				Time:      testTime,
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer) RollbackRule(_ context.Context, req *v1.RollbackRuleRequest) (*v1.RollbackRuleResponse, error) {
+	s.last = req
+	return &v1.RollbackRuleResponse{Revision: 3}, nil
+}
+
+func (s *fakeServer) ListDispatcherSchema(
+	_ context.Context, req *v1.ListDispatcherSchemaRequest,
+) (*v1.ListDispatcherSchemaResponse, error) {
+	s.last = req
+	return &v1.ListDispatcherSchemaResponse{
+		DispatcherSchemas: []*v1.ListDispatcherSchemaResponse_DispatcherSchema{
+			{Type: "HTTPDispatcher", ParamsSchema: `{"type":"object"}`},
+		},
+	}, nil
+}
+
+func (s *fakeServer) Apply(_ context.Context, req *v1.ApplyRequest) (*v1.ApplyResponse, error) {
+	s.last = req
+	return &v1.ApplyResponse{
+		Changes: []*v1.ApplyResponse_Change{
+			{Action: "CREATE", Kind: "SCHEMA", BusName: "Default", Name: "testSource/testSourceType"},
+			{Action: "UPDATE", Kind: "RULE", BusName: "Default", Name: "testRule"},
+		},
+	}, nil
+}
+
+func (s *fakeServer) Export(_ context.Context, req *v1.ExportRequest) (*v1.ExportResponse, error) {
+	s.last = req
+	return &v1.ExportResponse{
+		Manifest: &v1.Manifest{
+			Buses: []*v1.Manifest_Bus{
+				{
+					Name:           "Default",
+					Mode:           v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+					Status:         v1.BusStatus_BUS_STATUS_ACTIVE,
+					Source:         testTopic("EBInterBusDefault"),
+					SourceDelay:    testTopic("EBInterDelayBusDefault"),
+					TargetExpDecay: testTopic("EBInterTargetExpDecayBusDefault"),
+					TargetBackoff:  testTopic("EBInterTargetBackoffBusDefault"),
+					Rules: []*v1.Manifest_Rule{
+						{
+							Name:    "testRule",
+							Status:  v1.RuleStatus_RULE_STATUS_ENABLE,
+							Pattern: `{"source":["testSource"]}`,
+							Targets: testTargets(),
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func newTestServer(t *testing.T) (*fakeServer, string) {
+	fs := &fakeServer{}
+	srv := grpc.NewServer(
+		grpc.Address("127.0.0.1:0"),
+		grpc.Middleware(jwt.Server(func(*jwtV5.Token) (interface{}, error) {
+			return []byte(testKey), nil
+		}, jwt.WithSigningMethod(jwtV5.SigningMethodHS256))),
+	)
+	v1.RegisterEventBridgeServiceServer(srv, fs)
+	u, err := srv.Endpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	t.Cleanup(func() {
+		_ = srv.Stop(context.Background())
+	})
+	return fs, u.Host
+}
+
+func TestCommands(t *testing.T) {
+	fs, endpoint := newTestServer(t)
+	commandTests := []struct {
+		name  string
+		args  []string
+		key   string
+		stdin string
+	}{
+		{name: "bus_list", args: []string{"bus", "list", "--prefix", "Def", "--limit", "1"}},
+		{name: "bus_list_json", args: []string{"bus", "list", "-o", "json"}},
+		{name: "bus_list_yaml", args: []string{"bus", "list", "-o", "yaml"}},
+		{name: "bus_create", args: []string{"bus", "create", "-f", "testdata/bus.yaml"}},
+		{name: "bus_delete", args: []string{"bus", "delete", "Orderly"}},
+		{name: "schema_list", args: []string{"schema", "list", "--bus", "Default"}},
+		{name: "schema_versions", args: []string{"schema", "versions", "testSource", "testSourceType"}},
+		{name: "schema_version", args: []string{"schema", "version", "testSource", "testSourceType", "1"}},
+		{name: "schema_discovered", args: []string{"schema", "discovered", "--bus", "Default"}},
+		{
+			name: "schema_promote",
+			args: []string{"schema", "promote", "newSource", "newSourceType", "--compatibility", "backward"},
+		},
+		{name: "schema_code", args: []string{"schema", "code", "--bus", "Default", "--language", "openapi"}},
+		{name: "rule_list", args: []string{"rule", "list", "Default", "--status", "enable"}},
+		{
+			name:  "rule_create",
+			args:  []string{"rule", "create", "-f", "-"},
+			stdin: `{"busName": "Default", "name": "testRule", "pattern": "{\"source\":[\"testSource\"]}"}`,
+		},
+		{name: "rule_delete_not_found", args: []string{"rule", "delete", "Default", "missing"}},
+		{name: "rule_revisions", args: []string{"rule", "revisions", "Default", "testRule"}},
+		{name: "rule_rollback", args: []string{"rule", "rollback", "Default", "testRule", "1"}},
+		{name: "target_delete", args: []string{"target", "delete", "Default", "testRule", "1", "2"}},
+		{name: "dispatcher_schema_list", args: []string{"dispatcher-schema", "list", "HTTPDispatcher"}},
+		{
+			name: "post_event",
+			args: []string{
+				"post-event", "-f", "testdata/event.yaml", "--retry-strategy", "backoff", "--schema-version", "1",
+			},
+		},
+		{
+			name: "post_event_quarantined",
+			args: []string{"post-event", "-f", "testdata/event.yaml", "--bus", "Default"},
+		},
+		{
+			name: "post_event_only_bus",
+			args: []string{"post-event", "-f", "testdata/event.yaml", "--only-bus", "Default,Orders"},
+		},
+		{
+			name: "test_pattern",
+			args: []string{"test-pattern", "-f", "testdata/event.yaml", "--pattern", `{"data":{"b":[2]}}`},
+		},
+		{
+			name: "test_pattern_rule",
+			args: []string{
+				"test-pattern", "-f", "testdata/event.yaml", "--bus", "Default", "--rule", "testRule", "-o", "json",
+			},
+		},
+		{name: "apply", args: []string{"apply", "-f", "testdata/manifest.yaml", "--dry-run"}},
+		{name: "export", args: []string{"export", "--bus", "Default"}},
+		{name: "export_json", args: []string{"export", "-o", "json"}},
+		{name: "wrong_key", args: []string{"bus", "list"}, key: "wrongKey"},
+		{name: "output_unknown", args: []string{"bus", "list", "-o", "xml"}},
+	}
+	for _, tt := range commandTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs.last = nil
+			key := tt.key
+			if key == "" {
+				key = testKey
+			}
+			cmd := NewRootCommand("test")
+			out := &bytes.Buffer{}
+			cmd.SetOut(out)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append([]string{"--endpoint", endpoint, "--key", key}, tt.args...))
+			err := cmd.Execute()
+			if err != nil {
+				out.WriteString(FormatError(err) + "\n")
+			}
+			if fs.last != nil {
+				bs, err := marshalJSON(fs.last)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out.WriteString("--- request\n" + string(bs) + "\n")
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				err = os.WriteFile(golden, out.Bytes(), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			expect, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != string(expect) {
+				t.Fatalf("expect:\n%s\nactual:\n%s", expect, out.String())
+			}
+		})
+	}
+}
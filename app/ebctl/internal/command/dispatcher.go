@@ -0,0 +1,34 @@
+package command
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newDispatcherSchemaCommand(o *options) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "dispatcher-schema",
+		Short: "Show the params schemas of the target types",
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "list [TYPE...]",
+		Short: "List the params schemas of the target types, all of them if no type is given",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListDispatcherSchema(ctx, &v1.ListDispatcherSchemaRequest{Types: args})
+				if err != nil {
+					return err
+				}
+				t := newTable("TYPE", "PARAMS SCHEMA")
+				for _, s := range resp.DispatcherSchemas {
+					t.row(s.Type, s.ParamsSchema)
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	})
+	return c
+}
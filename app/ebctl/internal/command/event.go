@@ -0,0 +1,167 @@
+package command
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/rule/pattern"
+)
+
+func newPostEventCommand(o *options) *cobra.Command {
+	var file, retryStrategy, pubTime, bus string
+	var schemaVersion uint32
+	var onlyBuses []string
+	c := &cobra.Command{
+		Use:   "post-event -f FILE",
+		Short: "Post the event in the file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.PostEventRequest{Event: &v1.Event{}}
+			err := readMessage(cmd, file, req.Event)
+			if err != nil {
+				return err
+			}
+			rs, err := parseEnum(retryStrategy, "RETRY_STRATEGY_", v1.RetryStrategy_value)
+			if err != nil {
+				return fmt.Errorf("invalid retry strategy: %w", err)
+			}
+			req.RetryStrategy = v1.RetryStrategy(rs)
+			if pubTime != "" {
+				pt, err := time.Parse(time.RFC3339, pubTime)
+				if err != nil {
+					return fmt.Errorf("invalid pub time: %w", err)
+				}
+				req.PubTime = timestamppb.New(pt)
+			}
+			if cmd.Flags().Changed("schema-version") {
+				req.SchemaVersion = &schemaVersion
+			}
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			req.BusNames = onlyBuses
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.PostEvent(ctx, req)
+				if err != nil {
+					return err
+				}
+				if resp.Quarantined {
+					return o.print(cmd, resp, message("event %d quarantined", resp.Id))
+				}
+				if len(resp.Results) > 1 {
+					t := newTable("BUS", "MESSAGE ID")
+					for _, r := range resp.Results {
+						t.row(r.BusName, r.MessageId)
+					}
+					t.row(fmt.Sprintf("event %d posted, message key: %s", resp.Id, resp.MessageKey))
+					return o.print(cmd, resp, t)
+				}
+				return o.print(cmd, resp, message(
+					"event %d posted, message id: %s, message key: %s", resp.Id, resp.MessageId, resp.MessageKey,
+				))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file of the event, - for stdin")
+	c.Flags().StringVar(&retryStrategy, "retry-strategy", "", "retry strategy, backoff or exponential_decay")
+	c.Flags().StringVar(&pubTime, "pub-time", "", "RFC3339 time to deliver the event, now if it is not set")
+	c.Flags().Uint32Var(&schemaVersion, "schema-version", 0, "version of the schema to validate the event against")
+	c.Flags().StringVar(&bus, "bus", "", "bus to quarantine the event if its source and type are unknown")
+	c.Flags().StringSliceVar(
+		&onlyBuses, "only-bus", nil, "post to these buses of the schema only, to retry the failed ones",
+	)
+	return c
+}
+
+func newTestPatternCommand(o *options) *cobra.Command {
+	var file, filterPattern, bus, ruleName string
+	c := &cobra.Command{
+		Use:   "test-pattern -f FILE (--pattern PATTERN | --bus BUS --rule RULE)",
+		Short: "Test whether the event in the file matches the pattern, or the pattern of the rule",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if (filterPattern == "") == (ruleName == "") {
+				return errors.New("either the pattern or the rule is required")
+			}
+			if ruleName != "" && bus == "" {
+				return errors.New("the bus of the rule is required")
+			}
+			evt := &v1.Event{}
+			err := readMessage(cmd, file, evt)
+			if err != nil {
+				return err
+			}
+			if filterPattern == "" {
+				err = o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+					filterPattern, err = rulePattern(ctx, client, bus, ruleName)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+			ok, err := matchPattern(cmd.Context(), filterPattern, evt)
+			if err != nil {
+				return err
+			}
+			resp, err := structpb.NewStruct(map[string]interface{}{"matched": ok})
+			if err != nil {
+				return err
+			}
+			if ok {
+				return o.print(cmd, resp, message("matched"))
+			}
+			return o.print(cmd, resp, message("not matched"))
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file of the event, - for stdin")
+	c.Flags().StringVar(&filterPattern, "pattern", "", "pattern in JSON")
+	c.Flags().StringVar(&bus, "bus", "", "bus of the rule")
+	c.Flags().StringVar(&ruleName, "rule", "", "rule whose pattern is tested")
+	return c
+}
+
+// rulePattern gets the pattern of the rule by listing the rules with its name as the prefix.
+func rulePattern(ctx context.Context, client v1.EventBridgeServiceClient, bus string, name string) (string, error) {
+	req := &v1.ListRuleRequest{BusName: bus, Prefix: &name}
+	for {
+		resp, err := client.ListRule(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range resp.Rules {
+			if r.Name == name {
+				return r.Pattern, nil
+			}
+		}
+		if resp.NextToken == 0 {
+			return "", v1.ErrorRuleNotFound("rule(%s:%s) not found", bus, name)
+		}
+		req.NextToken = resp.NextToken
+	}
+}
+
+// matchPattern matches the event locally with the same matcher as the job.
+func matchPattern(ctx context.Context, filterPattern string, evt *v1.Event) (bool, error) {
+	fp := make(map[string]interface{})
+	err := json.Unmarshal([]byte(filterPattern), &fp)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern: %w", err)
+	}
+	m, err := pattern.NewMatcher(ctx, log.DefaultLogger, fp)
+	if err != nil {
+		return false, fmt.Errorf("invalid pattern: %w", err)
+	}
+	// the event is not assigned an ID, which is only needed to post it
+	return m.Pattern(ctx, &rule.EventExt{EventExt: &v1.EventExt{Event: evt}})
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"context"
+
+	"buf.build/go/protoyaml"
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newApplyCommand(o *options) *cobra.Command {
+	var (
+		file   string
+		bus    string
+		dryRun bool
+	)
+	c := &cobra.Command{
+		Use:   "apply -f FILE",
+		Short: "Apply the manifest in the file, the buses not in it are deleted unless the bus is set",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.ApplyRequest{Manifest: &v1.Manifest{}, DryRun: dryRun}
+			err := readMessage(cmd, file, req.Manifest)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.Apply(ctx, req)
+				if err != nil {
+					return err
+				}
+				if len(resp.Changes) == 0 {
+					return o.print(cmd, resp, message("no changes"))
+				}
+				t := newTable("ACTION", "KIND", "BUS", "NAME")
+				for _, c := range resp.Changes {
+					t.row(c.Action, c.Kind, c.BusName, c.Name)
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file of the manifest, - for stdin")
+	c.Flags().StringVar(&bus, "bus", "", "apply only the bus")
+	c.Flags().BoolVar(&dryRun, "dry-run", false, "plan the changes without applying them")
+	return c
+}
+
+func newExportCommand(o *options) *cobra.Command {
+	var bus string
+	c := &cobra.Command{
+		Use:   "export",
+		Short: "Export the manifest of all buses or the bus, in YAML unless the output is json",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.ExportRequest{}
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.Export(ctx, req)
+				if err != nil {
+					return err
+				}
+				if o.output == outputJSON {
+					return o.print(cmd, resp.Manifest, nil)
+				}
+				// the manifest is exported as it is applied, so the table output is YAML too
+				bs, err := protoyaml.Marshal(resp.Manifest)
+				if err != nil {
+					return err
+				}
+				_, err = cmd.OutOrStdout().Write(bs)
+				return err
+			})
+		},
+	}
+	c.Flags().StringVar(&bus, "bus", "", "export only the bus")
+	return c
+}
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"buf.build/go/protoyaml"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// table is the table output of a response, the header is omitted if it is empty.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+func (t *table) row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// message is the table output of the response without content.
+func message(format string, args ...interface{}) *table {
+	t := newTable()
+	t.row(fmt.Sprintf(format, args...))
+	return t
+}
+
+func (t *table) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0) //nolint:mnd
+	if len(t.header) > 0 {
+		_, err := fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range t.rows {
+		_, err := fmt.Fprintln(tw, strings.Join(r, "\t"))
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// print prints the response in JSON or YAML, or its table.
+func (o *options) print(cmd *cobra.Command, m proto.Message, t *table) error {
+	w := cmd.OutOrStdout()
+	switch o.output {
+	case outputJSON:
+		bs, err := marshalJSON(m)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(bs))
+		return err
+	case outputYAML:
+		bs, err := protoyaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(bs)
+		return err
+	default:
+		return t.write(w)
+	}
+}
+
+// marshalJSON the output of protojson is indented again to be stable.
+func marshalJSON(m proto.Message) ([]byte, error) {
+	bs, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	v := jsontext.Value(bs)
+	err = v.Indent(jsontext.WithIndent("  "))
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// enumName is the name of the enum value without its prefix.
+func enumName(name string, prefix string) string {
+	return strings.TrimPrefix(name, prefix)
+}
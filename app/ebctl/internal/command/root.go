@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"buf.build/go/protoyaml"
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/middleware/auth/jwt"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	jwtV5 "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+type options struct {
+	endpoint string
+	key      string
+	timeout  time.Duration
+	output   string
+}
+
+// NewRootCommand the endpoint and the key default to the environment variables EBCTL_ENDPOINT and EBCTL_KEY.
+func NewRootCommand(version string) *cobra.Command {
+	o := &options{}
+	root := &cobra.Command{
+		Use:           "ebctl",
+		Short:         "ebctl controls the EventBridge service",
+		Version:       version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			switch o.output {
+			case outputTable, outputJSON, outputYAML:
+				return nil
+			default:
+				return fmt.Errorf("unknown output format %q, should be table, json or yaml", o.output)
+			}
+		},
+	}
+	endpoint := os.Getenv("EBCTL_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "127.0.0.1:9011"
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&o.endpoint, "endpoint", endpoint, "gRPC endpoint of the service")
+	flags.StringVar(&o.key, "key", os.Getenv("EBCTL_KEY"), "HS256 key of the JWT, the same as the Auth.key of the service")
+	flags.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of a request") //nolint:mnd
+	flags.StringVarP(&o.output, "output", "o", outputTable, "output format, table, json or yaml")
+
+	root.AddCommand(
+		newBusCommand(o),
+		newSchemaCommand(o),
+		newRuleCommand(o),
+		newTargetCommand(o),
+		newDispatcherSchemaCommand(o),
+		newPostEventCommand(o),
+		newTestPatternCommand(o),
+		newApplyCommand(o),
+		newExportCommand(o),
+	)
+	return root
+}
+
+// FormatError formats the error returned by the service with its reason.
+func FormatError(err error) string {
+	if _, ok := status.FromError(err); ok {
+		se := kerrors.FromError(err)
+		return fmt.Sprintf("Error: %s: %s", se.Reason, se.Message)
+	}
+	return fmt.Sprintf("Error: %s", err)
+}
+
+// run calls the service with a client signing the JWT by the key if it is set.
+func (o *options) run(
+	cmd *cobra.Command, call func(ctx context.Context, client v1.EventBridgeServiceClient) error,
+) error {
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+	defer cancel()
+	var ms []middleware.Middleware
+	if o.key != "" {
+		ms = append(ms, jwt.Client(func(*jwtV5.Token) (interface{}, error) {
+			return []byte(o.key), nil
+		}, jwt.WithSigningMethod(jwtV5.SigningMethodHS256), jwt.WithClaims(func() jwtV5.Claims {
+			now := time.Now()
+			return &jwtV5.RegisteredClaims{
+				Subject:   "ebctl",
+				IssuedAt:  jwtV5.NewNumericDate(now),
+				ExpiresAt: jwtV5.NewNumericDate(now.Add(o.timeout)),
+			}
+		})))
+	}
+	conn, err := grpc.DialInsecure(
+		ctx,
+		grpc.WithEndpoint(o.endpoint),
+		grpc.WithTimeout(o.timeout),
+		grpc.WithMiddleware(ms...),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	return call(ctx, v1.NewEventBridgeServiceClient(conn))
+}
+
+// readMessage reads the message in JSON or YAML from the file, or from stdin if the file is "-".
+func readMessage(cmd *cobra.Command, file string, m proto.Message) error {
+	if file == "" {
+		return fmt.Errorf("the file of the %s is required", m.ProtoReflect().Descriptor().Name())
+	}
+	var (
+		bs  []byte
+		err error
+	)
+	if file == "-" {
+		bs, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		bs, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	err = protoyaml.Unmarshal(bs, m)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+	return nil
+}
+
+// parseEnum parses the name of the enum value without its prefix, such as ENABLE of RULE_STATUS_ENABLE.
+func parseEnum(name string, prefix string, values map[string]int32) (int32, error) {
+	if name == "" {
+		return 0, nil
+	}
+	v, ok := values[prefix+strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown value %q", name)
+	}
+	return v, nil
+}
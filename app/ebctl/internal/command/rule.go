@@ -0,0 +1,202 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newRuleCommand(o *options) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "rule",
+		Short: "Manage the rules",
+	}
+	c.AddCommand(
+		newRuleListCommand(o),
+		newRuleCreateCommand(o),
+		newRuleUpdateCommand(o),
+		newRuleDeleteCommand(o),
+		newRuleRevisionsCommand(o),
+		newRuleRollbackCommand(o),
+	)
+	return c
+}
+
+func newRuleListCommand(o *options) *cobra.Command {
+	req := &v1.ListRuleRequest{}
+	var prefix, status string
+	c := &cobra.Command{
+		Use:   "list BUS",
+		Short: "List the rules of the bus",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.BusName = args[0]
+			if cmd.Flags().Changed("prefix") {
+				req.Prefix = &prefix
+			}
+			s, err := parseEnum(status, "RULE_STATUS_", v1.RuleStatus_value)
+			if err != nil {
+				return fmt.Errorf("invalid status: %w", err)
+			}
+			req.Status = v1.RuleStatus(s)
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListRule(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("NAME", "STATUS", "PATTERN", "TARGETS")
+				for _, r := range resp.Rules {
+					t.row(
+						r.Name,
+						enumName(r.Status.String(), "RULE_STATUS_"),
+						r.Pattern,
+						strconv.Itoa(len(r.Targets)),
+					)
+				}
+				if resp.NextToken > 0 {
+					t.row("next token: " + strconv.FormatUint(resp.NextToken, 10))
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().StringVar(&prefix, "prefix", "", "prefix of the rule names")
+	c.Flags().StringVar(&status, "status", "", "status of the rules, enable, disable or paused")
+	c.Flags().Int32Var(&req.Limit, "limit", 0, "max number of the rules, 100 if it is 0")
+	c.Flags().Uint64Var(&req.NextToken, "next-token", 0, "next token of the previous page")
+	return c
+}
+
+func newRuleCreateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create a rule from the CreateRuleRequest in the file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.CreateRuleRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.CreateRule(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("rule %s:%s created, id: %d", req.BusName, req.Name, resp.Id))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newRuleUpdateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "update -f FILE",
+		Short: "Update a rule by the UpdateRuleRequest in the file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.UpdateRuleRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.UpdateRule(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("rule %s:%s updated", req.BusName, req.Name))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newRuleDeleteCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete BUS NAME",
+		Short: "Delete a rule",
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.DeleteRule(ctx, &v1.DeleteRuleRequest{BusName: args[0], Name: args[1]})
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("rule %s:%s deleted", args[0], args[1]))
+			})
+		},
+	}
+}
+
+func newRuleRevisionsCommand(o *options) *cobra.Command {
+	req := &v1.ListRuleRevisionsRequest{}
+	c := &cobra.Command{
+		Use:   "revisions BUS NAME",
+		Short: "List the revisions of a rule from the latest one",
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.BusName = args[0]
+			req.RuleName = args[1]
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListRuleRevisions(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("REVISION", "OPERATION", "STATUS", "PATTERN", "TARGETS", "TIME")
+				for _, r := range resp.Revisions {
+					t.row(
+						strconv.FormatUint(r.Revision, 10),
+						r.Operation,
+						enumName(r.Status.String(), "RULE_STATUS_"),
+						r.Pattern,
+						strconv.Itoa(len(r.Targets)),
+						r.Time.AsTime().Format(time.RFC3339),
+					)
+				}
+				if resp.NextToken > 0 {
+					t.row("next token: " + strconv.FormatUint(resp.NextToken, 10))
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().Int32Var(&req.Limit, "limit", 0, "max number of the revisions, 100 if it is 0")
+	c.Flags().Uint64Var(&req.NextToken, "next-token", 0, "next token of the previous page")
+	return c
+}
+
+func newRuleRollbackCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback BUS NAME REVISION",
+		Short: "Roll a rule back to the revision, the rule is recreated if it has been deleted",
+		Args:  cobra.ExactArgs(3), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revision, err := strconv.ParseUint(args[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid revision %q", args[2])
+			}
+			req := &v1.RollbackRuleRequest{BusName: args[0], RuleName: args[1], Revision: revision}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.RollbackRule(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"rule %s:%s rolled back to revision %d, new revision: %d",
+					req.BusName, req.RuleName, revision, resp.Revision,
+				))
+			})
+		},
+	}
+}
@@ -0,0 +1,316 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newSchemaCommand(o *options) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "schema",
+		Short: "Manage the event schemas",
+	}
+	c.AddCommand(
+		newSchemaListCommand(o), newSchemaCreateCommand(o), newSchemaUpdateCommand(o), newSchemaDeleteCommand(o),
+		newSchemaVersionsCommand(o), newSchemaVersionCommand(o),
+		newSchemaDiscoveredCommand(o), newSchemaPromoteCommand(o), newSchemaCodeCommand(o),
+	)
+	return c
+}
+
+func newSchemaListCommand(o *options) *cobra.Command {
+	var source, sType, bus string
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List the schemas",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.ListSchemaRequest{}
+			if cmd.Flags().Changed("source") {
+				req.Source = &source
+			}
+			if cmd.Flags().Changed("type") {
+				req.Type = &sType
+			}
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListSchema(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("SOURCE", "TYPE", "BUS", "VERSION", "COMPATIBILITY", "TIME")
+				for _, s := range resp.Schemas {
+					t.row(
+						s.Source,
+						s.Type,
+						strings.Join(append([]string{s.BusName}, s.ExtraBusNames...), ","),
+						strconv.FormatUint(uint64(s.Version), 10),
+						enumName(s.Compatibility.String(), "SCHEMA_COMPATIBILITY_"),
+						s.Time.AsTime().Format(time.RFC3339),
+					)
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().StringVar(&source, "source", "", "source of the schemas")
+	c.Flags().StringVar(&sType, "type", "", "type of the schemas")
+	c.Flags().StringVar(&bus, "bus", "", "bus of the schemas")
+	return c
+}
+
+func newSchemaCreateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create a schema from the CreateSchemaRequest in the file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.CreateSchemaRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.CreateSchema(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("schema %s/%s created", req.Source, req.Type))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newSchemaUpdateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "update -f FILE",
+		Short: "Update a schema by the UpdateSchemaRequest in the file, the unset fields are not updated",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.UpdateSchemaRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.UpdateSchema(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"schema %s/%s updated, version: %d", req.Source, req.Type, resp.Version,
+				))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newSchemaDeleteCommand(o *options) *cobra.Command {
+	var sType string
+	c := &cobra.Command{
+		Use:   "delete SOURCE",
+		Short: "Delete the schema of the type, or all schemas of the source if the type is not set",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &v1.DeleteSchemaRequest{Source: args[0]}
+			name := args[0]
+			if cmd.Flags().Changed("type") {
+				req.Type = &sType
+				name += "/" + sType
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.DeleteSchema(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("schema %s deleted", name))
+			})
+		},
+	}
+	c.Flags().StringVar(&sType, "type", "", "type of the schema")
+	return c
+}
+
+func newSchemaVersionsCommand(o *options) *cobra.Command {
+	req := &v1.ListSchemaVersionsRequest{}
+	c := &cobra.Command{
+		Use:   "versions SOURCE TYPE",
+		Short: "List the versions of a schema from the latest one",
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.Source = args[0]
+			req.Type = args[1]
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListSchemaVersions(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("VERSION", "BUS", "COMPATIBILITY", "TIME")
+				for _, v := range resp.Versions {
+					t.row(
+						strconv.FormatUint(uint64(v.Version), 10),
+						strings.Join(append([]string{v.BusName}, v.ExtraBusNames...), ","),
+						enumName(v.Compatibility.String(), "SCHEMA_COMPATIBILITY_"),
+						v.Time.AsTime().Format(time.RFC3339),
+					)
+				}
+				if resp.NextToken > 0 {
+					t.row("next token: " + strconv.FormatUint(uint64(resp.NextToken), 10))
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().Int32Var(&req.Limit, "limit", 0, "max number of the versions, 100 if it is 0")
+	c.Flags().Uint32Var(&req.NextToken, "next-token", 0, "next token of the previous page")
+	return c
+}
+
+func newSchemaVersionCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "version SOURCE TYPE VERSION",
+		Short: "Print the spec of a schema at the version",
+		Args:  cobra.ExactArgs(3), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseUint(args[2], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid version %q", args[2])
+			}
+			req := &v1.GetSchemaVersionRequest{Source: args[0], Type: args[1], Version: uint32(version)}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.GetSchemaVersion(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("%s", resp.Version.GetSpec()))
+			})
+		},
+	}
+}
+
+func newSchemaDiscoveredCommand(o *options) *cobra.Command {
+	var bus string
+	req := &v1.ListDiscoveredSchemasRequest{}
+	c := &cobra.Command{
+		Use:   "discovered",
+		Short: "List the schemas discovered from the quarantined events",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.ListDiscoveredSchemas(ctx, req)
+				if err != nil {
+					return err
+				}
+				t := newTable("SOURCE", "TYPE", "BUS", "SAMPLES", "SPEC", "LAST TIME")
+				for _, s := range resp.Schemas {
+					t.row(
+						s.Source,
+						s.Type,
+						s.BusName,
+						strconv.FormatUint(uint64(s.Samples), 10),
+						s.Spec,
+						s.LastTime.AsTime().Format(time.RFC3339),
+					)
+				}
+				if resp.NextToken > 0 {
+					t.row("next token: " + strconv.FormatUint(resp.NextToken, 10))
+				}
+				return o.print(cmd, resp, t)
+			})
+		},
+	}
+	c.Flags().StringVar(&bus, "bus", "", "bus of the discovered schemas")
+	c.Flags().Int32Var(&req.Limit, "limit", 0, "max number of the discovered schemas, 100 if it is 0")
+	c.Flags().Uint64Var(&req.NextToken, "next-token", 0, "next token of the previous page")
+	return c
+}
+
+func newSchemaPromoteCommand(o *options) *cobra.Command {
+	var spec, compatibility string
+	c := &cobra.Command{
+		Use:   "promote SOURCE TYPE",
+		Short: "Create the schema discovered, and post the quarantined events valid under it",
+		Args:  cobra.ExactArgs(2), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &v1.PromoteDiscoveredSchemaRequest{Source: args[0], Type: args[1]}
+			if cmd.Flags().Changed("spec") {
+				req.Spec = &spec
+			}
+			sc, err := parseEnum(compatibility, "SCHEMA_COMPATIBILITY_", v1.SchemaCompatibility_value)
+			if err != nil {
+				return fmt.Errorf("invalid compatibility: %w", err)
+			}
+			req.Compatibility = v1.SchemaCompatibility(sc)
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.PromoteDiscoveredSchema(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"schema %s/%s promoted, events posted: %d, dropped: %d",
+					req.Source, req.Type, resp.Posted, resp.Dropped,
+				))
+			})
+		},
+	}
+	c.Flags().StringVar(&spec, "spec", "", "spec in JSON instead of the inferred one")
+	c.Flags().StringVar(&compatibility, "compatibility", "", "compatibility, none, backward, forward or full")
+	return c
+}
+
+func newSchemaCodeCommand(o *options) *cobra.Command {
+	var source, sType, bus, language, pkg string
+	c := &cobra.Command{
+		Use:   "code",
+		Short: "Generate the Go code or the OpenAPI document of the JSON schemas",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.GenerateSchemaCodeRequest{Package: pkg}
+			if cmd.Flags().Changed("source") {
+				req.Source = &source
+			}
+			if cmd.Flags().Changed("type") {
+				req.Type = &sType
+			}
+			if cmd.Flags().Changed("bus") {
+				req.BusName = &bus
+			}
+			l, err := parseEnum(language, "SCHEMA_CODE_LANGUAGE_", v1.SchemaCodeLanguage_value)
+			if err != nil {
+				return fmt.Errorf("invalid language: %w", err)
+			}
+			req.Language = v1.SchemaCodeLanguage(l)
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.GenerateSchemaCode(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message("%s", strings.TrimSuffix(resp.Code, "\n")))
+			})
+		},
+	}
+	c.Flags().StringVar(&source, "source", "", "source of the schemas")
+	c.Flags().StringVar(&sType, "type", "", "type of the schemas")
+	c.Flags().StringVar(&bus, "bus", "", "bus of the schemas")
+	c.Flags().StringVar(&language, "language", "", "language, go or openapi, go by default")
+	c.Flags().StringVar(&pkg, "package", "", "Go package name, events by default")
+	return c
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func newTargetCommand(o *options) *cobra.Command {
+	c := &cobra.Command{
+		Use:   "target",
+		Short: "Manage the targets of the rules",
+	}
+	c.AddCommand(newTargetCreateCommand(o), newTargetUpdateCommand(o), newTargetDeleteCommand(o))
+	return c
+}
+
+func newTargetCreateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Add the targets of the CreateTargetsRequest in the file to a rule",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.CreateTargetsRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.CreateTargets(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"%d targets of rule %s:%s created", len(req.Targets), req.BusName, req.RuleName,
+				))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newTargetUpdateCommand(o *options) *cobra.Command {
+	var file string
+	c := &cobra.Command{
+		Use:   "update -f FILE",
+		Short: "Replace the targets of a rule with the targets of the UpdateTargetsRequest in the file by their ids",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			req := &v1.UpdateTargetsRequest{}
+			err := readMessage(cmd, file, req)
+			if err != nil {
+				return err
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.UpdateTargets(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"%d targets of rule %s:%s updated", len(req.Targets), req.BusName, req.RuleName,
+				))
+			})
+		},
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "JSON or YAML file, - for stdin")
+	return c
+}
+
+func newTargetDeleteCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete BUS RULE ID...",
+		Short: "Delete the targets of a rule by their ids",
+		Args:  cobra.MinimumNArgs(3), //nolint:mnd
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &v1.DeleteTargetsRequest{BusName: args[0], RuleName: args[1]}
+			for _, a := range args[2:] {
+				id, err := strconv.ParseUint(a, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid target id %q", a)
+				}
+				req.Targets = append(req.Targets, id)
+			}
+			return o.run(cmd, func(ctx context.Context, client v1.EventBridgeServiceClient) error {
+				resp, err := client.DeleteTargets(ctx, req)
+				if err != nil {
+					return err
+				}
+				return o.print(cmd, resp, message(
+					"%d targets of rule %s:%s deleted", len(req.Targets), req.BusName, req.RuleName,
+				))
+			})
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var errBatcherClosed = errors.New("batcher is closed")
+
+// BatchError reports the error of each event of a batch by its index in the batch,
+// the events without an error have been dispatched.
+type BatchError struct {
+	Errs []error
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	var first error
+	for _, err := range e.Errs {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d events of the batch failed, first err: %s", failed, len(e.Errs), first)
+}
+
+// batcher accumulates the target events into batches and dispatches them by deliver.
+// The event waits until its batch is delivered, so the source message is only acked after the batch succeeds,
+// and it waits at most half of the time left before the deadline of its context,
+// the other half is left to deliver the batch or to defer the event through the retry queue.
+type batcher struct {
+	maxSize   int
+	maxBytes  int
+	maxLinger time.Duration
+	deliver   func(ctx context.Context, events []*EventExt) error
+
+	mu      sync.Mutex
+	pending *batch
+}
+
+type batch struct {
+	ctx      context.Context // the context of the first event
+	events   []*EventExt
+	bytes    int
+	flushAt  time.Time
+	deadline time.Time // the earliest deadline of the events, zero if none of them has deadline
+	timer    *time.Timer
+
+	done chan struct{}
+	err  error
+}
+
+func newBatcher(b *Batch, deliver func(ctx context.Context, events []*EventExt) error) *batcher {
+	return &batcher{
+		maxSize:   int(b.MaxSize),
+		maxBytes:  int(b.MaxBytes),
+		maxLinger: b.MaxLinger,
+		deliver:   deliver,
+	}
+}
+
+// add returns the result of the batch delivering the event.
+// The event is removed from its batch if ctx is done before the batch is flushed,
+// otherwise it waits for the delivery, whose deadline is not later than the deadline of ctx.
+func (b *batcher) add(ctx context.Context, event *EventExt) error {
+	size := len(event.Event.Data)
+	now := time.Now()
+	flushAt := now.Add(b.maxLinger)
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		flushAt = minTime(flushAt, now.Add(deadline.Sub(now)/2)) // nolint:mnd
+	}
+
+	b.mu.Lock()
+	if b.pending != nil && b.maxBytes > 0 && b.pending.bytes+size > b.maxBytes {
+		b.flushLocked()
+	}
+	if b.pending == nil {
+		bt := &batch{
+			ctx:     ctx,
+			flushAt: flushAt,
+			done:    make(chan struct{}),
+		}
+		bt.timer = time.AfterFunc(time.Until(flushAt), func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.pending == bt {
+				b.flushLocked()
+			}
+		})
+		b.pending = bt
+	}
+	bt := b.pending
+	bt.events = append(bt.events, event)
+	bt.bytes += size
+	if hasDeadline && (bt.deadline.IsZero() || deadline.Before(bt.deadline)) {
+		bt.deadline = deadline
+	}
+	if flushAt.Before(bt.flushAt) {
+		bt.flushAt = flushAt
+		bt.timer.Reset(time.Until(flushAt))
+	}
+	if len(bt.events) >= b.maxSize || (b.maxBytes > 0 && bt.bytes >= b.maxBytes) {
+		b.flushLocked()
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-bt.done:
+		return bt.eventErr(event)
+	case <-ctx.Done():
+	}
+	b.mu.Lock()
+	if b.pending == bt {
+		// the event is deferred by the caller, so it must not be delivered by the batch as well
+		if i := slices.Index(bt.events, event); i >= 0 {
+			bt.events = slices.Delete(bt.events, i, i+1)
+			bt.bytes -= size
+		}
+		if len(bt.events) == 0 {
+			bt.timer.Stop()
+			b.pending = nil
+		}
+		b.mu.Unlock()
+		return context.Cause(ctx)
+	}
+	b.mu.Unlock()
+	<-bt.done
+	return bt.eventErr(event)
+}
+
+// eventErr returns the error of the event in the delivered batch.
+func (bt *batch) eventErr(event *EventExt) error {
+	var be *BatchError
+	if !errors.As(bt.err, &be) {
+		return bt.err
+	}
+	i := slices.Index(bt.events, event)
+	if i < 0 || i >= len(be.Errs) {
+		return bt.err
+	}
+	return be.Errs[i]
+}
+
+// flushLocked delivers the pending batch, b.mu must be held.
+func (b *batcher) flushLocked() {
+	bt := b.pending
+	b.pending = nil
+	bt.timer.Stop()
+	go func() {
+		ctx := context.WithoutCancel(bt.ctx)
+		if !bt.deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, bt.deadline)
+			defer cancel()
+		}
+		bt.err = b.deliver(ctx, bt.events)
+		close(bt.done)
+	}()
+}
+
+// close fails the pending batch, its events are deferred through the retry queue.
+func (b *batcher) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		return
+	}
+	b.pending.timer.Stop()
+	b.pending.err = errBatcherClosed
+	close(b.pending.done)
+	b.pending = nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
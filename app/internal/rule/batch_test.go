@@ -0,0 +1,190 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+type recordDelivery struct {
+	mu      sync.Mutex
+	batches [][]uint64
+	err     error
+}
+
+func (r *recordDelivery) deliver(_ context.Context, events []*EventExt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]uint64, 0, len(events))
+	for _, evt := range events {
+		ids = append(ids, evt.Event.Id)
+	}
+	r.batches = append(r.batches, ids)
+	return r.err
+}
+
+func (r *recordDelivery) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sizes := make([]int, 0, len(r.batches))
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func batchEvent(id uint64, data string) *EventExt {
+	return &EventExt{EventExt: &v1.EventExt{Event: &v1.Event{Id: id, Data: data}}}
+}
+
+func TestBatcher(t *testing.T) {
+	runs := []struct {
+		name    string
+		batch   *Batch
+		events  int
+		timeout time.Duration
+		sizes   []int
+	}{
+		{
+			name:    "max size",
+			batch:   &Batch{MaxSize: 2, MaxLinger: time.Minute},
+			events:  4,
+			timeout: time.Minute,
+			sizes:   []int{2, 2},
+		},
+		{
+			name:    "max bytes",
+			batch:   &Batch{MaxSize: 10, MaxBytes: 6, MaxLinger: time.Minute},
+			events:  4,
+			timeout: time.Minute,
+			sizes:   []int{2, 2},
+		},
+		{
+			name:    "max linger",
+			batch:   &Batch{MaxSize: 10, MaxLinger: 20 * time.Millisecond},
+			events:  3,
+			timeout: time.Minute,
+			sizes:   []int{3},
+		},
+		{
+			name:    "half of the deadline",
+			batch:   &Batch{MaxSize: 10, MaxLinger: time.Minute},
+			events:  3,
+			timeout: 100 * time.Millisecond,
+			sizes:   []int{3},
+		},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			rd := &recordDelivery{}
+			b := newBatcher(run.batch, rd.deliver)
+			ctx, cancel := context.WithTimeout(context.Background(), run.timeout)
+			defer cancel()
+			wg := sync.WaitGroup{}
+			errs := make(chan error, run.events)
+			for i := range run.events {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- b.add(ctx, batchEvent(uint64(i), "abc"))
+				}()
+				time.Sleep(5 * time.Millisecond) // keep the order of the events
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			sizes := rd.sizes()
+			if len(sizes) != len(run.sizes) {
+				t.Fatalf("expect batch sizes: %v, actual: %v", run.sizes, sizes)
+			}
+			for i := range sizes {
+				if sizes[i] != run.sizes[i] {
+					t.Fatalf("expect batch sizes: %v, actual: %v", run.sizes, sizes)
+				}
+			}
+		})
+	}
+}
+
+func TestBatcherError(t *testing.T) {
+	errDown := errors.New("target down")
+	rd := &recordDelivery{err: errDown}
+	b := newBatcher(&Batch{MaxSize: 2, MaxLinger: time.Minute}, rd.deliver)
+
+	// every event of the failed batch fails
+	errs := make(chan error, 2)
+	for i := range 2 {
+		go func() {
+			errs <- b.add(context.Background(), batchEvent(uint64(i), "abc"))
+		}()
+	}
+	for range 2 {
+		if err := <-errs; !errors.Is(err, errDown) {
+			t.Fatalf("expect the delivery error, got: %v", err)
+		}
+	}
+
+	// only the events failed in the batch fail
+	rd.err = &BatchError{Errs: []error{nil, errDown}}
+	eventErrs := make([]chan error, 2)
+	for i := range 2 {
+		eventErrs[i] = make(chan error, 1)
+		go func() {
+			eventErrs[i] <- b.add(context.Background(), batchEvent(uint64(i+1), "abc"))
+		}()
+		time.Sleep(10 * time.Millisecond) // keep the order of the events in the batch
+	}
+	if err := <-eventErrs[0]; err != nil {
+		t.Fatalf("expect the first event dispatched, got: %v", err)
+	}
+	if err := <-eventErrs[1]; !errors.Is(err, errDown) {
+		t.Fatalf("expect the delivery error of the second event, got: %v", err)
+	}
+
+	// the event canceled before the flush is removed from the batch
+	rd.err = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		errs <- b.add(ctx, batchEvent(4, "abc"))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the canceled error, got: %v", err)
+	}
+	for i := range 2 {
+		go func() {
+			errs <- b.add(context.Background(), batchEvent(uint64(5+i), "abc"))
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd.mu.Lock()
+	last := rd.batches[len(rd.batches)-1]
+	rd.mu.Unlock()
+	if slices.Contains(last, 4) {
+		t.Fatalf("expect the canceled event removed, actual batch: %v", last)
+	}
+
+	// the pending batch fails when the batcher is closed
+	go func() {
+		errs <- b.add(context.Background(), batchEvent(3, "abc"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	b.close()
+	if err := <-errs; !errors.Is(err, errBatcherClosed) {
+		t.Fatalf("expect the batcher closed error, got: %v", err)
+	}
+}
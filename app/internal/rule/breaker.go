@@ -0,0 +1,137 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker of a target,
+// its value is also the value of the breaker state gauge.
+type BreakerState int64
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "CLOSED"
+	case BreakerHalfOpen:
+		return "HALF_OPEN"
+	case BreakerOpen:
+		return "OPEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BreakerStatus is the circuit breaker of a target.
+// Failures is the number of consecutive failures, and OpenUntil is zero unless the breaker is open.
+type BreakerStatus struct {
+	BusName   string    `json:"busName"`
+	RuleName  string    `json:"ruleName"`
+	TargetID  uint64    `json:"targetId"`
+	State     string    `json:"state"`
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"openUntil,omitzero"`
+}
+
+func IsBreakerOpenError(err error) bool {
+	return errors.Is(err, errBreakerOpen)
+}
+
+// breaker opens after threshold consecutive failures, and the events are rejected without dispatching.
+// After openTimeout it is half-open, one event probes the target and the others are still rejected,
+// the breaker is closed if the probe succeeds, otherwise it opens again.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, openTimeout time.Duration, onChange func(BreakerState)) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		onChange:    onChange,
+	}
+}
+
+// allow returns done to report the result of the dispatching if it is allowed.
+func (b *breaker) allow() (done func(err error), ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.openTimeout {
+		b.setState(BreakerHalfOpen)
+	}
+	switch b.state {
+	case BreakerOpen:
+		return nil, false
+	case BreakerHalfOpen:
+		if b.probing {
+			return nil, false
+		}
+		b.probing = true
+		return b.done(true), true
+	default:
+		return b.done(false), true
+	}
+}
+
+func (b *breaker) done(probe bool) func(err error) {
+	return func(err error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if probe {
+			b.probing = false
+		}
+		switch {
+		case err == nil || IsResponseNotPostedError(err): // the target succeeded
+			b.failures = 0
+			if probe {
+				b.setState(BreakerClosed)
+			}
+		case IsThrottledError(err) || errors.Is(err, context.Canceled):
+			// the target isn't called, or the dispatching is canceled by the others
+		default:
+			b.failures++
+			if probe || (b.state == BreakerClosed && b.failures >= b.threshold) {
+				b.openedAt = time.Now()
+				b.setState(BreakerOpen)
+			}
+		}
+	}
+}
+
+func (b *breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
+
+func (b *breaker) status() (BreakerState, int, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var openUntil time.Time
+	if b.state == BreakerOpen {
+		openUntil = b.openedAt.Add(b.openTimeout)
+	}
+	return b.state, b.failures, openUntil
+}
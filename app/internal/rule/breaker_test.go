@@ -0,0 +1,174 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("target down")
+	runs := []struct {
+		name    string
+		results []error // the results of the allowed dispatching in order
+		wait    bool    // wait for the open timeout after the results
+		allowed bool
+		state   BreakerState
+	}{
+		{
+			name:    "closed below threshold",
+			results: []error{errDown, errDown},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+		{
+			name:    "success resets failures",
+			results: []error{errDown, errDown, nil, errDown, errDown},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+		{
+			name:    "throttled and canceled are not failures",
+			results: []error{errDown, errDown, NewThrottledError("limit"), context.Canceled},
+			allowed: true,
+			state:   BreakerClosed,
+		},
+		{
+			name:    "open at threshold",
+			results: []error{errDown, errDown, errDown},
+			allowed: false,
+			state:   BreakerOpen,
+		},
+		{
+			name:    "half-open after timeout",
+			results: []error{errDown, errDown, errDown},
+			wait:    true,
+			allowed: true,
+			state:   BreakerHalfOpen,
+		},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			b := newBreaker(3, 20*time.Millisecond, nil)
+			for i, res := range run.results {
+				done, ok := b.allow()
+				if !ok {
+					t.Fatalf("dispatching %d is rejected", i)
+				}
+				done(res)
+			}
+			if run.wait {
+				time.Sleep(30 * time.Millisecond)
+			}
+			_, ok := b.allow()
+			if ok != run.allowed {
+				t.Errorf("expect allowed: %v, actual: %v", run.allowed, ok)
+			}
+			if state, _, _ := b.status(); state != run.state {
+				t.Errorf("expect state: %s, actual: %s", run.state, state)
+			}
+		})
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	errDown := errors.New("target down")
+	states := make([]BreakerState, 0)
+	b := newBreaker(1, 20*time.Millisecond, func(state BreakerState) {
+		states = append(states, state)
+	})
+	done, _ := b.allow()
+	done(errDown)
+
+	// the failed probe opens the breaker again
+	time.Sleep(30 * time.Millisecond)
+	probe, ok := b.allow()
+	if !ok {
+		t.Fatal("the probe is rejected")
+	}
+	if _, ok = b.allow(); ok {
+		t.Fatal("only one probe is allowed in half-open state")
+	}
+	probe(errDown)
+	if _, ok = b.allow(); ok {
+		t.Fatal("expect open after the failed probe")
+	}
+
+	// the successful probe closes the breaker
+	time.Sleep(30 * time.Millisecond)
+	probe, _ = b.allow()
+	probe(nil)
+	expected := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if !reflect.DeepEqual(states, expected) {
+		t.Fatalf("expect states: %v, actual: %v", expected, states)
+	}
+}
+
+type failDispatcher struct {
+	calls int
+}
+
+func (d *failDispatcher) Dispatch(_ context.Context, _ *EventExt) error {
+	d.calls++
+	return errors.New("target down")
+}
+
+func (d *failDispatcher) Close() error { return nil }
+
+func TestTargetBreaker(t *testing.T) {
+	fd := &failDispatcher{}
+	nef := NewNewExecutorFunc(
+		func(_ context.Context, _ log.Logger, _ map[string]interface{}) (Matcher, error) {
+			return nil, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Transformer, error) {
+			return idTransformer{}, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Dispatcher, error) {
+			return fd, nil
+		},
+	)
+	exec, err := nef(context.Background(), log.DefaultLogger, &Rule{
+		Name:    "rule1",
+		BusName: "bus1",
+		Pattern: `{}`,
+		Targets: []*Target{{ID: 1}},
+	}, WithCircuitBreaker(2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := &EventExt{
+		EventExt: &v1.EventExt{
+			Event:    &v1.Event{Id: 1},
+			BusName:  "bus1",
+			RuleName: "rule1",
+			TargetId: 1,
+		},
+	}
+	for range 2 {
+		err = exec.Dispatch(context.Background(), evt)
+		if err == nil || IsBreakerOpenError(err) {
+			t.Fatalf("expect the dispatching error, got: %v", err)
+		}
+	}
+
+	// rejected without dispatching
+	err = exec.Dispatch(context.Background(), evt)
+	if !IsBreakerOpenError(err) {
+		t.Fatalf("expect breaker open, got: %v", err)
+	}
+	if fd.calls != 2 {
+		t.Fatalf("expect dispatched 2 times, got: %d", fd.calls)
+	}
+	breakers := exec.Breakers()
+	if len(breakers) != 1 || breakers[0].State != "OPEN" || breakers[0].Failures != 2 ||
+		breakers[0].TargetID != 1 || breakers[0].OpenUntil.IsZero() {
+		t.Fatalf("unexpected breakers: %+v", breakers)
+	}
+}
@@ -0,0 +1,584 @@
+package rule
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"go/format"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// codeInitialisms are the words of the names in upper case, as golint suggests.
+var codeInitialisms = map[string]struct{}{
+	"api": {}, "html": {}, "http": {}, "https": {}, "id": {}, "ip": {}, "json": {},
+	"sql": {}, "uri": {}, "url": {}, "uuid": {}, "xml": {},
+}
+
+// CodeSchema is the JSON schema of the event data of a source and type to generate the code from.
+type CodeSchema struct {
+	Source  string
+	Type    string
+	Version uint32
+	Spec    string
+}
+
+// codeSortSchemas sorts the schemas by their source and type, and names them by their type,
+// the source is prefixed if the type is not unique. The code generated is deterministic this way.
+func codeSortSchemas(schemas []*CodeSchema) ([]*CodeSchema, []string) {
+	sorted := slices.Clone(schemas)
+	slices.SortFunc(sorted, func(a, b *CodeSchema) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Type, b.Type))
+	})
+	types := make(map[string]int, len(sorted))
+	for _, s := range sorted {
+		types[codeName(s.Type)]++
+	}
+	used := make(map[string]struct{}, len(sorted))
+	names := make([]string, 0, len(sorted))
+	for _, s := range sorted {
+		name := codeName(s.Type)
+		if types[name] > 1 {
+			name = codeName(s.Source) + name
+		}
+		name = codeUnique(used, name)
+		names = append(names, name)
+	}
+	return sorted, names
+}
+
+// codeName converts the name to an exported Go identifier, e.g. order.created -> OrderCreated.
+func codeName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if _, ok := codeInitialisms[strings.ToLower(word)]; ok {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		rs := []rune(word)
+		rs[0] = unicode.ToUpper(rs[0])
+		b.WriteString(string(rs))
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// codeUnique suffixes the name by a number if it is used, and marks it used.
+func codeUnique(used map[string]struct{}, name string) string {
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := used[unique]; !ok {
+			break
+		}
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = struct{}{}
+	return unique
+}
+
+// codeComment formats the text as the lines of a Go comment.
+func codeComment(b *bytes.Buffer, indent string, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString(strings.TrimRight(indent+"// "+strings.TrimSpace(line), " ") + "\n")
+	}
+}
+
+const (
+	codeKindOther = iota
+	// the optional or nullable fields of the scalar types are pointers
+	codeKindScalar
+	// the fields of the struct types are always pointers, so that the recursive types are valid
+	codeKindStruct
+)
+
+type goCodeType struct {
+	name    string
+	comment string
+	def     string
+}
+
+type goCodeGenerator struct {
+	root     interface{}
+	rootName string
+	// refs the type names of the references resolved in the root
+	refs     map[string]string
+	refKinds map[string]int
+	used     map[string]struct{}
+	types    []*goCodeType
+}
+
+// GenerateGoCode generates a Go file of the package, in which each schema has a struct of its event data
+// with the JSON tags, and a Post<Type> method on the Client that posts an event of its source and type.
+// The properties not required are omitted if empty, and the scalars of them are pointers.
+// The properties of the composite types, except allOf of the objects, are any.
+func GenerateGoCode(pkg string, schemas []*CodeSchema) (string, error) {
+	if !token.IsIdentifier(pkg) || token.IsKeyword(pkg) {
+		return "", fmt.Errorf("package(%s) is not a valid Go package name", pkg)
+	}
+	sorted, names := codeSortSchemas(schemas)
+	g := &goCodeGenerator{used: map[string]struct{}{"Client": {}, "NewClient": {}}}
+	for _, name := range names {
+		g.used[name] = struct{}{}
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString("// Code generated by eventbridge from the event schemas. DO NOT EDIT.\n\n")
+	b.WriteString("package " + pkg + "\n\n")
+	b.WriteString(goCodeClient)
+	for i, s := range sorted {
+		var root interface{}
+		err := json.Unmarshal([]byte(s.Spec), &root)
+		if err != nil {
+			return "", fmt.Errorf("schema(%s/%s) unmarshal err: %w", s.Source, s.Type, err)
+		}
+		g.root = root
+		g.rootName = names[i]
+		// the recursive reference to the root
+		g.refs = map[string]string{"#": names[i]}
+		g.refKinds = make(map[string]int)
+		g.types = nil
+		expr, _, err := g.typeExpr(root, names[i], true)
+		if err != nil {
+			return "", fmt.Errorf("schema(%s/%s) err: %w", s.Source, s.Type, err)
+		}
+		comment := fmt.Sprintf(
+			"%s is the data of the events of the source %s and the type %s, schema version %d.",
+			names[i], s.Source, s.Type, s.Version,
+		)
+		if expr != names[i] {
+			g.types = append([]*goCodeType{{name: names[i], def: expr}}, g.types...)
+		}
+		g.types[0].comment = strings.TrimSpace(comment + "\n" + g.types[0].comment)
+		for _, t := range g.types {
+			if t.comment == "" {
+				t.comment = fmt.Sprintf("%s is a part of the data of %s.", t.name, names[i])
+			}
+			b.WriteString("\n")
+			codeComment(b, "", t.comment)
+			b.WriteString("type " + t.name + " " + t.def + "\n")
+		}
+		fmt.Fprintf(
+			b, "\n// Post%s posts an event of the source %s and the type %s with the data,\n",
+			names[i], s.Source, s.Type,
+		)
+		b.WriteString("// the other fields of the event and the request are taken from req if it is not nil.\n")
+		fmt.Fprintf(
+			b, "func (c *Client) Post%s(\n\tctx context.Context, data *%s, req *v1.PostEventRequest,\n"+
+				") (*v1.PostEventResponse, error) {\n\treturn c.post(ctx, %s, %s, data, req)\n}\n",
+			names[i], names[i], strconv.Quote(s.Source), strconv.Quote(s.Type),
+		)
+	}
+	code, err := format.Source(b.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("go code format err: %w", err)
+	}
+	return string(code), nil
+}
+
+const goCodeClient = `import (
+	"context"
+	"encoding/json"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+// Client posts the events with the typed data of their schemas.
+type Client struct {
+	client v1.EventBridgeServiceClient
+}
+
+// NewClient wraps the client of the EventBridge service.
+func NewClient(client v1.EventBridgeServiceClient) *Client {
+	return &Client{client: client}
+}
+
+func (c *Client) post(
+	ctx context.Context, source string, eventType string, data any, req *v1.PostEventRequest,
+) (*v1.PostEventResponse, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		req = &v1.PostEventRequest{}
+	}
+	if req.Event == nil {
+		req.Event = &v1.Event{}
+	}
+	req.Event.Source = source
+	req.Event.Type = eventType
+	req.Event.Data = string(bs)
+	req.Event.Datacontenttype = "application/json"
+	return c.client.PostEvent(ctx, req)
+}
+`
+
+// typeExpr returns the Go type expression of the schema and its kind. The structs are defined by the name,
+// which is made unique unless exact, i.e. the name is reserved by the caller.
+func (g *goCodeGenerator) typeExpr(node interface{}, name string, exact bool) (string, int, error) {
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		// true or false schema
+		return "any", codeKindOther, nil
+	}
+	if ref, isStr := schema["$ref"].(string); isStr {
+		return g.refTypeExpr(ref, name)
+	}
+	if allOf, isArr := schema["allOf"].([]interface{}); isArr && len(allOf) > 0 {
+		merged, mergeable, err := g.mergeAllOf(schema, allOf)
+		if err != nil || !mergeable {
+			return "any", codeKindOther, err
+		}
+		schema = merged
+	}
+	if _, isArr := schema["oneOf"].([]interface{}); isArr {
+		return "any", codeKindOther, nil
+	}
+	if _, isArr := schema["anyOf"].([]interface{}); isArr {
+		return "any", codeKindOther, nil
+	}
+
+	switch t, _ := codeType(schema); t {
+	case "object":
+		props, _ := schema["properties"].(map[string]interface{})
+		if len(props) == 0 {
+			if additional, isObj := schema["additionalProperties"].(map[string]interface{}); isObj {
+				expr, _, err := g.typeExpr(additional, name+"Value", false)
+				return "map[string]" + expr, codeKindOther, err
+			}
+			return "map[string]any", codeKindOther, nil
+		}
+		if !exact {
+			name = codeUnique(g.used, name)
+		}
+		return name, codeKindStruct, g.defineStruct(name, schema, props)
+	case "array":
+		items, isObj := schema["items"].(map[string]interface{})
+		if !isObj {
+			return "[]any", codeKindOther, nil
+		}
+		expr, kind, err := g.typeExpr(items, name+"Item", false)
+		if kind == codeKindStruct {
+			expr = "*" + expr
+		}
+		return "[]" + expr, codeKindOther, err
+	case "string":
+		return "string", codeKindScalar, nil
+	case "integer":
+		return "int64", codeKindScalar, nil
+	case "number":
+		return "float64", codeKindScalar, nil
+	case "boolean":
+		return "bool", codeKindScalar, nil
+	default:
+		return "any", codeKindOther, nil
+	}
+}
+
+// refTypeExpr defines a type for each reference named after its last token,
+// the recursive reference is assumed to be a struct.
+func (g *goCodeGenerator) refTypeExpr(ref string, name string) (string, int, error) {
+	if refName, ok := g.refs[ref]; ok {
+		kind, done := g.refKinds[refName]
+		if !done {
+			kind = codeKindStruct
+		}
+		return refName, kind, nil
+	}
+	resolved, err := sampleResolveRef(g.root, ref)
+	if err != nil {
+		return "", codeKindOther, err
+	}
+	if tokens := strings.Split(ref, "/"); len(tokens) > 1 {
+		name = g.rootName + codeName(tokens[len(tokens)-1])
+	}
+	name = codeUnique(g.used, name)
+	g.refs[ref] = name
+	expr, kind, err := g.typeExpr(resolved, name, true)
+	if err != nil {
+		return "", codeKindOther, err
+	}
+	if expr != name {
+		schema, _ := resolved.(map[string]interface{})
+		description, _ := schema["description"].(string)
+		g.types = append(g.types, &goCodeType{name: name, comment: description, def: expr})
+	}
+	g.refKinds[name] = kind
+	return name, kind, nil
+}
+
+// mergeAllOf merges the properties and the required of the objects, it is not mergeable if any of them is not.
+func (g *goCodeGenerator) mergeAllOf(
+	schema map[string]interface{}, allOf []interface{},
+) (map[string]interface{}, bool, error) {
+	props := make(map[string]interface{})
+	var required []interface{}
+	merged := map[string]interface{}{"type": "object", "properties": props}
+	rest := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if k != "allOf" {
+			rest[k] = v
+		}
+	}
+	for _, node := range append([]interface{}{rest}, allOf...) {
+		sub, ok := node.(map[string]interface{})
+		for depth := 0; ok && depth < sampleMaxDepth; depth++ {
+			ref, isStr := sub["$ref"].(string)
+			if !isStr {
+				break
+			}
+			resolved, err := sampleResolveRef(g.root, ref)
+			if err != nil {
+				return nil, false, err
+			}
+			sub, ok = resolved.(map[string]interface{})
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		if t, _ := codeType(sub); t != "object" && t != "" {
+			return nil, false, nil
+		}
+		subProps, _ := sub["properties"].(map[string]interface{})
+		for k, v := range subProps {
+			props[k] = v
+		}
+		subRequired, _ := sub["required"].([]interface{})
+		required = append(required, subRequired...)
+		if description, isStr := sub["description"].(string); isStr && merged["description"] == nil {
+			merged["description"] = description
+		}
+	}
+	merged["required"] = required
+	return merged, true, nil
+}
+
+func (g *goCodeGenerator) defineStruct(name string, schema map[string]interface{}, props map[string]interface{}) error {
+	t := &goCodeType{name: name}
+	t.comment, _ = schema["description"].(string)
+	// the parent type is defined before the nested ones
+	g.types = append(g.types, t)
+
+	required := make(map[string]struct{})
+	requiredList, _ := schema["required"].([]interface{})
+	for _, r := range requiredList {
+		if s, ok := r.(string); ok {
+			required[s] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	b := &bytes.Buffer{}
+	b.WriteString("struct {\n")
+	fields := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if !codeValidTag(k) {
+			b.WriteString("\t// property " + strconv.Quote(k) + " is skipped, encoding/json can't name it by a tag\n")
+			continue
+		}
+		field := codeUnique(fields, codeName(k))
+		expr, kind, err := g.typeExpr(props[k], name+field, false)
+		if err != nil {
+			return err
+		}
+		_, isRequired := required[k]
+		prop, _ := props[k].(map[string]interface{})
+		_, nullable := codeType(prop)
+		if kind == codeKindStruct || (kind == codeKindScalar && (!isRequired || nullable)) {
+			expr = "*" + expr
+		}
+		if description, ok := prop["description"].(string); ok {
+			codeComment(b, "\t", description)
+		}
+		tag := k
+		if !isRequired {
+			tag += ",omitempty"
+		}
+		b.WriteString("\t" + field + " " + expr + " `json:" + strconv.Quote(tag) + "`\n")
+	}
+	b.WriteString("}")
+	t.def = b.String()
+	return nil
+}
+
+// codeValidTag reports whether the property name is a valid name of the json tag, like encoding/json checks it.
+// The other names, such as the ones containing a comma or a quote, are ignored by encoding/json.
+func codeValidTag(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case unicode.IsLetter(c), unicode.IsDigit(c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// codeType returns the non-null type of the schema, or the type inferred by its keywords and values.
+// The type of a schema allowing multiple non-null types is empty.
+func codeType(schema map[string]interface{}) (string, bool) {
+	switch t := schema["type"].(type) {
+	case string:
+		return t, false
+	case []interface{}:
+		var nonNull []string
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				nonNull = append(nonNull, s)
+			}
+		}
+		nullable := len(nonNull) < len(t)
+		if len(nonNull) == 1 {
+			return nonNull[0], nullable
+		}
+		return "", nullable
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object", false
+	}
+	if _, ok := schema["items"]; ok {
+		return "array", false
+	}
+	values, _ := schema["enum"].([]interface{})
+	if val, ok := schema["const"]; ok {
+		values = []interface{}{val}
+	}
+	inferred := ""
+	for _, val := range values {
+		var t string
+		switch val.(type) {
+		case string:
+			t = "string"
+		case float64:
+			t = "number"
+		case bool:
+			t = "boolean"
+		default:
+			return "", false
+		}
+		if inferred != "" && inferred != t {
+			return "", false
+		}
+		inferred = t
+	}
+	return inferred, false
+}
+
+// GenerateOpenAPI generates an OpenAPI 3.1 document of the schemas in its components, named like the Go types.
+// The definitions of each schema are moved to the components and the local references are rewritten,
+// the source, the type and the version of the schema are in the x-eventbridge extensions.
+func GenerateOpenAPI(title string, schemas []*CodeSchema) (string, error) {
+	sorted, names := codeSortSchemas(schemas)
+	used := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		used[name] = struct{}{}
+	}
+	components := make(map[string]interface{}, len(sorted))
+	for i, s := range sorted {
+		var root interface{}
+		err := json.Unmarshal([]byte(s.Spec), &root)
+		if err != nil {
+			return "", fmt.Errorf("schema(%s/%s) unmarshal err: %w", s.Source, s.Type, err)
+		}
+		schema, ok := root.(map[string]interface{})
+		if !ok {
+			schema = map[string]interface{}{}
+			if root == false {
+				schema["not"] = map[string]interface{}{}
+			}
+		}
+		delete(schema, "$schema")
+		delete(schema, "$id")
+		// the definitions are renamed as the components, e.g. #/$defs/address -> #/components/schemas/OrderAddress
+		defs := make(map[string]string)
+		var defNames []string
+		for _, key := range []string{"$defs", "definitions"} {
+			ds, _ := schema[key].(map[string]interface{})
+			keys := make([]string, 0, len(ds))
+			for k := range ds {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				defName := codeUnique(used, names[i]+codeName(k))
+				defs["#/"+key+"/"+k] = "#/components/schemas/" + defName
+				components[defName] = ds[k]
+				defNames = append(defNames, defName)
+			}
+			delete(schema, key)
+		}
+		schema["x-eventbridge-source"] = s.Source
+		schema["x-eventbridge-type"] = s.Type
+		schema["x-eventbridge-version"] = s.Version
+		components[names[i]] = schema
+		for _, defName := range append(defNames, names[i]) {
+			components[defName] = openAPIRewriteRefs(components[defName], names[i], defs)
+		}
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": "v1",
+		},
+		"components": map[string]interface{}{
+			"schemas": components,
+		},
+	}
+	bs, err := json.Marshal(doc, json.Deterministic(true), jsontext.WithIndent("  "))
+	if err != nil {
+		return "", err
+	}
+	return string(bs) + "\n", nil
+}
+
+// openAPIRewriteRefs rewrites the local references of the schema name in place,
+// the definitions by their components and the others under the component of the schema.
+func openAPIRewriteRefs(node interface{}, name string, defs map[string]string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			ref, isStr := v.(string)
+			if k != "$ref" || !isStr {
+				n[k] = openAPIRewriteRefs(v, name, defs)
+				continue
+			}
+			if !strings.HasPrefix(ref, "#") {
+				continue
+			}
+			n[k] = "#/components/schemas/" + name + strings.TrimPrefix(ref, "#")
+			for def, component := range defs {
+				if ref == def || strings.HasPrefix(ref, def+"/") {
+					n[k] = component + strings.TrimPrefix(ref, def)
+					break
+				}
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			n[i] = openAPIRewriteRefs(v, name, defs)
+		}
+	}
+	return node
+}
@@ -0,0 +1,211 @@
+package rule
+
+import (
+	"encoding/json/jsontext"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGoCode(t *testing.T) {
+	goCodeTests := []struct {
+		schemas  []*CodeSchema
+		contains []string
+	}{
+		{
+			schemas: []*CodeSchema{
+				{
+					Source:  "shop",
+					Type:    "order.created",
+					Version: 3,
+					Spec: `{
+  "type": "object",
+  "description": "An order.",
+  "properties": {
+    "id": {"type": "integer"},
+    "name": {"type": "string", "description": "name of the order"},
+    "amount": {"type": ["number", "null"]},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "items": {"type": "array", "items": {"properties": {"sku": {"type": "string"}}, "required": ["sku"]}},
+    "meta": {"type": "object", "additionalProperties": {"type": "integer"}},
+    "level": {"enum": ["high", "low"]},
+    "address": {"$ref": "#/$defs/address"},
+    "parent": {"$ref": "#"},
+    "any": {"oneOf": [{"type": "string"}, {"type": "integer"}]}
+  },
+  "required": ["id", "name", "amount"],
+  "$defs": {
+    "address": {"type": "object", "properties": {"city": {"type": "string"}, "next": {"$ref": "#/$defs/address"}}}
+  }
+}`,
+				},
+			},
+			contains: []string{
+				"// OrderCreated is the data of the events of the source shop and the type order.created, " +
+					"schema version 3.\n// An order.\ntype OrderCreated struct {\n" +
+					"\tAddress *OrderCreatedAddress     `json:\"address,omitempty\"`\n" +
+					"\tAmount  *float64                 `json:\"amount\"`\n" +
+					"\tAny     any                      `json:\"any,omitempty\"`\n" +
+					"\tID      int64                    `json:\"id\"`\n" +
+					"\tItems   []*OrderCreatedItemsItem `json:\"items,omitempty\"`\n" +
+					"\tLevel   *string                  `json:\"level,omitempty\"`\n" +
+					"\tMeta    map[string]int64         `json:\"meta,omitempty\"`\n" +
+					"\t// name of the order\n" +
+					"\tName   string        `json:\"name\"`\n" +
+					"\tParent *OrderCreated `json:\"parent,omitempty\"`\n" +
+					"\tTags   []string      `json:\"tags,omitempty\"`\n}\n",
+				"type OrderCreatedAddress struct {\n" +
+					"\tCity *string              `json:\"city,omitempty\"`\n" +
+					"\tNext *OrderCreatedAddress `json:\"next,omitempty\"`\n}\n",
+				"type OrderCreatedItemsItem struct {\n\tSku string `json:\"sku\"`\n}\n",
+				"func (c *Client) PostOrderCreated(\n" +
+					"\tctx context.Context, data *OrderCreated, req *v1.PostEventRequest,\n" +
+					") (*v1.PostEventResponse, error) {\n" +
+					"\treturn c.post(ctx, \"shop\", \"order.created\", data, req)\n}\n",
+			},
+		},
+		// the source is prefixed to the same type, and allOf of the objects is merged
+		{
+			schemas: []*CodeSchema{
+				{Source: "shop", Type: "paid", Spec: `{"type": "object", "properties": {"at": {"type": "string"}}}`},
+				{
+					Source: "billing",
+					Type:   "paid",
+					Spec: `{
+  "allOf": [{"$ref": "#/definitions/base"}, {"properties": {"at": {"type": "string"}}, "required": ["at"]}],
+  "definitions": {"base": {"type": "object", "properties": {"id": {"type": "string"}}}}
+}`,
+				},
+				{Source: "billing", Type: "refunds", Spec: `{"type": "array", "items": {"type": "integer"}}`},
+			},
+			contains: []string{
+				"type BillingPaid struct {\n" +
+					"\tAt string  `json:\"at\"`\n" +
+					"\tID *string `json:\"id,omitempty\"`\n}\n",
+				"type ShopPaid struct {\n\tAt *string `json:\"at,omitempty\"`\n}\n",
+				"type Refunds []int64\n",
+				"func (c *Client) PostBillingPaid(",
+				"func (c *Client) PostShopPaid(",
+				"func (c *Client) PostRefunds(",
+			},
+		},
+		// the property names which can't be the names of the json tags are skipped
+		{
+			schemas: []*CodeSchema{
+				{
+					Source: "shop",
+					Type:   "tagged",
+					Spec: `{"type": "object", "properties": {` +
+						`"a,b": {"type": "string"}, "q\"x": {"type": "string"}, "b` + "`" + `c": {"type": "string"}, ` +
+						`"ok-key": {"type": "string"}}}`,
+				},
+			},
+			contains: []string{
+				"type Tagged struct {\n" +
+					"\t// property \"a,b\" is skipped, encoding/json can't name it by a tag\n" +
+					"\t// property \"b`c\" is skipped, encoding/json can't name it by a tag\n" +
+					"\tOkKey *string `json:\"ok-key,omitempty\"`\n" +
+					"\t// property \"q\\\"x\" is skipped, encoding/json can't name it by a tag\n}\n",
+			},
+		},
+	}
+	for idx, tt := range goCodeTests {
+		code, err := GenerateGoCode("events", tt.schemas)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		_, err = parser.ParseFile(token.NewFileSet(), "events.go", code, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("case(index=%d) parse err: %v\n%s", idx, err, code)
+		}
+		for _, c := range tt.contains {
+			if !strings.Contains(code, c) {
+				t.Fatalf("case(index=%d) expect code contains:\n%s\nactual:\n%s", idx, c, code)
+			}
+		}
+		// the code is deterministic regardless of the order of the schemas
+		reversed := make([]*CodeSchema, 0, len(tt.schemas))
+		for i := len(tt.schemas) - 1; i >= 0; i-- {
+			reversed = append(reversed, tt.schemas[i])
+		}
+		again, err := GenerateGoCode("events", reversed)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if again != code {
+			t.Fatalf("case(index=%d) expect the same code, actual:\n%s", idx, again)
+		}
+	}
+
+	invalidTests := []struct {
+		pkg     string
+		schemas []*CodeSchema
+	}{
+		{pkg: "type", schemas: []*CodeSchema{{Source: "shop", Type: "paid", Spec: `{}`}}},
+		{pkg: "my-events", schemas: []*CodeSchema{{Source: "shop", Type: "paid", Spec: `{}`}}},
+		{pkg: "events", schemas: []*CodeSchema{{Source: "shop", Type: "paid", Spec: `{`}}},
+		{pkg: "events", schemas: []*CodeSchema{{Source: "shop", Type: "paid", Spec: `{"$ref": "#/$defs/a"}`}}},
+	}
+	for idx, tt := range invalidTests {
+		_, err := GenerateGoCode(tt.pkg, tt.schemas)
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	openAPITests := []struct {
+		schemas []*CodeSchema
+		res     string
+	}{
+		{
+			schemas: []*CodeSchema{
+				{
+					Source:  "shop",
+					Type:    "order.created",
+					Version: 2,
+					Spec: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "address": {"$ref": "#/$defs/address"},
+    "parent": {"$ref": "#"},
+    "city": {"$ref": "#/$defs/address/properties/city"}
+  },
+  "$defs": {"address": {"type": "object", "properties": {"city": {"type": "string"}}}}
+}`,
+				},
+				{Source: "shop", Type: "paid", Version: 1, Spec: `true`},
+			},
+			res: `{"components":{"schemas":{` +
+				`"OrderCreated":{"properties":{"address":{"$ref":"#/components/schemas/OrderCreatedAddress"},` +
+				`"city":{"$ref":"#/components/schemas/OrderCreatedAddress/properties/city"},` +
+				`"parent":{"$ref":"#/components/schemas/OrderCreated"}},"type":"object",` +
+				`"x-eventbridge-source":"shop","x-eventbridge-type":"order.created","x-eventbridge-version":2},` +
+				`"OrderCreatedAddress":{"properties":{"city":{"type":"string"}},"type":"object"},` +
+				`"Paid":{"x-eventbridge-source":"shop","x-eventbridge-type":"paid","x-eventbridge-version":1}}},` +
+				`"info":{"title":"shop events","version":"v1"},"openapi":"3.1.0"}`,
+		},
+	}
+	for idx, tt := range openAPITests {
+		doc, err := GenerateOpenAPI("shop events", tt.schemas)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		res := []byte(doc)
+		err = (*jsontext.Value)(&res).Compact()
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if string(res) != tt.res {
+			t.Fatalf("case(index=%d) expect: %s, actual: %s", idx, tt.res, res)
+		}
+	}
+
+	_, err := GenerateOpenAPI("shop events", []*CodeSchema{{Source: "shop", Type: "paid", Spec: `{`}})
+	if err == nil {
+		t.Fatal("invalid spec expect err")
+	}
+}
@@ -0,0 +1,465 @@
+package rule
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// compatUnsupportedKeywords are compared as they are, a change of them is incompatible.
+var compatUnsupportedKeywords = []string{
+	"allOf", "anyOf", "oneOf", "not", "if", "then", "else",
+	"dependencies", "dependentRequired", "dependentSchemas", "patternProperties", "propertyNames",
+	"contains", "prefixItems", "additionalItems", "unevaluatedItems", "unevaluatedProperties",
+}
+
+// SchemaAccepts checks whether every event data valid under the JSON schema other is valid under the JSON schema spec.
+// The check is structural, the keywords not understood must be unchanged,
+// and an object may have the properties undeclared by its schema unless its additionalProperties is false.
+// The error describes the first incompatible path, such as $.items[].name.
+func SchemaAccepts(spec string, other string) error {
+	var root, otherRoot interface{}
+	err := json.Unmarshal([]byte(spec), &root)
+	if err != nil {
+		return fmt.Errorf("schema unmarshal err: %w", err)
+	}
+	err = json.Unmarshal([]byte(other), &otherRoot)
+	if err != nil {
+		return fmt.Errorf("schema unmarshal err: %w", err)
+	}
+	c := &compatChecker{
+		root:      root,
+		otherRoot: otherRoot,
+		visiting:  make(map[[2]string]struct{}),
+	}
+	return c.accepts("$", root, otherRoot)
+}
+
+type compatChecker struct {
+	root      interface{}
+	otherRoot interface{}
+	// visiting the pairs of the references being compared, a recursive schema is accepted at its recursion
+	visiting map[[2]string]struct{}
+}
+
+func (c *compatChecker) accepts(path string, node interface{}, other interface{}) error {
+	schema, otherSchema, done, err := c.resolve(path, node, other)
+	if err != nil || done {
+		return err
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+
+	for _, k := range compatUnsupportedKeywords {
+		v, ok := schema[k]
+		if ok && !reflect.DeepEqual(v, otherSchema[k]) {
+			return fmt.Errorf("%s: %s is changed", path, k)
+		}
+	}
+
+	types := compatTypes(schema)
+	if values, ok := compatValues(otherSchema); ok {
+		for _, v := range values {
+			err = compatValueAccepted(path, schema, types, v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if _, ok := compatValues(schema); ok {
+		return fmt.Errorf("%s: enum is not met", path)
+	}
+	otherTypes := compatTypes(otherSchema)
+	if types != nil {
+		if otherTypes == nil {
+			return fmt.Errorf("%s: type is not met", path)
+		}
+		for _, t := range otherTypes {
+			if !compatTypeAccepted(types, t) {
+				return fmt.Errorf("%s: type %s is not accepted", path, t)
+			}
+		}
+	}
+	hasType := func(ts ...string) bool {
+		if otherTypes == nil {
+			return true
+		}
+		for _, t := range ts {
+			if slices.Contains(otherTypes, t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasType("string") {
+		err = compatString(path, schema, otherSchema)
+		if err != nil {
+			return err
+		}
+	}
+	if hasType("number", "integer") {
+		err = compatNumber(path, schema, otherSchema)
+		if err != nil {
+			return err
+		}
+	}
+	if hasType("object") {
+		err = c.compatObject(path, schema, otherSchema)
+		if err != nil {
+			return err
+		}
+	}
+	if hasType("array") {
+		err = c.compatArray(path, schema, otherSchema)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve resolves the references of the schemas, and returns done if the result is known without comparing them.
+// A boolean schema is returned as an empty schema if it is true, or nil if it is false.
+func (c *compatChecker) resolve(
+	path string, node interface{}, other interface{},
+) (schema map[string]interface{}, otherSchema map[string]interface{}, done bool, err error) {
+	var refs [2]string
+	for {
+		s, _ := node.(map[string]interface{})
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			break
+		}
+		refs[0] += ref
+		node, err = sampleResolveRef(c.root, ref)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+	for {
+		s, _ := other.(map[string]interface{})
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			break
+		}
+		refs[1] += ref
+		other, err = sampleResolveRef(c.otherRoot, ref)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if refs != [2]string{} {
+		if _, ok := c.visiting[refs]; ok {
+			return nil, nil, true, nil
+		}
+		c.visiting[refs] = struct{}{}
+	}
+
+	schema, ok := compatSchema(node)
+	if !ok {
+		return nil, nil, false, fmt.Errorf("invalid schema: %v", node)
+	}
+	otherSchema, ok = compatSchema(other)
+	if !ok {
+		return nil, nil, false, fmt.Errorf("invalid schema: %v", other)
+	}
+	if otherSchema == nil {
+		// nothing is valid under the other schema
+		return nil, nil, true, nil
+	}
+	if schema == nil {
+		return nil, nil, true, fmt.Errorf("%s: no value is accepted", path)
+	}
+	return schema, otherSchema, false, nil
+}
+
+func compatSchema(node interface{}) (map[string]interface{}, bool) {
+	switch s := node.(type) {
+	case bool:
+		if s {
+			return map[string]interface{}{}, true
+		}
+		return nil, true
+	case map[string]interface{}:
+		return s, true
+	default:
+		return nil, false
+	}
+}
+
+// compatTypes returns nil if the type is not restricted.
+func compatTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+func compatTypeAccepted(types []string, t string) bool {
+	return slices.Contains(types, t) || t == "integer" && slices.Contains(types, "number")
+}
+
+// compatValues returns the values of the const or the enum.
+func compatValues(schema map[string]interface{}) ([]interface{}, bool) {
+	if v, ok := schema["const"]; ok {
+		return []interface{}{v}, true
+	}
+	if v, ok := schema["enum"].([]interface{}); ok {
+		return v, true
+	}
+	return nil, false
+}
+
+func compatValueType(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// compatValueAccepted checks the value of the enum by the type, the enum and the bounds of the schema.
+func compatValueAccepted(path string, schema map[string]interface{}, types []string, v interface{}) error {
+	bs, _ := json.Marshal(v, json.Deterministic(true))
+	if types != nil && !compatTypeAccepted(types, compatValueType(v)) {
+		return fmt.Errorf("%s: value %s is not accepted by the type", path, bs)
+	}
+	if values, ok := compatValues(schema); ok && !slices.ContainsFunc(values, func(val interface{}) bool {
+		return reflect.DeepEqual(val, v)
+	}) {
+		return fmt.Errorf("%s: value %s is not in the enum", path, bs)
+	}
+	switch val := v.(type) {
+	case string:
+		n := float64(len([]rune(val)))
+		if minLen, ok := schema["minLength"].(float64); ok && n < minLen {
+			return fmt.Errorf("%s: value %s is shorter than minLength", path, bs)
+		}
+		if maxLen, ok := schema["maxLength"].(float64); ok && n > maxLen {
+			return fmt.Errorf("%s: value %s is longer than maxLength", path, bs)
+		}
+	case float64:
+		if lower, excl, ok := compatBound(schema, "minimum", "exclusiveMinimum", math.Max); ok &&
+			(val < lower || val == lower && excl) {
+			return fmt.Errorf("%s: value %s is less than minimum", path, bs)
+		}
+		if upper, excl, ok := compatBound(schema, "maximum", "exclusiveMaximum", math.Min); ok &&
+			(val > upper || val == upper && excl) {
+			return fmt.Errorf("%s: value %s is greater than maximum", path, bs)
+		}
+		if m, ok := schema["multipleOf"].(float64); ok && val/m != math.Trunc(val/m) {
+			return fmt.Errorf("%s: value %s is not a multiple of multipleOf", path, bs)
+		}
+	}
+	return nil
+}
+
+func compatString(path string, schema map[string]interface{}, otherSchema map[string]interface{}) error {
+	err := compatLowerBound(path, "minLength", schema, otherSchema)
+	if err != nil {
+		return err
+	}
+	err = compatUpperBound(path, "maxLength", schema, otherSchema)
+	if err != nil {
+		return err
+	}
+	for _, k := range []string{"pattern", "format"} {
+		v, ok := schema[k]
+		if ok && !reflect.DeepEqual(v, otherSchema[k]) {
+			return fmt.Errorf("%s: %s is changed", path, k)
+		}
+	}
+	return nil
+}
+
+// compatNumber the exclusive bounds are the numbers of draft 6 and later.
+func compatNumber(path string, schema map[string]interface{}, otherSchema map[string]interface{}) error {
+	if lower, excl, ok := compatBound(schema, "minimum", "exclusiveMinimum", math.Max); ok {
+		otherLower, otherExcl, otherOk := compatBound(otherSchema, "minimum", "exclusiveMinimum", math.Max)
+		if !otherOk || otherLower < lower || otherLower == lower && excl && !otherExcl {
+			return fmt.Errorf("%s: minimum is not met", path)
+		}
+	}
+	if upper, excl, ok := compatBound(schema, "maximum", "exclusiveMaximum", math.Min); ok {
+		otherUpper, otherExcl, otherOk := compatBound(otherSchema, "maximum", "exclusiveMaximum", math.Min)
+		if !otherOk || otherUpper > upper || otherUpper == upper && excl && !otherExcl {
+			return fmt.Errorf("%s: maximum is not met", path)
+		}
+	}
+	if m, ok := schema["multipleOf"].(float64); ok {
+		om, otherOk := otherSchema["multipleOf"].(float64)
+		if !otherOk || om/m != math.Trunc(om/m) {
+			return fmt.Errorf("%s: multipleOf is not met", path)
+		}
+	}
+	return nil
+}
+
+// compatBound returns the tighter one of the inclusive and the exclusive bounds, pick is math.Max or math.Min.
+func compatBound(
+	schema map[string]interface{}, inclusive string, exclusive string, pick func(float64, float64) float64,
+) (bound float64, excl bool, ok bool) {
+	in, inOk := schema[inclusive].(float64)
+	ex, exOk := schema[exclusive].(float64)
+	switch {
+	case inOk && exOk:
+		bound = pick(in, ex)
+		return bound, bound == ex, true
+	case inOk:
+		return in, false, true
+	case exOk:
+		return ex, true, true
+	default:
+		return 0, false, false
+	}
+}
+
+func compatLowerBound(
+	path string, key string, schema map[string]interface{}, otherSchema map[string]interface{},
+) error {
+	v, ok := schema[key].(float64)
+	if !ok || v <= 0 {
+		return nil
+	}
+	ov, _ := otherSchema[key].(float64)
+	if ov < v {
+		return fmt.Errorf("%s: %s is not met", path, key)
+	}
+	return nil
+}
+
+func compatUpperBound(
+	path string, key string, schema map[string]interface{}, otherSchema map[string]interface{},
+) error {
+	v, ok := schema[key].(float64)
+	if !ok {
+		return nil
+	}
+	ov, otherOk := otherSchema[key].(float64)
+	if !otherOk || ov > v {
+		return fmt.Errorf("%s: %s is not met", path, key)
+	}
+	return nil
+}
+
+func (c *compatChecker) compatObject(
+	path string, schema map[string]interface{}, otherSchema map[string]interface{},
+) error {
+	otherRequired, _ := otherSchema["required"].([]interface{})
+	required, _ := schema["required"].([]interface{})
+	for _, r := range required {
+		if !slices.Contains(otherRequired, r) {
+			return fmt.Errorf("%s: property %v is required", path, r)
+		}
+	}
+
+	// the additional properties are allowed if additionalProperties is missing
+	props, _ := schema["properties"].(map[string]interface{})
+	otherProps, _ := otherSchema["properties"].(map[string]interface{})
+	additional, ok := schema["additionalProperties"]
+	if !ok {
+		additional = true
+	}
+	otherAdditional, ok := otherSchema["additionalProperties"]
+	if !ok {
+		otherAdditional = true
+	}
+	for _, name := range compatSortedKeys(otherProps) {
+		p, ok := props[name]
+		if !ok {
+			p = additional
+		}
+		err := c.accepts(path+"."+name, p, otherProps[name])
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range compatSortedKeys(props) {
+		if _, ok := otherProps[name]; ok {
+			continue
+		}
+		err := c.accepts(path+"."+name, props[name], otherAdditional)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.accepts(path+".*", additional, otherAdditional)
+	if err != nil {
+		return err
+	}
+
+	err = compatLowerBound(path, "minProperties", schema, otherSchema)
+	if err != nil {
+		return err
+	}
+	return compatUpperBound(path, "maxProperties", schema, otherSchema)
+}
+
+func (c *compatChecker) compatArray(
+	path string, schema map[string]interface{}, otherSchema map[string]interface{},
+) error {
+	if items, ok := schema["items"]; ok {
+		otherItems, otherOk := otherSchema["items"]
+		if !otherOk {
+			otherItems = true
+		}
+		_, isTuple := items.([]interface{})
+		_, otherIsTuple := otherItems.([]interface{})
+		if isTuple || otherIsTuple {
+			if !reflect.DeepEqual(items, otherItems) {
+				return fmt.Errorf("%s: items is changed", path)
+			}
+		} else {
+			err := c.accepts(path+"[]", items, otherItems)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		if otherUnique, _ := otherSchema["uniqueItems"].(bool); !otherUnique {
+			return fmt.Errorf("%s: uniqueItems is not met", path)
+		}
+	}
+	err := compatLowerBound(path, "minItems", schema, otherSchema)
+	if err != nil {
+		return err
+	}
+	return compatUpperBound(path, "maxItems", schema, otherSchema)
+}
+
+func compatSortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestSchemaAccepts(t *testing.T) {
+	acceptsTests := []struct {
+		spec  string
+		other string
+		err   string
+	}{
+		{
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]}`,
+		},
+		{
+			// the optional property is added, but the additional property b of any type was valid
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			err:   `$.b: type is not met`,
+		},
+		{
+			// the optional property is added to the closed object
+			spec: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "integer"}}, ` +
+				`"additionalProperties": false}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+		},
+		{
+			// the optional property of any type is added
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}, "b": {}}}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+		},
+		{
+			// the object is closed
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			err:   `$.*: no value is accepted`,
+		},
+		{
+			// the required property is added
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			err:   `$: property a is required`,
+		},
+		{
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			other: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`,
+			err:   `$.b: no value is accepted`,
+		},
+		{
+			spec:  `{"type": "object", "properties": {"a": {"type": "number"}}}`,
+			other: `{"type": "object", "properties": {"a": {"type": "integer", "minimum": 1}}}`,
+		},
+		{
+			spec:  `{"type": "object", "properties": {"a": {"type": "integer"}}}`,
+			other: `{"type": "object", "properties": {"a": {"type": "number"}}}`,
+			err:   `$.a: type number is not accepted`,
+		},
+		{
+			spec:  `{"type": "object", "properties": {"a": {"type": "string"}}}`,
+			other: `{"type": "object", "properties": {"a": {}}}`,
+			err:   `$.a: type is not met`,
+		},
+		{
+			spec:  `{"type": "string", "maxLength": 8}`,
+			other: `{"type": "string", "maxLength": 4, "minLength": 1}`,
+		},
+		{
+			spec:  `{"type": "string", "maxLength": 4}`,
+			other: `{"type": "string", "maxLength": 8}`,
+			err:   `$: maxLength is not met`,
+		},
+		{
+			spec:  `{"type": "number", "exclusiveMinimum": 0, "maximum": 10}`,
+			other: `{"type": "integer", "minimum": 1, "exclusiveMaximum": 10}`,
+		},
+		{
+			spec:  `{"type": "number", "exclusiveMinimum": 0}`,
+			other: `{"type": "number", "minimum": 0}`,
+			err:   `$: minimum is not met`,
+		},
+		{
+			spec:  `{"enum": ["high", "low", "medium"]}`,
+			other: `{"type": "string", "enum": ["high", "low"]}`,
+		},
+		{
+			spec:  `{"type": "string", "enum": ["high"]}`,
+			other: `{"enum": ["high", "low"]}`,
+			err:   `$: value "low" is not in the enum`,
+		},
+		{
+			spec:  `{"type": "string", "enum": ["high"]}`,
+			other: `{"type": "string"}`,
+			err:   `$: enum is not met`,
+		},
+		{
+			spec:  `{"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}}`,
+			other: `{"type": "array", "items": {"type": "object", "properties": {"name": {"type": "boolean"}}}}`,
+			err:   `$[].name: type boolean is not accepted`,
+		},
+		{
+			spec:  `{"type": "array", "uniqueItems": true}`,
+			other: `{"type": "array"}`,
+			err:   `$: uniqueItems is not met`,
+		},
+		{
+			spec:  `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`,
+			other: `{"oneOf": [{"type": "string"}]}`,
+			err:   `$: oneOf is changed`,
+		},
+		{
+			// the recursive schemas are compared by their references
+			spec: `{"$ref": "#/$defs/node", "$defs": {"node": {"type": "object", ` +
+				`"properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}}}}`,
+			other: `{"$ref": "#/definitions/node", "definitions": {"node": {"type": "object", ` +
+				`"properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}}}}}`,
+		},
+		{
+			spec:  `true`,
+			other: `{"type": "string"}`,
+		},
+		{
+			spec:  `{"type": "string"}`,
+			other: `false`,
+		},
+	}
+	for idx, tt := range acceptsTests {
+		err := SchemaAccepts(tt.spec, tt.other)
+		if tt.err == "" {
+			if err != nil {
+				t.Fatalf("case(index=%d) err: %v", idx, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("case(index=%d) expect err: %s, actual: %v", idx, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package rule
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// discoveredSchema is the JSON schema inferred from the observed event data.
+// An object requires the properties present in every observed object,
+// and an integer is widened to a number once a fractional number is observed.
+type discoveredSchema struct {
+	types      map[string]struct{}
+	properties map[string]*discoveredSchema
+	required   map[string]struct{}
+	items      *discoveredSchema
+}
+
+// MergeSchemaSample infers the JSON schema of the event data, and merges it into the spec inferred before,
+// so that the data observed are all valid under the merged spec. The spec is empty if nothing is observed before.
+func MergeSchemaSample(spec string, data string) (string, error) {
+	var val interface{}
+	err := json.Unmarshal([]byte(data), &val)
+	if err != nil {
+		return "", fmt.Errorf("event data unmarshal err: %w", err)
+	}
+	ds := discoverValue(val)
+	if spec != "" {
+		var root interface{}
+		err = json.Unmarshal([]byte(spec), &root)
+		if err != nil {
+			return "", fmt.Errorf("schema unmarshal err: %w", err)
+		}
+		ds = discoveredFromSpec(root).merge(ds)
+	}
+	bs, err := json.Marshal(ds.spec(), json.Deterministic(true))
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func discoverValue(val interface{}) *discoveredSchema {
+	ds := &discoveredSchema{types: make(map[string]struct{}, 1)}
+	switch v := val.(type) {
+	case nil:
+		ds.types["null"] = struct{}{}
+	case bool:
+		ds.types["boolean"] = struct{}{}
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			ds.types["integer"] = struct{}{}
+		} else {
+			ds.types["number"] = struct{}{}
+		}
+	case string:
+		ds.types["string"] = struct{}{}
+	case []interface{}:
+		ds.types["array"] = struct{}{}
+		for _, item := range v {
+			ds.items = ds.items.merge(discoverValue(item))
+		}
+	case map[string]interface{}:
+		ds.types["object"] = struct{}{}
+		ds.properties = make(map[string]*discoveredSchema, len(v))
+		ds.required = make(map[string]struct{}, len(v))
+		for k, item := range v {
+			ds.properties[k] = discoverValue(item)
+			ds.required[k] = struct{}{}
+		}
+	}
+	return ds
+}
+
+// discoveredFromSpec parses the spec generated by spec, the keywords not generated are ignored.
+func discoveredFromSpec(node interface{}) *discoveredSchema {
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	ds := &discoveredSchema{types: make(map[string]struct{}, 1)}
+	switch t := schema["type"].(type) {
+	case string:
+		ds.types[t] = struct{}{}
+	case []interface{}:
+		for _, v := range t {
+			if s, isStr := v.(string); isStr {
+				ds.types[s] = struct{}{}
+			}
+		}
+	}
+	if _, ok = ds.types["object"]; ok {
+		props, _ := schema["properties"].(map[string]interface{})
+		ds.properties = make(map[string]*discoveredSchema, len(props))
+		for k, v := range props {
+			if p := discoveredFromSpec(v); p != nil {
+				ds.properties[k] = p
+			}
+		}
+		required, _ := schema["required"].([]interface{})
+		ds.required = make(map[string]struct{}, len(required))
+		for _, v := range required {
+			if s, isStr := v.(string); isStr {
+				ds.required[s] = struct{}{}
+			}
+		}
+	}
+	if _, ok = ds.types["array"]; ok {
+		ds.items = discoveredFromSpec(schema["items"])
+	}
+	return ds
+}
+
+// merge returns the schema accepting the values of both ds and other, ds and other may be modified.
+func (ds *discoveredSchema) merge(other *discoveredSchema) *discoveredSchema {
+	if ds == nil {
+		return other
+	}
+	if other == nil {
+		return ds
+	}
+	_, dsObject := ds.types["object"]
+	_, otherObject := other.types["object"]
+	switch {
+	case dsObject && otherObject:
+		for k, p := range other.properties {
+			ds.properties[k] = ds.properties[k].merge(p)
+		}
+		for k := range ds.required {
+			if _, ok := other.required[k]; !ok {
+				delete(ds.required, k)
+			}
+		}
+	case otherObject:
+		ds.properties = other.properties
+		ds.required = other.required
+	}
+	ds.items = ds.items.merge(other.items)
+	for t := range other.types {
+		ds.types[t] = struct{}{}
+	}
+	if _, ok := ds.types["number"]; ok {
+		delete(ds.types, "integer")
+	}
+	return ds
+}
+
+func (ds *discoveredSchema) spec() map[string]interface{} {
+	types := make([]string, 0, len(ds.types))
+	for t := range ds.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	schema := make(map[string]interface{})
+	if len(types) == 1 {
+		schema["type"] = types[0]
+	} else {
+		schema["type"] = types
+	}
+	if len(ds.properties) > 0 {
+		props := make(map[string]interface{}, len(ds.properties))
+		for k, p := range ds.properties {
+			props[k] = p.spec()
+		}
+		schema["properties"] = props
+	}
+	if len(ds.required) > 0 {
+		required := make([]string, 0, len(ds.required))
+		for k := range ds.required {
+			required = append(required, k)
+		}
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	if ds.items != nil {
+		schema["items"] = ds.items.spec()
+	}
+	return schema
+}
@@ -0,0 +1,79 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestMergeSchemaSample(t *testing.T) {
+	mergeTests := []struct {
+		samples []string
+		spec    string
+	}{
+		{
+			samples: []string{`{"a":"x","b":1,"c":true,"d":null}`},
+			spec: `{"properties":{"a":{"type":"string"},"b":{"type":"integer"},"c":{"type":"boolean"},` +
+				`"d":{"type":"null"}},"required":["a","b","c","d"],"type":"object"}`,
+		},
+		{
+			// the properties absent in a sample are optional
+			samples: []string{`{"a":"x","b":1}`, `{"a":"y","c":[1]}`},
+			spec: `{"properties":{"a":{"type":"string"},"b":{"type":"integer"},` +
+				`"c":{"items":{"type":"integer"},"type":"array"}},"required":["a"],"type":"object"}`,
+		},
+		{
+			// the integer is widened to the number
+			samples: []string{`{"a":1}`, `{"a":1.5}`, `{"a":2}`},
+			spec:    `{"properties":{"a":{"type":"number"}},"required":["a"],"type":"object"}`,
+		},
+		{
+			samples: []string{`{"a":"x"}`, `{"a":null}`},
+			spec:    `{"properties":{"a":{"type":["null","string"]}},"required":["a"],"type":"object"}`,
+		},
+		{
+			// the items of the arrays are merged
+			samples: []string{`[{"id":1,"name":"x"},{"id":2}]`, `[]`},
+			spec: `{"items":{"properties":{"id":{"type":"integer"},"name":{"type":"string"}},` +
+				`"required":["id"],"type":"object"},"type":"array"}`,
+		},
+		{
+			samples: []string{`{"a":{"b":"x"}}`, `{"a":"x"}`, `{"a":{"c":1}}`},
+			spec: `{"properties":{"a":{"properties":{"b":{"type":"string"},"c":{"type":"integer"}},` +
+				`"type":["object","string"]}},"required":["a"],"type":"object"}`,
+		},
+	}
+	for idx, tt := range mergeTests {
+		spec := ""
+		for _, sample := range tt.samples {
+			var err error
+			spec, err = MergeSchemaSample(spec, sample)
+			if err != nil {
+				t.Fatalf("case(index=%d) err: %v", idx, err)
+			}
+		}
+		if spec != tt.spec {
+			t.Fatalf("case(index=%d) expect spec: %s, actual: %s", idx, tt.spec, spec)
+		}
+
+		// every sample is valid under the merged spec
+		validator, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(spec))
+		if err != nil {
+			t.Fatalf("case(index=%d) invalid spec: %v", idx, err)
+		}
+		for _, sample := range tt.samples {
+			result, err := validator.Validate(gojsonschema.NewStringLoader(sample))
+			if err != nil {
+				t.Fatalf("case(index=%d) validate err: %v", idx, err)
+			}
+			if !result.Valid() {
+				t.Fatalf("case(index=%d) sample %s is not valid: %v", idx, sample, result.Errors())
+			}
+		}
+	}
+
+	_, err := MergeSchemaSample("", "not json")
+	if err == nil {
+		t.Fatal("expect err of the invalid event data")
+	}
+}
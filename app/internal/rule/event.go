@@ -1,9 +1,12 @@
 package rule
 
 import (
+	"encoding/base64"
 	"encoding/json/v2"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"os"
 	"strconv"
 	"sync"
 	"time"
@@ -30,12 +33,10 @@ func NewEventExt(evt *v1.Event, retry v1.RetryStrategy) (*EventExt, error) {
 		if ok {
 			idGen = val.(*sonyflake.Sonyflake)
 		} else {
-			st, _ := time.Parse("2006-01-02", "2022-08-10")
-			idGen = sonyflake.NewSonyflake(
-				sonyflake.Settings{
-					StartTime: st,
-				},
-			)
+			idGen = newIDGenerator()
+			if idGen == nil {
+				return nil, fmt.Errorf("can't create the ID generator of the source(%s)", evt.Source)
+			}
 			sourceIDGenMapping.Store(evt.Source, idGen)
 		}
 
@@ -54,6 +55,36 @@ func NewEventExt(evt *v1.Event, retry v1.RetryStrategy) (*EventExt, error) {
 	}, nil
 }
 
+// newIDGenerator the machine ID is the lower 16 bits of the private IP address,
+// or the hash of the hostname and the process ID if the host has no private IP address.
+func newIDGenerator() *sonyflake.Sonyflake {
+	st, _ := time.Parse("2006-01-02", "2022-08-10")
+	idGen := sonyflake.NewSonyflake(
+		sonyflake.Settings{
+			StartTime: st,
+		},
+	)
+	if idGen != nil {
+		return idGen
+	}
+	return sonyflake.NewSonyflake(
+		sonyflake.Settings{
+			StartTime: st,
+			MachineID: hostMachineID,
+		},
+	)
+}
+
+func hostMachineID() (uint16, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New32a()
+	_, _ = fmt.Fprintf(h, "%s:%d", hostname, os.Getpid())
+	return uint16(h.Sum32()), nil
+}
+
 func NewEventExtFromBytes(b []byte) (*EventExt, error) {
 	cee := &v1.EventExt{}
 	err := proto.Unmarshal(b, cee)
@@ -93,11 +124,13 @@ func CloneEventExt(evt *EventExt) *EventExt {
 				Data:            evt.Event.Data,
 				Datacontenttype: evt.Event.Datacontenttype,
 			},
-			BusName:       evt.BusName,
-			RuleName:      evt.RuleName,
-			TargetId:      evt.TargetId,
-			RetryStrategy: evt.RetryStrategy,
-			Metadata:      meta,
+			DecodedData:      evt.DecodedData,
+			BusName:          evt.BusName,
+			RuleName:         evt.RuleName,
+			TargetId:         evt.TargetId,
+			RetryStrategy:    evt.RetryStrategy,
+			Metadata:         meta,
+			PendingTransform: evt.PendingTransform,
 		},
 	}
 }
@@ -132,6 +165,47 @@ func (e *EventExt) ValidateEventData(validator *gojsonschema.Schema) error {
 	return nil
 }
 
+// DecodeEventData decodes the base64 encoded binary data by its schema,
+// and keeps the decoded data in JSON for the patterns and the transforms.
+func (e *EventExt) DecodeEventData(decoder BinaryDecoder) error {
+	if e.Event.Datacontenttype != decoder.ContentType() {
+		return v1.ErrorEventDataNotValid(
+			"event datacontenttype should be %s, actual: %s", decoder.ContentType(), e.Event.Datacontenttype,
+		)
+	}
+	data, err := base64.StdEncoding.DecodeString(e.Event.Data)
+	if err != nil {
+		return v1.ErrorEventDataNotValid(
+			"event data should be base64 encoded. see err: %s", err,
+		)
+	}
+	decoded, err := decoder.Decode(data)
+	if err != nil {
+		return v1.ErrorEventDataNotValid(
+			"event data is not valid. see err: %s", err,
+		)
+	}
+	e.DecodedData = string(decoded)
+	return nil
+}
+
+// JSONData returns the event data in JSON, which is the decoded data if the event data is binary.
+func (e *EventExt) JSONData() string {
+	if e.DecodedData != "" {
+		return e.DecodedData
+	}
+	return e.Event.Data
+}
+
+// SetJSONData replaces the event data with the JSON, the binary event data is re-encoded to JSON this way.
+func (e *EventExt) SetJSONData(data string) {
+	e.Event.Data = data
+	if e.DecodedData != "" {
+		e.Event.Datacontenttype = ContentTypeJSON
+		e.DecodedData = ""
+	}
+}
+
 type NotExistsValT struct{}
 
 var notExistsVal = &NotExistsValT{}
@@ -148,6 +222,7 @@ func NewNotExistsVal() *NotExistsValT {
 
 // GetFieldByPath get internal field value by path.
 // e.g.: ["data", "source"] -> e.Event.Data.source.
+// the fields of the binary event data are read from the decoded data.
 // if the path does not exist,
 // the function will return the value generated by NewNotExistsVal to distinguish nil.
 // if the event data is parsed incorrectly,
@@ -177,7 +252,7 @@ func (e *EventExt) GetFieldByPath(path []string) (interface{}, error) {
 			return e.Event.Time.AsTime(), nil
 		case "data":
 			var data interface{}
-			err := json.Unmarshal([]byte(e.Event.Data), &data)
+			err := json.Unmarshal([]byte(e.JSONData()), &data)
 			if err != nil {
 				return nil, newDataUnmarshalError(err)
 			}
@@ -194,7 +269,7 @@ func (e *EventExt) GetFieldByPath(path []string) (interface{}, error) {
 	}
 
 	var data interface{}
-	err := json.Unmarshal([]byte(e.Event.Data), &data)
+	err := json.Unmarshal([]byte(e.JSONData()), &data)
 	if err != nil {
 		return nil, newDataUnmarshalError(err)
 	}
@@ -0,0 +1,17 @@
+-- KEYS[1] is the sorted set of the leases scored by their expiry in milliseconds.
+-- ARGV[1] is the max concurrency, ARGV[2] is the lease and ARGV[3] is its ttl in milliseconds.
+-- returns 1 if the lease is acquired, otherwise 0.
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local ttl = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[1]) then
+    return 0
+end
+
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[2])
+if redis.call("PTTL", KEYS[1]) < ttl then
+    redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
@@ -0,0 +1,132 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// defaultMaxWait is the max wait of the context without deadline.
+const defaultMaxWait = 5 * time.Second
+
+var _ rule.NewLimiterFunc = NewLocalLimiter
+
+// maxWait returns half of the time left before the deadline of ctx,
+// the other half is left to dispatch the event or to defer it through the retry queue.
+func maxWait(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return defaultMaxWait
+	}
+	return max(time.Until(deadline)/2, 0) // nolint:mnd
+}
+
+// rateOf returns the emission interval and the burst of the requests per second,
+// a second of requests is allowed to burst.
+func rateOf(target *rule.Target) (time.Duration, int64) {
+	if target.MaxRequestsPerSecond == 0 {
+		return 0, 0
+	}
+	return time.Second / time.Duration(target.MaxRequestsPerSecond), int64(target.MaxRequestsPerSecond)
+}
+
+// reserve is the generic cell rate algorithm, tat is the theoretical arrival time of the next request.
+// It returns the new tat and how long the request has to wait at now.
+func reserve(tat time.Time, now time.Time, interval time.Duration, burst int64) (time.Time, time.Duration) {
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(interval)
+	return newTat, max(newTat.Sub(now)-interval*time.Duration(burst), 0)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// localLimiter limits the dispatching of the job replica it runs in.
+type localLimiter struct {
+	target   uint64
+	maxRPS   uint32
+	interval time.Duration // 0 if the rate is unlimited
+	burst    int64
+	sem      chan struct{} // nil if the concurrency is unlimited
+
+	mu  sync.Mutex
+	tat time.Time
+}
+
+// NewLocalLimiter the limits are enforced per job replica.
+func NewLocalLimiter(_ context.Context, _ log.Logger, _ string, target *rule.Target) (rule.Limiter, error) {
+	l := &localLimiter{
+		target: target.ID,
+		maxRPS: target.MaxRequestsPerSecond,
+	}
+	l.interval, l.burst = rateOf(target)
+	if target.MaxConcurrency > 0 {
+		l.sem = make(chan struct{}, target.MaxConcurrency)
+	}
+	return l, nil
+}
+
+// Acquire takes the concurrency slot before reserving the rate, so that the rate isn't used up
+// by the requests waiting for the concurrency.
+func (l *localLimiter) Acquire(ctx context.Context) (func(), error) {
+	deadline := time.Now().Add(maxWait(ctx))
+	release, err := l.acquireConcurrency(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if l.interval == 0 {
+		return release, nil
+	}
+	l.mu.Lock()
+	tat, d := reserve(l.tat, time.Now(), l.interval, l.burst)
+	if d > time.Until(deadline) {
+		l.mu.Unlock()
+		release()
+		return nil, rule.NewThrottledError(
+			"target(id: %d) exceeds %d requests per second", l.target, l.maxRPS,
+		)
+	}
+	l.tat = tat
+	l.mu.Unlock()
+	err = sleep(ctx, d)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *localLimiter) acquireConcurrency(ctx context.Context, deadline time.Time) (func(), error) {
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-t.C:
+		return nil, rule.NewThrottledError(
+			"target(id: %d) exceeds %d concurrent requests", l.target, cap(l.sem),
+		)
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
@@ -0,0 +1,146 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func TestReserve(t *testing.T) {
+	now := time.Unix(100, 0)
+	runs := []struct {
+		name    string
+		tat     time.Time
+		burst   int64
+		wantTat time.Time
+		want    time.Duration
+	}{
+		{name: "idle", tat: time.Time{}, burst: 1, wantTat: now.Add(time.Second), want: 0},
+		{name: "busy", tat: now.Add(time.Second), burst: 1, wantTat: now.Add(2 * time.Second), want: time.Second},
+		{name: "burst", tat: now.Add(time.Second), burst: 2, wantTat: now.Add(2 * time.Second), want: 0},
+		{name: "burst exhausted", tat: now.Add(3 * time.Second), burst: 2, wantTat: now.Add(4 * time.Second),
+			want: 2 * time.Second},
+	}
+	for _, run := range runs {
+		t.Run(run.name, func(t *testing.T) {
+			tat, wait := reserve(run.tat, now, time.Second, run.burst)
+			if !tat.Equal(run.wantTat) || wait != run.want {
+				t.Errorf("expect tat: %v, wait: %v, actual tat: %v, wait: %v", run.wantTat, run.want, tat, wait)
+			}
+		})
+	}
+}
+
+func acquire(l rule.Limiter, timeout time.Duration) (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.Acquire(ctx)
+}
+
+func TestLocalLimiterRate(t *testing.T) {
+	l, err := NewLocalLimiter(context.Background(), log.DefaultLogger, "bus:rule:1", &rule.Target{
+		ID:                   1,
+		MaxRequestsPerSecond: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second of requests is allowed to burst
+	for i := range 10 {
+		release, err := acquire(l, time.Second)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		release()
+	}
+
+	// the next one is allowed 100ms later, beyond the wait of the short deadline
+	_, err = acquire(l, 50*time.Millisecond)
+	if !rule.IsThrottledError(err) {
+		t.Fatalf("expect throttled, got: %v", err)
+	}
+
+	start := time.Now()
+	release, err := acquire(l, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expect waiting for the rate, elapsed: %v", elapsed)
+	}
+}
+
+func TestLocalLimiterConcurrency(t *testing.T) {
+	l, err := NewLocalLimiter(context.Background(), log.DefaultLogger, "bus:rule:1", &rule.Target{
+		ID:             1,
+		MaxConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := acquire(l, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = acquire(l, 50*time.Millisecond)
+	if !rule.IsThrottledError(err) {
+		t.Fatalf("expect throttled, got: %v", err)
+	}
+
+	// the waiting one acquires once the other is released
+	time.AfterFunc(20*time.Millisecond, release)
+	release, err = acquire(l, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestLocalLimiterConcurrencyTimeout(t *testing.T) {
+	l, err := NewLocalLimiter(context.Background(), log.DefaultLogger, "bus:rule:1", &rule.Target{
+		ID:                   1,
+		MaxRequestsPerSecond: 2,
+		MaxConcurrency:       1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := acquire(l, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the requests timed out waiting for the concurrency don't use up the rate
+	for range 3 {
+		_, err = acquire(l, 50*time.Millisecond)
+		if !rule.IsThrottledError(err) {
+			t.Fatalf("expect throttled, got: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = l.Acquire(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect canceled, got: %v", err)
+	}
+	release()
+
+	// the burst of the rate is left for the next one
+	start := time.Now()
+	release, err = acquire(l, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expect no wait for the rate, elapsed: %v", elapsed)
+	}
+}
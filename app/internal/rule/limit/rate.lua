@@ -0,0 +1,23 @@
+-- the generic cell rate algorithm, KEYS[1] is the theoretical arrival time of the next request in microseconds.
+-- ARGV[1] is the emission interval, ARGV[2] is the burst and ARGV[3] is the max wait in microseconds.
+-- returns the wait in microseconds, or -1 if it exceeds the max wait.
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+    tat = now
+end
+
+local newTat = tat + interval
+local wait = newTat - now - interval * tonumber(ARGV[2])
+if wait < 0 then
+    wait = 0
+end
+if wait > tonumber(ARGV[3]) then
+    return -1
+end
+
+redis.call("SET", KEYS[1], string.format("%d", newTat), "PX", math.ceil((newTat - now) / 1000) + 1000)
+return wait
@@ -0,0 +1,152 @@
+package limit
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	redisKeyPrefix = "eventbridge:limit:"
+	// redisLeaseGrace keeps the lease a little longer than the deadline of the dispatching.
+	redisLeaseGrace = time.Second
+	// redisDefaultLeaseTTL is the lease ttl of the context without deadline.
+	redisDefaultLeaseTTL = time.Minute
+	redisReleaseTimeout  = time.Second
+	redisMinPollInterval = 10 * time.Millisecond
+	redisMaxPollInterval = 200 * time.Millisecond
+)
+
+var (
+	//go:embed rate.lua
+	rateLua    string
+	rateScript = redis.NewScript(rateLua)
+
+	//go:embed concurrency.lua
+	concurrencyLua    string
+	concurrencyScript = redis.NewScript(concurrencyLua)
+)
+
+// redisLimiter limits the dispatching of all the job replicas sharing the redis.
+type redisLimiter struct {
+	rc             redis.Cmdable
+	target         uint64
+	maxRPS         uint32
+	interval       time.Duration // 0 if the rate is unlimited
+	burst          int64
+	maxConcurrency int64 // 0 if the concurrency is unlimited
+	rateKey        string
+	concurrencyKey string
+}
+
+// NewRedisLimiterFunc the limits are shared by the job replicas using the same redis.
+func NewRedisLimiterFunc(rc redis.Cmdable) rule.NewLimiterFunc {
+	return func(_ context.Context, _ log.Logger, key string, target *rule.Target) (rule.Limiter, error) {
+		l := &redisLimiter{
+			rc:             rc,
+			target:         target.ID,
+			maxRPS:         target.MaxRequestsPerSecond,
+			maxConcurrency: int64(target.MaxConcurrency),
+			rateKey:        redisKeyPrefix + key + ":rate",
+			concurrencyKey: redisKeyPrefix + key + ":concurrency",
+		}
+		l.interval, l.burst = rateOf(target)
+		return l, nil
+	}
+}
+
+// Acquire takes the concurrency slot before reserving the rate, so that the rate isn't used up
+// by the requests waiting for the concurrency.
+func (l *redisLimiter) Acquire(ctx context.Context) (func(), error) {
+	deadline := time.Now().Add(maxWait(ctx))
+	release, err := l.acquireConcurrency(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if l.interval == 0 {
+		return release, nil
+	}
+	d, err := rateScript.Run(
+		ctx, l.rc, []string{l.rateKey},
+		l.interval.Microseconds(), l.burst, max(time.Until(deadline), 0).Microseconds(),
+	).Int64()
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("reserve the rate of target(id: %d) err: %w", l.target, err)
+	}
+	if d < 0 {
+		release()
+		return nil, rule.NewThrottledError(
+			"target(id: %d) exceeds %d requests per second", l.target, l.maxRPS,
+		)
+	}
+	err = sleep(ctx, time.Duration(d)*time.Microsecond)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *redisLimiter) acquireConcurrency(ctx context.Context, deadline time.Time) (func(), error) {
+	if l.maxConcurrency == 0 {
+		return func() {}, nil
+	}
+
+	lease, err := newLease()
+	if err != nil {
+		return nil, err
+	}
+	ttl := redisDefaultLeaseTTL
+	if ctxDeadline, ok := ctx.Deadline(); ok {
+		ttl = time.Until(ctxDeadline) + redisLeaseGrace
+	}
+	interval := redisMinPollInterval
+	for {
+		var ok int64
+		ok, err = concurrencyScript.Run(
+			ctx, l.rc, []string{l.concurrencyKey},
+			l.maxConcurrency, lease, ttl.Milliseconds(),
+		).Int64()
+		if err != nil {
+			return nil, fmt.Errorf("acquire the concurrency of target(id: %d) err: %w", l.target, err)
+		}
+		if ok == 1 {
+			return func() { l.release(lease) }, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, rule.NewThrottledError(
+				"target(id: %d) exceeds %d concurrent requests", l.target, l.maxConcurrency,
+			)
+		}
+		err = sleep(ctx, interval)
+		if err != nil {
+			return nil, err
+		}
+		interval = min(interval*2, redisMaxPollInterval) // nolint:mnd
+	}
+}
+
+// release the lease expires by itself if it fails to be removed.
+func (l *redisLimiter) release(lease string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisReleaseTimeout)
+	defer cancel()
+	_ = l.rc.ZRem(ctx, l.concurrencyKey, lease).Err()
+}
+
+func newLease() (string, error) {
+	b := make([]byte, 16) // nolint:mnd
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+
+	"github.com/hamba/avro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// BinaryDecoder decodes the binary event data by the spec of its schema,
+// so that the patterns and the transforms read the fields of the decoded data.
+type BinaryDecoder interface {
+	// ContentType is the content type of the binary event data.
+	ContentType() string
+	// Decode returns the data in JSON, an error is returned if the data is not valid under the spec.
+	Decode(data []byte) ([]byte, error)
+}
+
+// FindMessageDescriptor finds the message by its full name in the serialized FileDescriptorSet.
+func FindMessageDescriptor(descriptorSet []byte, message string) (protoreflect.MessageDescriptor, error) {
+	fds := &descriptorpb.FileDescriptorSet{}
+	err := proto.Unmarshal(descriptorSet, fds)
+	if err != nil {
+		return nil, fmt.Errorf("descriptor set unmarshal err: %w", err)
+	}
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf message(%s) err: %w", message, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("protobuf name(%s) is not a message", message)
+	}
+	return md, nil
+}
+
+type protobufDecoder struct {
+	md protoreflect.MessageDescriptor
+}
+
+// NewProtobufDecoder decodes the data as the message in the serialized FileDescriptorSet,
+// and the decoded data follows the protobuf JSON mapping.
+func NewProtobufDecoder(descriptorSet []byte, message string) (BinaryDecoder, error) {
+	md, err := FindMessageDescriptor(descriptorSet, message)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufDecoder{md: md}, nil
+}
+
+func (d *protobufDecoder) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (d *protobufDecoder) Decode(data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(d.md)
+	err := proto.Unmarshal(data, msg)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf message(%s) unmarshal err: %w", d.md.FullName(), err)
+	}
+	bs, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	// protojson randomizes the whitespaces
+	err = (*jsontext.Value)(&bs).Compact()
+	return bs, err
+}
+
+type avroDecoder struct {
+	schema avro.Schema
+}
+
+// NewAvroDecoder decodes the data by the Avro schema in JSON. The unions are decoded as their values,
+// the bytes are base64 encoded and the timestamps are RFC 3339 strings.
+func NewAvroDecoder(spec string) (BinaryDecoder, error) {
+	schema, err := avro.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("avro schema parse err: %w", err)
+	}
+	return &avroDecoder{schema: schema}, nil
+}
+
+func (d *avroDecoder) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (d *avroDecoder) Decode(data []byte) ([]byte, error) {
+	if len(data) == 0 && d.schema.Type() != avro.Null {
+		return nil, errors.New("avro data is empty")
+	}
+	// avro.Unmarshal loses the error of the truncated data decoded into an interface
+	r := avro.NewReader(nil, 0)
+	r.Reset(data)
+	var val interface{}
+	r.ReadVal(d.schema, &val)
+	if r.Error != nil {
+		return nil, fmt.Errorf("avro data unmarshal err: %w", r.Error)
+	}
+	return json.Marshal(val, json.Deterministic(true))
+}
@@ -0,0 +1,190 @@
+package rule
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+)
+
+func orderDescriptorSet(t *testing.T) []byte {
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("order.proto"),
+				Package: proto.String("test.v1"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("Order"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							{
+								Name:     proto.String("name"),
+								JsonName: proto.String("name"),
+								Number:   proto.Int32(1),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+							},
+							{
+								Name:     proto.String("amount"),
+								JsonName: proto.String("amount"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	bs, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+const orderAvroSpec = `{"type":"record","name":"Order","fields":[` +
+	`{"name":"name","type":"string"},{"name":"amount","type":"long"}]}`
+
+func TestBinaryDecoder(t *testing.T) {
+	descriptorSet := orderDescriptorSet(t)
+	protobufDecoder, err := NewProtobufDecoder(descriptorSet, "test.v1.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	avroDecoder, err := NewAvroDecoder(orderAvroSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		decoder     BinaryDecoder
+		data        []byte
+		res         string
+		contentType string
+		valid       bool
+	}{
+		// Order{name: "test1", amount: 12}
+		{
+			decoder:     protobufDecoder,
+			data:        []byte{0x0a, 0x05, 't', 'e', 's', 't', '1', 0x10, 0x0c},
+			res:         `{"name":"test1","amount":12}`,
+			contentType: ContentTypeProtobuf,
+			valid:       true,
+		},
+		// the zero values are omitted
+		{
+			decoder:     protobufDecoder,
+			data:        []byte{},
+			res:         `{}`,
+			contentType: ContentTypeProtobuf,
+			valid:       true,
+		},
+		// the name is truncated
+		{
+			decoder:     protobufDecoder,
+			data:        []byte{0x0a, 0x05, 't', 'e'},
+			contentType: ContentTypeProtobuf,
+		},
+		{
+			decoder:     avroDecoder,
+			data:        []byte{0x0a, 't', 'e', 's', 't', '1', 0x18},
+			res:         `{"amount":12,"name":"test1"}`,
+			contentType: ContentTypeAvro,
+			valid:       true,
+		},
+		// the amount is missing
+		{
+			decoder:     avroDecoder,
+			data:        []byte{0x0a, 't', 'e', 's', 't', '1'},
+			contentType: ContentTypeAvro,
+		},
+		{
+			decoder:     avroDecoder,
+			data:        []byte{},
+			contentType: ContentTypeAvro,
+		},
+	}
+	for idx, tt := range tests {
+		if tt.decoder.ContentType() != tt.contentType {
+			t.Fatalf(
+				"case(index=%d) expect content type: %s, actual: %s", idx, tt.contentType, tt.decoder.ContentType(),
+			)
+		}
+		res, err := tt.decoder.Decode(tt.data)
+		if !tt.valid {
+			if err == nil {
+				t.Fatalf("case(index=%d) expect err, actual data: %s", idx, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if string(res) != tt.res {
+			t.Fatalf("case(index=%d) expect data: %s, actual: %s", idx, tt.res, res)
+		}
+	}
+
+	invalidDecoders := []func() (BinaryDecoder, error){
+		func() (BinaryDecoder, error) { return NewProtobufDecoder([]byte("invalid"), "test.v1.Order") },
+		func() (BinaryDecoder, error) { return NewProtobufDecoder(descriptorSet, "test.v1.NotExists") },
+		func() (BinaryDecoder, error) { return NewProtobufDecoder(descriptorSet, "test.v1.Order.name") },
+		func() (BinaryDecoder, error) { return NewAvroDecoder(`{"type":"record"}`) },
+	}
+	for idx, f := range invalidDecoders {
+		_, err = f()
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
+
+func TestEventExtDecodeEventData(t *testing.T) {
+	decoder, err := NewAvroDecoder(orderAvroSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := base64.StdEncoding.EncodeToString([]byte{0x0a, 't', 'e', 's', 't', '1', 0x18})
+	tests := []struct {
+		contentType string
+		data        string
+		valid       bool
+	}{
+		{contentType: ContentTypeAvro, data: order, valid: true},
+		{contentType: ContentTypeJSON, data: order},
+		{contentType: ContentTypeAvro, data: "not base64"},
+		{contentType: ContentTypeAvro, data: base64.StdEncoding.EncodeToString([]byte{0x0a})},
+	}
+	for idx, tt := range tests {
+		e := &EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Datacontenttype: tt.contentType, Data: tt.data},
+			},
+		}
+		err = e.DecodeEventData(decoder)
+		if !tt.valid {
+			if !v1.IsEventDataNotValid(err) {
+				t.Fatalf("case(index=%d) expect event data not valid err, actual: %v", idx, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		val, _ := e.GetFieldByPath([]string{"data", "name"})
+		if val != "test1" {
+			t.Fatalf("case(index=%d) data.name expect test1, actual %v", idx, val)
+		}
+
+		// re-encode the binary event data to JSON
+		e.SetJSONData(`{"name":"test2"}`)
+		if e.Event.Datacontenttype != ContentTypeJSON || e.DecodedData != "" || e.JSONData() != `{"name":"test2"}` {
+			t.Fatalf("case(index=%d) expect JSON data, actual event: %v", idx, e.EventExt)
+		}
+	}
+}
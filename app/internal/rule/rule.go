@@ -1,12 +1,14 @@
 package rule
 
 import (
+	"cmp"
 	"context"
 	"encoding/json/v2"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -29,12 +31,23 @@ const (
 	metricLabelEvent     = "event"
 	metricLabelOperation = "operation"
 	metricLabelResult    = "result"
+
+	// metricResultSkip is the result of the target skipped by its pattern.
+	metricResultSkip = "skip"
+	// metricResultThrottle is the result of the target event throttled by the target limits.
+	metricResultThrottle = "throttle"
+	// metricResultOpen is the result of the target event rejected by the open circuit breaker.
+	metricResultOpen = "open"
+	// metricResultPause is the result of the target event parked by the paused rule.
+	metricResultPause = "pause"
 )
 
 var (
 	errNoMatcherAvailable     = errors.New("no matcher available")
 	errNoTransformerAvailable = errors.New("no transformer available")
 	errNoDispatcherAvailable  = errors.New("no dispatcher available")
+	errThrottled              = errors.New("throttled")
+	errPaused                 = errors.New("paused")
 )
 
 type TargetParam struct {
@@ -44,11 +57,146 @@ type TargetParam struct {
 	Template *string
 }
 
+// Redaction removes or obscures the value at Path in the event data before it is transformed.
+// Action is one of DROP, MASK or HASH. KeepLast is the number of trailing characters
+// MASK leaves readable, and Key is the HMAC key used by HASH, which is encrypted when it is stored.
+type Redaction struct {
+	Path     string
+	Action   string
+	KeepLast uint32
+	Key      string
+
+	// HMACKey is the decrypted Key,
+	// it is resolved when the target is loaded rather than stored with the target.
+	HMACKey string `json:"-"`
+}
+
+// Content types of the target event data set by the output encoding.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+	ContentTypeXML  = "application/xml"
+	ContentTypeCSV  = "text/csv"
+	// ContentTypeProtobuf the binary message is base64 encoded in the event data.
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeAvro the binary Avro data is base64 encoded in the event data.
+	ContentTypeAvro = "application/avro"
+)
+
+// Encoding is the output encoding of the transformed payload.
+// Format is one of JSON, FORM, XML, CSV or PROTOBUF. If Field is set, only the value of
+// that field of the payload is encoded, such as the body of the HTTP dispatcher.
+// Root is the root element of XML, and Descriptor and Message are the registered
+// protobuf descriptor and the full name of the message to encode.
+type Encoding struct {
+	Format     string
+	Field      string
+	Root       string
+	Descriptor string
+	Message    string
+
+	// DescriptorSet is the serialized FileDescriptorSet of Descriptor,
+	// it is resolved when the target is loaded rather than stored with the target.
+	DescriptorSet []byte `json:"-"`
+}
+
+const (
+	AuthTypeAPIKey = "API_KEY"
+	AuthTypeBasic  = "BASIC"
+	AuthTypeOAuth2 = "OAUTH2_CLIENT_CREDENTIALS"
+	AuthTypeDSN    = "DSN"
+	AuthTypeTLS    = "TLS_CLIENT_CERT"
+)
+
+// Connection is the auth of the targets referencing it by name.
+// Key is the header name of API_KEY, the username of BASIC or the client ID of OAUTH2_CLIENT_CREDENTIALS,
+// and Secret is the API key, the password or the client secret respectively.
+// TokenURL and Scopes are only used by OAUTH2_CLIENT_CREDENTIALS.
+// DSN is the database of the SQL dispatcher, Key is the driver (mysql or postgres) and Secret is the DSN.
+// TLS_CLIENT_CERT is the client certificate of mTLS of the HTTP dispatcher,
+// Secret is the PEM encoded certificate chain followed by its private key.
+type Connection struct {
+	Name     string
+	AuthType string
+	Key      string
+	Secret   string
+	TokenURL string
+	Scopes   []string
+}
+
+// Signing signs the requests of the HTTP dispatcher by HMAC-SHA256, see package webhook.
+// Secrets are encrypted when they are stored, and at most two are active to rotate them.
+type Signing struct {
+	Secrets []string
+
+	// Keys are the decrypted Secrets,
+	// they are resolved when the target is loaded rather than stored with the target.
+	Keys []string `json:"-"`
+}
+
+// Batch delivers the target events in batches. A batch is dispatched once it has MaxSize events,
+// or the event data in it would exceed MaxBytes, or MaxLinger has passed since its first event.
+// MaxBytes 0 means unlimited.
+type Batch struct {
+	MaxSize   uint32
+	MaxBytes  uint32
+	MaxLinger time.Duration `json:",format:units"`
+}
+
+// HTTPClient configures the client of the HTTP dispatcher, it is built once with the dispatcher.
+// The client certificate of mTLS is provided by the TLS_CLIENT_CERT Connection of the target.
+type HTTPClient struct {
+	// Timeout 0 means no timeout.
+	Timeout time.Duration `json:",format:units"`
+	// SuccessCodes are the status codes like 200 or the ranges like 200-299, 200-299 by default.
+	SuccessCodes []string
+	TLS          *HTTPClientTLS
+	Proxy        string
+	// MaxConns limits the connections per host, 0 means unlimited.
+	MaxConns uint32
+	// MaxRedirects nil follows at most 10 redirects as the default client, and 0 doesn't follow.
+	MaxRedirects *uint32
+}
+
+// HTTPClientTLS verifies the server by CACert, or the system roots if it is empty.
+type HTTPClientTLS struct {
+	CACert     string
+	ServerName string
+	// InsecureSkipVerify skips verifying the server certificate, for development only.
+	InsecureSkipVerify bool
+}
+
+// OnSuccess publishes the response of the target event dispatched successfully as a new event
+// of Source and Type into the bus named BusName.
+type OnSuccess struct {
+	BusName string
+	Source  string
+	Type    string
+}
+
 type Target struct {
 	ID            uint64
 	Type          string
 	Params        []*TargetParam
 	RetryStrategy v1.RetryStrategy
+	Redactions    []*Redaction
+	Encoding      *Encoding
+	// Pattern is the optional pattern evaluated before transforming the event for the target,
+	// the target is skipped if the event does not match.
+	Pattern string
+	// Connection is the name of the connection whose credentials are injected when dispatching.
+	Connection string
+	Signing    *Signing
+	// MaxRequestsPerSecond and MaxConcurrency limit dispatching to the target, 0 means unlimited.
+	MaxRequestsPerSecond uint32
+	MaxConcurrency       uint32
+	Batch                *Batch
+	OnSuccess            *OnSuccess
+	HTTPClient           *HTTPClient
+
+	// Auth is the decrypted Connection,
+	// it is resolved when the target is loaded rather than stored with the target.
+	Auth *Connection `json:"-"`
 }
 
 type Rule struct {
@@ -68,6 +216,20 @@ type Dispatcher interface {
 	Dispatch(ctx context.Context, event *EventExt) error
 }
 
+// Limiter limits the rate and the concurrency of dispatching to a target.
+type Limiter interface {
+	// Acquire waits until the target event is allowed to be dispatched, and release must be called after dispatching.
+	// It gives up with the error created by NewThrottledError if the wait would exceed the deadline of ctx.
+	Acquire(ctx context.Context) (release func(), err error)
+}
+
+// BatchDispatcher dispatches a batch of target events as a whole, it fails if any of them fails,
+// unless it reports the error of each event by BatchError.
+type BatchDispatcher interface {
+	Dispatcher
+	DispatchBatch(ctx context.Context, events []*EventExt) error
+}
+
 type Transformer interface {
 	Transform(ctx context.Context, event *EventExt) (*EventExt, error)
 }
@@ -77,9 +239,13 @@ type Executor interface {
 	io.Closer
 	Dispatch(context.Context, *EventExt) error
 	Transform(ctx context.Context, event *EventExt) ([]*EventExt, error)
+	// TransformTarget transforms the event pending transformation for its target.
+	TransformTarget(ctx context.Context, event *EventExt) (*EventExt, error)
 	Update(context.Context, *Rule) error
 	IsFilterPatternEqual(filterPattern string) bool
 	IsTargetsEqual(Targets []*Target) bool
+	// Breakers returns the circuit breakers of the targets, it is empty if the breakers are disabled.
+	Breakers() []*BreakerStatus
 }
 
 type RulesManager interface {
@@ -90,12 +256,16 @@ type RulesManager interface {
 
 type Rules interface {
 	GetExecutors(busName string) (map[string]Executor, error)
+	// GetBreakers returns the circuit breakers of the targets of all the rules.
+	GetBreakers() []*BreakerStatus
 }
 
 type (
 	NewMatcherFunc     func(ctx context.Context, logger log.Logger, pattern map[string]interface{}) (Matcher, error)
 	NewTransformerFunc func(ctx context.Context, logger log.Logger, Target *Target) (Transformer, error)
 	NewDispatcherFunc  func(ctx context.Context, logger log.Logger, Target *Target) (Dispatcher, error)
+	// NewLimiterFunc key identifies the target among the rules of all the buses.
+	NewLimiterFunc func(ctx context.Context, logger log.Logger, key string, Target *Target) (Limiter, error)
 )
 
 // Option is a functional option for configuring the executor.
@@ -124,6 +294,30 @@ func WithTransformParallelism(parallelism int) Option {
 	}
 }
 
+// WithNewLimiterFunc enforces the limits of the targets by the limiters,
+// the limits are ignored if it isn't set.
+func WithNewLimiterFunc(f NewLimiterFunc) Option {
+	return func(o *options) {
+		o.newLimiterFunc = f
+	}
+}
+
+// WithCircuitBreaker opens the circuit breaker of the target after threshold consecutive dispatching failures,
+// and probes the target after openTimeout. The breakers are disabled if threshold is 0.
+func WithCircuitBreaker(threshold int, openTimeout time.Duration) Option {
+	return func(o *options) {
+		o.breakerThreshold = threshold
+		o.breakerOpenTimeout = openTimeout
+	}
+}
+
+// WithBreakerState with circuit breaker state gauge.
+func WithBreakerState(g metric.Int64Gauge) Option {
+	return func(o *options) {
+		o.breakerState = g
+	}
+}
+
 type options struct {
 	// histogram: job_rule_execute_duration_seconds_bucket{"name", "event", "operation"}
 	executeDuration metric.Float64Histogram
@@ -131,6 +325,13 @@ type options struct {
 	executeTotal metric.Int64Counter
 	// transformParallelism is the parallelism for transforming events.
 	transformParallelism int
+	// newLimiterFunc creates the limiter of the target with limits.
+	newLimiterFunc NewLimiterFunc
+	// breakerThreshold is the consecutive failures to open the circuit breaker, 0 disables the breakers.
+	breakerThreshold   int
+	breakerOpenTimeout time.Duration
+	// gauge: job_rule_breaker_state{"name"}
+	breakerState metric.Int64Gauge
 }
 
 type executor struct {
@@ -141,11 +342,15 @@ type executor struct {
 	opts     *options
 
 	pattern string
+	paused  bool
 	targets map[uint64]*Target
 
 	matcher      Matcher
-	transformers map[uint64]Transformer
+	transformers map[uint64]*wrapTransformer
 	dispatchers  map[uint64]Dispatcher
+	limiters     map[uint64]Limiter // only the targets with limits
+	breakers     map[uint64]*breaker
+	batchers     map[uint64]*batcher // only the targets with batch
 
 	newMatcherFunc     NewMatcherFunc
 	newTransformerFunc NewTransformerFunc
@@ -180,8 +385,11 @@ func NewNewExecutorFunc(
 			newTransformerFunc: ntf,
 			newDispatcherFunc:  ndf,
 			targets:            map[uint64]*Target{},
-			transformers:       map[uint64]Transformer{},
+			transformers:       map[uint64]*wrapTransformer{},
 			dispatchers:        map[uint64]Dispatcher{},
+			limiters:           map[uint64]Limiter{},
+			breakers:           map[uint64]*breaker{},
+			batchers:           map[uint64]*batcher{},
 		}
 		err := exec.Update(ctx, r)
 		if err != nil {
@@ -223,7 +431,7 @@ func (d *executor) Pattern(ctx context.Context, event *EventExt) (ok bool, err e
 }
 
 func (d *executor) Transform(ctx context.Context, event *EventExt) ([]*EventExt, error) {
-	transformers := make(map[uint64]Transformer)
+	transformers := make(map[uint64]*wrapTransformer)
 	d.RLock()
 	for tID, t := range d.transformers {
 		transformers[tID] = t
@@ -250,6 +458,9 @@ func (d *executor) Transform(ctx context.Context, event *EventExt) ([]*EventExt,
 			if err != nil {
 				return nil, err
 			}
+			if targetEvent == nil { // skipped by the target pattern
+				return []*EventExt{}, nil
+			}
 			return []*EventExt{targetEvent}, nil
 		}
 	}
@@ -266,6 +477,9 @@ func (d *executor) Transform(ctx context.Context, event *EventExt) ([]*EventExt,
 			if err != nil {
 				return err
 			}
+			if targetEvent == nil { // skipped by the target pattern
+				return nil
+			}
 			targetEventsLock.Lock()
 			targetEvents = append(targetEvents, targetEvent)
 			targetEventsLock.Unlock()
@@ -283,6 +497,7 @@ func (d *executor) Transform(ctx context.Context, event *EventExt) ([]*EventExt,
 
 type wrapTransformer struct {
 	transformer   Transformer
+	matcher       Matcher // nil if the target has no pattern
 	executeTotal  metric.Int64Counter
 	busName       string
 	ruleName      string
@@ -290,7 +505,30 @@ type wrapTransformer struct {
 	retryStrategy v1.RetryStrategy
 }
 
+// Transform returns nil if the event does not match the target pattern.
+// It defers the transformation to the retry queue if it failed with a retryable error,
+// the returned event is the source event marked as pending transformation for the target.
 func (t *wrapTransformer) Transform(ctx context.Context, event *EventExt) (*EventExt, error) {
+	if t.matcher != nil {
+		ok, err := t.pattern(ctx, event)
+		if err != nil {
+			return nil, fmt.Errorf("match target(id: %d) pattern err: %w", t.targetID, err)
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+	evt, err := t.transformTarget(ctx, event)
+	if err != nil && IsRetryableError(err) {
+		evt = CloneEventExt(event)
+		t.setTarget(evt)
+		evt.PendingTransform = true
+		return evt, nil
+	}
+	return evt, err
+}
+
+func (t *wrapTransformer) transformTarget(ctx context.Context, event *EventExt) (*EventExt, error) {
 	newEvt := CloneEventExt(event)
 	evt, err := t.transformer.Transform(ctx, newEvt)
 	if err != nil {
@@ -318,17 +556,59 @@ func (t *wrapTransformer) Transform(ctx context.Context, event *EventExt) (*Even
 			),
 		)
 	}
+	t.setTarget(evt)
+	evt.PendingTransform = false
+	return evt, nil
+}
+
+func (t *wrapTransformer) pattern(ctx context.Context, event *EventExt) (ok bool, err error) {
+	if t.executeTotal != nil {
+		defer func() {
+			res := "ok"
+			if !ok {
+				res = metricResultSkip
+			}
+			if err != nil {
+				res = fmt.Sprintf("%T", err)
+			}
+			t.executeTotal.Add(
+				ctx, 1,
+				metric.WithAttributes(
+					attribute.String(metricLabelRuleName, fmt.Sprintf("%s:%s:%d", t.busName, t.ruleName, t.targetID)),
+					attribute.String(metricLabelEvent, fmt.Sprintf("%s:%s", event.Event.Source, event.Event.Type)),
+					attribute.String(metricLabelOperation, "Pattern"),
+					attribute.String(metricLabelResult, res),
+				),
+			)
+		}()
+	}
+	return t.matcher.Pattern(ctx, event)
+}
+
+func (t *wrapTransformer) setTarget(evt *EventExt) {
 	evt.TargetId = t.targetID
 	evt.RuleName = t.ruleName
 	if t.retryStrategy != v1.RetryStrategy_RETRY_STRATEGY_UNSPECIFIED { // override event's retry strategy
 		evt.RetryStrategy = t.retryStrategy
 	}
-	return evt, nil
+}
+
+func (d *executor) TransformTarget(ctx context.Context, event *EventExt) (*EventExt, error) {
+	d.RLock()
+	transformer, ok := d.transformers[event.TargetId]
+	d.RUnlock()
+	if !ok {
+		return nil, errNoTransformerAvailable
+	}
+	return transformer.transformTarget(ctx, event)
 }
 
 func (d *executor) Dispatch(ctx context.Context, event *EventExt) (err error) {
 	var dispatcher Dispatcher
 	var dispatcherExist bool
+	var limiter Limiter
+	var cb *breaker
+	var b *batcher
 	if d.opts.executeDuration != nil {
 		startTime := time.Now()
 		defer func() {
@@ -344,11 +624,21 @@ func (d *executor) Dispatch(ctx context.Context, event *EventExt) (err error) {
 	}
 	d.RLock()
 	dispatcher, dispatcherExist = d.dispatchers[event.TargetId]
+	limiter = d.limiters[event.TargetId]
+	cb = d.breakers[event.TargetId]
+	b = d.batchers[event.TargetId]
+	paused := d.paused
 	d.RUnlock()
 	if d.opts.executeTotal != nil {
 		defer func() {
 			res := "ok"
-			if err != nil {
+			if IsThrottledError(err) {
+				res = metricResultThrottle
+			} else if IsPausedError(err) {
+				res = metricResultPause
+			} else if IsBreakerOpenError(err) {
+				res = metricResultOpen
+			} else if err != nil {
 				res = fmt.Sprintf("%T", err)
 			}
 			d.opts.executeTotal.Add(
@@ -365,7 +655,40 @@ func (d *executor) Dispatch(ctx context.Context, event *EventExt) (err error) {
 	if !dispatcherExist {
 		return errNoDispatcherAvailable
 	}
-	return dispatcher.Dispatch(ctx, event)
+	if paused {
+		return fmt.Errorf("rule(%s:%s) %w", d.busName, d.ruleName, errPaused)
+	}
+	if b != nil {
+		return b.add(ctx, event)
+	}
+	return guardDispatch(ctx, event.TargetId, cb, limiter, func(ctx context.Context) error {
+		return dispatcher.Dispatch(ctx, event)
+	})
+}
+
+// guardDispatch dispatches by f under the circuit breaker and the limiter of the target,
+// f dispatches an event or a batch of events.
+func guardDispatch(
+	ctx context.Context, targetID uint64, cb *breaker, limiter Limiter, f func(ctx context.Context) error,
+) (err error) {
+	if cb != nil {
+		done, ok := cb.allow()
+		if !ok {
+			return fmt.Errorf("target(id: %d) %w", targetID, errBreakerOpen)
+		}
+		defer func() {
+			done(err)
+		}()
+	}
+	if limiter != nil {
+		var release func()
+		release, err = limiter.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	return f(ctx)
 }
 
 func IsMatcherNotFound(err error) bool {
@@ -380,15 +703,79 @@ func IsDispatcherNotFound(err error) bool {
 	return errors.Is(err, errNoDispatcherAvailable)
 }
 
+// NewRetryableError marks err as a transient failure, the event will be retried through the retry queue.
+func NewRetryableError(err error) error {
+	return &retryableError{
+		error: err,
+	}
+}
+
+type retryableError struct {
+	error
+}
+
+func (re *retryableError) Unwrap() error {
+	return re.error
+}
+
+func IsRetryableError(err error) bool {
+	var target *retryableError
+	return errors.As(err, &target)
+}
+
+// NewThrottledError reports that the target event isn't dispatched because of the target limits,
+// it is deferred through the retry queue rather than counted as a failure.
+func NewThrottledError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errThrottled, fmt.Sprintf(format, args...))
+}
+
+func IsThrottledError(err error) bool {
+	return errors.Is(err, errThrottled)
+}
+
+// IsPausedError reports that the target event isn't dispatched because its rule is paused,
+// it is parked in the retry queue until the rule is resumed rather than counted as a failure.
+func IsPausedError(err error) bool {
+	return errors.Is(err, errPaused)
+}
+
+// NewResponseNotPostedError reports that the target event is dispatched but its response isn't posted,
+// the event keeps the response and is retried through the retry queue without dispatching it again.
+func NewResponseNotPostedError(err error) error {
+	return &responseNotPostedError{
+		error: err,
+	}
+}
+
+type responseNotPostedError struct {
+	error
+}
+
+func (re *responseNotPostedError) Unwrap() error {
+	return re.error
+}
+
+func IsResponseNotPostedError(err error) bool {
+	var target *responseNotPostedError
+	return errors.As(err, &target)
+}
+
 func (d *executor) Close() error {
 	d.Lock()
 	d.matcher = nil
 	d.pattern = ""
-	d.transformers = map[uint64]Transformer{}
+	d.transformers = map[uint64]*wrapTransformer{}
 	dispatchers := d.dispatchers
 	d.dispatchers = map[uint64]Dispatcher{}
+	d.limiters = map[uint64]Limiter{}
+	d.breakers = map[uint64]*breaker{}
+	batchers := d.batchers
+	d.batchers = map[uint64]*batcher{}
 	d.targets = map[uint64]*Target{}
 	d.Unlock()
+	for _, b := range batchers {
+		b.close()
+	}
 	errs := make([]error, 0, len(dispatchers))
 	for _, dispatcher := range dispatchers {
 		if err := dispatcher.Close(); err != nil {
@@ -447,6 +834,7 @@ func (d *executor) Update(ctx context.Context, r *Rule) (err error) {
 	}()
 	d.Lock()
 	defer d.Unlock()
+	d.paused = r.Status == v1.RuleStatus_RULE_STATUS_PAUSED
 	if d.pattern != r.Pattern {
 		parsedPattern := make(map[string]interface{})
 		err = json.Unmarshal([]byte(r.Pattern), &parsedPattern)
@@ -467,6 +855,15 @@ func (d *executor) Update(ctx context.Context, r *Rule) (err error) {
 			delete(d.transformers, id)
 			closeDispatchers = append(closeDispatchers, d.dispatchers[id])
 			delete(d.dispatchers, id)
+			delete(d.limiters, id)
+			if _, ok = d.breakers[id]; ok {
+				d.recordBreakerState(id, BreakerClosed)
+				delete(d.breakers, id)
+			}
+			if b, ok := d.batchers[id]; ok {
+				b.close()
+				delete(d.batchers, id)
+			}
 			delete(d.targets, id)
 		}
 	}
@@ -478,8 +875,21 @@ func (d *executor) Update(ctx context.Context, r *Rule) (err error) {
 			if err != nil {
 				return err
 			}
+			var targetMatcher Matcher
+			if t.Pattern != "" {
+				parsedPattern := make(map[string]interface{})
+				err = json.Unmarshal([]byte(t.Pattern), &parsedPattern)
+				if err != nil {
+					return err
+				}
+				targetMatcher, err = d.newMatcherFunc(ctx, d.baseLog, parsedPattern)
+				if err != nil {
+					return err
+				}
+			}
 			d.transformers[id] = &wrapTransformer{
 				transformer:   transformer,
+				matcher:       targetMatcher,
 				executeTotal:  d.opts.executeTotal,
 				busName:       d.busName,
 				ruleName:      d.ruleName,
@@ -496,11 +906,78 @@ func (d *executor) Update(ctx context.Context, r *Rule) (err error) {
 				closeDispatchers = append(closeDispatchers, oldDispatcher)
 			}
 			d.dispatchers[id] = dispatcher
+			delete(d.limiters, id)
+			if d.opts.newLimiterFunc != nil && (t.MaxRequestsPerSecond > 0 || t.MaxConcurrency > 0) {
+				var limiter Limiter
+				limiter, err = d.opts.newLimiterFunc(
+					ctx, d.baseLog, fmt.Sprintf("%s:%s:%d", d.busName, d.ruleName, id), t,
+				)
+				if err != nil {
+					return err
+				}
+				d.limiters[id] = limiter
+			}
+			if d.opts.breakerThreshold > 0 {
+				d.breakers[id] = newBreaker(d.opts.breakerThreshold, d.opts.breakerOpenTimeout, func(state BreakerState) {
+					d.recordBreakerState(id, state)
+				})
+				d.recordBreakerState(id, BreakerClosed)
+			}
+			if b, ok := d.batchers[id]; ok {
+				b.close()
+				delete(d.batchers, id)
+			}
+			if t.Batch != nil {
+				bd, ok := dispatcher.(BatchDispatcher)
+				if !ok {
+					return fmt.Errorf("target(id: %d) type %s doesn't support batch", id, t.Type)
+				}
+				limiter, cb := d.limiters[id], d.breakers[id]
+				d.batchers[id] = newBatcher(t.Batch, func(ctx context.Context, events []*EventExt) error {
+					return guardDispatch(ctx, id, cb, limiter, func(ctx context.Context) error {
+						return bd.DispatchBatch(ctx, events)
+					})
+				})
+			}
 		}
 	}
 	return nil
 }
 
+func (d *executor) recordBreakerState(targetID uint64, state BreakerState) {
+	if d.opts.breakerState == nil {
+		return
+	}
+	d.opts.breakerState.Record(
+		context.Background(), int64(state),
+		metric.WithAttributes(
+			attribute.String(metricLabelRuleName, fmt.Sprintf("%s:%s:%d", d.busName, d.ruleName, targetID)),
+		),
+	)
+}
+
+func (d *executor) Breakers() []*BreakerStatus {
+	d.RLock()
+	breakers := make(map[uint64]*breaker, len(d.breakers))
+	for id, b := range d.breakers {
+		breakers[id] = b
+	}
+	d.RUnlock()
+	statuses := make([]*BreakerStatus, 0, len(breakers))
+	for id, b := range breakers {
+		state, failures, openUntil := b.status()
+		statuses = append(statuses, &BreakerStatus{
+			BusName:   d.busName,
+			RuleName:  d.ruleName,
+			TargetID:  id,
+			State:     state.String(),
+			Failures:  failures,
+			OpenUntil: openUntil,
+		})
+	}
+	return statuses
+}
+
 func (d *executor) IsFilterPatternEqual(filterPattern string) bool {
 	d.RLock()
 	pattern := d.pattern
@@ -588,6 +1065,25 @@ func (rs *rules) GetExecutors(busName string) (map[string]Executor, error) {
 	return nil, nil
 }
 
+// GetBreakers the breakers are sorted by the bus name, the rule name and the target id.
+func (rs *rules) GetBreakers() []*BreakerStatus {
+	statuses := make([]*BreakerStatus, 0)
+	rs.executors.Range(func(_, value interface{}) bool {
+		for _, e := range value.(map[string]Executor) {
+			statuses = append(statuses, e.Breakers()...)
+		}
+		return true
+	})
+	slices.SortFunc(statuses, func(a, b *BreakerStatus) int {
+		return cmp.Or(
+			cmp.Compare(a.BusName, b.BusName),
+			cmp.Compare(a.RuleName, b.RuleName),
+			cmp.Compare(a.TargetID, b.TargetID),
+		)
+	})
+	return statuses
+}
+
 func (rs *rules) updateRule(r *Rule) error {
 	v, ok := rs.executors.Load(r.BusName)
 	if !ok { // add
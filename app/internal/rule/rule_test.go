@@ -1,9 +1,14 @@
 package rule
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"slices"
 	"testing"
 
+	"github.com/go-kratos/kratos/v2/log"
+
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 )
 
@@ -299,3 +304,283 @@ func TestRuleComparison(t *testing.T) {
 		})
 	}
 }
+
+type retryTransformer struct {
+	fails int
+}
+
+func (rt *retryTransformer) Transform(_ context.Context, event *EventExt) (*EventExt, error) {
+	if rt.fails > 0 {
+		rt.fails--
+		event.Event.Data = "partially transformed"
+		return nil, NewRetryableError(errors.New("enrich source unavailable"))
+	}
+	event.Event.Data = `{"transformed":true}`
+	return event, nil
+}
+
+type nopDispatcher struct{}
+
+func (nopDispatcher) Dispatch(_ context.Context, _ *EventExt) error { return nil }
+
+func (nopDispatcher) Close() error { return nil }
+
+func TestTransformRetryable(t *testing.T) {
+	rt := &retryTransformer{fails: 1}
+	nef := NewNewExecutorFunc(
+		func(_ context.Context, _ log.Logger, _ map[string]interface{}) (Matcher, error) {
+			return nil, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Transformer, error) {
+			return rt, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Dispatcher, error) {
+			return nopDispatcher{}, nil
+		},
+	)
+	exec, err := nef(context.Background(), log.DefaultLogger, &Rule{
+		Name:    "rule1",
+		BusName: "bus1",
+		Pattern: `{}`,
+		Targets: []*Target{{ID: 1, RetryStrategy: v1.RetryStrategy_RETRY_STRATEGY_BACKOFF}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &EventExt{
+		EventExt: &v1.EventExt{
+			Event:   &v1.Event{Id: 1, Data: `{"a":1}`},
+			BusName: "bus1",
+		},
+	}
+
+	// the failed transformation is deferred with the source event
+	evts, err := exec.Transform(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evts) != 1 {
+		t.Fatalf("expect 1 event, got %d", len(evts))
+	}
+	pending := evts[0]
+	if !pending.PendingTransform || pending.TargetId != 1 || pending.RuleName != "rule1" ||
+		pending.RetryStrategy != v1.RetryStrategy_RETRY_STRATEGY_BACKOFF || pending.Event.Data != `{"a":1}` {
+		t.Fatalf("unexpected pending event: %v", pending.EventExt)
+	}
+
+	// transform again when it is consumed from the retry queue
+	evt, err := exec.TransformTarget(context.Background(), pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.PendingTransform || evt.Event.Data != `{"transformed":true}` {
+		t.Fatalf("unexpected target event: %v", evt.EventExt)
+	}
+
+	_, err = exec.TransformTarget(context.Background(), &EventExt{
+		EventExt: &v1.EventExt{Event: &v1.Event{Id: 1}, TargetId: 2},
+	})
+	if !IsTransformerNotFound(err) {
+		t.Fatalf("expect transformer not found, got: %v", err)
+	}
+}
+
+type sourceMatcher struct {
+	source string
+}
+
+func (m *sourceMatcher) Pattern(_ context.Context, event *EventExt) (bool, error) {
+	return event.Event.Source == m.source, nil
+}
+
+type idTransformer struct{}
+
+func (idTransformer) Transform(_ context.Context, event *EventExt) (*EventExt, error) {
+	return event, nil
+}
+
+func TestTargetPattern(t *testing.T) {
+	nef := NewNewExecutorFunc(
+		func(_ context.Context, _ log.Logger, pattern map[string]interface{}) (Matcher, error) {
+			source, _ := pattern["source"].(string)
+			return &sourceMatcher{source: source}, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Transformer, error) {
+			return idTransformer{}, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Dispatcher, error) {
+			return nopDispatcher{}, nil
+		},
+	)
+	exec, err := nef(context.Background(), log.DefaultLogger, &Rule{
+		Name:    "rule1",
+		BusName: "bus1",
+		Pattern: `{}`,
+		Targets: []*Target{
+			{ID: 1},
+			{ID: 2, Pattern: `{"source":"critical"}`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := []struct {
+		source  string
+		targets []uint64
+	}{
+		{source: "critical", targets: []uint64{1, 2}},
+		{source: "info", targets: []uint64{1}},
+	}
+	for _, run := range runs {
+		evts, err := exec.Transform(context.Background(), &EventExt{
+			EventExt: &v1.EventExt{
+				Event:   &v1.Event{Id: 1, Source: run.source},
+				BusName: "bus1",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets := make([]uint64, 0, len(evts))
+		for _, evt := range evts {
+			targets = append(targets, evt.TargetId)
+		}
+		slices.Sort(targets)
+		if !reflect.DeepEqual(targets, run.targets) {
+			t.Fatalf("source(%s) expect targets: %v, actual: %v", run.source, run.targets, targets)
+		}
+	}
+}
+
+type countLimiter struct {
+	throttle bool
+	acquired int
+	released int
+}
+
+func (l *countLimiter) Acquire(_ context.Context) (func(), error) {
+	if l.throttle {
+		return nil, NewThrottledError("target(id: %d) exceeds %d concurrent requests", 1, 1)
+	}
+	l.acquired++
+	return func() { l.released++ }, nil
+}
+
+func TestTargetLimiter(t *testing.T) {
+	limiter := &countLimiter{}
+	keys := make([]string, 0)
+	nef := NewNewExecutorFunc(
+		func(_ context.Context, _ log.Logger, _ map[string]interface{}) (Matcher, error) {
+			return nil, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Transformer, error) {
+			return idTransformer{}, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Dispatcher, error) {
+			return nopDispatcher{}, nil
+		},
+	)
+	exec, err := nef(context.Background(), log.DefaultLogger, &Rule{
+		Name:    "rule1",
+		BusName: "bus1",
+		Pattern: `{}`,
+		Targets: []*Target{
+			{ID: 1, MaxConcurrency: 1},
+			{ID: 2},
+		},
+	}, WithNewLimiterFunc(func(_ context.Context, _ log.Logger, key string, _ *Target) (Limiter, error) {
+		keys = append(keys, key)
+		return limiter, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"bus1:rule1:1"}) {
+		t.Fatalf("expect only the limited target has a limiter, got: %v", keys)
+	}
+
+	dispatch := func(targetID uint64) error {
+		return exec.Dispatch(context.Background(), &EventExt{
+			EventExt: &v1.EventExt{
+				Event:    &v1.Event{Id: 1},
+				BusName:  "bus1",
+				RuleName: "rule1",
+				TargetId: targetID,
+			},
+		})
+	}
+	if err = dispatch(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = dispatch(2); err != nil {
+		t.Fatal(err)
+	}
+	if limiter.acquired != 1 || limiter.released != 1 {
+		t.Fatalf("expect acquired and released once, got: %d, %d", limiter.acquired, limiter.released)
+	}
+
+	limiter.throttle = true
+	if err = dispatch(1); !IsThrottledError(err) {
+		t.Fatalf("expect throttled, got: %v", err)
+	}
+}
+
+func TestPausedRule(t *testing.T) {
+	fd := &failDispatcher{}
+	nef := NewNewExecutorFunc(
+		func(_ context.Context, _ log.Logger, _ map[string]interface{}) (Matcher, error) {
+			return nil, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Transformer, error) {
+			return idTransformer{}, nil
+		},
+		func(_ context.Context, _ log.Logger, _ *Target) (Dispatcher, error) {
+			return fd, nil
+		},
+	)
+	r := &Rule{
+		Name:    "rule1",
+		BusName: "bus1",
+		Status:  v1.RuleStatus_RULE_STATUS_PAUSED,
+		Pattern: `{}`,
+		Targets: []*Target{{ID: 1}},
+	}
+	exec, err := nef(context.Background(), log.DefaultLogger, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatch := func() error {
+		return exec.Dispatch(context.Background(), &EventExt{
+			EventExt: &v1.EventExt{
+				Event:    &v1.Event{Id: 1},
+				BusName:  "bus1",
+				RuleName: "rule1",
+				TargetId: 1,
+			},
+		})
+	}
+	if err = dispatch(); !IsPausedError(err) {
+		t.Fatalf("expect paused, got: %v", err)
+	}
+	if fd.calls != 0 {
+		t.Fatalf("expect the paused target not dispatched, got calls: %d", fd.calls)
+	}
+
+	// resume
+	err = exec.Update(context.Background(), &Rule{
+		Name:    r.Name,
+		BusName: r.BusName,
+		Status:  v1.RuleStatus_RULE_STATUS_ENABLE,
+		Pattern: r.Pattern,
+		Targets: r.Targets,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dispatch(); err == nil || IsPausedError(err) {
+		t.Fatalf("expect dispatched after resumed, got: %v", err)
+	}
+	if fd.calls != 1 {
+		t.Fatalf("expect dispatched once, got calls: %d", fd.calls)
+	}
+}
@@ -0,0 +1,245 @@
+package rule
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"strings"
+)
+
+const sampleMaxDepth = 16
+
+// sampleStringFormats are the sample values of the well-known string formats.
+var sampleStringFormats = map[string]string{
+	"date-time": "2020-08-17T16:04:46.149Z",
+	"date":      "2020-08-17",
+	"time":      "16:04:46Z",
+	"email":     "user@example.com",
+	"hostname":  "example.com",
+	"ipv4":      "10.0.0.1",
+	"ipv6":      "::1",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"uuid":      "00000000-0000-4000-8000-000000000000",
+}
+
+// NewSampleData generates a representative event data from the JSON schema.
+// Every property of the object is present, and const, enum, default and examples are used if defined.
+// The value is not guaranteed to be valid, e.g. the pattern of the string is ignored,
+// so validate it by the schema if necessary.
+func NewSampleData(spec string) (string, error) {
+	var root interface{}
+	err := json.Unmarshal([]byte(spec), &root)
+	if err != nil {
+		return "", fmt.Errorf("schema unmarshal err: %w", err)
+	}
+	val, err := sampleValue(root, root, 0)
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(val, json.Deterministic(true))
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func sampleValue(root interface{}, node interface{}, depth int) (interface{}, error) {
+	if depth > sampleMaxDepth {
+		return nil, nil
+	}
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		// true or false schema
+		return nil, nil
+	}
+	if ref, isStr := schema["$ref"].(string); isStr {
+		resolved, err := sampleResolveRef(root, ref)
+		if err != nil {
+			return nil, err
+		}
+		return sampleValue(root, resolved, depth+1)
+	}
+	if val, exist := schema["const"]; exist {
+		return val, nil
+	}
+	if enum, isArr := schema["enum"].([]interface{}); isArr && len(enum) > 0 {
+		return enum[0], nil
+	}
+	if val, exist := schema["default"]; exist {
+		return val, nil
+	}
+	if examples, isArr := schema["examples"].([]interface{}); isArr && len(examples) > 0 {
+		return examples[0], nil
+	}
+	if allOf, isArr := schema["allOf"].([]interface{}); isArr && len(allOf) > 0 {
+		return sampleAllOf(root, schema, allOf, depth)
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if subs, isArr := schema[key].([]interface{}); isArr && len(subs) > 0 {
+			return sampleValue(root, subs[0], depth+1)
+		}
+	}
+
+	switch sampleType(schema) {
+	case "object":
+		obj := make(map[string]interface{})
+		props, _ := schema["properties"].(map[string]interface{})
+		for key, prop := range props {
+			val, err := sampleValue(root, prop, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = val
+		}
+		return obj, nil
+	case "array":
+		arr := make([]interface{}, 0)
+		// prefixItems of draft 2020-12 or the array form of items of the earlier drafts
+		prefix, isArr := schema["prefixItems"].([]interface{})
+		if !isArr {
+			prefix, _ = schema["items"].([]interface{})
+		}
+		for _, item := range prefix {
+			val, err := sampleValue(root, item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		if items, isObj := schema["items"].(map[string]interface{}); isObj {
+			n := max(sampleNumber(schema, "minItems", 1), 1)
+			for range int(n) - len(arr) {
+				val, err := sampleValue(root, items, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+		}
+		return arr, nil
+	case "string":
+		return sampleString(schema), nil
+	case "integer":
+		return int64(sampleNumeric(schema, 1)), nil
+	case "number":
+		return sampleNumeric(schema, 0.5), nil
+	case "boolean":
+		return true, nil
+	default:
+		return nil, nil
+	}
+}
+
+// sampleType returns the first non-null type of the schema, or the type inferred by its keywords.
+func sampleType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				return s
+			}
+		}
+		return "null"
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return ""
+}
+
+// sampleAllOf merges the objects of the sub-schemas, the last one wins if any of them is not an object.
+func sampleAllOf(
+	root interface{}, schema map[string]interface{}, allOf []interface{}, depth int,
+) (interface{}, error) {
+	rest := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		if k != "allOf" {
+			rest[k] = v
+		}
+	}
+	var res interface{}
+	for _, sub := range append([]interface{}{rest}, allOf...) {
+		val, err := sampleValue(root, sub, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		obj, isObj := val.(map[string]interface{})
+		resObj, isResObj := res.(map[string]interface{})
+		switch {
+		case isObj && isResObj:
+			for k, v := range obj {
+				resObj[k] = v
+			}
+		case val != nil:
+			res = val
+		}
+	}
+	return res, nil
+}
+
+// sampleResolveRef resolves the JSON pointer in the schema, only local references are supported.
+func sampleResolveRef(root interface{}, ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("schema ref(%s) is not supported", ref)
+	}
+	node := root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema ref(%s) not found", ref)
+		}
+		node, ok = obj[token]
+		if !ok {
+			return nil, fmt.Errorf("schema ref(%s) not found", ref)
+		}
+	}
+	return node, nil
+}
+
+func sampleString(schema map[string]interface{}) string {
+	format, _ := schema["format"].(string)
+	s, ok := sampleStringFormats[format]
+	if !ok {
+		s = "string"
+	}
+	if minLen := int(sampleNumber(schema, "minLength", 0)); len(s) < minLen {
+		s += strings.Repeat("s", minLen-len(s))
+	}
+	if maxLen, exist := schema["maxLength"].(float64); exist && len(s) > int(maxLen) {
+		s = s[:int(maxLen)]
+	}
+	return s
+}
+
+// sampleNumeric returns a number in the range of the schema, step is the distance from the exclusive bound.
+func sampleNumeric(schema map[string]interface{}, step float64) float64 {
+	if v, ok := schema["minimum"].(float64); ok {
+		return v
+	}
+	if v, ok := schema["exclusiveMinimum"].(float64); ok {
+		return v + step
+	}
+	if v, ok := schema["maximum"].(float64); ok && v < 0 {
+		return v
+	}
+	if v, ok := schema["exclusiveMaximum"].(float64); ok && v <= 0 {
+		return v - step
+	}
+	return 0
+}
+
+func sampleNumber(schema map[string]interface{}, key string, defaultVal float64) float64 {
+	if v, ok := schema[key].(float64); ok {
+		return v
+	}
+	return defaultVal
+}
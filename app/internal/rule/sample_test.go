@@ -0,0 +1,77 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestNewSampleData(t *testing.T) {
+	sampleTests := []struct {
+		spec string
+		res  string
+	}{
+		{
+			spec: `{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "minLength": 8},
+    "code": {"type": "string", "maxLength": 3},
+    "amount": {"type": "integer", "exclusiveMinimum": 10},
+    "ratio": {"type": ["null", "number"]},
+    "paid": {"type": "boolean"},
+    "time": {"type": "string", "format": "date-time"},
+    "level": {"enum": ["high", "low"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "minItems": 2},
+    "address": {"$ref": "#/$defs/address"}
+  },
+  "required": ["name"],
+  "$defs": {
+    "address": {"properties": {"city": {"type": "string", "default": "x"}}}
+  }
+}`,
+			res: `{"address":{"city":"x"},"amount":11,"code":"str","level":"high","name":"stringss","paid":true,` +
+				`"ratio":0,"tags":["string","string"],"time":"2020-08-17T16:04:46.149Z"}`,
+		},
+		{
+			spec: `{"allOf": [{"properties": {"a": {"const": 1}}}, {"properties": {"b": {"examples": ["e"]}}}]}`,
+			res:  `{"a":1,"b":"e"}`,
+		},
+		{
+			spec: `{"oneOf": [{"type": "array", "prefixItems": [{"type": "number", "minimum": 2}]}, {"type": "string"}]}`,
+			res:  `[2]`,
+		},
+	}
+	for idx, tt := range sampleTests {
+		res, err := NewSampleData(tt.spec)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if res != tt.res {
+			t.Fatalf("case(index=%d) expect: %s, actual: %s", idx, tt.res, res)
+		}
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(tt.spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := schema.Validate(gojsonschema.NewStringLoader(res))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid() {
+			t.Fatalf("case(index=%d) sample is not valid: %s", idx, result.Errors())
+		}
+	}
+
+	invalidSpecs := []string{
+		`not json`,
+		`{"properties": {"a": {"$ref": "#/$defs/notExists"}}}`,
+		`{"properties": {"a": {"$ref": "https://example.com/schema.json"}}}`,
+	}
+	for idx, spec := range invalidSpecs {
+		_, err := NewSampleData(spec)
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package target
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	oauth2DefaultTokenTTL = time.Hour
+	// oauth2ExpiryDelta refreshes the token a little earlier than its expiry
+	oauth2ExpiryDelta   = 10 * time.Second
+	oauth2TokenTimeout  = 5 * time.Second
+	oauth2MaxRespBytes  = 1 << 20
+	authorizationHeader = "Authorization"
+)
+
+var newAuthenticatorFunctions = map[string]newAuthenticatorFunc{}
+
+type newAuthenticatorFunc func(conn *rule.Connection) (authenticator, error)
+
+// authenticator provides the header carrying the credentials of a connection.
+type authenticator interface {
+	header(ctx context.Context) (key string, value string, err error)
+	// invalidate drops the cached credentials after the target rejects them.
+	invalidate()
+}
+
+func init() {
+	registerAuthenticator(rule.AuthTypeAPIKey, newAPIKeyAuthenticator)
+	registerAuthenticator(rule.AuthTypeBasic, newBasicAuthenticator)
+	registerAuthenticator(rule.AuthTypeOAuth2, newOAuth2Authenticator)
+}
+
+// registerAuthenticator register authenticator,
+// note that this function cannot be called in more than one goroutine.
+// recommended for use in func init() only
+func registerAuthenticator(authType string, newFunc newAuthenticatorFunc) {
+	newAuthenticatorFunctions[authType] = newFunc
+}
+
+// newAuthenticator returns nil if the target doesn't reference a connection.
+func newAuthenticator(target *rule.Target) (authenticator, error) {
+	if target.Connection == "" {
+		return nil, nil
+	}
+	if target.Auth == nil {
+		return nil, fmt.Errorf("connection(%s) not found", target.Connection)
+	}
+	if target.Auth.AuthType == rule.AuthTypeDSN {
+		return nil, fmt.Errorf("connection(%s) of DSN can only be used by SQLDispatcher", target.Connection)
+	}
+	if target.Auth.AuthType == rule.AuthTypeTLS {
+		return nil, fmt.Errorf("connection(%s) of TLS_CLIENT_CERT can only be used by HTTPDispatcher", target.Connection)
+	}
+	newFunc, ok := newAuthenticatorFunctions[target.Auth.AuthType]
+	if !ok {
+		return nil, fmt.Errorf("connection(%s) unknown auth type: %s", target.Connection, target.Auth.AuthType)
+	}
+	return newFunc(target.Auth)
+}
+
+// ConnectionSyntaxCheck checks the auth type and the required fields of the connection.
+func ConnectionSyntaxCheck(conn *rule.Connection) error {
+	if conn.AuthType == rule.AuthTypeDSN {
+		return dsnSyntaxCheck(conn)
+	}
+	if conn.AuthType == rule.AuthTypeTLS {
+		return tlsClientCertSyntaxCheck(conn)
+	}
+	newFunc, ok := newAuthenticatorFunctions[conn.AuthType]
+	if !ok {
+		return fmt.Errorf("unknown auth type: %s", conn.AuthType)
+	}
+	_, err := newFunc(conn)
+	return err
+}
+
+type staticAuthenticator struct {
+	key   string
+	value string
+}
+
+func (a *staticAuthenticator) header(context.Context) (string, string, error) {
+	return a.key, a.value, nil
+}
+
+func (a *staticAuthenticator) invalidate() {}
+
+func newAPIKeyAuthenticator(conn *rule.Connection) (authenticator, error) {
+	if conn.Key == "" {
+		return nil, errors.New("API key header name is required")
+	}
+	return &staticAuthenticator{key: conn.Key, value: conn.Secret}, nil
+}
+
+func newBasicAuthenticator(conn *rule.Connection) (authenticator, error) {
+	if conn.Key == "" {
+		return nil, errors.New("basic auth username is required")
+	}
+	return &staticAuthenticator{
+		key:   authorizationHeader,
+		value: "Basic " + base64.StdEncoding.EncodeToString([]byte(conn.Key+":"+conn.Secret)),
+	}, nil
+}
+
+// oauth2Authenticator fetches the token by the client credentials grant,
+// the token is cached until it expires or is invalidated.
+type oauth2Authenticator struct {
+	client   *http.Client
+	tokenURL string
+	clientID string
+	secret   string
+	scopes   []string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func newOAuth2Authenticator(conn *rule.Connection) (authenticator, error) {
+	if conn.Key == "" {
+		return nil, errors.New("OAuth2 client ID is required")
+	}
+	u, err := url.Parse(conn.TokenURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("OAuth2 token url(%s) should be http or https", conn.TokenURL)
+	}
+	return &oauth2Authenticator{
+		client:   &http.Client{Timeout: oauth2TokenTimeout},
+		tokenURL: conn.TokenURL,
+		clientID: conn.Key,
+		secret:   conn.Secret,
+		scopes:   conn.Scopes,
+	}, nil
+}
+
+func (a *oauth2Authenticator) header(ctx context.Context) (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == "" || !time.Now().Before(a.expiry) {
+		err := a.refresh(ctx)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return authorizationHeader, a.token, nil
+}
+
+func (a *oauth2Authenticator) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
+
+func (a *oauth2Authenticator) refresh(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", rule.ContentTypeForm)
+	req.Header.Set("Accept", rule.ContentTypeJSON)
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.secret))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch OAuth2 token err: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oauth2MaxRespBytes))
+	if err != nil {
+		return fmt.Errorf("read OAuth2 token err: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch OAuth2 token status code: %d, body: %s", resp.StatusCode, body)
+	}
+	token := &oauth2Token{}
+	err = json.Unmarshal(body, token)
+	if err != nil || token.AccessToken == "" {
+		return errors.New("OAuth2 token response should be JSON with access_token")
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	ttl := oauth2DefaultTokenTTL
+	if token.ExpiresIn > 0 {
+		ttl = time.Duration(token.ExpiresIn) * time.Second
+	}
+	a.token = tokenType + " " + token.AccessToken
+	a.expiry = time.Now().Add(max(ttl-oauth2ExpiryDelta, 0))
+	return nil
+}
@@ -0,0 +1,217 @@
+package target
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/xeipuuv/gojsonschema"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	// MetadataKeyHops is the metadata key of the number of the buses the event has been routed into
+	// by EventBusDispatcher.
+	MetadataKeyHops = "x-eb-hops"
+	// defaultMaxHops stops the event routed in a loop of the buses.
+	defaultMaxHops = 8
+)
+
+func init() {
+	registerDispatcher(
+		"EventBusDispatcher",
+		newEventBusDispatcher,
+		`
+		{
+		  "$schema": "https://json-schema.org/draft/2020-12/schema",
+		  "title": "EventBus dispatcher",
+		  "description": "The data format of the EventBus dispatcher",
+		  "type": "object",
+		  "properties": {
+			"busName": {
+			  "description": "busName is the name of the bus the event is published into",
+			  "type": "string"
+			},
+			"data": {
+			  "description": "data is the data of the published event",
+			  "type": "object"
+			},
+			"source": {
+			  "description": "source of the published event, the source of the target event by default",
+			  "type": "string"
+			},
+			"type": {
+			  "description": "type of the published event, the type of the target event by default",
+			  "type": "string"
+			},
+			"subject": {
+			  "description": "subject of the published event, the subject of the target event by default",
+			  "type": "string"
+			},
+			"maxHops": {
+			  "description": "maxHops is the max number of the buses the event can be routed into, 8 by default",
+			  "type": "integer",
+			  "minimum": 1
+			}
+		  },
+		  "required": [
+			"busName",
+			"data"
+		  ]
+		}`)
+}
+
+// BusSender publishes the event into the source topic of the bus.
+type BusSender interface {
+	SendToBus(ctx context.Context, busName string, event *rule.EventExt) error
+	// PostToBus validates the event by the schema of its source and type before publishing it,
+	// like the events posted to the service.
+	PostToBus(ctx context.Context, busName string, event *rule.EventExt) error
+}
+
+type busSenderKey struct{}
+
+// NewNewDispatcherFunc the dispatchers publishing the events into the buses use bs.
+func NewNewDispatcherFunc(bs BusSender) rule.NewDispatcherFunc {
+	return func(ctx context.Context, logger log.Logger, target *rule.Target) (rule.Dispatcher, error) {
+		return NewDispatcher(context.WithValue(ctx, busSenderKey{}, bs), logger, target)
+	}
+}
+
+type eventBusParams struct {
+	BusName string         `json:"busName"`
+	Data    jsontext.Value `json:"data"`
+	Source  string         `json:"source"`
+	Type    string         `json:"type"`
+	Subject *string        `json:"subject"`
+	MaxHops int            `json:"maxHops"`
+}
+
+type eventBusDispatcher struct {
+	log       *log.Helper
+	sender    BusSender // nil if the dispatcher is only created to check the target
+	validator *gojsonschema.Schema
+	// Only one check is required per dispatcher
+	validated int32
+}
+
+func newEventBusDispatcher(
+	ctx context.Context,
+	logger log.Logger,
+	_ *rule.Target,
+	validator *gojsonschema.Schema,
+) (rule.Dispatcher, error) {
+	sender, _ := ctx.Value(busSenderKey{}).(BusSender)
+	return &eventBusDispatcher{
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "target/eventBusDispatcher",
+			"caller", log.DefaultCaller,
+		)),
+		sender:    sender,
+		validator: validator,
+	}, nil
+}
+
+// Dispatch publishes the event into the bus with the metadata of the target event,
+// the event routed into more than maxHops buses is dropped. The event is validated
+// by its schema like the events posted to the service.
+func (d *eventBusDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	if d.sender == nil {
+		return errors.New("event bus dispatcher has no bus sender")
+	}
+
+	// validate
+	val := atomic.LoadInt32(&d.validated)
+	if val == 0 {
+		result, err := d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
+		if err != nil {
+			return err
+		}
+		if !result.Valid() {
+			return fmt.Errorf(
+				"event bus dispatcher target event data is not valid. see err: %s",
+				result.Errors(),
+			)
+		}
+	}
+	atomic.AddInt32(&d.validated, 1)
+
+	// fetch params
+	var params eventBusParams
+	err := json.Unmarshal([]byte(event.Event.Data), &params)
+	if err != nil {
+		return err
+	}
+	hops, err := eventHops(event)
+	if err != nil {
+		return err
+	}
+	maxHops := defaultMaxHops
+	if params.MaxHops > 0 {
+		maxHops = params.MaxHops
+	}
+	if hops >= maxHops {
+		d.log.WithContext(ctx).Errorf(
+			"drop event(%s) routed into %d buses, the buses may be in a loop. from bus: %s, to bus: %s",
+			event.Key(), hops, event.BusName, params.BusName,
+		)
+		return nil
+	}
+
+	// publish
+	evt := &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{
+				Id:              event.Event.Id,
+				Source:          event.Event.Source,
+				Subject:         event.Event.Subject,
+				Type:            event.Event.Type,
+				Time:            event.Event.Time,
+				Data:            string(params.Data),
+				Datacontenttype: rule.ContentTypeJSON,
+			},
+			BusName:       params.BusName,
+			RetryStrategy: event.RetryStrategy,
+			Metadata:      make(map[string]string, len(event.Metadata)+1),
+		},
+	}
+	if params.Source != "" {
+		evt.Event.Source = params.Source
+	}
+	if params.Type != "" {
+		evt.Event.Type = params.Type
+	}
+	if params.Subject != nil {
+		evt.Event.Subject = params.Subject
+	}
+	for k, v := range event.Metadata {
+		evt.Metadata[k] = v
+	}
+	evt.Metadata[MetadataKeyHops] = strconv.Itoa(hops + 1)
+	return d.sender.PostToBus(ctx, params.BusName, evt)
+}
+
+// eventHops returns the number of the buses the event has been routed into.
+func eventHops(event *rule.EventExt) (int, error) {
+	val, ok := event.Metadata[MetadataKeyHops]
+	if !ok {
+		return 0, nil
+	}
+	hops, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("parse the hops(%s) of the event err: %w", val, err)
+	}
+	return hops, nil
+}
+
+func (d *eventBusDispatcher) Close() error {
+	return nil
+}
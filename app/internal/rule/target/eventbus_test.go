@@ -0,0 +1,136 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+type busSender struct {
+	busName string
+	event   *rule.EventExt
+	posted  bool
+	postErr error
+}
+
+func (s *busSender) SendToBus(_ context.Context, busName string, event *rule.EventExt) error {
+	s.busName = busName
+	s.event = event
+	return nil
+}
+
+func (s *busSender) PostToBus(ctx context.Context, busName string, event *rule.EventExt) error {
+	if s.postErr != nil {
+		return s.postErr
+	}
+	s.posted = true
+	return s.SendToBus(ctx, busName, event)
+}
+
+func TestEventBusDispatcher(t *testing.T) {
+	subject := "order"
+	dispatchTests := []struct {
+		name     string
+		data     string
+		metadata map[string]string
+		postErr  error
+		sent     bool
+		expect   *v1.Event
+		hops     string
+	}{
+		{
+			name:   "first hop",
+			data:   `{"busName":"bus2","data":{"a":1}}`,
+			sent:   true,
+			expect: &v1.Event{Id: 1, Source: "src", Type: "tp", Data: `{"a":1}`},
+			hops:   "1",
+		},
+		{
+			name:     "overrides",
+			data:     `{"busName":"bus2","data":{"a":1},"source":"src2","type":"tp2","subject":"order"}`,
+			metadata: map[string]string{MetadataKeyHops: "3", "traceparent": "tp"},
+			sent:     true,
+			expect:   &v1.Event{Id: 1, Source: "src2", Type: "tp2", Subject: &subject, Data: `{"a":1}`},
+			hops:     "4",
+		},
+		{
+			name:     "dropped in a loop",
+			data:     `{"busName":"bus2","data":{"a":1}}`,
+			metadata: map[string]string{MetadataKeyHops: "8"},
+		},
+		{
+			name:     "dropped by max hops",
+			data:     `{"busName":"bus2","data":{"a":1},"maxHops":2}`,
+			metadata: map[string]string{MetadataKeyHops: "2"},
+		},
+		{
+			name:    "rejected by the schema",
+			data:    `{"busName":"bus2","data":{"a":"1"}}`,
+			postErr: v1.ErrorEventDataNotValid("event data is not valid"),
+		},
+	}
+	for _, tt := range dispatchTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &busSender{postErr: tt.postErr}
+			d, err := NewNewDispatcherFunc(s)(
+				context.Background(), log.DefaultLogger, &rule.Target{Type: "EventBusDispatcher"},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = d.Dispatch(context.Background(), &rule.EventExt{
+				EventExt: &v1.EventExt{
+					Event:    &v1.Event{Id: 1, Source: "src", Type: "tp", Data: tt.data},
+					BusName:  "bus1",
+					Metadata: tt.metadata,
+				},
+			})
+			if !errors.Is(err, tt.postErr) {
+				t.Fatalf("expect err: %v, got: %v", tt.postErr, err)
+			}
+			if !tt.sent {
+				if s.event != nil {
+					t.Fatalf("expect dropped, got: %v", s.event)
+				}
+				return
+			}
+			if !s.posted || s.busName != "bus2" || s.event.BusName != "bus2" {
+				t.Fatalf("expect sent to bus2, got: %s", s.busName)
+			}
+			evt := s.event.Event
+			if evt.Id != tt.expect.Id || evt.Source != tt.expect.Source || evt.Type != tt.expect.Type ||
+				evt.GetSubject() != tt.expect.GetSubject() || evt.Data != tt.expect.Data ||
+				evt.Datacontenttype != rule.ContentTypeJSON {
+				t.Fatalf("expect event: %v, got: %v", tt.expect, evt)
+			}
+			if s.event.Metadata[MetadataKeyHops] != tt.hops {
+				t.Fatalf("expect hops: %s, got: %s", tt.hops, s.event.Metadata[MetadataKeyHops])
+			}
+			for k, v := range tt.metadata {
+				if k != MetadataKeyHops && s.event.Metadata[k] != v {
+					t.Fatalf("expect metadata %s: %s, got: %s", k, v, s.event.Metadata[k])
+				}
+			}
+		})
+	}
+}
+
+func TestEventBusDispatcherWithoutSender(t *testing.T) {
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "EventBusDispatcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Dispatch(context.Background(), &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{Id: 1, Data: `{"busName":"bus2","data":{}}`},
+		},
+	})
+	if err == nil {
+		t.Fatal("expect error without the bus sender")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware/recovery"
 	"github.com/go-kratos/kratos/v2/middleware/tracing"
@@ -55,6 +56,7 @@ type gRPCDispatcher struct {
 	log         *log.Helper
 	clients     sync.Map
 	connections sync.Map
+	auth        authenticator
 	validator   *gojsonschema.Schema
 	// Only one check is required per dispatcher
 	validated int32
@@ -63,9 +65,13 @@ type gRPCDispatcher struct {
 func newGRPCDispatcher(
 	_ context.Context,
 	logger log.Logger,
-	_ *rule.Target,
+	target *rule.Target,
 	validator *gojsonschema.Schema,
 ) (rule.Dispatcher, error) {
+	auth, err := newAuthenticator(target)
+	if err != nil {
+		return nil, err
+	}
 	return &gRPCDispatcher{
 		log: log.NewHelper(log.With(
 			logger,
@@ -74,19 +80,42 @@ func newGRPCDispatcher(
 			"caller", log.DefaultCaller,
 		)),
 		validator: validator,
+		auth:      auth,
 	}, nil
 }
 
 func (d *gRPCDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	_, err := d.call(ctx, event)
+	return err
+}
+
+// dispatchResponse returns the data of the response for the on success of the target,
+// the data is JSON unless the response tells its content type.
+func (d *gRPCDispatcher) dispatchResponse(ctx context.Context, event *rule.EventExt) ([]byte, string, error) {
+	resp, err := d.call(ctx, event)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.GetData() == "" {
+		return nil, "", nil
+	}
+	contentType := resp.GetDatacontenttype()
+	if contentType == "" {
+		contentType = rule.ContentTypeJSON
+	}
+	return []byte(resp.GetData()), contentType, nil
+}
+
+func (d *gRPCDispatcher) call(ctx context.Context, event *rule.EventExt) (*v1.PostTargetEventResponse, error) {
 	// validate
 	val := atomic.LoadInt32(&d.validated)
 	if val == 0 {
 		result, err := d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if !result.Valid() {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"gRPC dispatcher target event data is not valid. see err: %s",
 				result.Errors(),
 			)
@@ -107,12 +136,19 @@ func (d *gRPCDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) err
 		m := make(map[string]string)
 		err := json.Unmarshal([]byte(md), &m)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		for k, v := range m {
 			ctx = metadata.AppendToOutgoingContext(ctx, k, v)
 		}
 	}
+	if d.auth != nil {
+		authKey, authValue, err := d.auth.header(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(authKey), authValue)
+	}
 
 	// get client
 	clientVal, ok := d.clients.Load(endpoint)
@@ -130,13 +166,13 @@ func (d *gRPCDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) err
 			t.WithTimeout(0),
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		connVal, exist := d.connections.LoadOrStore(endpoint, conn)
 		if exist { // store by other goroutine
 			err = conn.Close()
 			if err != nil {
-				return fmt.Errorf("close grpc connection(%s) failed: %w", endpoint, err)
+				return nil, fmt.Errorf("close grpc connection(%s) failed: %w", endpoint, err)
 			}
 			conn = connVal.(*grpc.ClientConn)
 		}
@@ -145,16 +181,19 @@ func (d *gRPCDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) err
 	}
 
 	// call gRPC
-	_, err := client.PostTargetEvent(ctx, &v1.PostTargetEventRequest{
+	resp, err := client.PostTargetEvent(ctx, &v1.PostTargetEventRequest{
 		Id:              event.Event.Id,
 		Source:          event.Event.Source,
 		Datacontenttype: event.Event.Datacontenttype,
 		Data:            string(marshalData),
 	})
 	if err != nil {
-		return err
+		if d.auth != nil && errors.IsUnauthorized(err) {
+			d.auth.invalidate()
+		}
+		return nil, err
 	}
-	return nil
+	return resp, nil
 }
 
 func (d *gRPCDispatcher) Close() error {
@@ -3,7 +3,11 @@ package target
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +18,7 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 
 	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/webhook"
 )
 
 func init() {
@@ -45,52 +50,227 @@ func init() {
 			  }
 			},
 			"body": {
-			  "description": "body is the request's body",
-			  "type": "object"
+			  "description": "body is the request's body, the object is sent as JSON and the string is sent as it is encoded by the target encoding",
+			  "type": ["object", "string"]
 			}
 		  },
 		  "required": [
 			"method",
 			"url"
-		  ]
+		  ],
+		  "$defs": {
+			"httpClient": {
+			  "description": "httpClient is the optional field of the target rather than a param, it configures the HTTP client built once for the target",
+			  "type": "object",
+			  "properties": {
+				"timeout": {
+				  "description": "timeout of the request like 5s, no timeout if it is empty or 0s",
+				  "type": "string"
+				},
+				"successCodes": {
+				  "description": "successCodes are the status codes like 200 or the ranges like 200-299 of the successful responses, 200-299 by default",
+				  "type": "array",
+				  "items": {
+					"type": "string",
+					"pattern": "^[1-5][0-9]{2}(-[1-5][0-9]{2})?$"
+				  }
+				},
+				"tls": {
+				  "description": "tls configures the server verification, the client certificate of mTLS is the TLS_CLIENT_CERT connection of the target",
+				  "type": "object",
+				  "properties": {
+					"caCert": {
+					  "description": "caCert is the PEM encoded CA certificates to verify the server, the system roots by default",
+					  "type": "string"
+					},
+					"serverName": {
+					  "description": "serverName is used to verify the server certificate, the host of the url by default",
+					  "type": "string"
+					},
+					"insecureSkipVerify": {
+					  "description": "insecureSkipVerify skips verifying the server certificate, for development only",
+					  "type": "boolean"
+					}
+				  }
+				},
+				"proxy": {
+				  "description": "proxy is the URL of the proxy, no proxy by default",
+				  "type": "string"
+				},
+				"maxConns": {
+				  "description": "maxConns limits the connections per host, no limit if it is 0",
+				  "type": "integer",
+				  "minimum": 0
+				},
+				"maxRedirects": {
+				  "description": "maxRedirects is the maximum redirects to follow, 0 doesn't follow, 10 by default",
+				  "type": "integer",
+				  "minimum": 0
+				}
+			  }
+			}
+		  }
 		}`)
 }
 
+var _ rule.BatchDispatcher = (*httpDispatcher)(nil)
+
 type httpDispatcher struct {
 	log       *log.Helper
-	client    atomic.Value
+	client    *httpClient
+	auth      authenticator
 	validator *gojsonschema.Schema
 	// Only one check is required per dispatcher
 	validated int32
+	// signingKeys sign the requests if they are not empty
+	signingKeys []string
 }
 
 func newHTTPDispatcher(
 	_ context.Context,
 	logger log.Logger,
-	_ *rule.Target,
+	target *rule.Target,
 	validator *gojsonschema.Schema,
 ) (rule.Dispatcher, error) {
+	var auth authenticator
+	var certificate *tls.Certificate
+	var err error
+	if target.Auth != nil && target.Auth.AuthType == rule.AuthTypeTLS {
+		certificate, err = newClientCertificate(target.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("connection(%s) %w", target.Connection, err)
+		}
+	} else {
+		auth, err = newAuthenticator(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := newHTTPClient(target.HTTPClient, certificate)
+	if err != nil {
+		return nil, err
+	}
+	var signingKeys []string
+	if target.Signing != nil {
+		if len(target.Signing.Keys) == 0 {
+			return nil, errors.New("signing keys are not resolved")
+		}
+		signingKeys = target.Signing.Keys
+	}
 	return &httpDispatcher{
 		log: log.NewHelper(log.With(
 			logger,
 			"module", "target/httpDispatcher",
 			"caller", log.DefaultCaller,
 		)),
-		validator: validator,
+		client:      client,
+		validator:   validator,
+		auth:        auth,
+		signingKeys: signingKeys,
 	}, nil
 }
 
-func (d *httpDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) (err error) {
+// httpRequest is the request params of the target event.
+type httpRequest struct {
+	method      string
+	url         string
+	header      map[string]interface{}
+	body        []byte // nil if the event has no body
+	contentType string
+}
+
+func (d *httpDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	r, err := d.parseRequest(event)
+	if err != nil {
+		return err
+	}
+	_, _, err = d.send(ctx, r, r.body, r.contentType, false)
+	return err
+}
+
+// dispatchResponse sends the request and returns the response body for the on success of the target.
+func (d *httpDispatcher) dispatchResponse(ctx context.Context, event *rule.EventExt) ([]byte, string, error) {
+	r, err := d.parseRequest(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return d.send(ctx, r, r.body, r.contentType, true)
+}
+
+// DispatchBatch sends the JSON array of the bodies of the events as one request,
+// the events with the different method, url or header are sent by different requests.
+// All the events are parsed before any request is sent, and the error of each event is reported by rule.BatchError,
+// so only the invalid events and the events of the failed requests are retried.
+func (d *httpDispatcher) DispatchBatch(ctx context.Context, events []*rule.EventExt) error {
+	errs := make([]error, len(events))
+	failed := false
+	requests := make([]*httpRequest, len(events))
+	groups := make(map[string][]int) // key -> indexes of the events
+	keys := make([]string, 0)
+	for i, event := range events {
+		r, key, err := d.parseBatchRequest(event)
+		if err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+		requests[i] = r
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	for _, key := range keys {
+		idxs := groups[key]
+		bodies := make([]jsontext.Value, 0, len(idxs))
+		for _, i := range idxs {
+			if requests[i].body != nil {
+				bodies = append(bodies, requests[i].body)
+			}
+		}
+		body, err := json.Marshal(bodies)
+		if err == nil {
+			_, _, err = d.send(ctx, requests[idxs[0]], body, rule.ContentTypeJSON, false)
+		}
+		if err != nil {
+			for _, i := range idxs {
+				errs[i] = err
+			}
+			failed = true
+		}
+	}
+	if failed {
+		return &rule.BatchError{Errs: errs}
+	}
+	return nil
+}
+
+// parseBatchRequest returns the request of the event and the key of its request group.
+func (d *httpDispatcher) parseBatchRequest(event *rule.EventExt) (*httpRequest, string, error) {
+	r, err := d.parseRequest(event)
+	if err != nil {
+		return nil, "", err
+	}
+	if r.body != nil && r.contentType != rule.ContentTypeJSON {
+		return nil, "", fmt.Errorf("http dispatcher batch body should be JSON, got: %s", r.contentType)
+	}
+	key, err := json.Marshal([]interface{}{r.method, r.url, r.header}, json.Deterministic(true))
+	if err != nil {
+		return nil, "", err
+	}
+	return r, string(key), nil
+}
+
+func (d *httpDispatcher) parseRequest(event *rule.EventExt) (*httpRequest, error) {
 	// validate
 	val := atomic.LoadInt32(&d.validated)
 	if val == 0 {
-		var result *gojsonschema.Result
-		result, err = d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
+		result, err := d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if !result.Valid() {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"http dispatcher target event data is not valid. see err: %s",
 				result.Errors(),
 			)
@@ -101,67 +281,117 @@ func (d *httpDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) (er
 	// fetch params
 	jsonData := make(map[string]interface{})
 	_ = json.Unmarshal([]byte(event.Event.Data), &jsonData)
-	method := jsonData["method"].(string)
-	url := jsonData["url"].(string)
-	var body io.Reader
+	r := &httpRequest{
+		method: jsonData["method"].(string),
+		url:    jsonData["url"].(string),
+	}
 	bodyData, ok := jsonData["body"]
 	if ok {
-		marshalBody, _ := json.Marshal(bodyData)
-		body = bytes.NewReader(marshalBody)
-	}
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return err
+		var err error
+		r.body, r.contentType, err = encodedBody(event, bodyData)
+		if err != nil {
+			return nil, err
+		}
 	}
 	headerData, ok := jsonData["header"]
 	if ok {
-		header := headerData.(map[string]string)
-		for key, val := range header {
-			req.Header.Set(key, val)
-		}
+		r.header = headerData.(map[string]interface{})
 	}
+	return r, nil
+}
 
-	// get client
-	clientVal := d.client.Load()
-	var client *http.Client
-	if clientVal == nil {
-		client = &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:    10,
-				IdleConnTimeout: 30 * time.Second,
-			},
-			Timeout: 0,
+// send sends the request with the body, the body is nil if there is no body.
+// send returns the body of the successful response and its content type if readResponse is true.
+func (d *httpDispatcher) send(
+	ctx context.Context, r *httpRequest, rawBody []byte, contentType string, readResponse bool,
+) (respBody []byte, respContentType string, err error) {
+	var body io.Reader
+	if rawBody != nil {
+		body = bytes.NewReader(rawBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, r.url, body)
+	if err != nil {
+		return nil, "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, val := range r.header {
+		req.Header.Set(key, val.(string))
+	}
+	// the credentials of the connection take precedence over the header of the event
+	if d.auth != nil {
+		authKey, authValue, errAuth := d.auth.header(ctx)
+		if errAuth != nil {
+			return nil, "", errAuth
 		}
-		d.client.Store(client)
-	} else {
-		client = clientVal.(*http.Client)
+		req.Header.Set(authKey, authValue)
+	}
+	if len(d.signingKeys) > 0 {
+		webhook.SetHeaders(req.Header, d.signingKeys, time.Now(), rawBody)
 	}
 
 	// call http request
-	resp, err := client.Do(req)
+	resp, err := d.client.client.Do(req)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 	defer func() {
-		err = resp.Body.Close()
+		// keep the error of the response
+		errClose := resp.Body.Close()
+		if err == nil {
+			err = errClose
+		}
 	}()
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusUnauthorized && d.auth != nil {
+		d.auth.invalidate()
+	}
+	if !d.client.isSuccess(resp.StatusCode) {
 		var rb []byte
 		rb, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf(
+			return nil, "", fmt.Errorf(
 				"response status code: %d, read body err: %s",
 				resp.StatusCode, err,
 			)
 		}
-		return fmt.Errorf(
+		return nil, "", fmt.Errorf(
 			"response status code: %d, body: %s",
 			resp.StatusCode, rb,
 		)
 	}
-	return nil
+	if !readResponse {
+		return nil, "", nil
+	}
+	respBody, err = io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("read response body err: %w", err)
+	}
+	if len(respBody) > maxResponseBytes {
+		return nil, "", fmt.Errorf("response body exceeds %d bytes", maxResponseBytes)
+	}
+	return respBody, resp.Header.Get("Content-Type"), nil
+}
+
+// encodedBody returns the body and its content type. The object body is sent as JSON,
+// and the string body has been encoded by the target encoding described by the event data content type.
+func encodedBody(event *rule.EventExt, bodyData interface{}) ([]byte, string, error) {
+	strBody, ok := bodyData.(string)
+	if !ok {
+		marshalBody, _ := json.Marshal(bodyData)
+		return marshalBody, rule.ContentTypeJSON, nil
+	}
+	if event.Event.Datacontenttype == rule.ContentTypeProtobuf {
+		rawBody, err := base64.StdEncoding.DecodeString(strBody)
+		if err != nil {
+			return nil, "", fmt.Errorf("decode protobuf body err: %w", err)
+		}
+		return rawBody, rule.ContentTypeProtobuf, nil
+	}
+	return []byte(strBody), event.Event.Datacontenttype, nil
 }
 
 func (d *httpDispatcher) Close() error {
+	d.client.client.CloseIdleConnections()
 	return nil
 }
@@ -0,0 +1,164 @@
+package target
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	httpDefaultMaxIdleConns    = 10
+	httpDefaultIdleConnTimeout = 30 * time.Second
+)
+
+// httpDefaultSuccessCodes accepts all the 2xx responses.
+var httpDefaultSuccessCodes = []statusRange{{min: 200, max: 299}}
+
+type statusRange struct {
+	min int
+	max int
+}
+
+// httpClient is the client of the target built by its HTTPClient.
+type httpClient struct {
+	client       *http.Client
+	successCodes []statusRange
+}
+
+func (c *httpClient) isSuccess(code int) bool {
+	for _, r := range c.successCodes {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPClient builds the client by the config, the default client if it is nil.
+// The certificate is the client certificate of mTLS if it is not nil.
+func newHTTPClient(cfg *rule.HTTPClient, certificate *tls.Certificate) (*httpClient, error) {
+	if cfg == nil {
+		cfg = &rule.HTTPClient{}
+	}
+	transport := &http.Transport{
+		MaxIdleConns:    httpDefaultMaxIdleConns,
+		IdleConnTimeout: httpDefaultIdleConnTimeout,
+	}
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("http client timeout(%s) should be non-negative", cfg.Timeout)
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}
+	if cfg.MaxConns > 0 {
+		transport.MaxConnsPerHost = int(cfg.MaxConns)
+		transport.MaxIdleConnsPerHost = int(cfg.MaxConns)
+		transport.MaxIdleConns = max(transport.MaxIdleConns, int(cfg.MaxConns))
+	}
+	if cfg.Proxy != "" {
+		proxyURL, errProxy := url.Parse(cfg.Proxy)
+		if errProxy != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("http client proxy(%s) is not a valid URL", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	var err error
+	if cfg.TLS != nil || certificate != nil {
+		transport.TLSClientConfig, err = newTLSConfig(cfg.TLS, certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxRedirects != nil {
+		maxRedirects := int(*cfg.MaxRedirects)
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				if maxRedirects == 0 {
+					return http.ErrUseLastResponse
+				}
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		}
+	}
+	successCodes := httpDefaultSuccessCodes
+	if len(cfg.SuccessCodes) > 0 {
+		successCodes, err = parseStatusRanges(cfg.SuccessCodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &httpClient{
+		client:       client,
+		successCodes: successCodes,
+	}, nil
+}
+
+func newTLSConfig(cfg *rule.HTTPClientTLS, certificate *tls.Certificate) (*tls.Config, error) {
+	if cfg == nil {
+		cfg = &rule.HTTPClientTLS{}
+	}
+	tlsConfig := &tls.Config{
+		ServerName: cfg.ServerName,
+		// #nosec G402 -- it is only allowed to be enabled explicitly for development
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, errors.New("http client tls caCert is not a valid PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*certificate}
+	}
+	return tlsConfig, nil
+}
+
+// newClientCertificate parses the secret of the TLS_CLIENT_CERT connection,
+// the PEM encoded certificate chain followed by its private key.
+func newClientCertificate(conn *rule.Connection) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(conn.Secret), []byte(conn.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("client certificate err: %w", err)
+	}
+	return &cert, nil
+}
+
+// tlsClientCertSyntaxCheck the secret is only checked if it is set, since it is kept if it is not updated.
+func tlsClientCertSyntaxCheck(conn *rule.Connection) error {
+	if conn.Secret == "" {
+		return nil
+	}
+	_, err := newClientCertificate(conn)
+	return err
+}
+
+// parseStatusRanges parses the status codes like "200" and the ranges like "200-299".
+func parseStatusRanges(codes []string) ([]statusRange, error) {
+	ranges := make([]statusRange, 0, len(codes))
+	for _, code := range codes {
+		minStr, maxStr, isRange := strings.Cut(code, "-")
+		if !isRange {
+			maxStr = minStr
+		}
+		minCode, errMin := strconv.Atoi(strings.TrimSpace(minStr))
+		maxCode, errMax := strconv.Atoi(strings.TrimSpace(maxStr))
+		if errMin != nil || errMax != nil || minCode < 100 || maxCode > 599 || minCode > maxCode {
+			return nil, fmt.Errorf("http client success code(%s) should be a status code or a range of them", code)
+		}
+		ranges = append(ranges, statusRange{min: minCode, max: maxCode})
+	}
+	return ranges, nil
+}
@@ -0,0 +1,537 @@
+package target
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json/v2"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/webhook"
+)
+
+func TestHTTPDispatcherContentType(t *testing.T) {
+	type received struct {
+		contentType string
+		token       string
+		body        string
+	}
+	rcv := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv <- received{
+			contentType: r.Header.Get("Content-Type"),
+			token:       r.Header.Get("X-Token"),
+			body:        string(body),
+		}
+	}))
+	defer srv.Close()
+
+	protoBody := base64.StdEncoding.EncodeToString([]byte{0x0a, 0x01, 'a'})
+	dispatchTests := []struct {
+		data        string
+		contentType string
+		expect      received
+	}{
+		// object body is sent as JSON
+		{
+			data:   `{"method":"POST","url":"` + srv.URL + `","header":{"X-Token":"t"},"body":{"a":1}}`,
+			expect: received{contentType: rule.ContentTypeJSON, token: "t", body: `{"a":1}`},
+		},
+		// encoded body
+		{
+			data:        `{"method":"POST","url":"` + srv.URL + `","body":"<data><a>1</a></data>"}`,
+			contentType: rule.ContentTypeXML,
+			expect:      received{contentType: rule.ContentTypeXML, body: `<data><a>1</a></data>`},
+		},
+		{
+			data:        `{"method":"POST","url":"` + srv.URL + `","body":"` + protoBody + `"}`,
+			contentType: rule.ContentTypeProtobuf,
+			expect:      received{contentType: rule.ContentTypeProtobuf, body: "\x0a\x01a"},
+		},
+		// header overrides the content type
+		{
+			data:        `{"method":"POST","url":"` + srv.URL + `","header":{"Content-Type":"text/plain"},"body":"a,b\n"}`,
+			contentType: rule.ContentTypeCSV,
+			expect:      received{contentType: "text/plain", body: "a,b\n"},
+		},
+	}
+	for idx, tt := range dispatchTests {
+		d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "HTTPDispatcher"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 1, Data: tt.data, Datacontenttype: tt.contentType},
+			},
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if r := <-rcv; r != tt.expect {
+			t.Fatalf("case(index=%d) expect: %+v, actual: %+v", idx, tt.expect, r)
+		}
+	}
+}
+
+func TestValidateDispatcherParams(t *testing.T) {
+	validateTests := []struct {
+		targetType string
+		data       string
+		valid      bool
+	}{
+		{targetType: "HTTPDispatcher", data: `{"method":"POST","url":"http://127.0.0.1","body":{"a":1}}`, valid: true},
+		{targetType: "HTTPDispatcher", data: `{"method":"POST","body":{"a":1}}`},
+		{targetType: "HTTPDispatcher", data: `{"method":"POST","url":"http://127.0.0.1","body":1}`},
+		{targetType: "notExists", data: `{}`},
+	}
+	for idx, tt := range validateTests {
+		err := ValidateDispatcherParams(tt.targetType, tt.data)
+		if (err == nil) != tt.valid {
+			t.Fatalf("case(index=%d) expect valid: %v, err: %v", idx, tt.valid, err)
+		}
+	}
+}
+
+// TestHTTPClientSchema keeps the httpClient documented by the dispatcher schema in step with rule.HTTPClient.
+func TestHTTPClientSchema(t *testing.T) {
+	type propertySchema struct {
+		Properties map[string]*propertySchema `json:"properties"`
+	}
+	var schema struct {
+		Defs map[string]*propertySchema `json:"$defs"`
+	}
+	err := json.Unmarshal([]byte(ListAllDispatcherParamsSchema()["HTTPDispatcher"]), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, ok := schema.Defs["httpClient"]
+	if !ok {
+		t.Fatal("expect the httpClient documented")
+	}
+	checkFields := func(typ reflect.Type, properties map[string]*propertySchema) {
+		if typ.NumField() != len(properties) {
+			t.Errorf("expect %d properties of %s, got: %d", typ.NumField(), typ.Name(), len(properties))
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			name := typ.Field(i).Name
+			found := false
+			for p := range properties {
+				found = found || strings.EqualFold(p, name)
+			}
+			if !found {
+				t.Errorf("expect the property of %s.%s documented", typ.Name(), name)
+			}
+		}
+	}
+	checkFields(reflect.TypeFor[rule.HTTPClient](), client.Properties)
+	checkFields(reflect.TypeFor[rule.HTTPClientTLS](), client.Properties["tls"].Properties)
+}
+
+func TestHTTPDispatcherConnection(t *testing.T) {
+	var tokenCalls atomic.Int32
+	var reject atomic.Bool
+	auths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			id, secret, _ := r.BasicAuth()
+			if id != "client1" || secret != "secret1" || r.FormValue("scope") != "a b" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			n := tokenCalls.Add(1)
+			_, _ = fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":3600}`, n)
+			return
+		}
+		auths <- r.Header.Get("Authorization") + r.Header.Get("X-Api-Key")
+		if reject.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	data := `{"method":"POST","url":"` + srv.URL + `/events","header":{"Authorization":"event"},"body":{"a":1}}`
+	dispatch := func(d rule.Dispatcher) error {
+		return d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{Event: &v1.Event{Id: 1, Data: data}},
+		})
+	}
+	connTests := []struct {
+		conn   *rule.Connection
+		expect string
+	}{
+		{
+			conn:   &rule.Connection{AuthType: rule.AuthTypeAPIKey, Key: "X-Api-Key", Secret: "key1"},
+			expect: "eventkey1",
+		},
+		{
+			conn:   &rule.Connection{AuthType: rule.AuthTypeBasic, Key: "user1", Secret: "pass1"},
+			expect: "Basic dXNlcjE6cGFzczE=",
+		},
+	}
+	for idx, tt := range connTests {
+		d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+			Type: "HTTPDispatcher", Connection: "conn1", Auth: tt.conn,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dispatch(d)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if auth := <-auths; auth != tt.expect {
+			t.Fatalf("case(index=%d) expect auth: %s, actual: %s", idx, tt.expect, auth)
+		}
+	}
+
+	// OAuth2 token is cached, and refreshed after the target rejects it
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:       "HTTPDispatcher",
+		Connection: "conn1",
+		Auth: &rule.Connection{
+			AuthType: rule.AuthTypeOAuth2,
+			Key:      "client1",
+			Secret:   "secret1",
+			TokenURL: srv.URL + "/token",
+			Scopes:   []string{"a", "b"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth2Steps := []struct {
+		reject bool
+		expect string
+	}{
+		{expect: "Bearer token1"},
+		{expect: "Bearer token1", reject: true},
+		{expect: "Bearer token2"},
+	}
+	for idx, step := range oauth2Steps {
+		reject.Store(step.reject)
+		err = dispatch(d)
+		if (err != nil) != step.reject {
+			t.Fatalf("step(index=%d) err: %v", idx, err)
+		}
+		if auth := <-auths; auth != step.expect {
+			t.Fatalf("step(index=%d) expect auth: %s, actual: %s", idx, step.expect, auth)
+		}
+	}
+
+	// the connection is not resolved
+	_, err = NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type: "HTTPDispatcher", Connection: "conn1",
+	})
+	if err == nil {
+		t.Fatal("expect err of the unresolved connection")
+	}
+}
+
+func TestHTTPDispatcherSigning(t *testing.T) {
+	verifier := webhook.NewVerifier([]string{"old"})
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, err := verifier.VerifyRequest(r)
+		errs <- err
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:    "HTTPDispatcher",
+		Signing: &rule.Signing{Keys: []string{"new", "old"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Dispatch(context.Background(), &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{Id: 1, Data: `{"method":"POST","url":"` + srv.URL + `","body":{"a":1}}`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-errs; err != nil {
+		t.Fatalf("verify signature err: %v", err)
+	}
+
+	// the secrets are not decrypted
+	_, err = NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:    "HTTPDispatcher",
+		Signing: &rule.Signing{Secrets: []string{"encrypted"}},
+	})
+	if err == nil {
+		t.Fatal("expect err of the unresolved signing keys")
+	}
+}
+
+func TestHTTPDispatcherClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accepted":
+			w.WriteHeader(http.StatusAccepted)
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/redirect":
+			http.Redirect(w, r, "/no-content", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer tlsSrv.Close()
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}))
+	zero := uint32(0)
+
+	clientTests := []struct {
+		url    string
+		client *rule.HTTPClient
+		ok     bool
+	}{
+		{url: srv.URL + "/accepted", ok: true},
+		{url: srv.URL + "/no-content", ok: true},
+		{url: srv.URL + "/not-found"},
+		{url: srv.URL + "/no-content", client: &rule.HTTPClient{SuccessCodes: []string{"200"}}},
+		{url: srv.URL + "/not-found", client: &rule.HTTPClient{SuccessCodes: []string{"200-299", "404"}}, ok: true},
+		{url: srv.URL + "/redirect", ok: true},
+		{url: srv.URL + "/redirect", client: &rule.HTTPClient{MaxRedirects: &zero}},
+		{
+			url:    srv.URL + "/redirect",
+			client: &rule.HTTPClient{MaxRedirects: &zero, SuccessCodes: []string{"302"}},
+			ok:     true,
+		},
+		{url: srv.URL + "/slow", client: &rule.HTTPClient{Timeout: 50 * time.Millisecond}},
+		{url: srv.URL + "/slow", client: &rule.HTTPClient{Timeout: time.Second}, ok: true},
+		{url: tlsSrv.URL},
+		{url: tlsSrv.URL, client: &rule.HTTPClient{TLS: &rule.HTTPClientTLS{CACert: caCert}}, ok: true},
+		{url: tlsSrv.URL, client: &rule.HTTPClient{TLS: &rule.HTTPClientTLS{InsecureSkipVerify: true}}, ok: true},
+	}
+	for idx, tt := range clientTests {
+		d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+			Type: "HTTPDispatcher", HTTPClient: tt.client,
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{Event: &v1.Event{Id: 1, Data: `{"method":"GET","url":"` + tt.url + `"}`}},
+		})
+		if (err == nil) != tt.ok {
+			t.Fatalf("case(index=%d) expect ok: %v, err: %v", idx, tt.ok, err)
+		}
+		_ = d.Close()
+	}
+
+	// the invalid client fails to build the dispatcher
+	invalidTargets := []*rule.Target{
+		{Type: "HTTPDispatcher", HTTPClient: &rule.HTTPClient{TLS: &rule.HTTPClientTLS{CACert: "not a certificate"}}},
+		{Type: "HTTPDispatcher", HTTPClient: &rule.HTTPClient{Proxy: "not a url"}},
+		{Type: "HTTPDispatcher", HTTPClient: &rule.HTTPClient{Timeout: -time.Second}},
+		{Type: "HTTPDispatcher", HTTPClient: &rule.HTTPClient{SuccessCodes: []string{"2xx"}}},
+		{Type: "noopDispatcher", HTTPClient: &rule.HTTPClient{}},
+		{
+			Type:       "HTTPDispatcher",
+			Connection: "conn1",
+			Auth:       &rule.Connection{AuthType: rule.AuthTypeTLS, Secret: "not a certificate"},
+		},
+	}
+	for idx, target := range invalidTargets {
+		_, err := NewDispatcher(context.Background(), log.DefaultLogger, target)
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
+
+func TestHTTPDispatcherClientCertificate(t *testing.T) {
+	clientCert, clientPEM := newTestCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+	client := &rule.HTTPClient{TLS: &rule.HTTPClientTLS{InsecureSkipVerify: true}}
+
+	certTests := []struct {
+		target *rule.Target
+		ok     bool
+	}{
+		{target: &rule.Target{Type: "HTTPDispatcher", HTTPClient: client}},
+		{
+			target: &rule.Target{
+				Type:       "HTTPDispatcher",
+				HTTPClient: client,
+				Connection: "conn1",
+				Auth:       &rule.Connection{AuthType: rule.AuthTypeTLS, Secret: clientPEM},
+			},
+			ok: true,
+		},
+	}
+	for idx, tt := range certTests {
+		d, err := NewDispatcher(context.Background(), log.DefaultLogger, tt.target)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{Event: &v1.Event{Id: 1, Data: `{"method":"GET","url":"` + srv.URL + `"}`}},
+		})
+		if (err == nil) != tt.ok {
+			t.Fatalf("case(index=%d) expect ok: %v, err: %v", idx, tt.ok, err)
+		}
+		_ = d.Close()
+	}
+
+	err := ConnectionSyntaxCheck(&rule.Connection{AuthType: rule.AuthTypeTLS, Secret: clientPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ConnectionSyntaxCheck(&rule.Connection{AuthType: rule.AuthTypeTLS, Secret: "not a certificate"})
+	if err == nil {
+		t.Fatal("expect err of the invalid client certificate")
+	}
+}
+
+// newTestCertificate returns a self-signed client certificate and the PEM of the certificate and its key.
+func newTestCertificate(t *testing.T) (*x509.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "eventbridge"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return cert, string(certPEM) + string(keyPEM)
+}
+
+func TestHTTPDispatcherBatch(t *testing.T) {
+	rcv := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv <- r.URL.Path + " " + r.Header.Get("Content-Type") + " " + string(body)
+	}))
+	defer srv.Close()
+
+	target := &rule.Target{
+		Type:  "HTTPDispatcher",
+		Batch: &rule.Batch{MaxSize: 10, MaxLinger: time.Second},
+	}
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bd, ok := d.(rule.BatchDispatcher)
+	if !ok {
+		t.Fatal("expect batch dispatcher")
+	}
+	event := func(data string, contentType string) *rule.EventExt {
+		return &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 1, Data: data, Datacontenttype: contentType},
+			},
+		}
+	}
+
+	// the events with the same url are sent by one request
+	err = bd.DispatchBatch(context.Background(), []*rule.EventExt{
+		event(`{"method":"POST","url":"`+srv.URL+`/a","body":{"a":1}}`, ""),
+		event(`{"method":"POST","url":"`+srv.URL+`/b","body":{"b":1}}`, ""),
+		event(`{"method":"POST","url":"`+srv.URL+`/a","body":"{\"a\":2}"}`, rule.ContentTypeJSON),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/a application/json [{\"a\":1},{\"a\":2}]",
+		"/b application/json [{\"b\":1}]",
+	}
+	for _, exp := range expected {
+		if actual := <-rcv; actual != exp {
+			t.Fatalf("expect request: %s, actual: %s", exp, actual)
+		}
+	}
+
+	// only the JSON bodies can be batched, the error of each event is reported
+	// and the valid events are still sent
+	err = bd.DispatchBatch(context.Background(), []*rule.EventExt{
+		event(`{"method":"POST","url":"`+srv.URL+`/a","body":"a,b\n"}`, rule.ContentTypeCSV),
+		event(`{"method":"POST","url":"`+srv.URL+`/a","body":{"a":3}}`, ""),
+		event(`{"method":"POST","url":"`+srv.URL+`/b","body":"AAA"}`, rule.ContentTypeProtobuf),
+	})
+	var be *rule.BatchError
+	if !errors.As(err, &be) || len(be.Errs) != 3 || be.Errs[0] == nil || be.Errs[1] != nil || be.Errs[2] == nil {
+		t.Fatalf("expect the errors of the first and the last events, actual: %v", err)
+	}
+	if actual := <-rcv; actual != "/a application/json [{\"a\":3}]" {
+		t.Fatalf("expect the valid event sent, actual: %s", actual)
+	}
+
+	// the events of the failed request fail only
+	err = bd.DispatchBatch(context.Background(), []*rule.EventExt{
+		event(`{"method":"POST","url":"http://127.0.0.1:1/c","body":{"c":1}}`, ""),
+		event(`{"method":"POST","url":"`+srv.URL+`/a","body":{"a":4}}`, ""),
+	})
+	if !errors.As(err, &be) || be.Errs[0] == nil || be.Errs[1] != nil {
+		t.Fatalf("expect the error of the first event, actual: %v", err)
+	}
+	if actual := <-rcv; actual != "/a application/json [{\"a\":4}]" {
+		t.Fatalf("expect the valid event sent, actual: %s", actual)
+	}
+
+	// invalid batch
+	target.Batch = &rule.Batch{MaxLinger: time.Second}
+	_, err = NewDispatcher(context.Background(), log.DefaultLogger, target)
+	if err == nil {
+		t.Fatal("expect the batch max size error")
+	}
+	_, err = NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:  "noopDispatcher",
+		Batch: &rule.Batch{MaxSize: 10, MaxLinger: time.Second},
+	})
+	if err == nil {
+		t.Fatal("expect the batch unsupported error")
+	}
+}
@@ -0,0 +1,109 @@
+package target
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func init() {
+	registerDispatcher(
+		"KafkaDispatcher",
+		newKafkaDispatcher,
+		`
+		{
+		  "$schema": "https://json-schema.org/draft/2020-12/schema",
+		  "title": "Kafka dispatcher",
+		  "description": "The data format of the Kafka dispatcher",
+		  "type": "object",
+		  "properties": {
+			"brokers": {
+			  "description": "brokers are the comma separated addresses of the Kafka brokers like 127.0.0.1:9092",
+			  "type": "string"
+			},
+			"topic": {
+			  "description": "topic the message is produced to",
+			  "type": "string"
+			},
+			"key": {
+			  "description": "key of the message, the messages with the same key are produced to the same partition",
+			  "type": "string"
+			},
+			"headers": {
+			  "description": "headers of the message",
+			  "type": "object",
+			  "patternProperties": {
+				".*": {
+				  "type": "string"
+				}
+			  }
+			},
+			"body": {
+			  "description": "body is the value of the message, the object is sent as JSON and the string is sent as it is encoded by the target encoding",
+			  "type": ["object", "string"]
+			}
+		  },
+		  "required": [
+			"brokers",
+			"topic",
+			"body"
+		  ]
+		}`)
+}
+
+func newKafkaDispatcher(
+	_ context.Context,
+	logger log.Logger,
+	_ *rule.Target,
+	validator *gojsonschema.Schema,
+) (rule.Dispatcher, error) {
+	return newMQDispatcher(logger, "kafkaDispatcher", "brokers", "topic", newKafkaProducer, validator), nil
+}
+
+type kafkaProducer struct {
+	client *kgo.Client
+}
+
+func newKafkaProducer(brokers string, _ *mqMessage) (mqProducer, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(strings.Split(brokers, ",")...))
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaProducer{client: client}, nil
+}
+
+// send produces the message and waits for the acks of all the in-sync replicas.
+func (p *kafkaProducer) send(ctx context.Context, msg *mqMessage) error {
+	record := &kgo.Record{
+		Topic: msg.topic,
+		Value: msg.body,
+	}
+	if msg.key != "" {
+		record.Key = []byte(msg.key)
+	}
+	keys := make([]string, 0, len(msg.headers))
+	for k := range msg.headers {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: k, Value: []byte(msg.headers[k])})
+	}
+	if _, ok := msg.headers["content-type"]; !ok && msg.contentType != "" {
+		record.Headers = append(record.Headers, kgo.RecordHeader{
+			Key: "content-type", Value: []byte(msg.contentType),
+		})
+	}
+	return p.client.ProduceSync(ctx, record).FirstErr()
+}
+
+func (p *kafkaProducer) Close() error {
+	p.client.Close()
+	return nil
+}
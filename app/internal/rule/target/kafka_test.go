@@ -0,0 +1,111 @@
+package target
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// countProducers returns the number of the pooled producers of the message queue dispatcher.
+func countProducers(d rule.Dispatcher) int {
+	n := 0
+	d.(*dispatcher).dispatcher.(*mqDispatcher).producers.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestKafkaDispatcher(t *testing.T) {
+	c, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	brokers := strings.Join(c.ListenAddrs(), ",")
+
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "KafkaDispatcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	dispatchTests := []struct {
+		data        string
+		contentType string
+		key         string
+		headers     map[string]string
+		value       string
+	}{
+		{
+			data:    `{"brokers":"` + brokers + `","topic":"orders","key":"o1","headers":{"h":"v"},"body":{"a":1}}`,
+			key:     "o1",
+			headers: map[string]string{"h": "v", "content-type": rule.ContentTypeJSON},
+			value:   `{"a":1}`,
+		},
+		{
+			data:        `{"brokers":"` + brokers + `","topic":"orders","body":"<a>1</a>"}`,
+			contentType: rule.ContentTypeXML,
+			headers:     map[string]string{"content-type": rule.ContentTypeXML},
+			value:       `<a>1</a>`,
+		},
+	}
+	for idx, tt := range dispatchTests {
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 1, Data: tt.data, Datacontenttype: tt.contentType},
+			},
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+	}
+	if n := countProducers(d); n != 1 {
+		t.Fatalf("expect 1 producer of the brokers, got: %d", n)
+	}
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.ConsumeTopics("orders"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	records := make([]*kgo.Record, 0, len(dispatchTests))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for len(records) < len(dispatchTests) {
+		fetches := consumer.PollFetches(ctx)
+		if err = fetches.Err(); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, fetches.Records()...)
+	}
+	for idx, tt := range dispatchTests {
+		r := records[idx]
+		if string(r.Key) != tt.key || string(r.Value) != tt.value {
+			t.Errorf("case(index=%d) expect key: %s, value: %s, actual key: %s, value: %s",
+				idx, tt.key, tt.value, r.Key, r.Value)
+		}
+		headers := make(map[string]string, len(r.Headers))
+		for _, h := range r.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		for k, v := range tt.headers {
+			if headers[k] != v {
+				t.Errorf("case(index=%d) expect header %s: %s, actual: %s", idx, k, v, headers[k])
+			}
+		}
+	}
+}
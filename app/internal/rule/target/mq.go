@@ -0,0 +1,163 @@
+package target
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// mqMessage is the message of the target event sent to the message queue.
+type mqMessage struct {
+	topic       string // the topic, stream or subject
+	key         string // empty if the event has no key
+	headers     map[string]string
+	body        []byte
+	contentType string
+	// params are the params of the target event, for the params only used by one message queue
+	params map[string]interface{}
+}
+
+// mqProducer sends the messages to the brokers of an endpoint.
+type mqProducer interface {
+	send(ctx context.Context, msg *mqMessage) error
+	io.Closer
+}
+
+// newMQProducerFunc creates the producer of the endpoint, msg is the first message sent by the producer.
+type newMQProducerFunc func(endpoint string, msg *mqMessage) (mqProducer, error)
+
+// mqDispatcher dispatches the events to the message queue,
+// endpointParam and topicParam are the names of the params of the endpoint and the topic.
+type mqDispatcher struct {
+	log           *log.Helper
+	name          string
+	endpointParam string
+	topicParam    string
+	newProducer   newMQProducerFunc
+	producers     sync.Map // endpoint -> mqProducer
+	validator     *gojsonschema.Schema
+	// Only one check is required per dispatcher
+	validated int32
+}
+
+func newMQDispatcher(
+	logger log.Logger,
+	name string,
+	endpointParam string,
+	topicParam string,
+	newProducer newMQProducerFunc,
+	validator *gojsonschema.Schema,
+) *mqDispatcher {
+	return &mqDispatcher{
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "target/"+name,
+			"caller", log.DefaultCaller,
+		)),
+		name:          name,
+		endpointParam: endpointParam,
+		topicParam:    topicParam,
+		newProducer:   newProducer,
+		validator:     validator,
+	}
+}
+
+func (d *mqDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	endpoint, msg, err := d.parseMessage(event)
+	if err != nil {
+		return err
+	}
+	p, err := d.getProducer(endpoint, msg)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, msg)
+}
+
+func (d *mqDispatcher) parseMessage(event *rule.EventExt) (string, *mqMessage, error) {
+	// validate
+	val := atomic.LoadInt32(&d.validated)
+	if val == 0 {
+		result, err := d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
+		if err != nil {
+			return "", nil, err
+		}
+		if !result.Valid() {
+			return "", nil, fmt.Errorf(
+				"%s target event data is not valid. see err: %s",
+				d.name, result.Errors(),
+			)
+		}
+	}
+	atomic.AddInt32(&d.validated, 1)
+
+	// fetch params
+	jsonData := make(map[string]interface{})
+	_ = json.Unmarshal([]byte(event.Event.Data), &jsonData)
+	msg := &mqMessage{
+		topic:  jsonData[d.topicParam].(string),
+		params: jsonData,
+	}
+	if key, ok := jsonData["key"]; ok {
+		msg.key = key.(string)
+	}
+	if headerData, ok := jsonData["headers"]; ok {
+		headers := headerData.(map[string]interface{})
+		msg.headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			msg.headers[k] = v.(string)
+		}
+	}
+	var err error
+	msg.body, msg.contentType, err = encodedBody(event, jsonData["body"])
+	if err != nil {
+		return "", nil, err
+	}
+	return jsonData[d.endpointParam].(string), msg, nil
+}
+
+// getProducer returns the producer of the endpoint, the producers are pooled per endpoint.
+func (d *mqDispatcher) getProducer(endpoint string, msg *mqMessage) (mqProducer, error) {
+	if p, ok := d.producers.Load(endpoint); ok {
+		return p.(mqProducer), nil
+	}
+	p, err := d.newProducer(endpoint, msg)
+	if err != nil {
+		return nil, fmt.Errorf("create %s producer(%s) err: %w", d.name, endpoint, err)
+	}
+	actual, exist := d.producers.LoadOrStore(endpoint, p)
+	if exist { // store by other goroutine
+		err = p.Close()
+		if err != nil {
+			d.log.Errorf("close %s producer(%s) err: %s", d.name, endpoint, err)
+		}
+	}
+	return actual.(mqProducer), nil
+}
+
+func (d *mqDispatcher) Close() error {
+	errs := make([]string, 0)
+	d.producers.Range(func(key, _ interface{}) bool {
+		value, loaded := d.producers.LoadAndDelete(key)
+		if loaded {
+			err := value.(mqProducer).Close()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("close producer(%s) failed: %s", key, err))
+			}
+		}
+		return true
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("close %s failed: %s", d.name, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package target
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func init() {
+	registerDispatcher(
+		"NATSDispatcher",
+		newNATSDispatcher,
+		`
+		{
+		  "$schema": "https://json-schema.org/draft/2020-12/schema",
+		  "title": "NATS dispatcher",
+		  "description": "The data format of the NATS dispatcher",
+		  "type": "object",
+		  "properties": {
+			"url": {
+			  "description": "url is the comma separated urls of the NATS servers like nats://127.0.0.1:4222",
+			  "type": "string"
+			},
+			"subject": {
+			  "description": "subject the message is published to",
+			  "type": "string"
+			},
+			"key": {
+			  "description": "key is the Nats-Msg-Id header of the message, JetStream deduplicates the messages by it",
+			  "type": "string"
+			},
+			"jetStream": {
+			  "description": "jetStream publishes the message to JetStream and waits for the ack, otherwise the message is published to the core NATS",
+			  "type": "boolean"
+			},
+			"headers": {
+			  "description": "headers of the message",
+			  "type": "object",
+			  "patternProperties": {
+				".*": {
+				  "type": "string"
+				}
+			  }
+			},
+			"body": {
+			  "description": "body is the data of the message, the object is sent as JSON and the string is sent as it is encoded by the target encoding",
+			  "type": ["object", "string"]
+			}
+		  },
+		  "required": [
+			"url",
+			"subject",
+			"body"
+		  ]
+		}`)
+}
+
+func newNATSDispatcher(
+	_ context.Context,
+	logger log.Logger,
+	_ *rule.Target,
+	validator *gojsonschema.Schema,
+) (rule.Dispatcher, error) {
+	return newMQDispatcher(logger, "natsDispatcher", "url", "subject", newNATSProducer, validator), nil
+}
+
+type natsProducer struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+func newNATSProducer(url string, _ *mqMessage) (mqProducer, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsProducer{conn: conn, js: js}, nil
+}
+
+// send the message published to the core NATS is sent once the server receives it.
+func (p *natsProducer) send(ctx context.Context, msg *mqMessage) error {
+	m := nats.NewMsg(msg.topic)
+	m.Data = msg.body
+	for k, v := range msg.headers {
+		m.Header.Set(k, v)
+	}
+	if m.Header.Get("Content-Type") == "" && msg.contentType != "" {
+		m.Header.Set("Content-Type", msg.contentType)
+	}
+	if msg.key != "" {
+		m.Header.Set(jetstream.MsgIDHeader, msg.key)
+	}
+	if js, _ := msg.params["jetStream"].(bool); js {
+		_, err := p.js.PublishMsg(ctx, m)
+		return err
+	}
+	err := p.conn.PublishMsg(m)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok { // flushing with the context requires the deadline
+		return p.conn.Flush()
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *natsProducer) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,105 @@
+package target
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func runNATSServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server isn't ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNATSDispatcher(t *testing.T) {
+	s := runNATSServer(t)
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	sub, err := nc.SubscribeSync("orders.created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{
+		Name:     "PAYMENTS",
+		Subjects: []string{"payments.>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "NATSDispatcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	dispatch := func(data string) {
+		t.Helper()
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 1, Data: data},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// core NATS
+	dispatch(`{"url":"` + s.ClientURL() + `","subject":"orders.created","headers":{"h":"v"},"body":{"a":1}}`)
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != `{"a":1}` || msg.Header.Get("h") != "v" ||
+		msg.Header.Get("Content-Type") != rule.ContentTypeJSON {
+		t.Fatalf("unexpected message: %s, header: %v", msg.Data, msg.Header)
+	}
+
+	// JetStream deduplicates the messages by the key
+	for range 2 {
+		dispatch(`{"url":"` + s.ClientURL() + `","subject":"payments.paid","key":"p1","jetStream":true,"body":{"b":2}}`)
+	}
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("expect 1 message in the stream, got: %d", info.State.Msgs)
+	}
+	if n := countProducers(d); n != 1 {
+		t.Fatalf("expect 1 producer of the url, got: %d", n)
+	}
+}
@@ -0,0 +1,169 @@
+package target
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	// MetadataKeyCorrelationID is the metadata key of the ID of the target event
+	// whose response is published as the event by the on success of the target.
+	MetadataKeyCorrelationID = "x-eb-correlation-id"
+	// metadataKeyResponse is the metadata key of the response event which isn't posted yet,
+	// it is the base64 encoded protobuf of the event.
+	metadataKeyResponse = "x-eb-response"
+	// maxResponseBytes limits the response published as the event.
+	maxResponseBytes = 1 << 20
+)
+
+// responseDispatcher returns the response of the destination for the on success of the target,
+// the body is nil if the response has none.
+type responseDispatcher interface {
+	rule.Dispatcher
+	dispatchResponse(ctx context.Context, event *rule.EventExt) (body []byte, contentType string, err error)
+}
+
+// onSuccessDispatcher publishes the response of the target event into the bus of the on success.
+type onSuccessDispatcher struct {
+	dispatcher
+	responseDispatcher responseDispatcher
+	onSuccess          *rule.OnSuccess
+	sender             BusSender // nil if the dispatcher is only created to check the target
+}
+
+func onSuccessSyntaxCheck(onSuccess *rule.OnSuccess) error {
+	if onSuccess.BusName == "" {
+		return errors.New("on success bus name is required")
+	}
+	if onSuccess.Source == "" || onSuccess.Type == "" {
+		return errors.New("on success source and type are required")
+	}
+	return nil
+}
+
+// Dispatch the response is a new event whose correlation ID is the ID of the target event.
+// The response of the event routed into too many buses is dropped with an error log.
+// If the response can't be posted, such as the response not valid under its schema, it is kept
+// in the metadata of the target event, so the retried event posts it without dispatching the target again.
+// The target event is dispatched again only if its response can't be made into an event.
+func (d *onSuccessDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	if d.sender == nil {
+		return errors.New("on success of the target has no bus sender")
+	}
+	if val, ok := event.Metadata[metadataKeyResponse]; ok {
+		evt, err := decodeResponse(val)
+		if err != nil {
+			return fmt.Errorf("response of event(%s) err: %w", event.Key(), err)
+		}
+		return d.post(ctx, event, evt)
+	}
+	body, contentType, err := d.responseDispatcher.dispatchResponse(ctx, event)
+	if err != nil {
+		return err
+	}
+	hops, err := eventHops(event)
+	if err != nil {
+		return err
+	}
+	if hops >= defaultMaxHops {
+		d.log.WithContext(ctx).Errorf(
+			"drop the response of event(%s) routed into %d buses, the buses may be in a loop. from bus: %s, to bus: %s",
+			event.Key(), hops, event.BusName, d.onSuccess.BusName,
+		)
+		return nil
+	}
+	evt, err := d.newResponse(event, hops, body, contentType)
+	if err != nil {
+		return err
+	}
+	err = d.post(ctx, event, evt)
+	if err != nil {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string, 1)
+		}
+		event.Metadata[metadataKeyResponse] = base64.StdEncoding.EncodeToString(evt.Value())
+		return rule.NewResponseNotPostedError(err)
+	}
+	return nil
+}
+
+// newResponse returns the response event of the target event.
+func (d *onSuccessDispatcher) newResponse(
+	event *rule.EventExt, hops int, body []byte, contentType string,
+) (*rule.EventExt, error) {
+	data, err := responseData(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("response of event(%s) err: %w", event.Key(), err)
+	}
+	evt, err := rule.NewEventExt(&v1.Event{
+		Source:          d.onSuccess.Source,
+		Subject:         event.Event.Subject,
+		Type:            d.onSuccess.Type,
+		Time:            timestamppb.Now(),
+		Data:            data,
+		Datacontenttype: rule.ContentTypeJSON,
+	}, event.RetryStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("response of event(%s) err: %w", event.Key(), err)
+	}
+	evt.BusName = d.onSuccess.BusName
+	evt.Metadata = make(map[string]string, len(event.Metadata)+2)
+	for k, v := range event.Metadata {
+		evt.Metadata[k] = v
+	}
+	evt.Metadata[MetadataKeyHops] = strconv.Itoa(hops + 1)
+	evt.Metadata[MetadataKeyCorrelationID] = strconv.FormatUint(event.Event.Id, 10)
+	return evt, nil
+}
+
+func (d *onSuccessDispatcher) post(ctx context.Context, event *rule.EventExt, evt *rule.EventExt) error {
+	err := d.sender.PostToBus(ctx, d.onSuccess.BusName, evt)
+	if err != nil {
+		return fmt.Errorf("post the response of event(%s) err: %w", event.Key(), err)
+	}
+	return nil
+}
+
+// decodeResponse returns the response kept in the metadata of the target event.
+func decodeResponse(val string) (*rule.EventExt, error) {
+	b, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	return rule.NewEventExtFromBytes(b)
+}
+
+// responseData returns the JSON data of the response event. The JSON response is the data as it is,
+// the protobuf response is the base64 encoded string and the other response is the string of its body.
+// The response without body is null.
+func responseData(body []byte, contentType string) (string, error) {
+	if len(body) == 0 {
+		return "null", nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == rule.ContentTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		if !jsontext.Value(body).IsValid() {
+			return "", fmt.Errorf("response of %s is not valid JSON", contentType)
+		}
+		return string(body), nil
+	case mediaType == rule.ContentTypeProtobuf:
+		data, err := json.Marshal(base64.StdEncoding.EncodeToString(body))
+		return string(data), err
+	default:
+		data, err := json.Marshal(string(body), jsontext.AllowInvalidUTF8(true))
+		return string(data), err
+	}
+}
@@ -0,0 +1,200 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func TestOnSuccessDispatcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"price":10}`))
+	}))
+	defer srv.Close()
+	onSuccess := &rule.OnSuccess{BusName: "bus2", Source: "pricing", Type: "price:quoted"}
+	data := `{"method":"POST","url":"` + srv.URL + `","body":{"a":1}}`
+
+	dispatchTests := []struct {
+		name     string
+		metadata map[string]string
+		postErr  error
+		sent     bool
+		hops     string
+		err      bool
+	}{
+		{
+			name:     "published",
+			metadata: map[string]string{"traceparent": "tp"},
+			sent:     true,
+			hops:     "1",
+		},
+		{
+			name:     "dropped in a loop",
+			metadata: map[string]string{MetadataKeyHops: "8"},
+		},
+		{
+			name:    "not valid under the schema",
+			postErr: errors.New("event data is not valid"),
+			err:     true,
+		},
+	}
+	for _, tt := range dispatchTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &busSender{postErr: tt.postErr}
+			d, err := NewNewDispatcherFunc(s)(context.Background(), log.DefaultLogger, &rule.Target{
+				Type:      "HTTPDispatcher",
+				OnSuccess: onSuccess,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = d.Dispatch(context.Background(), &rule.EventExt{
+				EventExt: &v1.EventExt{
+					Event:    &v1.Event{Id: 10188, Source: "orders", Type: "order:created", Data: data},
+					BusName:  "bus1",
+					Metadata: tt.metadata,
+				},
+			})
+			if (err != nil) != tt.err {
+				t.Fatalf("expect err: %t, got: %v", tt.err, err)
+			}
+			if !tt.sent {
+				if s.event != nil {
+					t.Fatalf("expect the response dropped, got: %v", s.event)
+				}
+				return
+			}
+			if !s.posted || s.busName != "bus2" || s.event.BusName != "bus2" {
+				t.Fatalf("expect the response published into bus2, got: %s", s.busName)
+			}
+			e := s.event.Event
+			if e.Id == 0 || e.Id == 10188 || e.Source != "pricing" || e.Type != "price:quoted" ||
+				e.Data != `{"price":10}` || e.Datacontenttype != rule.ContentTypeJSON {
+				t.Fatalf("unexpected response event: %v", e)
+			}
+			if s.event.Metadata[MetadataKeyCorrelationID] != "10188" || s.event.Metadata[MetadataKeyHops] != tt.hops ||
+				s.event.Metadata["traceparent"] != "tp" {
+				t.Fatalf("unexpected metadata: %v", s.event.Metadata)
+			}
+		})
+	}
+}
+
+func TestOnSuccessDispatcherRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", rule.ContentTypeJSON)
+		_, _ = w.Write([]byte(`{"price":10}`))
+	}))
+	defer srv.Close()
+	s := &busSender{postErr: errors.New("send to bus err")}
+	d, err := NewNewDispatcherFunc(s)(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:      "HTTPDispatcher",
+		OnSuccess: &rule.OnSuccess{BusName: "bus2", Source: "pricing", Type: "price:quoted"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{
+				Id: 10188, Source: "orders", Type: "order:created",
+				Data: `{"method":"POST","url":"` + srv.URL + `","body":{"a":1}}`,
+			},
+			BusName: "bus1",
+		},
+	}
+	err = d.Dispatch(context.Background(), evt)
+	if !rule.IsResponseNotPostedError(err) {
+		t.Fatalf("expect the response not posted, got: %v", err)
+	}
+	if evt.Metadata[metadataKeyResponse] == "" {
+		t.Fatal("expect the response kept by the target event")
+	}
+
+	// the retried event posts the response without dispatching the target again
+	s.postErr = nil
+	err = d.Dispatch(context.Background(), evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expect the target dispatched once, got: %d", calls.Load())
+	}
+	if !s.posted || s.event.Event.Data != `{"price":10}` ||
+		s.event.Metadata[MetadataKeyCorrelationID] != "10188" {
+		t.Fatalf("unexpected response event: %v", s.event)
+	}
+	if _, ok := s.event.Metadata[metadataKeyResponse]; ok {
+		t.Fatal("expect the response not kept by the response event")
+	}
+}
+
+func TestOnSuccessSyntaxCheck(t *testing.T) {
+	onSuccess := &rule.OnSuccess{BusName: "bus2", Source: "pricing", Type: "price:quoted"}
+	checkTests := []struct {
+		name   string
+		target *rule.Target
+	}{
+		{
+			name:   "no bus",
+			target: &rule.Target{Type: "HTTPDispatcher", OnSuccess: &rule.OnSuccess{Source: "s", Type: "t"}},
+		},
+		{
+			name:   "no type",
+			target: &rule.Target{Type: "HTTPDispatcher", OnSuccess: &rule.OnSuccess{BusName: "b", Source: "s"}},
+		},
+		{
+			name: "batch",
+			target: &rule.Target{
+				Type:      "HTTPDispatcher",
+				OnSuccess: onSuccess,
+				Batch:     &rule.Batch{MaxSize: 10, MaxLinger: 1},
+			},
+		},
+		{
+			name:   "no response",
+			target: &rule.Target{Type: "KafkaDispatcher", OnSuccess: onSuccess},
+		},
+	}
+	for _, tt := range checkTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDispatcher(context.Background(), log.DefaultLogger, tt.target)
+			if err == nil {
+				t.Fatal("expect err, got nil")
+			}
+		})
+	}
+}
+
+func TestResponseData(t *testing.T) {
+	dataTests := []struct {
+		body        string
+		contentType string
+		data        string
+		err         bool
+	}{
+		{body: "", contentType: rule.ContentTypeJSON, data: "null"},
+		{body: `{"a":1}`, contentType: "application/json; charset=utf-8", data: `{"a":1}`},
+		{body: `{"a":1}`, contentType: "application/problem+json", data: `{"a":1}`},
+		{body: `{"a":`, contentType: rule.ContentTypeJSON, err: true},
+		{body: "<a>1</a>", contentType: rule.ContentTypeXML, data: `"<a>1</a>"`},
+		{body: "\x08\x01", contentType: rule.ContentTypeProtobuf, data: `"CAE="`},
+	}
+	for idx, tt := range dataTests {
+		data, err := responseData([]byte(tt.body), tt.contentType)
+		if (err != nil) != tt.err || data != tt.data {
+			t.Errorf("case(index=%d) expect data: %s, err: %t, got data: %s, err: %v", idx, tt.data, tt.err, data, err)
+		}
+	}
+}
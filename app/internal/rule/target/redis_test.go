@@ -0,0 +1,65 @@
+package target
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func TestRedisStreamsDispatcher(t *testing.T) {
+	mr := miniredis.RunT(t)
+	url := "redis://" + mr.Addr()
+
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "RedisStreamsDispatcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	for i := range 3 {
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{
+					Id: 1,
+					Data: `{"url":"` + url + `","stream":"orders","key":"o` + strconv.Itoa(i) +
+						`","maxLen":2,"headers":{"h":"v","body":"overridden"},"body":{"a":1}}`,
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := mr.Stream("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expect the stream trimmed to 2 entries, got: %d", len(entries))
+	}
+	values := make(map[string]string)
+	for i := 0; i+1 < len(entries[1].Values); i += 2 {
+		values[entries[1].Values[i]] = entries[1].Values[i+1]
+	}
+	expected := map[string]string{
+		"h":           "v",
+		"key":         "o2",
+		"contentType": rule.ContentTypeJSON,
+		"body":        `{"a":1}`,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expect entry: %v, got: %v", expected, values)
+	}
+	if n := countProducers(d); n != 1 {
+		t.Fatalf("expect 1 producer of the url, got: %d", n)
+	}
+}
@@ -0,0 +1,124 @@
+package target
+
+import (
+	"context"
+
+	rmqClient "github.com/apache/rocketmq-clients/golang/v5"
+	"github.com/apache/rocketmq-clients/golang/v5/credentials"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func init() {
+	registerDispatcher(
+		"RocketMQDispatcher",
+		newRocketMQDispatcher,
+		`
+		{
+		  "$schema": "https://json-schema.org/draft/2020-12/schema",
+		  "title": "RocketMQ dispatcher",
+		  "description": "The data format of the RocketMQ dispatcher",
+		  "type": "object",
+		  "properties": {
+			"endpoints": {
+			  "description": "endpoints of the RocketMQ proxy like 127.0.0.1:8081",
+			  "type": "string"
+			},
+			"topic": {
+			  "description": "topic the message is sent to",
+			  "type": "string"
+			},
+			"key": {
+			  "description": "key is the key of the message to query it",
+			  "type": "string"
+			},
+			"messageGroup": {
+			  "description": "messageGroup is required by the FIFO topic, the messages in a group are consumed in order",
+			  "type": "string"
+			},
+			"headers": {
+			  "description": "headers are the properties of the message",
+			  "type": "object",
+			  "patternProperties": {
+				".*": {
+				  "type": "string"
+				}
+			  }
+			},
+			"body": {
+			  "description": "body of the message, the object is sent as JSON and the string is sent as it is encoded by the target encoding",
+			  "type": ["object", "string"]
+			}
+		  },
+		  "required": [
+			"endpoints",
+			"topic",
+			"body"
+		  ]
+		}`)
+}
+
+func newRocketMQDispatcher(
+	_ context.Context,
+	logger log.Logger,
+	_ *rule.Target,
+	validator *gojsonschema.Schema,
+) (rule.Dispatcher, error) {
+	return newMQDispatcher(
+		logger, "rocketMQDispatcher", "endpoints", "topic", newRocketMQProducer, validator,
+	), nil
+}
+
+type rocketMQProducer struct {
+	p rmqClient.Producer
+}
+
+// newRocketMQProducer the producer fetches the route of the topic of the first message when it starts,
+// and the routes of the other topics when they are sent to.
+func newRocketMQProducer(endpoints string, msg *mqMessage) (mqProducer, error) {
+	producer, err := rmqClient.NewProducer(
+		&rmqClient.Config{
+			Endpoint: endpoints,
+			Credentials: &credentials.SessionCredentials{
+				AccessKey:    "",
+				AccessSecret: "",
+			},
+		},
+		rmqClient.WithTopics(msg.topic),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = producer.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &rocketMQProducer{p: producer}, nil
+}
+
+func (p *rocketMQProducer) send(ctx context.Context, msg *mqMessage) error {
+	m := &rmqClient.Message{
+		Topic: msg.topic,
+		Body:  msg.body,
+	}
+	if msg.key != "" {
+		m.SetKeys(msg.key)
+	}
+	if group, _ := msg.params["messageGroup"].(string); group != "" {
+		m.SetMessageGroup(group)
+	}
+	for k, v := range msg.headers {
+		m.AddProperty(k, v)
+	}
+	if _, ok := msg.headers["content-type"]; !ok && msg.contentType != "" {
+		m.AddProperty("content-type", msg.contentType)
+	}
+	_, err := p.p.Send(ctx, m)
+	return err
+}
+
+func (p *rocketMQProducer) Close() error {
+	return p.p.GracefulStop()
+}
@@ -0,0 +1,153 @@
+package target
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	rmqClient "github.com/apache/rocketmq-clients/golang/v5"
+	v2 "github.com/apache/rocketmq-clients/golang/v5/protocol/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/grpc"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// rocketMQProxy is an in-process stand-in of the RocketMQ proxy, it serves a broker of one queue per topic.
+type rocketMQProxy struct {
+	v2.UnimplementedMessagingServiceServer
+	endpoints *v2.Endpoints
+
+	mu       sync.Mutex
+	messages []*v2.Message
+}
+
+func runRocketMQProxy(t *testing.T) (*rocketMQProxy, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().(*net.TCPAddr)
+	p := &rocketMQProxy{
+		endpoints: &v2.Endpoints{
+			Scheme:    v2.AddressScheme_IPv4,
+			Addresses: []*v2.Address{{Host: addr.IP.String(), Port: int32(addr.Port)}},
+		},
+	}
+	s := grpc.NewServer()
+	v2.RegisterMessagingServiceServer(s, p)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+	return p, addr.String()
+}
+
+func (p *rocketMQProxy) QueryRoute(_ context.Context, req *v2.QueryRouteRequest) (*v2.QueryRouteResponse, error) {
+	return &v2.QueryRouteResponse{
+		Status: &v2.Status{Code: v2.Code_OK},
+		MessageQueues: []*v2.MessageQueue{{
+			Topic:              req.GetTopic(),
+			Permission:         v2.Permission_READ_WRITE,
+			Broker:             &v2.Broker{Name: "broker", Endpoints: p.endpoints},
+			AcceptMessageTypes: []v2.MessageType{v2.MessageType_NORMAL, v2.MessageType_FIFO},
+		}},
+	}, nil
+}
+
+func (p *rocketMQProxy) Heartbeat(context.Context, *v2.HeartbeatRequest) (*v2.HeartbeatResponse, error) {
+	return &v2.HeartbeatResponse{Status: &v2.Status{Code: v2.Code_OK}}, nil
+}
+
+func (p *rocketMQProxy) NotifyClientTermination(
+	context.Context, *v2.NotifyClientTerminationRequest,
+) (*v2.NotifyClientTerminationResponse, error) {
+	return &v2.NotifyClientTerminationResponse{Status: &v2.Status{Code: v2.Code_OK}}, nil
+}
+
+// Telemetry replies the settings of the producer to finish its start.
+func (p *rocketMQProxy) Telemetry(stream v2.MessagingService_TelemetryServer) error {
+	for {
+		cmd, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if cmd.GetSettings() == nil {
+			continue
+		}
+		err = stream.Send(&v2.TelemetryCommand{
+			Command: &v2.TelemetryCommand_Settings{Settings: &v2.Settings{
+				PubSub: &v2.Settings_Publishing{Publishing: &v2.Publishing{MaxBodySize: 4 << 20}},
+			}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *rocketMQProxy) SendMessage(_ context.Context, req *v2.SendMessageRequest) (*v2.SendMessageResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, req.GetMessages()...)
+	entries := make([]*v2.SendResultEntry, 0, len(req.GetMessages()))
+	for _, m := range req.GetMessages() {
+		entries = append(entries, &v2.SendResultEntry{
+			Status:    &v2.Status{Code: v2.Code_OK},
+			MessageId: m.GetSystemProperties().GetMessageId(),
+		})
+	}
+	return &v2.SendMessageResponse{Status: &v2.Status{Code: v2.Code_OK}, Entries: entries}, nil
+}
+
+func TestRocketMQDispatcher(t *testing.T) {
+	enableSsl := rmqClient.EnableSsl
+	rmqClient.EnableSsl = false
+	defer func() {
+		rmqClient.EnableSsl = enableSsl
+	}()
+	proxy, endpoints := runRocketMQProxy(t)
+
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{Type: "RocketMQDispatcher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	for _, data := range []string{
+		`{"endpoints":"` + endpoints + `","topic":"orders","key":"o1","headers":{"h":"v"},"body":{"a":1}}`,
+		`{"endpoints":"` + endpoints + `","topic":"payments","messageGroup":"g1","body":{"b":2}}`,
+	} {
+		err = d.Dispatch(context.Background(), &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 1, Data: data},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := countProducers(d); n != 1 {
+		t.Fatalf("expect 1 producer of the endpoints, got: %d", n)
+	}
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.messages) != 2 {
+		t.Fatalf("expect 2 messages, got: %d", len(proxy.messages))
+	}
+	m := proxy.messages[0]
+	if m.GetTopic().GetName() != "orders" || string(m.GetBody()) != `{"a":1}` ||
+		len(m.GetSystemProperties().GetKeys()) != 1 || m.GetSystemProperties().GetKeys()[0] != "o1" ||
+		m.GetUserProperties()["h"] != "v" || m.GetUserProperties()["content-type"] != rule.ContentTypeJSON {
+		t.Fatalf("unexpected message: %v", m)
+	}
+	m = proxy.messages[1]
+	if m.GetTopic().GetName() != "payments" || m.GetSystemProperties().GetMessageGroup() != "g1" {
+		t.Fatalf("unexpected message: %v", m)
+	}
+}
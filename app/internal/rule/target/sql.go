@@ -0,0 +1,451 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+
+	// init db driver
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+// sqlIdentifier is the name of the table or the column, the table may be qualified by its schema.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlDialect writes the statements of a database, it is chosen by the driver of the DSN connection.
+type sqlDialect struct {
+	driverName  string
+	quote       string
+	placeholder func(n int) string
+	// upsert returns the clause updating the columns other than the conflict keys if the row exists.
+	upsert func(d *sqlDialect, columns []string, conflictKeys []string) string
+}
+
+var sqlDialects = map[string]*sqlDialect{
+	"mysql": {
+		driverName: "mysql",
+		quote:      "`",
+		placeholder: func(int) string {
+			return "?"
+		},
+		upsert: mysqlUpsert,
+	},
+	"postgres": {
+		driverName: "pgx",
+		quote:      `"`,
+		placeholder: func(n int) string {
+			return "$" + strconv.Itoa(n)
+		},
+		upsert: postgresUpsert,
+	},
+}
+
+func init() {
+	registerDispatcher(
+		"SQLDispatcher",
+		newSQLDispatcher,
+		`
+		{
+		  "$schema": "https://json-schema.org/draft/2020-12/schema",
+		  "title": "SQL dispatcher",
+		  "description": "The data format of the SQL dispatcher, the database is the DSN connection of the target",
+		  "type": "object",
+		  "properties": {
+			"table": {
+			  "description": "table the row is inserted into, it may be qualified by the schema like audit.events",
+			  "type": "string"
+			},
+			"columns": {
+			  "description": "columns of the row, the object and array values are inserted as JSON",
+			  "type": "object",
+			  "minProperties": 1
+			},
+			"conflictKeys": {
+			  "description": "conflictKeys are the columns of the unique key, the other columns are updated if the row exists",
+			  "type": "array",
+			  "items": {
+				"type": "string"
+			  }
+			}
+		  },
+		  "required": [
+			"table",
+			"columns"
+		  ]
+		}`)
+}
+
+// dsnSyntaxCheck checks the driver of the DSN connection, the DSN is checked when it is opened.
+func dsnSyntaxCheck(conn *rule.Connection) error {
+	if _, ok := sqlDialects[conn.Key]; !ok {
+		return fmt.Errorf("DSN driver(%s) should be mysql or postgres", conn.Key)
+	}
+	return nil
+}
+
+type sqlParams struct {
+	Table        string                    `json:"table"`
+	Columns      map[string]jsontext.Value `json:"columns"`
+	ConflictKeys []string                  `json:"conflictKeys"`
+}
+
+// sqlRow is the row of the target event, the columns are sorted.
+type sqlRow struct {
+	table        string
+	columns      []string
+	values       []interface{}
+	conflictKeys []string
+}
+
+// statementKey is the same for the rows inserted by the same statement.
+func (r *sqlRow) statementKey() string {
+	return r.table + "\x00" + strings.Join(r.columns, ",") + "\x00" + strings.Join(r.conflictKeys, ",")
+}
+
+// conflictKey is the same for the rows of the same unique key.
+func (r *sqlRow) conflictKey() (string, error) {
+	values := make([]interface{}, 0, len(r.conflictKeys))
+	for _, k := range r.conflictKeys {
+		idx, _ := slices.BinarySearch(r.columns, k)
+		values = append(values, r.values[idx])
+	}
+	key, err := json.Marshal(values)
+	return string(key), err
+}
+
+type sqlDispatcher struct {
+	log        *log.Helper
+	connection string
+	dialect    *sqlDialect
+	dsn        string
+	validator  *gojsonschema.Schema
+	// Only one check is required per dispatcher
+	validated int32
+
+	mu sync.Mutex
+	db *sql.DB // opened on the first dispatch
+}
+
+func newSQLDispatcher(
+	_ context.Context,
+	logger log.Logger,
+	target *rule.Target,
+	validator *gojsonschema.Schema,
+) (rule.Dispatcher, error) {
+	if target.Connection == "" {
+		return nil, errors.New("SQLDispatcher requires the connection of DSN")
+	}
+	if target.Auth == nil {
+		return nil, fmt.Errorf("connection(%s) not found", target.Connection)
+	}
+	if target.Auth.AuthType != rule.AuthTypeDSN {
+		return nil, fmt.Errorf(
+			"connection(%s) of SQLDispatcher should be DSN, got: %s", target.Connection, target.Auth.AuthType,
+		)
+	}
+	dialect, ok := sqlDialects[target.Auth.Key]
+	if !ok {
+		return nil, fmt.Errorf("connection(%s) DSN driver(%s) should be mysql or postgres", target.Connection, target.Auth.Key)
+	}
+	return &sqlDispatcher{
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "target/sqlDispatcher",
+			"caller", log.DefaultCaller,
+		)),
+		connection: target.Connection,
+		dialect:    dialect,
+		dsn:        target.Auth.Secret,
+		validator:  validator,
+	}, nil
+}
+
+func (d *sqlDispatcher) Dispatch(ctx context.Context, event *rule.EventExt) error {
+	row, err := d.parseRow(event)
+	if err != nil {
+		return err
+	}
+	db, err := d.getDB()
+	if err != nil {
+		return err
+	}
+	query, args := d.insertStatement([]*sqlRow{row})
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// DispatchBatch inserts the rows of the same table and columns by one statement.
+// The later row of the same conflict keys takes precedence, since a statement can't update a row twice.
+// All the events are parsed before any statement is executed, and the error of each event is reported
+// by rule.BatchError, so only the invalid events and the events of the failed statements are retried.
+func (d *sqlDispatcher) DispatchBatch(ctx context.Context, events []*rule.EventExt) error {
+	errs := make([]error, len(events))
+	failed := false
+	groups := make(map[string][]*sqlRow)
+	idxs := make(map[string][]int) // statement key -> indexes of the events, including the replaced rows
+	keys := make([]string, 0)
+	conflicts := make(map[string]map[string]int) // statement key -> conflict key -> index in the group
+	for i, event := range events {
+		row, ck, err := d.parseBatchRow(event)
+		if err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+		key := row.statementKey()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			conflicts[key] = make(map[string]int)
+		}
+		idxs[key] = append(idxs[key], i)
+		if len(row.conflictKeys) > 0 {
+			if idx, ok := conflicts[key][ck]; ok {
+				groups[key][idx] = row
+				continue
+			}
+			conflicts[key][ck] = len(groups[key])
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(keys) > 0 {
+		db, err := d.getDB()
+		if err != nil {
+			for _, key := range keys {
+				for _, i := range idxs[key] {
+					errs[i] = err
+				}
+			}
+			return &rule.BatchError{Errs: errs}
+		}
+		for _, key := range keys {
+			query, args := d.insertStatement(groups[key])
+			_, err = db.ExecContext(ctx, query, args...)
+			if err != nil {
+				for _, i := range idxs[key] {
+					errs[i] = err
+				}
+				failed = true
+			}
+		}
+	}
+	if failed {
+		return &rule.BatchError{Errs: errs}
+	}
+	return nil
+}
+
+// parseBatchRow returns the row of the event and its conflict key.
+func (d *sqlDispatcher) parseBatchRow(event *rule.EventExt) (*sqlRow, string, error) {
+	row, err := d.parseRow(event)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(row.conflictKeys) == 0 {
+		return row, "", nil
+	}
+	ck, err := row.conflictKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return row, ck, nil
+}
+
+func (d *sqlDispatcher) parseRow(event *rule.EventExt) (*sqlRow, error) {
+	// validate
+	val := atomic.LoadInt32(&d.validated)
+	if val == 0 {
+		result, err := d.validator.Validate(gojsonschema.NewStringLoader(event.Event.Data))
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid() {
+			return nil, fmt.Errorf(
+				"sql dispatcher target event data is not valid. see err: %s",
+				result.Errors(),
+			)
+		}
+	}
+	atomic.AddInt32(&d.validated, 1)
+
+	// fetch params
+	params := &sqlParams{}
+	err := json.Unmarshal([]byte(event.Event.Data), params)
+	if err != nil {
+		return nil, err
+	}
+	// the identifiers are checked for each event since they are written into the statement
+	parts := strings.Split(params.Table, ".")
+	if len(parts) > 2 || !sqlIdentifier.MatchString(parts[0]) ||
+		(len(parts) == 2 && !sqlIdentifier.MatchString(parts[1])) {
+		return nil, fmt.Errorf("sql dispatcher table(%s) is not a valid identifier", params.Table)
+	}
+	if len(params.Columns) == 0 {
+		return nil, errors.New("sql dispatcher columns should not be empty")
+	}
+	row := &sqlRow{
+		table:        params.Table,
+		columns:      make([]string, 0, len(params.Columns)),
+		values:       make([]interface{}, 0, len(params.Columns)),
+		conflictKeys: params.ConflictKeys,
+	}
+	for column := range params.Columns {
+		if !sqlIdentifier.MatchString(column) {
+			return nil, fmt.Errorf("sql dispatcher column(%s) is not a valid identifier", column)
+		}
+		row.columns = append(row.columns, column)
+	}
+	slices.Sort(row.columns)
+	for _, column := range row.columns {
+		var v interface{}
+		v, err = sqlValue(params.Columns[column])
+		if err != nil {
+			return nil, fmt.Errorf("sql dispatcher column(%s) err: %w", column, err)
+		}
+		row.values = append(row.values, v)
+	}
+	for _, k := range row.conflictKeys {
+		if _, found := slices.BinarySearch(row.columns, k); !found {
+			return nil, fmt.Errorf("sql dispatcher conflict key(%s) should be one of the columns", k)
+		}
+	}
+	return row, nil
+}
+
+// sqlValue converts the JSON value to the argument of the statement.
+func sqlValue(v jsontext.Value) (interface{}, error) {
+	switch v.Kind() {
+	case 'n':
+		return nil, nil
+	case 't', 'f':
+		return v.Kind() == 't', nil
+	case '"':
+		var s string
+		err := json.Unmarshal(v, &s)
+		return s, err
+	case '0':
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(string(v), 64)
+	default: // object and array
+		return string(v), nil
+	}
+}
+
+// insertStatement returns the statement inserting the rows, which have the same table and columns.
+func (d *sqlDispatcher) insertStatement(rows []*sqlRow) (string, []interface{}) {
+	first := rows[0]
+	columns := make([]string, 0, len(first.columns))
+	for _, c := range first.columns {
+		columns = append(columns, d.dialect.quoteIdentifier(c))
+	}
+	b := strings.Builder{}
+	b.WriteString("INSERT INTO ")
+	b.WriteString(d.dialect.quoteIdentifier(first.table))
+	b.WriteString(" (")
+	b.WriteString(strings.Join(columns, ", "))
+	b.WriteString(") VALUES ")
+	args := make([]interface{}, 0, len(rows)*len(first.columns))
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range row.values {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, v)
+			b.WriteString(d.dialect.placeholder(len(args)))
+		}
+		b.WriteByte(')')
+	}
+	if len(first.conflictKeys) > 0 {
+		b.WriteString(d.dialect.upsert(d.dialect, first.columns, first.conflictKeys))
+	}
+	return b.String(), args
+}
+
+// quoteIdentifier quotes the identifier, and each part of the qualified table.
+func (d *sqlDialect) quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = d.quote + p + d.quote
+	}
+	return strings.Join(parts, ".")
+}
+
+func mysqlUpsert(d *sqlDialect, columns []string, conflictKeys []string) string {
+	sets := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if !slices.Contains(conflictKeys, c) {
+			q := d.quoteIdentifier(c)
+			sets = append(sets, q+" = VALUES("+q+")")
+		}
+	}
+	if len(sets) == 0 { // keep the row as it is
+		q := d.quoteIdentifier(conflictKeys[0])
+		sets = append(sets, q+" = "+q)
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func postgresUpsert(d *sqlDialect, columns []string, conflictKeys []string) string {
+	keys := make([]string, 0, len(conflictKeys))
+	for _, k := range conflictKeys {
+		keys = append(keys, d.quoteIdentifier(k))
+	}
+	sets := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if !slices.Contains(conflictKeys, c) {
+			q := d.quoteIdentifier(c)
+			sets = append(sets, q+" = EXCLUDED."+q)
+		}
+	}
+	clause := " ON CONFLICT (" + strings.Join(keys, ", ") + ")"
+	if len(sets) == 0 {
+		return clause + " DO NOTHING"
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// getDB opens the database of the connection on the first dispatch,
+// so that the dispatcher created by the syntax check doesn't need the DSN.
+func (d *sqlDispatcher) getDB() (*sql.DB, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db == nil {
+		db, err := sql.Open(d.dialect.driverName, d.dsn)
+		if err != nil {
+			return nil, fmt.Errorf("open the database of connection(%s) err: %w", d.connection, err)
+		}
+		d.db = db
+	}
+	return d.db, nil
+}
+
+func (d *sqlDispatcher) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db == nil {
+		return nil
+	}
+	err := d.db.Close()
+	d.db = nil
+	return err
+}
@@ -0,0 +1,299 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// sqlRecorder records the statements executed by the connections of a DSN.
+type sqlRecorder struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]interface{}
+}
+
+var sqlRecorders sync.Map // dsn -> *sqlRecorder
+
+func (r *sqlRecorder) record(query string, args []interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, query)
+	r.args = append(r.args, args)
+}
+
+// fakeSQLDriver is a database/sql driver recording the statements instead of executing them.
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(dsn string) (driver.Conn, error) {
+	r, _ := sqlRecorders.LoadOrStore(dsn, &sqlRecorder{})
+	return &fakeSQLConn{r: r.(*sqlRecorder)}, nil
+}
+
+type fakeSQLConn struct {
+	r *sqlRecorder
+}
+
+func (c *fakeSQLConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	c.r.record("BEGIN", nil)
+	return c, nil
+}
+
+func (c *fakeSQLConn) Commit() error {
+	c.r.record("COMMIT", nil)
+	return nil
+}
+
+func (c *fakeSQLConn) Rollback() error {
+	c.r.record("ROLLBACK", nil)
+	return nil
+}
+
+func (c *fakeSQLConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]interface{}, 0, len(args))
+	for _, a := range args {
+		values = append(values, a.Value)
+	}
+	c.r.record(query, values)
+	if strings.Contains(query, `"broken"`) {
+		return nil, errors.New(`relation "broken" does not exist`)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func init() {
+	sql.Register("sqltest", fakeSQLDriver{})
+}
+
+// useFakeSQLDriver makes the dialects open the databases by the fake driver.
+func useFakeSQLDriver(t *testing.T) {
+	t.Helper()
+	dialects := sqlDialects
+	sqlDialects = make(map[string]*sqlDialect, len(dialects))
+	for name, d := range dialects {
+		fake := *d
+		fake.driverName = "sqltest"
+		sqlDialects[name] = &fake
+	}
+	t.Cleanup(func() {
+		sqlDialects = dialects
+	})
+}
+
+func newSQLTestDispatcher(t *testing.T, driverName string, dsn string) rule.Dispatcher {
+	t.Helper()
+	d, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:       "SQLDispatcher",
+		Connection: "audit",
+		Auth:       &rule.Connection{Name: "audit", AuthType: rule.AuthTypeDSN, Key: driverName, Secret: dsn},
+		Batch:      &rule.Batch{MaxSize: 10, MaxLinger: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	return d
+}
+
+func sqlTestEvent(data string) *rule.EventExt {
+	return &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{Id: 1, Data: data},
+		},
+	}
+}
+
+func TestDSNConnectionSyntaxCheck(t *testing.T) {
+	checkTests := []struct {
+		conn *rule.Connection
+		ok   bool
+	}{
+		{conn: &rule.Connection{AuthType: rule.AuthTypeDSN, Key: "mysql"}, ok: true},
+		{conn: &rule.Connection{AuthType: rule.AuthTypeDSN, Key: "postgres"}, ok: true},
+		{conn: &rule.Connection{AuthType: rule.AuthTypeDSN, Key: "sqlite"}},
+	}
+	for idx, tt := range checkTests {
+		err := ConnectionSyntaxCheck(tt.conn)
+		if (err == nil) != tt.ok {
+			t.Errorf("case(index=%d) expect ok: %t, got err: %v", idx, tt.ok, err)
+		}
+	}
+}
+
+func TestNewSQLDispatcherConnection(t *testing.T) {
+	newTests := []*rule.Target{
+		{Type: "SQLDispatcher"},
+		{Type: "SQLDispatcher", Connection: "audit"},
+		{
+			Type:       "SQLDispatcher",
+			Connection: "audit",
+			Auth:       &rule.Connection{Name: "audit", AuthType: rule.AuthTypeAPIKey, Key: "X-Api-Key"},
+		},
+	}
+	for idx, target := range newTests {
+		_, err := NewDispatcher(context.Background(), log.DefaultLogger, target)
+		if err == nil {
+			t.Errorf("case(index=%d) expect err, got nil", idx)
+		}
+	}
+
+	// the DSN connection can't be used by the other dispatchers
+	_, err := NewDispatcher(context.Background(), log.DefaultLogger, &rule.Target{
+		Type:       "HTTPDispatcher",
+		Connection: "audit",
+		Auth:       &rule.Connection{Name: "audit", AuthType: rule.AuthTypeDSN, Key: "mysql"},
+	})
+	if err == nil {
+		t.Error("expect err of the HTTP dispatcher with the DSN connection, got nil")
+	}
+}
+
+func TestSQLDispatcherDispatch(t *testing.T) {
+	useFakeSQLDriver(t)
+	dispatchTests := []struct {
+		driver string
+		data   string
+		query  string
+		args   []interface{}
+	}{
+		{
+			driver: "postgres",
+			data:   `{"table":"audit.events","columns":{"id":10188,"source":"orders","payload":{"a":1},"ok":true,"note":null}}`,
+			query:  `INSERT INTO "audit"."events" ("id", "note", "ok", "payload", "source") VALUES ($1, $2, $3, $4, $5)`,
+			args:   []interface{}{int64(10188), nil, true, `{"a":1}`, "orders"},
+		},
+		{
+			driver: "postgres",
+			data:   `{"table":"events","columns":{"id":1,"amount":1.5},"conflictKeys":["id"]}`,
+			query: `INSERT INTO "events" ("amount", "id") VALUES ($1, $2)` +
+				` ON CONFLICT ("id") DO UPDATE SET "amount" = EXCLUDED."amount"`,
+			args: []interface{}{1.5, int64(1)},
+		},
+		{
+			driver: "postgres",
+			data:   `{"table":"events","columns":{"id":1},"conflictKeys":["id"]}`,
+			query:  `INSERT INTO "events" ("id") VALUES ($1) ON CONFLICT ("id") DO NOTHING`,
+			args:   []interface{}{int64(1)},
+		},
+		{
+			driver: "mysql",
+			data:   `{"table":"events","columns":{"id":1,"amount":1.5},"conflictKeys":["id"]}`,
+			query:  "INSERT INTO `events` (`amount`, `id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `amount` = VALUES(`amount`)",
+			args:   []interface{}{1.5, int64(1)},
+		},
+	}
+	for idx, tt := range dispatchTests {
+		dsn := t.Name() + "/" + tt.driver
+		d := newSQLTestDispatcher(t, tt.driver, dsn)
+		err := d.Dispatch(context.Background(), sqlTestEvent(tt.data))
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		r, _ := sqlRecorders.LoadAndDelete(dsn)
+		rec := r.(*sqlRecorder)
+		if len(rec.queries) != 1 || rec.queries[0] != tt.query || !reflect.DeepEqual(rec.args[0], tt.args) {
+			t.Errorf("case(index=%d) expect query: %s, args: %v, got queries: %v, args: %v",
+				idx, tt.query, tt.args, rec.queries, rec.args)
+		}
+	}
+
+	// the identifiers are checked for each event
+	d := newSQLTestDispatcher(t, "postgres", t.Name())
+	for _, data := range []string{
+		`{"table":"events; DROP TABLE events","columns":{"id":1}}`,
+		`{"table":"a.b.c","columns":{"id":1}}`,
+		`{"table":"events","columns":{"id\"":1}}`,
+		`{"table":"events","columns":{"id":1},"conflictKeys":["name"]}`,
+	} {
+		err := d.Dispatch(context.Background(), sqlTestEvent(data))
+		if err == nil {
+			t.Errorf("expect err of data: %s, got nil", data)
+		}
+	}
+}
+
+func TestSQLDispatcherDispatchBatch(t *testing.T) {
+	useFakeSQLDriver(t)
+	d := newSQLTestDispatcher(t, "postgres", t.Name())
+	bd, ok := d.(rule.BatchDispatcher)
+	if !ok {
+		t.Fatal("SQL dispatcher should support batch")
+	}
+	err := bd.DispatchBatch(context.Background(), []*rule.EventExt{
+		sqlTestEvent(`{"table":"events","columns":{"id":1,"amount":1},"conflictKeys":["id"]}`),
+		sqlTestEvent(`{"table":"events","columns":{"id":2,"amount":2},"conflictKeys":["id"]}`),
+		sqlTestEvent(`{"table":"logs","columns":{"msg":"m"}}`),
+		sqlTestEvent(`{"table":"events","columns":{"id":1,"amount":3},"conflictKeys":["id"]}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := sqlRecorders.Load(t.Name())
+	rec := r.(*sqlRecorder)
+	expectedQueries := []string{
+		`INSERT INTO "events" ("amount", "id") VALUES ($1, $2), ($3, $4)` +
+			` ON CONFLICT ("id") DO UPDATE SET "amount" = EXCLUDED."amount"`,
+		`INSERT INTO "logs" ("msg") VALUES ($1)`,
+	}
+	if !reflect.DeepEqual(rec.queries, expectedQueries) {
+		t.Fatalf("expect queries: %v, got: %v", expectedQueries, rec.queries)
+	}
+	// the later row of the same conflict key takes precedence
+	expectedArgs := []interface{}{int64(3), int64(1), int64(2), int64(2)}
+	if !reflect.DeepEqual(rec.args[0], expectedArgs) {
+		t.Fatalf("expect args: %v, got: %v", expectedArgs, rec.args[0])
+	}
+}
+
+func TestSQLDispatcherDispatchBatchBadRows(t *testing.T) {
+	useFakeSQLDriver(t)
+	d := newSQLTestDispatcher(t, "postgres", t.Name())
+	err := d.(rule.BatchDispatcher).DispatchBatch(context.Background(), []*rule.EventExt{
+		sqlTestEvent(`{"table":"events","columns":{"id":1}}`),
+		sqlTestEvent(`{"table":"events;","columns":{"id":2}}`),
+		sqlTestEvent(`{"table":"broken","columns":{"id":3}}`),
+		sqlTestEvent(`{"table":"events","columns":{"id":4}}`),
+	})
+	var be *rule.BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("expect the batch error, got: %v", err)
+	}
+	failed := make([]bool, 0, len(be.Errs))
+	for _, e := range be.Errs {
+		failed = append(failed, e != nil)
+	}
+	expectedFailed := []bool{false, true, true, false}
+	if !reflect.DeepEqual(failed, expectedFailed) {
+		t.Fatalf("expect failed events: %v, got: %v (%v)", expectedFailed, failed, be.Errs)
+	}
+	// the valid rows are inserted
+	r, _ := sqlRecorders.Load(t.Name())
+	rec := r.(*sqlRecorder)
+	expectedQueries := []string{
+		`INSERT INTO "events" ("id") VALUES ($1), ($2)`,
+		`INSERT INTO "broken" ("id") VALUES ($1)`,
+	}
+	if !reflect.DeepEqual(rec.queries, expectedQueries) {
+		t.Fatalf("expect queries: %v, got: %v", expectedQueries, rec.queries)
+	}
+}
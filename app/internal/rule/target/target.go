@@ -3,6 +3,7 @@ package target
 import (
 	"context"
 	"encoding/json/jsontext"
+	"errors"
 	"fmt"
 
 	"github.com/go-kratos/kratos/v2/log"
@@ -48,11 +49,62 @@ func NewDispatcher(ctx context.Context, logger log.Logger, target *rule.Target)
 	if !ok {
 		return nil, fmt.Errorf("unknown target type:%s", target.Type)
 	}
+	if target.HTTPClient != nil && target.Type != "HTTPDispatcher" {
+		return nil, fmt.Errorf("target type %s doesn't support http client", target.Type)
+	}
 	validator := validators[target.Type]
 	d, err := newFunc(ctx, logger, target, validator)
 	if err != nil {
 		return nil, err
 	}
+	if target.OnSuccess != nil {
+		err = onSuccessSyntaxCheck(target.OnSuccess)
+		if err != nil {
+			return nil, err
+		}
+		if target.Batch != nil {
+			return nil, errors.New("on success doesn't support batch")
+		}
+		rd, ok := d.(responseDispatcher)
+		if !ok {
+			return nil, fmt.Errorf("target type %s doesn't support on success", target.Type)
+		}
+		sender, _ := ctx.Value(busSenderKey{}).(BusSender)
+		return &onSuccessDispatcher{
+			dispatcher: dispatcher{
+				dispatcher: d,
+				log: log.NewHelper(log.With(
+					logger,
+					"module", "target/dispatcher",
+					"caller", log.DefaultCaller,
+				)),
+			},
+			responseDispatcher: rd,
+			onSuccess:          target.OnSuccess,
+			sender:             sender,
+		}, nil
+	}
+	if target.Batch != nil {
+		err = batchSyntaxCheck(target.Batch)
+		if err != nil {
+			return nil, err
+		}
+		bd, ok := d.(rule.BatchDispatcher)
+		if !ok {
+			return nil, fmt.Errorf("target type %s doesn't support batch", target.Type)
+		}
+		return &batchDispatcher{
+			dispatcher: dispatcher{
+				dispatcher: d,
+				log: log.NewHelper(log.With(
+					logger,
+					"module", "target/dispatcher",
+					"caller", log.DefaultCaller,
+				)),
+			},
+			batchDispatcher: bd,
+		}, nil
+	}
 	return &dispatcher{
 		dispatcher: d,
 		log: log.NewHelper(log.With(
@@ -71,6 +123,26 @@ func (d *dispatcher) Close() error {
 	return d.dispatcher.Close()
 }
 
+// batchDispatcher is the dispatcher of the target with batch.
+type batchDispatcher struct {
+	dispatcher
+	batchDispatcher rule.BatchDispatcher
+}
+
+func (d *batchDispatcher) DispatchBatch(ctx context.Context, events []*rule.EventExt) error {
+	return d.batchDispatcher.DispatchBatch(ctx, events)
+}
+
+func batchSyntaxCheck(batch *rule.Batch) error {
+	if batch.MaxSize == 0 {
+		return errors.New("batch max size should be positive")
+	}
+	if batch.MaxLinger <= 0 {
+		return errors.New("batch max linger should be positive")
+	}
+	return nil
+}
+
 func ListAllDispatcherParamsSchema() map[string]string {
 	schema := make(map[string]string, len(dispatcherSchemas))
 	for key, val := range dispatcherSchemas {
@@ -78,3 +150,19 @@ func ListAllDispatcherParamsSchema() map[string]string {
 	}
 	return schema
 }
+
+// ValidateDispatcherParams validates the transformed event data by the params schema of the target type.
+func ValidateDispatcherParams(targetType string, data string) error {
+	validator, ok := validators[targetType]
+	if !ok {
+		return fmt.Errorf("unknown target type:%s", targetType)
+	}
+	result, err := validator.Validate(gojsonschema.NewStringLoader(data))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		return fmt.Errorf("target(type: %s) params are not valid. see err: %s", targetType, result.Errors())
+	}
+	return nil
+}
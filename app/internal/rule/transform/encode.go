@@ -0,0 +1,302 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json/v2"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	xmlDefaultRoot = "data"
+	xmlArrayItem   = "item"
+)
+
+var newEncodeFunctions = map[string]newEncodeFunc{}
+
+type (
+	encodeFunc func(val interface{}) (string, error)
+	// newEncodeFunc keys is the order of the payload fields, it is empty if the order is unknown.
+	newEncodeFunc func(encoding *rule.Encoding, keys []string) (fc encodeFunc, contentType string, err error)
+)
+
+func init() {
+	registerEncodeFunc("JSON", newEncodeFuncJSON)
+	registerEncodeFunc("FORM", newEncodeFuncForm)
+	registerEncodeFunc("XML", newEncodeFuncXML)
+	registerEncodeFunc("CSV", newEncodeFuncCSV)
+	registerEncodeFunc("PROTOBUF", newEncodeFuncProtobuf)
+}
+
+// registerEncodeFunc register encode function,
+// note that this function cannot be called in more than one goroutine.
+// recommended for use in func init() only
+func registerEncodeFunc(format string, newFunc newEncodeFunc) {
+	newEncodeFunctions[format] = newFunc
+}
+
+type encoder struct {
+	field       string
+	contentType string
+	fc          encodeFunc
+}
+
+func newEncoder(encoding *rule.Encoding, keys []string) (*encoder, error) {
+	if encoding == nil {
+		return nil, nil
+	}
+	newFunc, ok := newEncodeFunctions[encoding.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding(format=%s)", encoding.Format)
+	}
+	if encoding.Field != "" {
+		keys = nil
+	}
+	fc, contentType, err := newFunc(encoding, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &encoder{
+		field:       encoding.Field,
+		contentType: contentType,
+		fc:          fc,
+	}, nil
+}
+
+// encode the payload or the field of the payload.
+func (e *encoder) encode(event *rule.EventExt, payload interface{}) error {
+	var data string
+	if e.field == "" {
+		var err error
+		data, err = e.fc(payload)
+		if err != nil {
+			return err
+		}
+	} else {
+		obj, ok := payload.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("encoding field(%s) of non-object payload(%T)", e.field, payload)
+		}
+		if val, exist := obj[e.field]; exist {
+			encoded, err := e.fc(val)
+			if err != nil {
+				return err
+			}
+			obj[e.field] = encoded
+		}
+		bs, err := json.Marshal(obj, json.Deterministic(true))
+		if err != nil {
+			return err
+		}
+		data = string(bs)
+	}
+	event.Event.Data = data
+	event.Event.Datacontenttype = e.contentType
+	event.DecodedData = ""
+	return nil
+}
+
+func newEncodeFuncJSON(_ *rule.Encoding, _ []string) (encodeFunc, string, error) {
+	return func(val interface{}) (string, error) {
+		bs, err := json.Marshal(val, json.Deterministic(true))
+		if err != nil {
+			return "", err
+		}
+		return string(bs), nil
+	}, rule.ContentTypeJSON, nil
+}
+
+// newEncodeFuncForm encodes an object, the array is encoded as repeated values of the key
+// and the nested object is encoded as its JSON text.
+func newEncodeFuncForm(_ *rule.Encoding, _ []string) (encodeFunc, string, error) {
+	return func(val interface{}) (string, error) {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("form encoding requires an object, got %T", val)
+		}
+		values := make(url.Values, len(obj))
+		for k, v := range obj {
+			if arr, isArr := v.([]interface{}); isArr {
+				for _, item := range arr {
+					values.Add(k, encodeText(item))
+				}
+				continue
+			}
+			values.Set(k, encodeText(v))
+		}
+		return values.Encode(), nil
+	}, rule.ContentTypeForm, nil
+}
+
+// newEncodeFuncXML encodes the value under the root element, the array is encoded as
+// repeated elements named by its key, or by item if it has no key.
+func newEncodeFuncXML(encoding *rule.Encoding, keys []string) (encodeFunc, string, error) {
+	root := encoding.Root
+	if root == "" {
+		root = xmlDefaultRoot
+	}
+	return func(val interface{}) (string, error) {
+		buf := &bytes.Buffer{}
+		enc := xml.NewEncoder(buf)
+		err := encodeXMLElement(enc, root, val, keys)
+		if err != nil {
+			return "", err
+		}
+		err = enc.Flush()
+		if err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}, rule.ContentTypeXML, nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, val interface{}, keys []string) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for _, k := range orderedKeys(v, keys) {
+			err = encodeXMLValue(enc, k, v[k])
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		err = encodeXMLValue(enc, xmlArrayItem, v)
+		if err != nil {
+			return err
+		}
+	case nil:
+	default:
+		err = enc.EncodeToken(xml.CharData(encodeText(v)))
+		if err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, val interface{}) error {
+	arr, ok := val.([]interface{})
+	if !ok {
+		return encodeXMLElement(enc, name, val, nil)
+	}
+	for _, item := range arr {
+		err := encodeXMLElement(enc, name, item, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newEncodeFuncCSV encodes an object as a line, or an array as lines.
+// The columns are in the order of the target params, or in the order of the keys if unknown.
+func newEncodeFuncCSV(_ *rule.Encoding, keys []string) (encodeFunc, string, error) {
+	return func(val interface{}) (string, error) {
+		var lines []interface{}
+		if arr, ok := val.([]interface{}); ok {
+			lines = arr
+		} else {
+			lines = []interface{}{val}
+		}
+		buf := &bytes.Buffer{}
+		w := csv.NewWriter(buf)
+		for _, line := range lines {
+			var record []string
+			switch v := line.(type) {
+			case map[string]interface{}:
+				for _, k := range orderedKeys(v, keys) {
+					record = append(record, encodeText(v[k]))
+				}
+			case []interface{}:
+				for _, item := range v {
+					record = append(record, encodeText(item))
+				}
+			default:
+				record = []string{encodeText(v)}
+			}
+			err := w.Write(record)
+			if err != nil {
+				return "", err
+			}
+		}
+		w.Flush()
+		return buf.String(), w.Error()
+	}, rule.ContentTypeCSV, nil
+}
+
+// newEncodeFuncProtobuf encodes the value as the message by the protobuf JSON mapping.
+func newEncodeFuncProtobuf(encoding *rule.Encoding, _ []string) (encodeFunc, string, error) {
+	md, err := findMessageDescriptor(encoding)
+	if err != nil {
+		return nil, "", err
+	}
+	return func(val interface{}) (string, error) {
+		bs, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		msg := dynamicpb.NewMessage(md)
+		err = protojson.Unmarshal(bs, msg)
+		if err != nil {
+			return "", fmt.Errorf("protobuf encoding(message=%s) err: %w", md.FullName(), err)
+		}
+		bs, err = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(bs), nil
+	}, rule.ContentTypeProtobuf, nil
+}
+
+func findMessageDescriptor(encoding *rule.Encoding) (protoreflect.MessageDescriptor, error) {
+	if encoding.DescriptorSet == nil {
+		return nil, fmt.Errorf("protobuf descriptor(%s) not found", encoding.Descriptor)
+	}
+	md, err := rule.FindMessageDescriptor(encoding.DescriptorSet, encoding.Message)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf descriptor(%s) err: %w", encoding.Descriptor, err)
+	}
+	return md, nil
+}
+
+// orderedKeys returns the keys of obj, keys in order come first, and the rest are sorted.
+func orderedKeys(obj map[string]interface{}, order []string) []string {
+	res := make([]string, 0, len(obj))
+	for _, k := range order {
+		if _, ok := obj[k]; ok {
+			res = append(res, k)
+		}
+	}
+	rest := make([]string, 0, len(obj)-len(res))
+	for k := range obj {
+		if !slices.Contains(res, k) {
+			rest = append(rest, k)
+		}
+	}
+	slices.Sort(rest)
+	return append(res, rest...)
+}
+
+// encodeText formats the value as text, null is empty.
+func encodeText(val interface{}) string {
+	if val == nil {
+		return ""
+	}
+	return valueString(val)
+}
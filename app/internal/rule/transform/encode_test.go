@@ -0,0 +1,173 @@
+package transform
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func orderDescriptorSet(t *testing.T) []byte {
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("order.proto"),
+				Package: proto.String("test.v1"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("Order"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							{
+								Name:     proto.String("name"),
+								JsonName: proto.String("name"),
+								Number:   proto.Int32(1),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+							},
+							{
+								Name:     proto.String("amount"),
+								JsonName: proto.String("amount"),
+								Number:   proto.Int32(2),
+								Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+								Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	bs, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+func TestEncode(t *testing.T) {
+	logger := log.DefaultLogger
+	data := `{"name":"test1","amount":12,"tags":["a","b"],"address":{"city":"x & y"}}`
+	params := []*rule.TargetParam{
+		{Key: "name", Form: "JSONPATH", Value: "$.data.name"},
+		{Key: "amount", Form: "JSONPATH", Value: "$.data.amount"},
+		{Key: "tags", Form: "JSONPATH", Value: "$.data.tags"},
+		{Key: "address", Form: "JSONPATH", Value: "$.data.address"},
+	}
+	descriptorSet := orderDescriptorSet(t)
+	// the protobuf wire format of Order{name: "test1", amount: 12}
+	orderBytes := base64.StdEncoding.EncodeToString([]byte{0x0a, 0x05, 't', 'e', 's', 't', '1', 0x10, 0x0c})
+	encodeTests := []struct {
+		params      []*rule.TargetParam
+		encoding    *rule.Encoding
+		res         string
+		contentType string
+	}{
+		{
+			params:      params,
+			encoding:    &rule.Encoding{Format: "JSON"},
+			res:         `{"address":{"city":"x & y"},"amount":12,"name":"test1","tags":["a","b"]}`,
+			contentType: rule.ContentTypeJSON,
+		},
+		{
+			params:      params,
+			encoding:    &rule.Encoding{Format: "FORM"},
+			res:         `address=%7B%22city%22%3A%22x+%26+y%22%7D&amount=12&name=test1&tags=a&tags=b`,
+			contentType: rule.ContentTypeForm,
+		},
+		{
+			params:   params,
+			encoding: &rule.Encoding{Format: "XML", Root: "order"},
+			res: `<order><name>test1</name><amount>12</amount><tags>a</tags><tags>b</tags>` +
+				`<address><city>x &amp; y</city></address></order>`,
+			contentType: rule.ContentTypeXML,
+		},
+		{
+			params:      params,
+			encoding:    &rule.Encoding{Format: "CSV"},
+			res:         "test1,12,\"[\"\"a\"\",\"\"b\"\"]\",\"{\"\"city\"\":\"\"x & y\"\"}\"\n",
+			contentType: rule.ContentTypeCSV,
+		},
+		// CSV lines of an array
+		{
+			params:      []*rule.TargetParam{{Key: "tags", Form: "JSONPATH", Value: "$.data.tags"}},
+			encoding:    &rule.Encoding{Format: "CSV", Field: "tags"},
+			res:         `{"tags":"a\nb\n"}`,
+			contentType: rule.ContentTypeCSV,
+		},
+		{
+			params: params[:2],
+			encoding: &rule.Encoding{
+				Format:        "PROTOBUF",
+				Descriptor:    "order",
+				Message:       "test.v1.Order",
+				DescriptorSet: descriptorSet,
+			},
+			res:         orderBytes,
+			contentType: rule.ContentTypeProtobuf,
+		},
+		// Encode the body of the HTTP dispatcher
+		{
+			params: []*rule.TargetParam{
+				{Key: "url", Form: "CONSTANT", Value: "http://127.0.0.1/orders"},
+				{
+					Key:      "body",
+					Form:     "TEMPLATE",
+					Value:    `{"name":"$.data.name","amount":"$.data.amount"}`,
+					Template: proto.String(`{"name":"${name}","amount":${amount}}`),
+				},
+			},
+			encoding:    &rule.Encoding{Format: "XML", Field: "body"},
+			res:         `{"body":"<data><amount>12</amount><name>test1</name></data>","url":"http://127.0.0.1/orders"}`,
+			contentType: rule.ContentTypeXML,
+		},
+	}
+	for idx, tt := range encodeTests {
+		tfr, err := NewTransformer(context.Background(), logger, &rule.Target{
+			Params:   tt.params,
+			Encoding: tt.encoding,
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		ee := &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 123, Source: "testSource1", Type: "testSourceType1", Data: data},
+			},
+		}
+		res, err := tfr.Transform(context.Background(), ee)
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		if res.Event.Data != tt.res {
+			t.Fatalf("case(index=%d) expect data: %s, actual: %s", idx, tt.res, res.Event.Data)
+		}
+		if res.Event.Datacontenttype != tt.contentType {
+			t.Fatalf(
+				"case(index=%d) expect content type: %s, actual: %s",
+				idx, tt.contentType, res.Event.Datacontenttype,
+			)
+		}
+	}
+
+	invalidEncodings := []*rule.Encoding{
+		{Format: "YAML"},
+		{Format: "PROTOBUF", Descriptor: "order", Message: "test.v1.Order"},
+		{Format: "PROTOBUF", Descriptor: "order", Message: "test.v1.NotExists", DescriptorSet: descriptorSet},
+	}
+	for idx, encoding := range invalidEncodings {
+		_, err := NewTransformer(context.Background(), logger, &rule.Target{
+			Params:   params,
+			Encoding: encoding,
+		})
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
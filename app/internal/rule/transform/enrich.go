@@ -0,0 +1,234 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+const (
+	enrichDefaultTimeout = time.Second
+	enrichMaxRespBytes   = 1 << 20
+)
+
+// enrichURLVarRegexp matches the variable like ${name} in the url
+var enrichURLVarRegexp = regexp.MustCompile(`\$\{\s*([^}]*?)\s*}`)
+
+func init() {
+	registerTransformFunc("ENRICH", newTransformFuncEnrich)
+}
+
+// enrichConfig is the value of the ENRICH transform param.
+// Variables are JSONPATH of the event, which are used by ${name} in the URL,
+// and are sent as a JSON object in the body if the method is not GET.
+// Fields are JSONPATH of the response, all of the response is returned if it is empty.
+type enrichConfig struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	Variables map[string]string `json:"variables"`
+	Fields    map[string]string `json:"fields"`
+	Timeout   string            `json:"timeout"`
+	CacheTTL  string            `json:"cacheTTL"`
+}
+
+type enricher struct {
+	client    *http.Client
+	url       string
+	method    string
+	headers   map[string]string
+	variables map[string]transformFunc
+	fields    map[string][]string
+	timeout   time.Duration
+	cache     *cache.Cache // nil if the cache is disabled
+}
+
+func newTransformFuncEnrich(
+	ctx context.Context,
+	logger *log.Helper,
+	value string,
+	_ *string,
+) (transformFunc, error) {
+	cfg := &enrichConfig{}
+	err := json.Unmarshal([]byte(value), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("transformer(ENRICH) value unmarshal err: %s", err)
+	}
+	u, err := url.Parse(enrichURLVarRegexp.ReplaceAllString(cfg.URL, "var"))
+	if err != nil {
+		return nil, fmt.Errorf("transformer(ENRICH) url(%s) err: %s", cfg.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("transformer(ENRICH) url(%s) should be http or https", cfg.URL)
+	}
+	e := &enricher{
+		client:    &http.Client{},
+		url:       cfg.URL,
+		method:    strings.ToUpper(cfg.Method),
+		headers:   cfg.Headers,
+		variables: make(map[string]transformFunc, len(cfg.Variables)),
+		fields:    make(map[string][]string, len(cfg.Fields)),
+		timeout:   enrichDefaultTimeout,
+	}
+	if e.method == "" {
+		e.method = http.MethodGet
+	}
+	for key, jsonpath := range cfg.Variables {
+		var fc transformFunc
+		fc, err = newTransformFuncJsonpath(ctx, logger, jsonpath, nil)
+		if err != nil {
+			return nil, err
+		}
+		e.variables[strings.TrimSpace(key)] = fc
+	}
+	for _, match := range enrichURLVarRegexp.FindAllStringSubmatch(cfg.URL, -1) {
+		if _, ok := e.variables[match[1]]; !ok {
+			return nil, fmt.Errorf("transformer(ENRICH) url variable(key=%s) not found", match[1])
+		}
+	}
+	for key, jsonpath := range cfg.Fields {
+		path := strings.Split(jsonpath, ".")
+		if len(path) != 0 && path[0] == "$" {
+			path = path[1:]
+		}
+		e.fields[key] = path
+	}
+	if cfg.Timeout != "" {
+		e.timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil || e.timeout <= 0 {
+			return nil, fmt.Errorf("transformer(ENRICH) timeout(%s) should be a positive duration", cfg.Timeout)
+		}
+	}
+	if cfg.CacheTTL != "" {
+		var ttl time.Duration
+		ttl, err = time.ParseDuration(cfg.CacheTTL)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("transformer(ENRICH) cacheTTL(%s) should be a non-negative duration", cfg.CacheTTL)
+		}
+		if ttl > 0 {
+			e.cache = cache.New(ttl, ttl)
+		}
+	}
+	return e.enrich, nil
+}
+
+// enrich calls the configured endpoint, any failure of the call is retryable.
+func (e *enricher) enrich(ctx context.Context, ext *rule.EventExt) (interface{}, error) {
+	vars := make(map[string]interface{}, len(e.variables))
+	for key, fc := range e.variables {
+		val, err := fc(ctx, ext)
+		if err != nil {
+			return nil, err
+		}
+		vars[key] = val
+	}
+	reqURL := enrichURLVarRegexp.ReplaceAllStringFunc(e.url, func(s string) string {
+		name := enrichURLVarRegexp.FindStringSubmatch(s)[1]
+		// space is escaped to %20 so that it is valid in both path and query
+		return strings.ReplaceAll(url.QueryEscape(encodeText(vars[name])), "+", "%20")
+	})
+	var body []byte
+	if e.method != http.MethodGet {
+		var err error
+		body, err = json.Marshal(vars, json.Deterministic(true))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cacheKey := e.method + " " + reqURL + " " + string(body)
+	if e.cache != nil {
+		if res, ok := e.cache.Get(cacheKey); ok {
+			return res, nil
+		}
+	}
+	res, err := e.call(ctx, reqURL, body)
+	if err != nil {
+		return nil, rule.NewRetryableError(fmt.Errorf("enrich from %s %s err: %w", e.method, reqURL, err))
+	}
+	if e.cache != nil {
+		e.cache.SetDefault(cacheKey, res)
+	}
+	return res, nil
+}
+
+func (e *enricher) call(ctx context.Context, reqURL string, body []byte) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, e.method, reqURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, enrichMaxRespBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status code %d, body: %s", resp.StatusCode, respBody)
+	}
+	var respJSON interface{}
+	err = json.Unmarshal(respBody, &respJSON)
+	if err != nil {
+		return nil, errors.New("response body should be JSON")
+	}
+	if len(e.fields) == 0 {
+		return respJSON, nil
+	}
+	res := make(map[string]interface{}, len(e.fields))
+	for key, path := range e.fields {
+		res[key] = enrichSelect(respJSON, path)
+	}
+	return res, nil
+}
+
+// enrichSelect returns the value of the response by path, nil if not exists.
+func enrichSelect(val interface{}, path []string) interface{} {
+	for _, key := range path {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			val = v[key]
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil
+			}
+			val = v[idx]
+		default:
+			return nil
+		}
+	}
+	return val
+}
@@ -0,0 +1,167 @@
+package transform
+
+import (
+	"context"
+	"encoding/json/v2"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func TestEnrich(t *testing.T) {
+	logger := log.DefaultLogger
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/customers/c 1":
+			if r.URL.Query().Get("region") != "eu&us" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"c 1","tier":"gold","profile":{"locale":"en-GB"},"tags":["a","b"]}`))
+		case "/lookup":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost || r.Header.Get("X-Token") != "token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write(body) // echo
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	data := `{"customer":"c 1","region":"eu&us","amount":12}`
+	enrichTests := []struct {
+		value     string
+		res       string
+		retryable bool
+	}{
+		// Select fields of the response
+		{
+			value: `{
+  "url": "` + srv.URL + `/customers/${customer}?region=${ region }",
+  "variables": {"customer": "$.data.customer", "region": "$.data.region"},
+  "fields": {"tier": "$.tier", "locale": "$.profile.locale", "tag": "$.tags.1", "missing": "$.not.exists"}
+}`,
+			res: `{"resKey": {"tier": "gold", "locale": "en-GB", "tag": "b", "missing": null}}`,
+		},
+		// The whole response, variables are sent in the body
+		{
+			value: `{
+  "url": "` + srv.URL + `/lookup",
+  "method": "POST",
+  "headers": {"X-Token": "token"},
+  "variables": {"customer": "$.data.customer", "amount": "$.data.amount"}
+}`,
+			res: `{"resKey": {"customer": "c 1", "amount": 12}}`,
+		},
+		// Server error
+		{
+			value:     `{"url": "` + srv.URL + `/error"}`,
+			retryable: true,
+		},
+		// Timeout
+		{
+			value:     `{"url": "` + srv.URL + `/slow", "timeout": "50ms"}`,
+			retryable: true,
+		},
+	}
+	for idx, tt := range enrichTests {
+		tfr, err := NewTransformer(context.Background(), logger, &rule.Target{
+			Params: []*rule.TargetParam{{Key: "resKey", Form: "ENRICH", Value: tt.value}},
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		ee := &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 123, Source: "testSource1", Type: "testSourceType1", Data: data},
+			},
+		}
+		res, err := tfr.Transform(context.Background(), ee)
+		if tt.retryable {
+			if !rule.IsRetryableError(err) {
+				t.Fatalf("case(index=%d) expect retryable err, got: %v", idx, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		var expectJSON interface{}
+		var resJSON interface{}
+		err = json.Unmarshal([]byte(tt.res), &expectJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.Unmarshal([]byte(res.Event.Data), &resJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expectJSON, resJSON) {
+			t.Fatalf("case(index=%d) test failure, res: %s", idx, res.Event.Data)
+		}
+	}
+
+	// cache
+	tfr, err := NewTransformer(context.Background(), logger, &rule.Target{
+		Params: []*rule.TargetParam{{
+			Key:  "resKey",
+			Form: "ENRICH",
+			Value: `{
+  "url": "` + srv.URL + `/customers/${customer}?region=${region}",
+  "variables": {"customer": "$.data.customer", "region": "$.data.region"},
+  "cacheTTL": "1m"
+}`,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls.Store(0)
+	for range 3 {
+		ee := &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{Id: 123, Source: "testSource1", Type: "testSourceType1", Data: data},
+			},
+		}
+		_, err = tfr.Transform(context.Background(), ee)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expect 1 call with cache, got %d", calls.Load())
+	}
+
+	invalidValues := []string{
+		`{"url": "ftp://example.com"}`,
+		`{"url": "http://example.com/${id}"}`,
+		`{"url": "http://example.com", "timeout": "-1s"}`,
+		`{"url": "http://example.com", "cacheTTL": "abc"}`,
+		`not json`,
+	}
+	for idx, value := range invalidValues {
+		_, err = NewTransformer(context.Background(), logger, &rule.Target{
+			Params: []*rule.TargetParam{{Key: "resKey", Form: "ENRICH", Value: value}},
+		})
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// redactWildcard matches every key of an object or every element of an array.
+const redactWildcard = "*"
+
+var newRedactFunctions = map[string]newRedactFunc{}
+
+type (
+	// redactFunc returns the replacement of val, keep is false if the value should be removed.
+	redactFunc    func(val interface{}) (res interface{}, keep bool)
+	newRedactFunc func(redaction *rule.Redaction) (redactFunc, error)
+)
+
+func init() {
+	registerRedactFunc("DROP", newRedactFuncDrop)
+	registerRedactFunc("MASK", newRedactFuncMask)
+	registerRedactFunc("HASH", newRedactFuncHash)
+}
+
+// registerRedactFunc register redact function,
+// note that this function cannot be called in more than one goroutine.
+// recommended for use in func init() only
+func registerRedactFunc(name string, newFunc newRedactFunc) {
+	newRedactFunctions[name] = newFunc
+}
+
+type redactor struct {
+	path []string // path under the event data
+	fc   redactFunc
+}
+
+func newRedactors(redactions []*rule.Redaction) ([]*redactor, error) {
+	redactors := make([]*redactor, 0, len(redactions))
+	for _, r := range redactions {
+		newFunc, ok := newRedactFunctions[r.Action]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction(action=%s)", r.Action)
+		}
+		path := strings.Split(r.Path, ".")
+		if len(path) != 0 && path[0] == "$" {
+			path = path[1:]
+		}
+		if len(path) < 2 || path[0] != "data" { //nolint:mnd
+			return nil, fmt.Errorf("redaction path(%s) should be a field of $.data", r.Path)
+		}
+		fc, err := newFunc(r)
+		if err != nil {
+			return nil, err
+		}
+		redactors = append(redactors, &redactor{
+			path: path[1:],
+			fc:   fc,
+		})
+	}
+	return redactors, nil
+}
+
+// redactEventData applies redactors to the event data in place,
+// so that every transform form only sees the redacted values.
+// The binary event data is redacted in its decoded form and re-encoded to JSON.
+// The numbers are kept as their JSON text, so the integers beyond the precision of float64 aren't changed.
+func redactEventData(event *rule.EventExt, redactors []*redactor) error {
+	var data interface{}
+	err := json.Unmarshal([]byte(event.JSONData()), &data, json.WithUnmarshalers(unmarshalNumberText))
+	if err != nil {
+		return fmt.Errorf("redact event data unmarshal err: %w", err)
+	}
+	for _, r := range redactors {
+		data = redactValue(data, r.path, r.fc)
+	}
+	bs, err := json.Marshal(data, json.Deterministic(true))
+	if err != nil {
+		return err
+	}
+	event.SetJSONData(string(bs))
+	return nil
+}
+
+// unmarshalNumberText decodes the JSON number into interface{} as its jsontext.Value.
+var unmarshalNumberText = json.UnmarshalFromFunc(func(dec *jsontext.Decoder, val *interface{}) error {
+	if dec.PeekKind() != '0' {
+		return json.SkipFunc
+	}
+	num, err := dec.ReadValue()
+	if err != nil {
+		return err
+	}
+	*val = num.Clone()
+	return nil
+})
+
+func redactValue(node interface{}, path []string, fc redactFunc) interface{} {
+	key := path[0]
+	last := len(path) == 1
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := []string{key}
+		if key == redactWildcard {
+			keys = make([]string, 0, len(n))
+			for k := range n {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			val, ok := n[k]
+			if !ok {
+				continue
+			}
+			if !last {
+				n[k] = redactValue(val, path[1:], fc)
+				continue
+			}
+			res, keep := fc(val)
+			if keep {
+				n[k] = res
+			} else {
+				delete(n, k)
+			}
+		}
+		return n
+	case []interface{}:
+		idx := -1
+		if key != redactWildcard {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(n) {
+				return n
+			}
+			idx = i
+		}
+		res := make([]interface{}, 0, len(n))
+		for i, val := range n {
+			if idx != -1 && i != idx {
+				res = append(res, val)
+				continue
+			}
+			if !last {
+				res = append(res, redactValue(val, path[1:], fc))
+				continue
+			}
+			rv, keep := fc(val)
+			if keep {
+				res = append(res, rv)
+			}
+		}
+		return res
+	default:
+		return node
+	}
+}
+
+// valueString formats a non-string value by its JSON representation.
+func valueString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	bs, _ := json.Marshal(val)
+	return string(bs)
+}
+
+func newRedactFuncDrop(_ *rule.Redaction) (redactFunc, error) {
+	return func(_ interface{}) (interface{}, bool) {
+		return nil, false
+	}, nil
+}
+
+func newRedactFuncMask(redaction *rule.Redaction) (redactFunc, error) {
+	keepLast := int(redaction.KeepLast)
+	return func(val interface{}) (interface{}, bool) {
+		if val == nil {
+			return nil, true
+		}
+		rs := []rune(valueString(val))
+		for i := 0; i < len(rs)-keepLast; i++ {
+			rs[i] = '*'
+		}
+		return string(rs), true
+	}, nil
+}
+
+func newRedactFuncHash(redaction *rule.Redaction) (redactFunc, error) {
+	if redaction.HMACKey == "" {
+		return nil, errors.New("redaction(action=HASH) key should not be empty")
+	}
+	key := []byte(redaction.HMACKey)
+	return func(val interface{}) (interface{}, bool) {
+		if val == nil {
+			return nil, true
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(valueString(val)))
+		return hex.EncodeToString(mac.Sum(nil)), true
+	}, nil
+}
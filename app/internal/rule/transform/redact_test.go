@@ -0,0 +1,170 @@
+package transform
+
+import (
+	"context"
+	"encoding/json/v2"
+	"reflect"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func TestRedact(t *testing.T) {
+	logger := log.DefaultLogger
+	tmplTxt := "\"phone ${phone}\""
+	data := `
+{
+  "user": {
+    "name": "alice",
+    "phone": "13800138000",
+    "email": "alice@example.com",
+    "card": 6222020200001234
+  },
+  "orders": [
+    {"id": 1, "card": "6222020200005678", "items": [{"sku": "a", "price": 10}]},
+    {"id": 2, "card": "6222020200009012", "items": [{"sku": "b", "price": 20}]}
+  ]
+}`
+	redactTests := []struct {
+		params     []*rule.TargetParam
+		redactions []*rule.Redaction
+		res        string
+	}{
+		// Nested object fields
+		{
+			params: []*rule.TargetParam{{Key: "user", Form: "JSONPATH", Value: "$.data.user"}},
+			redactions: []*rule.Redaction{
+				{Path: "$.data.user.name", Action: "DROP"},
+				{Path: "$.data.user.phone", Action: "MASK", KeepLast: 4},
+				{Path: "$.data.user.email", Action: "HASH", HMACKey: "secret"},
+				{Path: "$.data.user.card", Action: "MASK", KeepLast: 4},
+				{Path: "$.data.user.not.exists", Action: "DROP"},
+			},
+			res: `
+{
+  "user": {
+    "phone": "*******8000",
+    "email": "a398d49ce1980b3642bc4dbd110121e3c953e1eadb497d50dea23e9611f83ee7",
+    "card": "************1234"
+  }
+}`,
+		},
+		// Array index and wildcard
+		{
+			params: []*rule.TargetParam{{Key: "orders", Form: "JSONPATH", Value: "$.data.orders"}},
+			redactions: []*rule.Redaction{
+				{Path: "$.data.orders.*.card", Action: "MASK"},
+				{Path: "$.data.orders.0.items.*.price", Action: "DROP"},
+				{Path: "$.data.orders.5.id", Action: "DROP"},
+			},
+			res: `
+{
+  "orders": [
+    {"id": 1, "card": "****************", "items": [{"sku": "a"}]},
+    {"id": 2, "card": "****************", "items": [{"sku": "b", "price": 20}]}
+  ]
+}`,
+		},
+		// Drop array elements
+		{
+			params: []*rule.TargetParam{{Key: "orders", Form: "JSONPATH", Value: "$.data.orders"}},
+			redactions: []*rule.Redaction{
+				{Path: "$.data.orders.0", Action: "DROP"},
+				{Path: "$.data.orders.*.items", Action: "DROP"},
+			},
+			res: `
+{
+  "orders": [
+    {"id": 2, "card": "6222020200009012"}
+  ]
+}`,
+		},
+		// Applied before the template form
+		{
+			params: []*rule.TargetParam{
+				{Key: "resKey", Form: "TEMPLATE", Value: "{\"phone\":\"$.data.user.phone\"}", Template: &tmplTxt},
+			},
+			redactions: []*rule.Redaction{
+				{Path: "$.data.user.phone", Action: "HASH", HMACKey: "secret"},
+			},
+			res: `{"resKey": "phone 4aceeec2f7abf01475a1207fc3cfd8dc44b967a470aff922b87bb75a60a2cd94"}`,
+		},
+	}
+	for idx, tt := range redactTests {
+		tfr, err := NewTransformer(context.Background(), logger, &rule.Target{
+			Params:     tt.params,
+			Redactions: tt.redactions,
+		})
+		if err != nil {
+			t.Fatalf("case(index=%d) err: %v", idx, err)
+		}
+		ee := &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{
+					Id:     123,
+					Source: "testSource1",
+					Type:   "testSourceType1",
+					Data:   data,
+				},
+			},
+		}
+		res, err := tfr.Transform(context.Background(), ee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expectJSON interface{}
+		var resJSON interface{}
+		err = json.Unmarshal([]byte(tt.res), &expectJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.Unmarshal([]byte(res.Event.Data), &resJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expectJSON, resJSON) {
+			t.Fatalf("case(index=%d) test failure, res: %s", idx, res.Event.Data)
+		}
+	}
+
+	invalidRedactions := []*rule.Redaction{
+		{Path: "$.data.name", Action: "UNKNOWN"},
+		{Path: "$.source", Action: "DROP"},
+		{Path: "$.data", Action: "DROP"},
+		{Path: "$.data.name", Action: "HASH"},
+	}
+	for idx, r := range invalidRedactions {
+		_, err := NewTransformer(context.Background(), logger, &rule.Target{
+			Redactions: []*rule.Redaction{r},
+		})
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+}
+
+func TestRedactLargeNumber(t *testing.T) {
+	redactors, err := newRedactors([]*rule.Redaction{{Path: "$.data.user.phone", Action: "MASK", KeepLast: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ee := &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event: &v1.Event{
+				Id:   123,
+				Data: `{"id":9007199254740993,"user":{"phone":13800138000,"score":1.50}}`,
+			},
+		},
+	}
+	err = redactEventData(ee, redactors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"id":9007199254740993,"user":{"phone":"*******8000","score":1.50}}`
+	if ee.JSONData() != expect {
+		t.Fatalf("expect: %s, actual: %s", expect, ee.JSONData())
+	}
+}
@@ -35,6 +35,7 @@ func NewTransformer(ctx context.Context, logger log.Logger, target *rule.Target)
 		"caller", log.DefaultCaller,
 	))
 	fcs := make(map[string]transformFunc, len(target.Params))
+	keys := make([]string, 0, len(target.Params))
 	for _, tp := range target.Params {
 		newFunc, ok := newTransformFunctions[tp.Form]
 		if !ok {
@@ -45,9 +46,20 @@ func NewTransformer(ctx context.Context, logger log.Logger, target *rule.Target)
 			return nil, err
 		}
 		fcs[tp.Key] = fc
+		keys = append(keys, tp.Key)
+	}
+	redactors, err := newRedactors(target.Redactions)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newEncoder(target.Encoding, keys)
+	if err != nil {
+		return nil, err
 	}
 	return &transformer{
 		transformFunctions: fcs,
+		redactors:          redactors,
+		encoder:            enc,
 		log:                lg,
 	}, nil
 }
@@ -55,19 +67,39 @@ func NewTransformer(ctx context.Context, logger log.Logger, target *rule.Target)
 type transformer struct {
 	log                *log.Helper
 	transformFunctions map[string]transformFunc
+	redactors          []*redactor
+	encoder            *encoder // nil if the payload is JSON without setting the content type
 }
 
 // Transform if `target.Params` is empty, the entire original event is returned.
 // The event is modified and returned because it is known that
 // the upper layer assigns a separate event to each transformer,
-// rather than generating a new event
+// rather than generating a new event.
+// Redactions are applied to the event data first, so they take effect on every transform form,
+// and the output encoding is applied to the result last. The result is JSON unless it is encoded,
+// even if the event data is binary.
 func (t *transformer) Transform(ctx context.Context, event *rule.EventExt) (*rule.EventExt, error) {
+	if len(t.redactors) != 0 {
+		err := redactEventData(event, t.redactors)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if len(t.transformFunctions) == 0 {
 		data, err := protojson.Marshal(event.Event)
 		if err != nil {
 			return nil, err
 		}
-		event.Event.Data = string(data)
+		if t.encoder != nil {
+			var payload interface{}
+			err = json.Unmarshal(data, &payload)
+			if err != nil {
+				return nil, err
+			}
+			err = t.encoder.encode(event, payload)
+			return event, err
+		}
+		event.SetJSONData(string(data))
 	} else {
 		transformed := make(map[string]interface{}, len(t.transformFunctions))
 		for key, fc := range t.transformFunctions {
@@ -77,11 +109,15 @@ func (t *transformer) Transform(ctx context.Context, event *rule.EventExt) (*rul
 			}
 			transformed[key] = val
 		}
+		if t.encoder != nil {
+			err := t.encoder.encode(event, transformed)
+			return event, err
+		}
 		data, err := json.Marshal(transformed)
 		if err != nil {
 			return nil, err
 		}
-		event.Event.Data = string(data)
+		event.SetJSONData(string(data))
 	}
 
 	return event, nil
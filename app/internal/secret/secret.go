@@ -0,0 +1,73 @@
+// Package secret encrypts the secrets stored in the database, e.g. the credentials of the connections.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrNoKey is returned if the secret key is not configured.
+var ErrNoKey = errors.New("secret key is not configured")
+
+// Cipher encrypts and decrypts secrets by AES-GCM,
+// the ciphertext is the base64 encoded nonce followed by the sealed data.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher key is the base64 encoded AES key of 16, 24 or 32 bytes.
+// It returns nil if the key is empty, and the methods of a nil Cipher return ErrNoKey.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secret key should be base64 encoded: %w", err)
+	}
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, fmt.Errorf("secret key err: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c == nil {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	if c == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decode secret err: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("secret is too short")
+	}
+	nonce, data := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret err: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := c.Encrypt("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext == "secret1" {
+		t.Fatal("secret is not encrypted")
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext != "secret1" {
+		t.Fatalf("expect: secret1, actual: %s", plaintext)
+	}
+
+	other, err := NewCipher("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.Decrypt(ciphertext)
+	if err == nil {
+		t.Fatal("expect err decrypting by another key")
+	}
+
+	invalidKeys := []string{"not base64!", "c2hvcnQ="}
+	for idx, key := range invalidKeys {
+		_, err = NewCipher(key)
+		if err == nil {
+			t.Fatalf("invalid case(index=%d) expect err", idx)
+		}
+	}
+
+	var disabled *Cipher
+	disabled, err = NewCipher("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = disabled.Encrypt("secret1")
+	if !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expect ErrNoKey, actual: %v", err)
+	}
+}
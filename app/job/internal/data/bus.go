@@ -5,6 +5,7 @@ import (
 	"encoding/json/v2"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +32,11 @@ const (
 	TopicTypeSourceDelay    = "SourceDelay"    // source delay topic
 	TopicTypeTargetExpDecay = "TargetExpDecay" // target exponential decay topic
 	TopicTypeTargetBackoff  = "TargetBackoff"  // target backoff topic
+
+	// drainIdleTimeout is how long a draining topic receives nothing before it is regarded as empty,
+	// it is longer than the max invisible duration of the retried events.
+	drainIdleTimeout   = 10 * time.Minute
+	drainCheckInterval = time.Minute
 )
 
 var ppg = propagation.NewCompositeTextMapPropagator(
@@ -45,6 +51,7 @@ type Sender interface {
 
 type Bus interface {
 	Sender
+	SendToBus(ctx context.Context, busName string, eventExt *rule.EventExt) error
 	event.Receiver
 }
 
@@ -58,6 +65,9 @@ type MQConsumer interface {
 		ctx context.Context, handler event.Handler, mode v1.BusWorkMode,
 		timeout time.Duration, workers uint32, runningWorkers metric.Int64Gauge,
 	) error
+	// LastReceived returns the time the consumer received messages last time,
+	// it's the time the consumer created if it has received nothing.
+	LastReceived() time.Time
 	io.Closer
 }
 
@@ -76,6 +86,17 @@ type busInfo struct {
 	sourceDelay    MQTopic
 	targetExpDecay MQTopic
 	targetBackoff  MQTopic
+	draining       string // JSON of the old topics drained after the bus updated
+	status         v1.BusStatus
+}
+
+// drainingTopic is the old topic of the bus which is still consumed until it is empty.
+type drainingTopic struct {
+	TopicType string         `json:"topicType"`
+	Topic     MQTopic        `json:"topic"`
+	Mode      v1.BusWorkMode `json:"mode"`
+	Since     int64          `json:"since"` // unix time the topic was replaced
+	Until     int64          `json:"until"` // unix time of the latest delayed event in the topic
 }
 
 type busReflector struct {
@@ -208,6 +229,8 @@ func (br *busReflector) fetchBuses() ([]*busInfo, error) {
 				entBus.FieldSourceDelayTopic,
 				entBus.FieldTargetExpDecayTopic,
 				entBus.FieldTargetBackoffTopic,
+				entBus.FieldDrainingTopics,
+				entBus.FieldStatus,
 			).
 			Order(ent.Asc(entBus.FieldID)).
 			Limit(limit + 1).
@@ -243,6 +266,8 @@ func (br *busReflector) fetchBuses() ([]*busInfo, error) {
 				sourceDelay:    sourceDelay,
 				targetExpDecay: targetExpDecay,
 				targetBackoff:  targetBackoff,
+				draining:       b.DrainingTopics,
+				status:         v1.BusStatus(b.Status),
 			})
 		}
 		if next == 0 {
@@ -253,7 +278,8 @@ func (br *busReflector) fetchBuses() ([]*busInfo, error) {
 }
 
 type bus struct {
-	mode v1.BusWorkMode
+	mode   v1.BusWorkMode
+	paused bool // the paused bus has no consumers, its events are kept in the topics until it is resumed
 
 	source         MQTopic
 	sourceDelay    MQTopic
@@ -264,14 +290,69 @@ type bus struct {
 	sourceDelayMQConsumer    MQConsumer
 	targetExpDecayMQConsumer MQConsumer
 	targetBackoffMQConsumer  MQConsumer
+	sourceMQProducer         MQProducer
 	targetExpDecayMQProducer MQProducer
 	targetBackoffMQProducer  MQProducer
 }
 
+// consumer returns the consumer of the topic type and its topic.
+func (b *bus) consumer(topicType string) (MQConsumer, MQTopic) {
+	switch topicType {
+	case TopicTypeSource:
+		return b.sourceMQConsumer, b.source
+	case TopicTypeSourceDelay:
+		return b.sourceDelayMQConsumer, b.sourceDelay
+	case TopicTypeTargetExpDecay:
+		return b.targetExpDecayMQConsumer, b.targetExpDecay
+	case TopicTypeTargetBackoff:
+		return b.targetBackoffMQConsumer, b.targetBackoff
+	default:
+		return nil, MQTopic{}
+	}
+}
+
+// drainingConsumer consumes the old topic of the bus until it is empty.
+type drainingConsumer struct {
+	consumer MQConsumer
+	until    time.Time
+	once     sync.Once
+	done     chan struct{}
+}
+
+func (d *drainingConsumer) Close() error {
+	var err error
+	d.once.Do(func() {
+		close(d.done)
+		err = d.consumer.Close()
+	})
+	return err
+}
+
+// drained reports whether the topic has no delayed events and has received nothing for a while.
+func (d *drainingConsumer) drained(now time.Time) bool {
+	return !now.Before(d.until) && now.Sub(d.consumer.LastReceived()) >= drainIdleTimeout
+}
+
+// stopped reports whether the consumer is closed, such as the topic is drained.
+func (d *drainingConsumer) stopped() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainKey since is the time the topic was replaced, so the topic reused and replaced again is drained again.
+func drainKey(busName string, t drainingTopic) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%d", busName, t.TopicType, t.Topic.Endpoints, t.Topic.Topic, t.Since)
+}
+
 type buses struct {
 	baseLog        log.Logger
 	log            *log.Helper
 	runningWorkers metric.Int64Gauge
+	parkedEvents   *parkedEvents
 
 	workersPerMqTopic     uint32
 	sourceTimeout         time.Duration
@@ -283,6 +364,7 @@ type buses struct {
 	eg                    *errgroup.Group
 	eventHandler          event.Handler
 	buses                 sync.Map // map[busName]*bus
+	drains                sync.Map // map[drainKey]*drainingConsumer
 	closed                chan struct{}
 }
 
@@ -300,6 +382,7 @@ func NewBuses(
 			"caller", log.DefaultCaller,
 		)),
 		runningWorkers: m.RunningWorkers,
+		parkedEvents:   m.RuleParkedEvents,
 
 		workersPerMqTopic:     bc.Server.Event.WorkersPerMqTopic,
 		sourceTimeout:         bc.Server.Event.SourceTimeout.AsDuration(),
@@ -317,10 +400,7 @@ func NewBuses(
 }
 
 func (bs *buses) Send(ctx context.Context, eventExt *rule.EventExt) error {
-	// inject propagation
-	carrier := propagation.MapCarrier{}
-	ppg.Inject(ctx, carrier)
-	eventExt.Metadata = carrier
+	injectMetadata(ctx, eventExt)
 
 	v, ok := bs.buses.Load(eventExt.BusName)
 	if !ok {
@@ -334,6 +414,30 @@ func (bs *buses) Send(ctx context.Context, eventExt *rule.EventExt) error {
 	return b.targetExpDecayMQProducer.Send(ctx, b.targetExpDecay.Topic, b.mode, eventExt)
 }
 
+// SendToBus publishes the event into the source topic of the bus, such as the events routed by EventBusDispatcher.
+func (bs *buses) SendToBus(ctx context.Context, busName string, eventExt *rule.EventExt) error {
+	injectMetadata(ctx, eventExt)
+
+	v, ok := bs.buses.Load(busName)
+	if !ok {
+		return fmt.Errorf("bus %s not found", busName)
+	}
+
+	b := v.(*bus)
+	return b.sourceMQProducer.Send(ctx, b.source.Topic, b.mode, eventExt)
+}
+
+// injectMetadata injects the propagation of ctx into the metadata of the event,
+// the other metadata like the hops of the event is kept.
+func injectMetadata(ctx context.Context, eventExt *rule.EventExt) {
+	carrier := make(propagation.MapCarrier, len(eventExt.Metadata))
+	for k, v := range eventExt.Metadata {
+		carrier[k] = v
+	}
+	ppg.Inject(ctx, carrier)
+	eventExt.Metadata = carrier
+}
+
 func (bs *buses) Receive(ctx context.Context, handler event.Handler) error {
 	bs.eventHandler = handler
 	bs.ctx = ctx
@@ -358,21 +462,27 @@ func (bs *buses) Close(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			err := b.sourceMQConsumer.Close()
-			if err != nil {
-				bs.log.Errorf("close sourceMQConsumer err: %s", err)
-			}
-			err = b.sourceDelayMQConsumer.Close()
-			if err != nil {
-				bs.log.Errorf("close sourceDelayMQConsumer err: %s", err)
+			if !b.paused {
+				err := b.sourceMQConsumer.Close()
+				if err != nil {
+					bs.log.Errorf("close sourceMQConsumer err: %s", err)
+				}
+				err = b.sourceDelayMQConsumer.Close()
+				if err != nil {
+					bs.log.Errorf("close sourceDelayMQConsumer err: %s", err)
+				}
+				err = b.targetExpDecayMQConsumer.Close()
+				if err != nil {
+					bs.log.Errorf("close targetExpDecayMQConsumer err: %s", err)
+				}
+				err = b.targetBackoffMQConsumer.Close()
+				if err != nil {
+					bs.log.Errorf("close targetBackoffMQConsumer err: %s", err)
+				}
 			}
-			err = b.targetExpDecayMQConsumer.Close()
+			err := b.sourceMQProducer.Close()
 			if err != nil {
-				bs.log.Errorf("close targetExpDecayMQConsumer err: %s", err)
-			}
-			err = b.targetBackoffMQConsumer.Close()
-			if err != nil {
-				bs.log.Errorf("close targetBackoffMQConsumer err: %s", err)
+				bs.log.Errorf("close sourceMQProducer err: %s", err)
 			}
 			err = b.targetExpDecayMQProducer.Close()
 			if err != nil {
@@ -384,6 +494,14 @@ func (bs *buses) Close(ctx context.Context) error {
 			}
 		}
 	}
+	bs.drains.Range(func(key, value interface{}) bool {
+		bs.drains.Delete(key)
+		err := value.(*drainingConsumer).Close()
+		if err != nil {
+			bs.log.Errorf("close draining consumer(%s) err: %s", key, err)
+		}
+		return true
+	})
 	_ = bs.eg.Wait()
 	return nil
 }
@@ -391,62 +509,46 @@ func (bs *buses) Close(ctx context.Context) error {
 func (bs *buses) updateBus(b *busInfo) error {
 	v, ok := bs.buses.Load(b.name)
 	if !ok { // Add
-		var targetExpDecayMQProducer, targetBackoffMQProducer MQProducer
-		targetExpDecayMQProducer, err := bs.newMQProducer(b.targetExpDecay)
-		if err != nil {
-			return err
-		}
-		targetBackoffMQProducer, err = bs.newMQProducer(b.targetBackoff)
-		if err != nil {
-			return err
-		}
-		var targetExpDecayMQConsumer, targetBackoffMQConsumer, sourceMQConsumer, sourceDelayMQConsumer MQConsumer
-		targetExpDecayMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeTargetExpDecay, b.targetExpDecay, b.mode, bs.targetExpDecayTimeout,
-		)
+		var sourceMQProducer, targetExpDecayMQProducer, targetBackoffMQProducer MQProducer
+		sourceMQProducer, err := bs.newMQProducer(b.source)
 		if err != nil {
 			return err
 		}
-		targetBackoffMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeTargetBackoff, b.targetBackoff, b.mode, bs.targetBackoffTimeout,
-		)
-		if err != nil {
-			return err
-		}
-		sourceMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeSource, b.source, b.mode, bs.sourceTimeout,
-		)
+		targetExpDecayMQProducer, err = bs.newMQProducer(b.targetExpDecay)
 		if err != nil {
 			return err
 		}
-		sourceDelayMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeSourceDelay, b.sourceDelay, b.mode, bs.sourceDelayTimeout,
-		)
+		targetBackoffMQProducer, err = bs.newMQProducer(b.targetBackoff)
 		if err != nil {
 			return err
 		}
-		bs.buses.Store(b.name, &bus{
-			mode: b.mode,
+		nb := &bus{
+			mode:   b.mode,
+			paused: b.status == v1.BusStatus_BUS_STATUS_PAUSED,
 
 			source:         b.source,
 			sourceDelay:    b.sourceDelay,
 			targetExpDecay: b.targetExpDecay,
 			targetBackoff:  b.targetBackoff,
 
-			sourceMQConsumer:         sourceMQConsumer,
-			sourceDelayMQConsumer:    sourceDelayMQConsumer,
-			targetExpDecayMQConsumer: targetExpDecayMQConsumer,
-			targetBackoffMQConsumer:  targetBackoffMQConsumer,
+			sourceMQProducer:         sourceMQProducer,
 			targetExpDecayMQProducer: targetExpDecayMQProducer,
 			targetBackoffMQProducer:  targetBackoffMQProducer,
-		})
+		}
+		err = bs.newConsumers(b.name, nb)
+		if err != nil {
+			return err
+		}
+		bs.buses.Store(b.name, nb)
+		bs.drainTopics(b, nil)
 		return nil
 	}
 
 	// Update
 	old := v.(*bus)
 	nb := &bus{
-		mode: b.mode,
+		mode:   b.mode,
+		paused: b.status == v1.BusStatus_BUS_STATUS_PAUSED,
 
 		source:         b.source,
 		sourceDelay:    b.sourceDelay,
@@ -454,6 +556,17 @@ func (bs *buses) updateBus(b *busInfo) error {
 		targetBackoff:  b.targetBackoff,
 	}
 	var cleanup []io.Closer
+	if old.source == nb.source {
+		nb.sourceMQProducer = old.sourceMQProducer
+	} else {
+		var sourceMQProducer MQProducer
+		sourceMQProducer, err := bs.newMQProducer(nb.source)
+		if err != nil {
+			return err
+		}
+		nb.sourceMQProducer = sourceMQProducer
+		cleanup = append(cleanup, old.sourceMQProducer)
+	}
 	if old.targetExpDecay == nb.targetExpDecay {
 		nb.targetExpDecayMQProducer = old.targetExpDecayMQProducer
 	} else {
@@ -476,7 +589,7 @@ func (bs *buses) updateBus(b *busInfo) error {
 		nb.targetBackoffMQProducer = targetBackoffMQProducer
 		cleanup = append(cleanup, old.targetBackoffMQProducer)
 	}
-	if old.mode == nb.mode {
+	if old.mode == nb.mode && !old.paused && !nb.paused {
 		if old.targetExpDecay == nb.targetExpDecay {
 			nb.targetExpDecayMQConsumer = old.targetExpDecayMQConsumer
 		} else {
@@ -530,43 +643,26 @@ func (bs *buses) updateBus(b *busInfo) error {
 			cleanup = append(cleanup, old.sourceDelayMQConsumer)
 		}
 	} else {
-		var targetExpDecayMQConsumer, targetBackoffMQConsumer, sourceMQConsumer, sourceDelayMQConsumer MQConsumer
-		targetExpDecayMQConsumer, err := bs.newMQConsumer(
-			b.name, TopicTypeTargetExpDecay, nb.targetExpDecay, nb.mode, bs.targetExpDecayTimeout,
-		)
+		err := bs.newConsumers(b.name, nb)
 		if err != nil {
 			return err
 		}
-		targetBackoffMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeTargetBackoff, nb.targetBackoff, nb.mode, bs.targetBackoffTimeout,
-		)
-		if err != nil {
-			return err
-		}
-		sourceMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeSource, nb.source, nb.mode, bs.sourceTimeout,
-		)
-		if err != nil {
-			return err
-		}
-		sourceDelayMQConsumer, err = bs.newMQConsumer(
-			b.name, TopicTypeSourceDelay, nb.sourceDelay, nb.mode, bs.sourceDelayTimeout,
-		)
-		if err != nil {
-			return err
+		if !old.paused {
+			cleanup = append(
+				cleanup,
+				old.targetExpDecayMQConsumer, old.targetBackoffMQConsumer,
+				old.sourceMQConsumer, old.sourceDelayMQConsumer,
+			)
 		}
-		nb.targetExpDecayMQConsumer = targetExpDecayMQConsumer
-		nb.targetBackoffMQConsumer = targetBackoffMQConsumer
-		nb.sourceMQConsumer = sourceMQConsumer
-		nb.sourceDelayMQConsumer = sourceDelayMQConsumer
-		cleanup = append(
-			cleanup,
-			old.targetExpDecayMQConsumer, old.targetBackoffMQConsumer,
-			old.sourceMQConsumer, old.sourceDelayMQConsumer,
-		)
 	}
+	adopted := bs.drainTopics(b, old)
 	bs.buses.Store(b.name, nb)
 	for i := len(cleanup) - 1; i >= 0; i-- {
+		if c, ok := cleanup[i].(MQConsumer); ok {
+			if _, ok = adopted[c]; ok { // keep consuming the old topic until it is empty
+				continue
+			}
+		}
 		err := cleanup[i].Close()
 		if err != nil {
 			bs.log.Errorf("close consumer or producer err: %s", err)
@@ -575,6 +671,143 @@ func (bs *buses) updateBus(b *busInfo) error {
 	return nil
 }
 
+// drainTopics consumes the old topics of the bus until they are empty, the consumers of the old bus
+// are adopted if they consume the old topics in the same mode, and the adopted consumers are returned.
+// The drains of the topics no longer draining are removed, and the paused bus stops its drains
+// like its consumers, they are drained again once the bus is resumed.
+func (bs *buses) drainTopics(b *busInfo, old *bus) map[MQConsumer]struct{} {
+	var topics []drainingTopic
+	if err := json.Unmarshal([]byte(b.draining), &topics); err != nil {
+		bs.log.Errorf("unmarshal draining topics of bus(%s) err: %s", b.name, err)
+		return nil
+	}
+	paused := b.status == v1.BusStatus_BUS_STATUS_PAUSED
+	current := map[string]MQTopic{
+		TopicTypeSource:         b.source,
+		TopicTypeSourceDelay:    b.sourceDelay,
+		TopicTypeTargetExpDecay: b.targetExpDecay,
+		TopicTypeTargetBackoff:  b.targetBackoff,
+	}
+	timeouts := map[string]time.Duration{
+		TopicTypeSource:         bs.sourceTimeout,
+		TopicTypeSourceDelay:    bs.sourceDelayTimeout,
+		TopicTypeTargetExpDecay: bs.targetExpDecayTimeout,
+		TopicTypeTargetBackoff:  bs.targetBackoffTimeout,
+	}
+	keys := make(map[string]struct{}, len(topics))
+	adopted := make(map[MQConsumer]struct{})
+	for _, t := range topics {
+		if current[t.TopicType] == t.Topic {
+			continue
+		}
+		key := drainKey(b.name, t)
+		keys[key] = struct{}{}
+		if paused {
+			continue
+		}
+		if _, ok := bs.drains.Load(key); ok { // draining or drained
+			continue
+		}
+		var consumer MQConsumer
+		if old != nil && old.mode == t.Mode {
+			if c, topic := old.consumer(t.TopicType); c != nil && topic == t.Topic {
+				consumer = c
+				adopted[c] = struct{}{}
+			}
+		}
+		if consumer == nil {
+			c, err := bs.newMQConsumer(b.name, t.TopicType, t.Topic, t.Mode, timeouts[t.TopicType])
+			if err != nil {
+				bs.log.Errorf("drain consumer(%s) err: %s", key, err)
+				continue
+			}
+			consumer = c
+		}
+		d := &drainingConsumer{
+			consumer: consumer,
+			until:    time.Unix(t.Until, 0),
+			done:     make(chan struct{}),
+		}
+		bs.drains.Store(key, d)
+		bs.eg.Go(func() error {
+			bs.watchDrain(key, d)
+			return nil
+		})
+	}
+	prefix := b.name + "|"
+	bs.drains.Range(func(key, value interface{}) bool {
+		if !strings.HasPrefix(key.(string), prefix) {
+			return true
+		}
+		d := value.(*drainingConsumer)
+		if _, ok := keys[key.(string)]; ok && (!paused || d.stopped()) {
+			return true
+		}
+		bs.drains.Delete(key)
+		err := d.Close()
+		if err != nil {
+			bs.log.Errorf("close draining consumer(%s) err: %s", key, err)
+		}
+		return true
+	})
+	return adopted
+}
+
+// watchDrain closes the draining consumer once its topic has no delayed events and has received nothing for a while.
+// The closed consumer is kept in drains until the topic is no longer draining, so that it is not drained again.
+func (bs *buses) watchDrain(key string, d *drainingConsumer) {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bs.closed:
+			return
+		case <-d.done:
+			return
+		case <-ticker.C:
+			if !d.drained(time.Now()) {
+				continue
+			}
+			err := d.Close()
+			if err != nil {
+				bs.log.Errorf("close draining consumer(%s) err: %s", key, err)
+			}
+			bs.log.Infof("topic drained: %s", key)
+			return
+		}
+	}
+}
+
+// newConsumers creates the consumers of the topics of the bus, the paused bus has no consumers.
+func (bs *buses) newConsumers(busName string, nb *bus) error {
+	if nb.paused {
+		return nil
+	}
+	var err error
+	nb.targetExpDecayMQConsumer, err = bs.newMQConsumer(
+		busName, TopicTypeTargetExpDecay, nb.targetExpDecay, nb.mode, bs.targetExpDecayTimeout,
+	)
+	if err != nil {
+		return err
+	}
+	nb.targetBackoffMQConsumer, err = bs.newMQConsumer(
+		busName, TopicTypeTargetBackoff, nb.targetBackoff, nb.mode, bs.targetBackoffTimeout,
+	)
+	if err != nil {
+		return err
+	}
+	nb.sourceMQConsumer, err = bs.newMQConsumer(
+		busName, TopicTypeSource, nb.source, nb.mode, bs.sourceTimeout,
+	)
+	if err != nil {
+		return err
+	}
+	nb.sourceDelayMQConsumer, err = bs.newMQConsumer(
+		busName, TopicTypeSourceDelay, nb.sourceDelay, nb.mode, bs.sourceDelayTimeout,
+	)
+	return err
+}
+
 func (bs *buses) newMQProducer(topic MQTopic) (MQProducer, error) {
 	switch topic.Type {
 	case v1.MQType_MQ_TYPE_ROCKETMQ:
@@ -591,7 +824,9 @@ func (bs *buses) newMQConsumer(
 	var err error
 	switch topic.Type {
 	case v1.MQType_MQ_TYPE_ROCKETMQ:
-		consumer, err = NewRocketMQConsumer(bs.baseLog, busName, topicType, topic.Endpoints, topic.Topic)
+		consumer, err = NewRocketMQConsumer(
+			bs.baseLog, busName, topicType, topic.Endpoints, topic.Topic, bs.parkedEvents,
+		)
 	default:
 		return nil, fmt.Errorf("unsupported mq type: %s", topic.Type)
 	}
@@ -611,13 +846,19 @@ func (bs *buses) newMQConsumer(
 func (bs *buses) deleteBus(busName string) error {
 	if v, ok := bs.buses.LoadAndDelete(busName); ok {
 		b := v.(*bus)
-		err := b.sourceMQConsumer.Close()
-		if err != nil {
-			bs.log.Errorf("close sourceMQConsumer err: %s", err)
+		if !b.paused {
+			err := b.sourceMQConsumer.Close()
+			if err != nil {
+				bs.log.Errorf("close sourceMQConsumer err: %s", err)
+			}
+			err = b.sourceDelayMQConsumer.Close()
+			if err != nil {
+				bs.log.Errorf("close sourceDelayMQConsumer err: %s", err)
+			}
 		}
-		err = b.sourceDelayMQConsumer.Close()
+		err := b.sourceMQProducer.Close()
 		if err != nil {
-			bs.log.Errorf("close sourceDelayMQConsumer err: %s", err)
+			bs.log.Errorf("close sourceMQProducer err: %s", err)
 		}
 		err = b.targetExpDecayMQProducer.Close()
 		if err != nil {
@@ -628,6 +869,18 @@ func (bs *buses) deleteBus(busName string) error {
 			bs.log.Errorf("close targetBackoffMQProducer err: %s", err)
 		}
 	}
+	bs.parkedEvents.removeBus(busName)
+	prefix := busName + "|"
+	bs.drains.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			bs.drains.Delete(key)
+			err := value.(*drainingConsumer).Close()
+			if err != nil {
+				bs.log.Errorf("close draining consumer(%s) err: %s", key, err)
+			}
+		}
+		return true
+	})
 	return nil
 }
 
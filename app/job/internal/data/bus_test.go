@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"encoding/json/v2"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/errgroup"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/event"
+)
+
+type mqConsumer struct {
+	lastReceived time.Time
+	closed       bool
+}
+
+func (c *mqConsumer) Receive(
+	_ context.Context, _ event.Handler, _ v1.BusWorkMode, _ time.Duration, _ uint32, _ metric.Int64Gauge,
+) error {
+	return nil
+}
+
+func (c *mqConsumer) LastReceived() time.Time {
+	return c.lastReceived
+}
+
+func (c *mqConsumer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDrainingConsumerDrained(t *testing.T) {
+	now := time.Now()
+	drainedTests := []struct {
+		name         string
+		until        time.Time
+		lastReceived time.Time
+		drained      bool
+	}{
+		{
+			name:         "idle without delayed events",
+			until:        time.Unix(0, 0),
+			lastReceived: now.Add(-drainIdleTimeout),
+			drained:      true,
+		},
+		{
+			name:         "received recently",
+			until:        time.Unix(0, 0),
+			lastReceived: now.Add(-time.Minute),
+		},
+		{
+			name:         "delayed events not due",
+			until:        now.Add(time.Hour),
+			lastReceived: now.Add(-2 * drainIdleTimeout),
+		},
+		{
+			name:         "delayed events due",
+			until:        now,
+			lastReceived: now.Add(-2 * drainIdleTimeout),
+			drained:      true,
+		},
+	}
+	for _, tt := range drainedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &drainingConsumer{
+				consumer: &mqConsumer{lastReceived: tt.lastReceived},
+				until:    tt.until,
+				done:     make(chan struct{}),
+			}
+			if drained := d.drained(now); drained != tt.drained {
+				t.Fatalf("expect drained: %t, got: %t", tt.drained, drained)
+			}
+		})
+	}
+}
+
+func TestDrainTopics(t *testing.T) {
+	topicA := MQTopic{Endpoints: "127.0.0.1:8081", Topic: "A"}
+	topicB := MQTopic{Endpoints: "127.0.0.1:8081", Topic: "B"}
+	until := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	delayA := drainingTopic{
+		TopicType: TopicTypeSourceDelay,
+		Topic:     topicA,
+		Mode:      v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+		Since:     time.Now().Unix(),
+		Until:     until.Unix(),
+	}
+	draining, _ := json.Marshal([]drainingTopic{
+		delayA,
+		// the current topic isn't drained
+		{
+			TopicType: TopicTypeSource,
+			Topic:     topicB,
+			Mode:      v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+			Since:     time.Now().Unix(),
+		},
+	})
+	b := &busInfo{
+		name:        "bus",
+		mode:        v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+		source:      topicB,
+		sourceDelay: topicB,
+		draining:    string(draining),
+	}
+	oldConsumer := &mqConsumer{lastReceived: time.Now()}
+	old := &bus{
+		mode:                  v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY,
+		sourceDelay:           topicA,
+		sourceDelayMQConsumer: oldConsumer,
+	}
+	bs := &buses{
+		log:    log.NewHelper(log.DefaultLogger),
+		eg:     new(errgroup.Group),
+		closed: make(chan struct{}),
+	}
+	defer func() {
+		close(bs.closed)
+		_ = bs.eg.Wait()
+	}()
+
+	adopted := bs.drainTopics(b, old)
+	if _, ok := adopted[oldConsumer]; !ok || len(adopted) != 1 {
+		t.Fatalf("expect the consumer of the old delay topic adopted, got: %v", adopted)
+	}
+	v, ok := bs.drains.Load(drainKey(b.name, delayA))
+	if !ok {
+		t.Fatal("expect the old delay topic draining")
+	}
+	d := v.(*drainingConsumer)
+	if d.consumer != oldConsumer || !d.until.Equal(until) {
+		t.Fatalf("expect the old consumer drained until %s, got: %s", until, d.until)
+	}
+	count := 0
+	bs.drains.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("expect only the old delay topic draining, got: %d", count)
+	}
+
+	// the topic draining is not drained again
+	adopted = bs.drainTopics(b, old)
+	if len(adopted) != 0 {
+		t.Fatalf("expect nothing adopted, got: %v", adopted)
+	}
+	if oldConsumer.closed {
+		t.Fatal("expect the draining consumer not closed")
+	}
+
+	// the paused bus stops draining
+	b.status = v1.BusStatus_BUS_STATUS_PAUSED
+	bs.drainTopics(b, nil)
+	if _, ok = bs.drains.Load(drainKey(b.name, delayA)); ok || !oldConsumer.closed {
+		t.Fatal("expect the drain of the paused bus stopped")
+	}
+
+	// the topic reused and replaced again is drained again
+	b.status = v1.BusStatus_BUS_STATUS_ACTIVE
+	delayA.Since++
+	draining, _ = json.Marshal([]drainingTopic{delayA})
+	b.draining = string(draining)
+	reusedConsumer := &mqConsumer{lastReceived: time.Now()}
+	old.sourceDelayMQConsumer = reusedConsumer
+	adopted = bs.drainTopics(b, old)
+	if _, ok = adopted[reusedConsumer]; !ok {
+		t.Fatalf("expect the reused topic drained again, got: %v", adopted)
+	}
+
+	// the topic active again is not drained any more
+	b.sourceDelay = topicA
+	bs.drainTopics(b, nil)
+	if _, ok = bs.drains.Load(drainKey(b.name, delayA)); ok || !reusedConsumer.closed {
+		t.Fatal("expect the drain of the active topic removed")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/go-kratos/kratos/v2/config"
 	"github.com/go-kratos/kratos/v2/config/env"
 	"github.com/go-kratos/kratos/v2/config/file"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/tianping526/eventbridge/app/job/internal/conf"
@@ -49,14 +50,16 @@ func NewConfigBootstrap(c config.Config) (*conf.Bootstrap, error) {
 
 	// server.event
 	defaultEventConf := &conf.Server_Event{
-		SourceTimeout:         durationpb.New(1 * time.Second),
-		DelayTimeout:          durationpb.New(1 * time.Second),
-		TargetExpDecayTimeout: durationpb.New(1 * time.Second),
-		TargetBackoffTimeout:  durationpb.New(1 * time.Second),
-		WorkersPerMqTopic:     256,
-		RuleParallelism:       20,
-		TransformParallelism:  20,
-		DispatchParallelism:   20,
+		SourceTimeout:           durationpb.New(1 * time.Second),
+		DelayTimeout:            durationpb.New(1 * time.Second),
+		TargetExpDecayTimeout:   durationpb.New(1 * time.Second),
+		TargetBackoffTimeout:    durationpb.New(1 * time.Second),
+		WorkersPerMqTopic:       256,
+		RuleParallelism:         20,
+		TransformParallelism:    20,
+		DispatchParallelism:     20,
+		BreakerFailureThreshold: proto.Uint32(5),
+		BreakerOpenTimeout:      durationpb.New(30 * time.Second),
 	}
 	if bc.Server.Event == nil {
 		bc.Server.Event = defaultEventConf
@@ -85,6 +88,12 @@ func NewConfigBootstrap(c config.Config) (*conf.Bootstrap, error) {
 		if bc.Server.Event.DispatchParallelism <= 1 {
 			bc.Server.Event.DispatchParallelism = defaultEventConf.DispatchParallelism
 		}
+		if bc.Server.Event.BreakerFailureThreshold == nil {
+			bc.Server.Event.BreakerFailureThreshold = defaultEventConf.BreakerFailureThreshold
+		}
+		if bc.Server.Event.BreakerOpenTimeout == nil || bc.Server.Event.BreakerOpenTimeout.AsDuration() <= 0 {
+			bc.Server.Event.BreakerOpenTimeout = defaultEventConf.BreakerOpenTimeout
+		}
 	}
 
 	// server.http
@@ -129,5 +138,18 @@ func NewConfigBootstrap(c config.Config) (*conf.Bootstrap, error) {
 		bc.Data.Database.ConnMaxIdleTime = durationpb.New(300 * time.Second)
 	}
 
+	// data.redis, optional
+	if bc.Data.Redis != nil {
+		if bc.Data.Redis.DialTimeout == nil {
+			bc.Data.Redis.DialTimeout = durationpb.New(1 * time.Second)
+		}
+		if bc.Data.Redis.ReadTimeout == nil {
+			bc.Data.Redis.ReadTimeout = durationpb.New(200 * time.Millisecond)
+		}
+		if bc.Data.Redis.WriteTimeout == nil {
+			bc.Data.Redis.WriteTimeout = durationpb.New(200 * time.Millisecond)
+		}
+	}
+
 	return &bc, nil
 }
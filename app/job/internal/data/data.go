@@ -15,11 +15,13 @@ var ProviderSet = wire.NewSet(
 	NewMetric,
 	NewTracerProvider,
 	NewEntClient,
+	NewRedisCmd,
 	NewRules,
 	NewBusReflector,
 	NewBuses,
 	NewReceiver,
 	NewSender,
+	NewBusSender,
 	NewEventRepo,
 )
 
@@ -0,0 +1,48 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+type ProtoDescriptor struct {
+	ent.Schema
+}
+
+func (ProtoDescriptor) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (ProtoDescriptor) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.Time{},
+	}
+}
+
+func (ProtoDescriptor) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			MaxLen(64).
+			Comment("protobuf descriptor name"),
+		field.Bytes("descriptor_set").
+			MaxLen(65535).
+			Comment("serialized FileDescriptorSet"),
+	}
+}
+
+func (ProtoDescriptor) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (ProtoDescriptor) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Unique(),
+	}
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+type EventSchema struct {
+	ent.Schema
+}
+
+func (EventSchema) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (EventSchema) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.Time{},
+	}
+}
+
+func (EventSchema) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("source").
+			MaxLen(64).
+			Comment("source of the event"),
+		field.String("type").
+			MaxLen(64).
+			Comment("type of the event"),
+		field.String("bus_name").
+			MaxLen(64).
+			Comment("event bus name"),
+		field.String("spec").
+			MaxLen(1024).
+			Comment("schema of the event data in the format"),
+		field.Uint32("version").
+			Comment("version of the event schema, it increases on every update and each version is saved"),
+		field.Uint8("compatibility").
+			Default(1).
+			Comment("compatibility of a new spec with the current one, 1-none, 2-backward, 3-forward, 4-full"),
+		field.Strings("extra_bus_names").
+			Optional().
+			Comment("event bus names the events are published to besides bus_name"),
+		field.Uint8("format").
+			Default(1).
+			Comment("format of the spec and the event data, 1-JSON schema, 2-protobuf, 3-avro"),
+		field.Bytes("descriptor_set").
+			Optional().
+			MaxLen(65535).
+			Comment("serialized FileDescriptorSet of the protobuf spec, copied from the registered descriptor"),
+	}
+}
+
+func (EventSchema) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (EventSchema) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("source", "type").Unique(),
+	}
+}
@@ -3,6 +3,8 @@ package data
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
@@ -23,6 +25,11 @@ const (
 	metricPostEventBusName = "bus_name"
 	metricPostEventType    = "event_type"
 	metricPostEventResult  = "result"
+	metricParkedRuleName   = "name"
+
+	// metadataKeyParked marks the target event parked by the paused rule,
+	// it is counted by the parked events until it is dispatched or sent to the DLQ.
+	metadataKeyParked = "x-eb-parked"
 )
 
 var otr = otel.Tracer("/app/job/internal/data/event")
@@ -101,8 +108,43 @@ func (repo *eventRepo) retryDispatchTargetEvent(
 		span.End()
 	}()
 
+	// transform the event whose transformation failed with a retryable error
+	if evt.PendingTransform {
+		var targetEvt *rule.EventExt
+		targetEvt, err = exec.TransformTarget(ctx, evt)
+		if err != nil {
+			if rule.IsTransformerNotFound(err) {
+				err = fmt.Errorf(
+					"no transformer for target(bus name: %s, rule name: %s, target id: %d) to transform",
+					evt.BusName, evt.RuleName, evt.TargetId,
+				)
+				return err
+			}
+			err = fmt.Errorf(
+				"transform target(bus name: %s, rule name: %s, target id: %d) err: %s",
+				evt.BusName, evt.RuleName, evt.TargetId, err,
+			)
+			return err
+		}
+		evt = targetEvt
+	}
+
 	// dispatch
 	err = exec.Dispatch(ctx, evt)
+	if rule.IsResponseNotPostedError(err) {
+		// the target is dispatched, send the event keeping the response to retry queue as a new message,
+		// otherwise the redelivered message would dispatch the target again
+		repo.log.WithContext(ctx).Errorf(
+			"target(bus name: %s, rule name: %s, target id: %d) will retry posting the response, %s",
+			evt.BusName, evt.RuleName, evt.TargetId, err,
+		)
+		sendErr := repo.sd.Send(ctx, evt)
+		if sendErr == nil {
+			err = nil
+			return err
+		}
+		repo.log.WithContext(ctx).Errorf("send event(%s) to retry queue err: %s", evt.Key(), sendErr)
+	}
 	if err != nil {
 		if rule.IsDispatcherNotFound(err) {
 			err = fmt.Errorf(
@@ -112,14 +154,99 @@ func (repo *eventRepo) retryDispatchTargetEvent(
 			return err
 		}
 		err = fmt.Errorf(
-			"dispatch target(bus name: %s, rule name: %s, target id: %d) err: %s",
+			"dispatch target(bus name: %s, rule name: %s, target id: %d) err: %w",
 			evt.BusName, evt.RuleName, evt.TargetId, err,
 		)
 		return err
 	}
+	parkedEventDone(ctx, repo.m.RuleParkedEvents, evt)
 	return err
 }
 
+// parkedEventDone decrements the parked events once the parked event is dispatched or sent to the DLQ.
+func parkedEventDone(ctx context.Context, parkedEvents *parkedEvents, evt *rule.EventExt) {
+	if evt.Metadata[metadataKeyParked] == "" {
+		return
+	}
+	parkedEvents.done(ctx, fmt.Sprintf("%s:%s", evt.BusName, evt.RuleName))
+}
+
+// parkedEvents counts the parked events of each rule by the job replica. The events parked by a replica
+// may be dispatched by another one, so the count of a replica can be negative, and only the sum makes sense.
+// The count of the removed rule is subtracted, and its parked events are not counted any more
+// once they are sent to the DLQ.
+type parkedEvents struct {
+	counter metric.Int64UpDownCounter
+
+	mu      sync.Mutex
+	counts  map[string]int64    // busName:ruleName -> parked events
+	removed map[string]struct{} // busName:ruleName of the removed rules
+}
+
+func newParkedEvents(counter metric.Int64UpDownCounter) *parkedEvents {
+	return &parkedEvents{
+		counter: counter,
+		counts:  make(map[string]int64),
+		removed: make(map[string]struct{}),
+	}
+}
+
+func (p *parkedEvents) park(ctx context.Context, key string) {
+	p.add(ctx, key, 1)
+}
+
+func (p *parkedEvents) done(ctx context.Context, key string) {
+	p.add(ctx, key, -1)
+}
+
+func (p *parkedEvents) add(ctx context.Context, key string, n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.removed[key]; ok {
+		return
+	}
+	p.counts[key] += n
+	p.counter.Add(ctx, n, metric.WithAttributes(attribute.String(metricParkedRuleName, key)))
+}
+
+// removeRule subtracts the parked events of the removed rule.
+func (p *parkedEvents) removeRule(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(key)
+}
+
+// removeBus subtracts the parked events of the rules of the deleted bus.
+func (p *parkedEvents) removeBus(busName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prefix := busName + ":"
+	for key := range p.counts {
+		if strings.HasPrefix(key, prefix) {
+			p.removeLocked(key)
+		}
+	}
+}
+
+func (p *parkedEvents) removeLocked(key string) {
+	p.removed[key] = struct{}{}
+	n, ok := p.counts[key]
+	if !ok {
+		return
+	}
+	delete(p.counts, key)
+	if n != 0 {
+		p.counter.Add(context.Background(), -n, metric.WithAttributes(attribute.String(metricParkedRuleName, key)))
+	}
+}
+
+// addRule counts the parked events of the rule added again.
+func (p *parkedEvents) addRule(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.removed, key)
+}
+
 func (repo *eventRepo) handleSourceEvent(
 	ctx context.Context, ruleName string, exec rule.Executor, evt *rule.EventExt,
 ) (err error) {
@@ -183,10 +310,7 @@ func (repo *eventRepo) handleSourceEvent(
 	}
 
 	// dispatch target event
-	if len(targetEvents) == 0 {
-		repo.log.WithContext(ctx).Errorf(
-			"no target event for rule(%s) in bus(%s) to dispatch", ruleName, evt.BusName,
-		)
+	if len(targetEvents) == 0 { // all targets are skipped by their patterns
 		return err
 	}
 	if len(targetEvents) == 1 {
@@ -222,21 +346,31 @@ func (repo *eventRepo) dispatchTargetEvent(ctx context.Context, exec rule.Execut
 		span.End()
 	}()
 
-	// dispatch
-	err = exec.Dispatch(ctx, evt)
-	if err == nil {
-		return err
-	}
-	if rule.IsDispatcherNotFound(err) {
-		repo.log.WithContext(ctx).Errorf(
-			"no dispatcher for target(bus name: %s, rule name: %s, target id: %d) to dispatch",
-			evt.BusName, evt.RuleName, evt.TargetId,
-		)
-		err = nil
-		return err
+	// dispatch, the event pending transformation is sent to retry queue directly
+	parked := false
+	if !evt.PendingTransform {
+		err = exec.Dispatch(ctx, evt)
+		if err == nil {
+			return err
+		}
+		if rule.IsPausedError(err) {
+			parked = true
+			if evt.Metadata == nil {
+				evt.Metadata = make(map[string]string, 1)
+			}
+			evt.Metadata[metadataKeyParked] = "1"
+		}
+		if rule.IsDispatcherNotFound(err) {
+			repo.log.WithContext(ctx).Errorf(
+				"no dispatcher for target(bus name: %s, rule name: %s, target id: %d) to dispatch",
+				evt.BusName, evt.RuleName, evt.TargetId,
+			)
+			err = nil
+			return err
+		}
 	}
 
-	// dispatch failed, send it to retry queue
+	// dispatch or transform failed, or the target is throttled or paused, send it to retry queue
 	startTime := time.Now()
 	err = repo.sd.Send(ctx, evt)
 	repo.m.PostEventDurationSec.Record(
@@ -266,5 +400,8 @@ func (repo *eventRepo) dispatchTargetEvent(ctx context.Context, exec rule.Execut
 			attribute.String(metricPostEventResult, "ok"),
 		),
 	)
+	if parked {
+		repo.m.RuleParkedEvents.park(ctx, fmt.Sprintf("%s:%s", evt.BusName, evt.RuleName))
+	}
 	return err
 }
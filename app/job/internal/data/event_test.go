@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// upDownCounter sums the values by the name attribute.
+type upDownCounter struct {
+	embedded.Int64UpDownCounter
+	sums map[string]int64
+}
+
+func (c *upDownCounter) Add(_ context.Context, incr int64, opts ...metric.AddOption) {
+	attrs := metric.NewAddConfig(opts).Attributes()
+	name, _ := attrs.Value(metricParkedRuleName)
+	c.sums[name.AsString()] += incr
+}
+
+func (c *upDownCounter) Enabled(context.Context) bool {
+	return true
+}
+
+func TestParkedEvents(t *testing.T) {
+	ctx := context.Background()
+	c := &upDownCounter{sums: make(map[string]int64)}
+	p := newParkedEvents(c)
+	parkedEvt := &rule.EventExt{
+		EventExt: &v1.EventExt{
+			Event:    &v1.Event{Id: 1},
+			BusName:  "bus",
+			RuleName: "rule1",
+			Metadata: map[string]string{metadataKeyParked: "1"},
+		},
+	}
+
+	p.park(ctx, "bus:rule1")
+	p.park(ctx, "bus:rule1")
+	p.park(ctx, "bus:rule2")
+	parkedEventDone(ctx, p, parkedEvt)
+	if c.sums["bus:rule1"] != 1 || c.sums["bus:rule2"] != 1 {
+		t.Fatalf("expect 1 event parked by each rule, got: %v", c.sums)
+	}
+
+	// the parked events of the removed rule are not counted any more
+	p.removeRule("bus:rule1")
+	parkedEventDone(ctx, p, parkedEvt)
+	if c.sums["bus:rule1"] != 0 {
+		t.Fatalf("expect no event parked by the removed rule, got: %d", c.sums["bus:rule1"])
+	}
+
+	// the rule added again is counted
+	p.addRule("bus:rule1")
+	p.park(ctx, "bus:rule1")
+	if c.sums["bus:rule1"] != 1 {
+		t.Fatalf("expect 1 event parked by the rule added again, got: %d", c.sums["bus:rule1"])
+	}
+
+	// the parked events of the rules of the deleted bus are not counted any more
+	p.removeBus("bus")
+	if c.sums["bus:rule1"] != 0 || c.sums["bus:rule2"] != 0 {
+		t.Fatalf("expect no event parked by the deleted bus, got: %v", c.sums)
+	}
+}
@@ -18,6 +18,8 @@ type Metric struct {
 	RunningWorkers       metric.Int64Gauge
 	RuleExecTotal        metric.Int64Counter
 	RuleExecSec          metric.Float64Histogram
+	RuleBreakerState     metric.Int64Gauge
+	RuleParkedEvents     *parkedEvents
 }
 
 func NewMetric(ai *conf.AppInfo) (*Metric, error) {
@@ -88,6 +90,24 @@ func NewMetric(ai *conf.AppInfo) (*Metric, error) {
 		return nil, err
 	}
 
+	ruleBreakerState, err := meter.Int64Gauge(
+		"job_rule_breaker_state",
+		metric.WithUnit("{state}"),
+		metric.WithDescription("State of the target circuit breakers, 0 is closed, 1 is half-open and 2 is open."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleParkedEvents, err := meter.Int64UpDownCounter(
+		"job_rule_parked_events",
+		metric.WithUnit("{count}"),
+		metric.WithDescription("Number of target events parked by the paused rules and not dispatched yet."),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Metric{
 		ServerCodeTotal:      serverCodeTotal,
 		ServerDurationSec:    serverDurationSec,
@@ -97,5 +117,7 @@ func NewMetric(ai *conf.AppInfo) (*Metric, error) {
 		RunningWorkers:       runningWorkers,
 		RuleExecTotal:        ruleExecTotal,
 		RuleExecSec:          ruleExecSec,
+		RuleBreakerState:     ruleBreakerState,
+		RuleParkedEvents:     newParkedEvents(ruleParkedEvents),
 	}, nil
 }
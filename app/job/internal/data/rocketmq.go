@@ -7,6 +7,8 @@ import (
 	"math"
 	"math/rand"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -26,6 +28,16 @@ import (
 
 const (
 	rmqReqTimeout = 3 * time.Second
+	// rmqThrottledDelay is the min delay of the event throttled by the target limits, and a random second is added.
+	rmqThrottledDelay = time.Second
+	// rmqPausedDelay is the min delay of the event parked by the paused rule, and a random second is added.
+	rmqPausedDelay = 30 * time.Second
+	// rmqBreakerOpenDelay is the min delay of the event rejected by the open circuit breaker of the target,
+	// and a random second is added.
+	rmqBreakerOpenDelay = 10 * time.Second
+	// rmqDeferMaxAttempts is the delivery attempts of the deferred event before it is republished,
+	// it is less than the max delivery attempts of the consumer groups so that the event isn't sent to the DLQ.
+	rmqDeferMaxAttempts = 3
 
 	metricLabelBusName      = "bus_name"
 	metricLabelBusTopicType = "topic_type"
@@ -87,14 +99,23 @@ func (r *rocketMQProducer) Close() error {
 type rocketMQConsumer struct {
 	log *log.Helper
 
-	busName   string
-	topicType string
-	c         rmqClient.SimpleConsumer
-	closeC    chan struct{}
+	busName      string
+	topicType    string
+	endpoints    string
+	topic        string
+	c            rmqClient.SimpleConsumer
+	closeC       chan struct{}
+	lastReceived atomic.Int64 // unix nano
+	parkedEvents *parkedEvents
+
+	parkProducerLock sync.Mutex
+	parkProducer     MQProducer // republishes the deferred events, created on the first use
 }
 
+// NewRocketMQConsumer the parked events are decremented when the parked events are sent to the DLQ.
 func NewRocketMQConsumer(
 	logger log.Logger, busName string, topicType string, endpoints string, topic string,
+	parkedEvents *parkedEvents,
 ) (MQConsumer, error) {
 	// new simpleConsumer instance
 	simpleConsumer, err := rmqClient.NewSimpleConsumer(&rmqClient.Config{
@@ -122,17 +143,26 @@ func NewRocketMQConsumer(
 	if err != nil {
 		return nil, err
 	}
-	return &rocketMQConsumer{
+	c := &rocketMQConsumer{
 		log: log.NewHelper(log.With(
 			logger,
 			"module", "rocketmq/consumer",
 			"caller", log.DefaultCaller,
 		)),
-		busName:   busName,
-		topicType: topicType,
-		c:         simpleConsumer,
-		closeC:    make(chan struct{}),
-	}, nil
+		busName:      busName,
+		topicType:    topicType,
+		endpoints:    endpoints,
+		topic:        topic,
+		c:            simpleConsumer,
+		closeC:       make(chan struct{}),
+		parkedEvents: parkedEvents,
+	}
+	c.lastReceived.Store(time.Now().UnixNano())
+	return c, nil
+}
+
+func (r *rocketMQConsumer) LastReceived() time.Time {
+	return time.Unix(0, r.lastReceived.Load())
 }
 
 func (r *rocketMQConsumer) Receive(
@@ -164,9 +194,12 @@ func (r *rocketMQConsumer) Receive(
 					continue
 				}
 			}
+			if len(mvs) > 0 {
+				r.lastReceived.Store(time.Now().UnixNano())
+			}
 			// handle a message
 			for _, mv := range mvs {
-				r.messageHandle(ctx, mv, handler, timeout)
+				r.messageHandle(ctx, mv, handler, mode, timeout)
 			}
 		}
 	} else { // CONCURRENTLY
@@ -222,6 +255,9 @@ func (r *rocketMQConsumer) Receive(
 					continue
 				}
 			}
+			if len(mvs) > 0 {
+				r.lastReceived.Store(time.Now().UnixNano())
+			}
 			// handle a message
 			for _, mv := range mvs {
 				select {
@@ -232,7 +268,7 @@ func (r *rocketMQConsumer) Receive(
 						defer func() {
 							<-sem // release a semaphore
 						}()
-						r.messageHandle(ctx, mv, handler, timeout)
+						r.messageHandle(ctx, mv, handler, mode, timeout)
 						return nil
 					})
 				}
@@ -245,6 +281,7 @@ func (r *rocketMQConsumer) messageHandle(
 	ctx context.Context,
 	mv *rmqClient.MessageView,
 	f event.Handler,
+	mode v1.BusWorkMode,
 	timeout time.Duration,
 ) {
 	// set timeout
@@ -278,12 +315,19 @@ func (r *rocketMQConsumer) messageHandle(
 			}
 		}
 
-		if err != nil { // failed
+		if rule.IsThrottledError(err) {
+			r.deferThrottled(ctx, mv, evt, mode, err)
+		} else if rule.IsPausedError(err) {
+			r.deferPaused(ctx, mv, evt, mode)
+		} else if rule.IsBreakerOpenError(err) {
+			r.deferBreakerOpen(ctx, mv, evt, mode, err)
+		} else if err != nil { // failed
 			if mv.GetDeliveryAttempt() >= 4 { // nolint:mnd
 				r.log.WithContext(ctx).Errorf(
 					"failed %d times, event key: %s, will into DLQ",
 					mv.GetDeliveryAttempt(), evt.Key(),
 				)
+				parkedEventDone(ctx, r.parkedEvents, evt)
 				// err = r.c.ForwardMessageToDeadLetterQueue(ctx, mv)
 				// if err != nil {
 				// 	r.log.Errorf("forward event(%s) to DLQ err: %s", evt.Key(), err)
@@ -314,12 +358,19 @@ func (r *rocketMQConsumer) messageHandle(
 			}
 		}
 
-		if err != nil { // failed
+		if rule.IsThrottledError(err) {
+			r.deferThrottled(ctx, mv, evt, mode, err)
+		} else if rule.IsPausedError(err) {
+			r.deferPaused(ctx, mv, evt, mode)
+		} else if rule.IsBreakerOpenError(err) {
+			r.deferBreakerOpen(ctx, mv, evt, mode, err)
+		} else if err != nil { // failed
 			if mv.GetDeliveryAttempt() >= 177 { // nolint:mnd
 				r.log.WithContext(ctx).Errorf(
 					"failed %d times, event key: %s, will into DLQ",
 					mv.GetDeliveryAttempt(), evt.Key(),
 				)
+				parkedEventDone(ctx, r.parkedEvents, evt)
 				// err = r.c.ForwardMessageToDeadLetterQueue(ctx, mv)
 				// if err != nil {
 				// 	r.log.Errorf("forward event(%s) to DLQ err: %s", evt.Key(), err)
@@ -342,12 +393,102 @@ func (r *rocketMQConsumer) messageHandle(
 	}
 }
 
+// deferThrottled defers the event throttled by the target limits for a short delay,
+// no matter how many times it has been delivered, since the target isn't called.
+func (r *rocketMQConsumer) deferThrottled(
+	ctx context.Context, mv *rmqClient.MessageView, evt *rule.EventExt, mode v1.BusWorkMode, throttledErr error,
+) {
+	delay := rmqThrottledDelay + time.Duration(rand.Int63n(int64(time.Second)))
+	r.log.WithContext(ctx).Warnf("event key: %s, will retry after %s, %s", evt.Key(), delay, throttledErr)
+	r.deferLater(ctx, mv, evt, mode, delay)
+}
+
+// deferPaused parks the event of the paused rule, no matter how many times it has been delivered.
+func (r *rocketMQConsumer) deferPaused(
+	ctx context.Context, mv *rmqClient.MessageView, evt *rule.EventExt, mode v1.BusWorkMode,
+) {
+	delay := rmqPausedDelay + time.Duration(rand.Int63n(int64(time.Second)))
+	r.log.WithContext(ctx).Debugf("event key: %s, rule is paused, will retry after %s", evt.Key(), delay)
+	r.deferLater(ctx, mv, evt, mode, delay)
+}
+
+// deferBreakerOpen defers the event rejected by the open circuit breaker of the target,
+// no matter how many times it has been delivered, since the target isn't called.
+func (r *rocketMQConsumer) deferBreakerOpen(
+	ctx context.Context, mv *rmqClient.MessageView, evt *rule.EventExt, mode v1.BusWorkMode, breakerErr error,
+) {
+	delay := rmqBreakerOpenDelay + time.Duration(rand.Int63n(int64(time.Second)))
+	r.log.WithContext(ctx).Warnf("event key: %s, will retry after %s, %s", evt.Key(), delay, breakerErr)
+	r.deferLater(ctx, mv, evt, mode, delay)
+}
+
+// deferLater retries the event after the delay. It is republished into the topic before its delivery attempts
+// run out, so that it isn't sent to the DLQ however long it is deferred. The event of the orderly bus is never
+// republished, since the new message would be behind the later events of its message group.
+func (r *rocketMQConsumer) deferLater(
+	ctx context.Context, mv *rmqClient.MessageView, evt *rule.EventExt, mode v1.BusWorkMode, delay time.Duration,
+) {
+	if mode != v1.BusWorkMode_BUS_WORK_MODE_ORDERLY &&
+		mv.GetDeliveryAttempt() >= rmqDeferMaxAttempts && r.republish(ctx, mv, evt, mode) {
+		return
+	}
+	err := r.c.ChangeInvisibleDuration(mv, delay)
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("change event(%s) invisible duration err: %s", evt.Key(), err)
+	}
+}
+
+// republish sends the event into the topic as a new message whose delivery attempts start over,
+// and acks the delivered one. It reports whether the event is republished.
+func (r *rocketMQConsumer) republish(
+	ctx context.Context, mv *rmqClient.MessageView, evt *rule.EventExt, mode v1.BusWorkMode,
+) bool {
+	// the handler may have timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rmqReqTimeout)
+	defer cancel()
+	p, err := r.getParkProducer()
+	if err == nil {
+		err = p.Send(ctx, r.topic, mode, evt)
+	}
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("republish event(%s) err: %s", evt.Key(), err)
+		return false
+	}
+	err = r.c.Ack(ctx, mv)
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("ack republished event(%s) err: %s", evt.Key(), err)
+	}
+	return true
+}
+
+func (r *rocketMQConsumer) getParkProducer() (MQProducer, error) {
+	r.parkProducerLock.Lock()
+	defer r.parkProducerLock.Unlock()
+	if r.parkProducer == nil {
+		p, err := NewRocketMQProducer(r.endpoints, r.topic)
+		if err != nil {
+			return nil, err
+		}
+		r.parkProducer = p
+	}
+	return r.parkProducer, nil
+}
+
 func (r *rocketMQConsumer) Close() error {
 	select {
 	case <-r.closeC:
 		return nil
 	default:
 		close(r.closeC)
-		return r.c.GracefulStop()
+		err := r.c.GracefulStop()
+		r.parkProducerLock.Lock()
+		defer r.parkProducerLock.Unlock()
+		if r.parkProducer != nil {
+			if perr := r.parkProducer.Close(); perr != nil {
+				r.log.Errorf("close park producer err: %s", perr)
+			}
+			r.parkProducer = nil
+		}
+		return err
 	}
 }
@@ -9,15 +9,20 @@ import (
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
 
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	"github.com/tianping526/eventbridge/app/internal/informer"
 	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/rule/limit"
 	"github.com/tianping526/eventbridge/app/internal/rule/pattern"
 	"github.com/tianping526/eventbridge/app/internal/rule/target"
 	"github.com/tianping526/eventbridge/app/internal/rule/transform"
+	"github.com/tianping526/eventbridge/app/internal/secret"
 	"github.com/tianping526/eventbridge/app/job/internal/conf"
 	"github.com/tianping526/eventbridge/app/job/internal/data/ent"
+	"github.com/tianping526/eventbridge/app/job/internal/data/ent/connection"
+	"github.com/tianping526/eventbridge/app/job/internal/data/ent/protodescriptor"
 	entRule "github.com/tianping526/eventbridge/app/job/internal/data/ent/rule"
 	"github.com/tianping526/eventbridge/app/job/internal/data/ent/version"
 	"github.com/tianping526/eventbridge/app/job/internal/data/entext"
@@ -27,10 +32,12 @@ type ruleReflector struct {
 	log *log.Helper
 
 	db           *ent.Client
+	cipher       *secret.Cipher
 	rulesVersion uint64
 	interval     time.Duration
 	dbTimeout    time.Duration
 	interRules   map[string]*rule.Rule
+	parkedEvents *parkedEvents
 	closeCh      chan struct{}
 
 	rules sync.Map // map[busName:ruleName]*rule.Rule
@@ -39,13 +46,17 @@ type ruleReflector struct {
 func NewRuleReflector(
 	logger log.Logger,
 	db *ent.Client,
+	cipher *secret.Cipher,
+	parkedEvents *parkedEvents,
 ) (informer.Reflector, error) {
 	return &ruleReflector{
-		log:       log.NewHelper(log.With(logger, "module", "rule/reflector")),
-		db:        db,
-		interval:  5 * time.Second,
-		dbTimeout: 5 * time.Second,
-		closeCh:   make(chan struct{}),
+		log:          log.NewHelper(log.With(logger, "module", "rule/reflector")),
+		db:           db,
+		cipher:       cipher,
+		interval:     5 * time.Second,
+		dbTimeout:    5 * time.Second,
+		parkedEvents: parkedEvents,
+		closeCh:      make(chan struct{}),
 	}, nil
 }
 
@@ -55,7 +66,7 @@ func (rr *ruleReflector) Watch() ([]string, error) {
 		return nil, err
 	}
 
-	rules, err := rr.fetchEnableRules()
+	rules, err := rr.fetchActiveRules()
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +78,11 @@ func (rr *ruleReflector) Watch() ([]string, error) {
 
 	updated := make([]string, 0)
 	for key, r := range newRules {
-		if old, ok := rr.interRules[key]; !ok || !reflect.DeepEqual(*old, *r) {
+		old, ok := rr.interRules[key]
+		if !ok {
+			rr.parkedEvents.addRule(key)
+		}
+		if !ok || !reflect.DeepEqual(*old, *r) {
 			rr.rules.Store(key, r)
 			updated = append(updated, key)
 		}
@@ -75,6 +90,7 @@ func (rr *ruleReflector) Watch() ([]string, error) {
 	for key := range rr.interRules {
 		if _, ok := newRules[key]; !ok {
 			rr.rules.Delete(key)
+			rr.parkedEvents.removeRule(key) // the disabled rule is removed too
 			updated = append(updated, key)
 		}
 	}
@@ -131,16 +147,20 @@ func (rr *ruleReflector) fetchNextRulesVersion() error {
 	}
 }
 
-func (rr *ruleReflector) fetchEnableRules() ([]*rule.Rule, error) {
+// fetchActiveRules fetches the enabled and the paused rules,
+// the paused rules still match the events and park their target events in the retry queue.
+func (rr *ruleReflector) fetchActiveRules() ([]*rule.Rule, error) {
 	rules := make([]*rule.Rule, 0)
 	next := uint64(0)
 	limit := 100
-	status := uint8(v1.RuleStatus_RULE_STATUS_ENABLE)
+	statuses := []uint8{uint8(v1.RuleStatus_RULE_STATUS_ENABLE), uint8(v1.RuleStatus_RULE_STATUS_PAUSED)}
+	descriptorSets := make(map[string][]byte)
+	connections := make(map[string]*rule.Connection)
 	for {
 		ctx, cancel := context.WithTimeout(context.Background(), rr.dbTimeout)
 		rs, err := rr.db.Rule.Query().
 			Where(
-				entRule.Status(status),
+				entRule.StatusIn(statuses...),
 				entRule.IDGTE(next),
 			).
 			Order(ent.Asc(entRule.FieldID)).
@@ -162,6 +182,16 @@ func (rr *ruleReflector) fetchEnableRules() ([]*rule.Rule, error) {
 			if err != nil {
 				return nil, err
 			}
+			err = rr.resolveProtoDescriptors(targets, descriptorSets)
+			if err != nil {
+				return nil, err
+			}
+			err = rr.resolveConnections(targets, connections)
+			if err != nil {
+				return nil, err
+			}
+			rr.resolveSigningKeys(targets)
+			rr.resolveRedactionKeys(targets)
 			rules = append(rules, &rule.Rule{
 				Name:    r.Name,
 				BusName: r.BusName,
@@ -177,21 +207,148 @@ func (rr *ruleReflector) fetchEnableRules() ([]*rule.Rule, error) {
 	return rules, nil
 }
 
-func NewRules(logger log.Logger, conf *conf.Bootstrap, db *ent.Client, m *Metric) (rule.Rules, func(), error) {
-	reflector, err := NewRuleReflector(logger, db)
+// resolveProtoDescriptors loads the descriptor sets used by the target encodings,
+// descriptorSets caches the loaded ones during a fetch.
+func (rr *ruleReflector) resolveProtoDescriptors(targets []*rule.Target, descriptorSets map[string][]byte) error {
+	for _, t := range targets {
+		if t.Encoding == nil || t.Encoding.Descriptor == "" {
+			continue
+		}
+		descriptorSet, ok := descriptorSets[t.Encoding.Descriptor]
+		if !ok {
+			ctx, cancel := context.WithTimeout(context.Background(), rr.dbTimeout)
+			pd, err := rr.db.ProtoDescriptor.Query().
+				Where(protodescriptor.Name(t.Encoding.Descriptor)).
+				Only(ctx)
+			cancel()
+			if err != nil && !ent.IsNotFound(err) {
+				return err
+			}
+			if pd != nil {
+				descriptorSet = pd.DescriptorSet
+			} else {
+				rr.log.Errorf("can't find the protobuf descriptor(%s)", t.Encoding.Descriptor)
+			}
+			descriptorSets[t.Encoding.Descriptor] = descriptorSet
+		}
+		t.Encoding.DescriptorSet = descriptorSet
+	}
+	return nil
+}
+
+// resolveConnections loads and decrypts the connections referenced by the targets,
+// connections caches the loaded ones during a fetch.
+// The target whose connection can't be resolved fails to build its dispatcher.
+func (rr *ruleReflector) resolveConnections(targets []*rule.Target, connections map[string]*rule.Connection) error {
+	for _, t := range targets {
+		if t.Connection == "" {
+			continue
+		}
+		conn, ok := connections[t.Connection]
+		if !ok {
+			ctx, cancel := context.WithTimeout(context.Background(), rr.dbTimeout)
+			c, err := rr.db.Connection.Query().
+				Where(connection.Name(t.Connection)).
+				Only(ctx)
+			cancel()
+			if err != nil && !ent.IsNotFound(err) {
+				return err
+			}
+			if c != nil {
+				var plaintext string
+				plaintext, err = rr.cipher.Decrypt(c.Secret)
+				if err != nil {
+					rr.log.Errorf("decrypt the secret of the connection(%s) err: %s", t.Connection, err)
+				} else {
+					conn = &rule.Connection{
+						Name:     c.Name,
+						AuthType: c.AuthType,
+						Key:      c.Key,
+						Secret:   plaintext,
+						TokenURL: c.TokenURL,
+						Scopes:   c.Scopes,
+					}
+				}
+			} else {
+				rr.log.Errorf("can't find the connection(%s)", t.Connection)
+			}
+			connections[t.Connection] = conn
+		}
+		t.Auth = conn
+	}
+	return nil
+}
+
+// resolveSigningKeys decrypts the signing secrets of the targets,
+// the target whose secrets can't be decrypted fails to build its dispatcher.
+func (rr *ruleReflector) resolveSigningKeys(targets []*rule.Target) {
+	for _, t := range targets {
+		if t.Signing == nil {
+			continue
+		}
+		keys := make([]string, 0, len(t.Signing.Secrets))
+		for _, encrypted := range t.Signing.Secrets {
+			key, err := rr.cipher.Decrypt(encrypted)
+			if err != nil {
+				rr.log.Errorf("decrypt the signing secret of the target(id: %d) err: %s", t.ID, err)
+				keys = nil
+				break
+			}
+			keys = append(keys, key)
+		}
+		t.Signing.Keys = keys
+	}
+}
+
+// resolveRedactionKeys decrypts the keys of the HASH redactions,
+// the target whose keys can't be decrypted fails to build its dispatcher.
+func (rr *ruleReflector) resolveRedactionKeys(targets []*rule.Target) {
+	for _, t := range targets {
+		for _, r := range t.Redactions {
+			if r.Key == "" {
+				continue
+			}
+			key, err := rr.cipher.Decrypt(r.Key)
+			if err != nil {
+				rr.log.Errorf("decrypt the redaction key of the target(id: %d) err: %s", t.ID, err)
+				continue
+			}
+			r.HMACKey = key
+		}
+	}
+}
+
+func NewRules(
+	logger log.Logger, conf *conf.Bootstrap, db *ent.Client, rc redis.Cmdable, bs target.BusSender, m *Metric,
+) (rule.Rules, func(), error) {
+	cipher, err := secret.NewCipher(conf.GetData().GetSecretKey())
 	if err != nil {
 		return nil, nil, err
 	}
+	reflector, err := NewRuleReflector(logger, db, cipher, m.RuleParkedEvents)
+	if err != nil {
+		return nil, nil, err
+	}
+	var newLimiterFunc rule.NewLimiterFunc = limit.NewLocalLimiter
+	if rc != nil {
+		newLimiterFunc = limit.NewRedisLimiterFunc(rc)
+	}
 	return rule.NewRules(
 		logger,
 		reflector,
 		rule.NewNewExecutorFunc(
 			pattern.NewMatcher,
 			transform.NewTransformer,
-			target.NewDispatcher,
+			target.NewNewDispatcherFunc(bs),
 		),
 		rule.WithExecuteDuration(m.RuleExecSec),
 		rule.WithExecuteTotal(m.RuleExecTotal),
 		rule.WithTransformParallelism(int(conf.Server.Event.TransformParallelism)),
+		rule.WithNewLimiterFunc(newLimiterFunc),
+		rule.WithCircuitBreaker(
+			int(conf.Server.Event.GetBreakerFailureThreshold()),
+			conf.Server.Event.BreakerOpenTimeout.AsDuration(),
+		),
+		rule.WithBreakerState(m.RuleBreakerState),
 	)
 }
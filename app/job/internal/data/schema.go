@@ -0,0 +1,110 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/xeipuuv/gojsonschema"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/rule/target"
+	"github.com/tianping526/eventbridge/app/job/internal/data/ent"
+	"github.com/tianping526/eventbridge/app/job/internal/data/ent/eventschema"
+)
+
+// schemaCacheExpiration the schemas of the posted events are cached locally for a short time,
+// so a schema update takes effect a little later on the job.
+const schemaCacheExpiration = 10 * time.Second
+
+// busSender validates the events posted by PostToBus like the events posted to the service.
+type busSender struct {
+	Bus
+	db *ent.Client
+	sc *cache.Cache
+}
+
+func NewBusSender(b Bus, db *ent.Client) target.BusSender {
+	return &busSender{
+		Bus: b,
+		db:  db,
+		sc:  cache.New(schemaCacheExpiration, 2*schemaCacheExpiration),
+	}
+}
+
+// eventSchema is the latest spec of a source and type, and the buses its events are published to.
+type eventSchema struct {
+	buses     []string
+	format    v1.SchemaFormat
+	validator *gojsonschema.Schema
+}
+
+// PostToBus the event is validated by the latest schema of its source and type,
+// and the bus must be one of the buses of the schema. The event data is JSON,
+// so the schema of a binary format is not accepted.
+func (s *busSender) PostToBus(ctx context.Context, busName string, eventExt *rule.EventExt) error {
+	schema, err := s.getSchema(ctx, eventExt.Event.Source, eventExt.Event.Type)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return v1.ErrorSourceTypeNotFound(
+			"source(%s) + type(%s) not found.",
+			eventExt.Event.Source, eventExt.Event.Type,
+		)
+	}
+	if !slices.Contains(schema.buses, busName) {
+		return v1.ErrorDataBusNotFound(
+			"data bus(%s) is not a bus of the schema. source: %s, type: %s",
+			busName, eventExt.Event.Source, eventExt.Event.Type,
+		)
+	}
+	if schema.validator == nil {
+		return v1.ErrorEventDataNotValid(
+			"event data is JSON but the schema format is %s. source: %s, type: %s",
+			schema.format, eventExt.Event.Source, eventExt.Event.Type,
+		)
+	}
+	err = eventExt.ValidateEventData(schema.validator)
+	if err != nil {
+		return err
+	}
+	return s.SendToBus(ctx, busName, eventExt)
+}
+
+// getSchema returns nil if the source and type have no schema.
+func (s *busSender) getSchema(ctx context.Context, source string, sType string) (*eventSchema, error) {
+	lcKey := fmt.Sprintf("%s:%s", source, sType)
+	val, ok := s.sc.Get(lcKey)
+	if ok {
+		return val.(*eventSchema), nil
+	}
+
+	es, err := s.db.EventSchema.Query().
+		Where(eventschema.Source(source), eventschema.Type(sType)).
+		Only(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return nil, err
+	}
+
+	var schema *eventSchema
+	if es != nil {
+		schema = &eventSchema{
+			buses:  append([]string{es.BusName}, es.ExtraBusNames...),
+			format: v1.SchemaFormat(es.Format),
+		}
+		if schema.format == v1.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA {
+			schema.validator, err = gojsonschema.NewSchema(gojsonschema.NewStringLoader(es.Spec))
+			if err != nil {
+				return nil, fmt.Errorf("parse the schema of source(%s) + type(%s) err: %w", source, sType, err)
+			}
+		}
+	}
+
+	s.sc.Set(lcKey, schema, cache.DefaultExpiration)
+
+	return schema, nil
+}
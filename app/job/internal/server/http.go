@@ -1,6 +1,10 @@
 package server
 
 import (
+	"encoding/json/v2"
+	nethttp "net/http"
+	"slices"
+
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware/logging"
 	"github.com/go-kratos/kratos/v2/middleware/metrics"
@@ -12,6 +16,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/tianping526/eventbridge/app/internal/rule"
 	"github.com/tianping526/eventbridge/app/job/internal/conf"
 	"github.com/tianping526/eventbridge/app/job/internal/data"
 )
@@ -21,6 +26,7 @@ func NewHTTPServer(
 	bc *conf.Bootstrap,
 	logger log.Logger,
 	m *data.Metric,
+	rs rule.Rules,
 	_ trace.TracerProvider, // otel.SetTracerProvider(provider) instead, but need to declare to wire injection
 ) *http.Server {
 	cs := bc.Server
@@ -53,5 +59,28 @@ func NewHTTPServer(
 	}
 	srv := http.NewServer(opts...)
 	srv.Handle("/metrics", promhttp.Handler())
+	srv.HandleFunc("/admin/breakers", breakersHandler(logger, rs))
 	return srv
 }
+
+// breakersHandler lists the circuit breakers of the targets, state=OPEN lists the open ones only.
+func breakersHandler(logger log.Logger, rs rule.Rules) nethttp.HandlerFunc {
+	l := log.NewHelper(log.With(logger, "module", "server/http"))
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != nethttp.MethodGet {
+			w.WriteHeader(nethttp.StatusMethodNotAllowed)
+			return
+		}
+		breakers := rs.GetBreakers()
+		if state := r.URL.Query().Get("state"); state != "" {
+			breakers = slices.DeleteFunc(breakers, func(b *rule.BreakerStatus) bool {
+				return b.State != state
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.MarshalWrite(w, map[string]interface{}{"breakers": breakers})
+		if err != nil {
+			l.Errorf("write breakers err: %s", err)
+		}
+	}
+}
@@ -9,4 +9,6 @@ var ProviderSet = wire.NewSet(
 	NewBusUseCase,
 	NewEventUseCase,
 	NewRuleUseCase,
+	NewConnectionUseCase,
+	NewManifestUseCase,
 )
@@ -23,14 +23,22 @@ type Bus struct {
 	SourceDelay    MQTopic
 	TargetExpDecay MQTopic
 	TargetBackoff  MQTopic
+	Status         v1.BusStatus
+	Discovery      bool
 }
 
 type BusRepo interface {
 	ListBus(ctx context.Context, prefix *string, limit int32, nextToken uint64) ([]*Bus, uint64, error)
 	CreateBus(
 		ctx context.Context, bus string, mode v1.BusWorkMode, source MQTopic,
-		sourceDelay MQTopic, targetExpDecay MQTopic, targetBackoff MQTopic,
+		sourceDelay MQTopic, targetExpDecay MQTopic, targetBackoff MQTopic, discovery bool,
 	) (uint64, error)
+	// UpdateBus updates the mode, the status, the discovery and the topics which are not nil,
+	// and the old topics are drained by the job until they are empty.
+	UpdateBus(
+		ctx context.Context, bus string, mode *v1.BusWorkMode, status *v1.BusStatus, source *MQTopic,
+		sourceDelay *MQTopic, targetExpDecay *MQTopic, targetBackoff *MQTopic, discovery *bool,
+	) error
 	DeleteBus(ctx context.Context, bus string) error
 }
 
@@ -59,9 +67,16 @@ func (uc *BusUseCase) ListBus(
 
 func (uc *BusUseCase) CreateBus(
 	ctx context.Context, bus string, mode v1.BusWorkMode, source MQTopic,
-	sourceDelay MQTopic, targetExpDecay MQTopic, targetBackoff MQTopic,
+	sourceDelay MQTopic, targetExpDecay MQTopic, targetBackoff MQTopic, discovery bool,
 ) (uint64, error) {
-	return uc.repo.CreateBus(ctx, bus, mode, source, sourceDelay, targetExpDecay, targetBackoff)
+	return uc.repo.CreateBus(ctx, bus, mode, source, sourceDelay, targetExpDecay, targetBackoff, discovery)
+}
+
+func (uc *BusUseCase) UpdateBus(
+	ctx context.Context, bus string, mode *v1.BusWorkMode, status *v1.BusStatus, source *MQTopic,
+	sourceDelay *MQTopic, targetExpDecay *MQTopic, targetBackoff *MQTopic, discovery *bool,
+) error {
+	return uc.repo.UpdateBus(ctx, bus, mode, status, source, sourceDelay, targetExpDecay, targetBackoff, discovery)
 }
 
 func (uc *BusUseCase) DeleteBus(ctx context.Context, bus string) error {
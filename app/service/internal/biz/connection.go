@@ -0,0 +1,75 @@
+package biz
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/rule/target"
+)
+
+// Connection the Secret is only set when it is created or updated, and it is never returned.
+type Connection struct {
+	rule.Connection
+	Time *timestamppb.Timestamp
+}
+
+type ConnectionRepo interface {
+	ListConnection(ctx context.Context, prefix *string) ([]*Connection, error)
+	CreateConnection(ctx context.Context, conn *rule.Connection) error
+	// UpdateConnection keeps the secret if it is nil.
+	UpdateConnection(ctx context.Context, conn *rule.Connection, secret *string) error
+	DeleteConnection(ctx context.Context, name string) error
+}
+
+type ConnectionUseCase struct {
+	repo ConnectionRepo
+
+	log *log.Helper
+}
+
+func NewConnectionUseCase(repo ConnectionRepo, logger log.Logger) *ConnectionUseCase {
+	return &ConnectionUseCase{
+		repo: repo,
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "usecase/connection",
+			"caller", log.DefaultCaller,
+		)),
+	}
+}
+
+func (uc *ConnectionUseCase) ListConnection(ctx context.Context, prefix *string) ([]*Connection, error) {
+	return uc.repo.ListConnection(ctx, prefix)
+}
+
+func (uc *ConnectionUseCase) CreateConnection(ctx context.Context, conn *rule.Connection) error {
+	err := target.ConnectionSyntaxCheck(conn)
+	if err != nil {
+		return v1.ErrorConnectionSyntaxError(
+			"syntax error: %s", err,
+		)
+	}
+	return uc.repo.CreateConnection(ctx, conn)
+}
+
+// UpdateConnection the secret is only checked if it is updated.
+func (uc *ConnectionUseCase) UpdateConnection(ctx context.Context, conn *rule.Connection, secret *string) error {
+	if secret != nil {
+		conn.Secret = *secret
+	}
+	err := target.ConnectionSyntaxCheck(conn)
+	if err != nil {
+		return v1.ErrorConnectionSyntaxError(
+			"syntax error: %s", err,
+		)
+	}
+	return uc.repo.UpdateConnection(ctx, conn, secret)
+}
+
+func (uc *ConnectionUseCase) DeleteConnection(ctx context.Context, name string) error {
+	return uc.repo.DeleteConnection(ctx, name)
+}
@@ -12,45 +12,144 @@ import (
 )
 
 type EventRepo interface {
-	PostEvent(ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp) (*EventInfo, error)
+	PostEvent(
+		ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp, schemaVersion *uint32,
+		busName *string, busNames []string,
+	) (*EventInfo, error)
 	ListSchema(
 		ctx context.Context, source *string, sType *string, busName *string, time *timestamppb.Timestamp,
 	) ([]*Schema, error)
-	CreateSchema(ctx context.Context, source string, sType string, busName string, spec []byte) error
-	UpdateSchema(ctx context.Context, source string, sType string, busName *string, spec []byte) error
+	// CreateSchema the descriptor of the PROTOBUF spec is resolved in the repo.
+	CreateSchema(
+		ctx context.Context, source string, sType string, busName string, spec []byte,
+		compatibility v1.SchemaCompatibility, extraBusNames []string, format v1.SchemaFormat,
+	) error
+	// UpdateSchema the spec and the extra bus names are not updated if they are nil.
+	UpdateSchema(
+		ctx context.Context, source string, sType string, busName *string, spec []byte,
+		compatibility *v1.SchemaCompatibility, extraBusNames []string, format *v1.SchemaFormat,
+	) (uint32, error)
 	DeleteSchema(ctx context.Context, source string, sType *string) error
+	ListSchemaVersions(
+		ctx context.Context, source string, sType string, limit int32, nextToken uint32,
+	) ([]*SchemaVersion, uint32, error)
+	GetSchemaVersion(ctx context.Context, source string, sType string, version uint32) (*SchemaVersion, error)
+	ListDiscoveredSchemas(
+		ctx context.Context, busName *string, limit int32, nextToken uint64,
+	) ([]*DiscoveredSchema, uint64, error)
+	// PromoteDiscoveredSchema creates the schema by the spec, or the inferred spec if it is nil,
+	// then posts the quarantined events valid under it and drops the others.
+	PromoteDiscoveredSchema(
+		ctx context.Context, source string, sType string, spec []byte, compatibility v1.SchemaCompatibility,
+	) (uint32, uint32, error)
+	ListProtoDescriptor(ctx context.Context, prefix *string) ([]*ProtoDescriptor, error)
+	CreateProtoDescriptor(ctx context.Context, name string, descriptorSet []byte) error
+	DeleteProtoDescriptor(ctx context.Context, name string) error
 }
 
 type EventInfo struct {
-	ID         uint64
-	MessageID  string
-	MessageKey string
-	TraceID    string
+	ID          uint64
+	MessageID   string
+	MessageKey  string
+	TraceID     string
+	Quarantined bool
+	Results     []*BusResult
+}
+
+// BusResult is the message published to a bus of the schema.
+type BusResult struct {
+	BusName   string
+	MessageID string
 }
 
 type Schema struct {
-	Source  string
-	Type    string
-	BusName string
-	Spec    string
-	Time    *timestamppb.Timestamp
+	Source        string
+	Type          string
+	BusName       string
+	Spec          string
+	Version       uint32
+	Compatibility v1.SchemaCompatibility
+	ExtraBusNames []string
+	Format        v1.SchemaFormat
+	DescriptorSet []byte
+	Time          *timestamppb.Timestamp
 
 	validator *gojsonschema.Schema
+	decoder   rule.BinaryDecoder
 }
 
+// BusNames returns the buses the events are published to, bus_name is excluded after its bus is deleted.
+func (s *Schema) BusNames() []string {
+	names := make([]string, 0, len(s.ExtraBusNames)+1)
+	if s.BusName != "" {
+		names = append(names, s.BusName)
+	}
+	return append(names, s.ExtraBusNames...)
+}
+
+// ParseSpec builds the validator of the JSON schema, or the decoder of the binary formats.
 func (s *Schema) ParseSpec() error {
-	validator, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(s.Spec))
-	if err != nil {
-		return err
+	var err error
+	switch s.Format {
+	case v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF:
+		var ps *ProtobufSpec
+		ps, err = ParseProtobufSpec([]byte(s.Spec))
+		if err != nil {
+			return err
+		}
+		s.decoder, err = rule.NewProtobufDecoder(s.DescriptorSet, ps.Message)
+	case v1.SchemaFormat_SCHEMA_FORMAT_AVRO:
+		s.decoder, err = rule.NewAvroDecoder(s.Spec)
+	default:
+		s.validator, err = gojsonschema.NewSchema(gojsonschema.NewStringLoader(s.Spec))
 	}
-	s.validator = validator
-	return nil
+	return err
 }
 
 func (s *Schema) GetValidator() *gojsonschema.Schema {
 	return s.validator
 }
 
+// ValidateEventData validates the event data by the parsed spec,
+// and the binary event data is decoded for the patterns and the transforms.
+func (s *Schema) ValidateEventData(e *rule.EventExt) error {
+	if s.decoder != nil {
+		return e.DecodeEventData(s.decoder)
+	}
+	return e.ValidateEventData(s.validator)
+}
+
+// SchemaVersion is the schema after an update, the versions of a schema increase from 1.
+type SchemaVersion struct {
+	Version       uint32
+	BusName       string
+	Spec          string
+	Compatibility v1.SchemaCompatibility
+	ExtraBusNames []string
+	Format        v1.SchemaFormat
+	DescriptorSet []byte
+	Time          *timestamppb.Timestamp
+}
+
+// DiscoveredSchema is the schema inferred from the quarantined events of an unknown source and type.
+type DiscoveredSchema struct {
+	Source    string
+	Type      string
+	BusName   string
+	Spec      string
+	Samples   uint32
+	FirstTime *timestamppb.Timestamp
+	LastTime  *timestamppb.Timestamp
+}
+
+// ProtoDescriptor is a registered protobuf FileDescriptorSet, Messages are the full names of its messages.
+type ProtoDescriptor struct {
+	Name          string
+	DescriptorSet []byte
+	Messages      []string
+	Time          *timestamppb.Timestamp
+}
+
 type EventUseCase struct {
 	repo EventRepo
 
@@ -68,10 +167,14 @@ func NewEventUseCase(repo EventRepo, logger log.Logger) *EventUseCase {
 	}
 }
 
+// PostEvent the event is validated by the spec of the schema version if it is set, otherwise the latest one.
+// The event of an unknown source and type is quarantined if the discovery of the bus is enabled.
+// The event is published to every bus of the schema, or the buses of busNames if it is not empty.
 func (uc *EventUseCase) PostEvent(
-	ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp,
+	ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp, schemaVersion *uint32,
+	busName *string, busNames []string,
 ) (*EventInfo, error) {
-	return uc.repo.PostEvent(ctx, eventExt, pubTime)
+	return uc.repo.PostEvent(ctx, eventExt, pubTime, schemaVersion, busName, busNames)
 }
 
 func (uc *EventUseCase) ListSchema(
@@ -80,30 +183,135 @@ func (uc *EventUseCase) ListSchema(
 	return uc.repo.ListSchema(ctx, source, sType, busName, time)
 }
 
+// CreateSchema the PROTOBUF spec is checked in the repo after its descriptor is resolved.
 func (uc *EventUseCase) CreateSchema(
 	ctx context.Context, source string, sType string, busName string, spec []byte,
+	compatibility v1.SchemaCompatibility, extraBusNames []string, format v1.SchemaFormat,
 ) error {
+	if format != v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF {
+		err := SchemaSpecCheck(format, compatibility, spec, nil)
+		if err != nil {
+			return v1.ErrorSchemaSyntaxError(
+				"syntax error: %s", err,
+			)
+		}
+	}
+	return uc.repo.CreateSchema(ctx, source, sType, busName, spec, compatibility, extraBusNames, format)
+}
+
+// UpdateSchema returns the new version, the spec is checked by its format and the compatibility in the repo
+// since they may be the current ones. The spec is required to change the format.
+func (uc *EventUseCase) UpdateSchema(
+	ctx context.Context, source string, sType string, busName *string, spec []byte,
+	compatibility *v1.SchemaCompatibility, extraBusNames []string, format *v1.SchemaFormat,
+) (uint32, error) {
+	if format != nil && spec == nil {
+		return 0, v1.ErrorSchemaSyntaxError("the spec is required to change the format")
+	}
+	return uc.repo.UpdateSchema(ctx, source, sType, busName, spec, compatibility, extraBusNames, format)
+}
+
+func (uc *EventUseCase) DeleteSchema(ctx context.Context, source string, sType *string) error {
+	return uc.repo.DeleteSchema(ctx, source, sType)
+}
+
+func (uc *EventUseCase) ListSchemaVersions(
+	ctx context.Context, source string, sType string, limit int32, nextToken uint32,
+) ([]*SchemaVersion, uint32, error) {
+	return uc.repo.ListSchemaVersions(ctx, source, sType, limit, nextToken)
+}
+
+func (uc *EventUseCase) GetSchemaVersion(
+	ctx context.Context, source string, sType string, version uint32,
+) (*SchemaVersion, error) {
+	return uc.repo.GetSchemaVersion(ctx, source, sType, version)
+}
+
+func (uc *EventUseCase) ListDiscoveredSchemas(
+	ctx context.Context, busName *string, limit int32, nextToken uint64,
+) ([]*DiscoveredSchema, uint64, error) {
+	return uc.repo.ListDiscoveredSchemas(ctx, busName, limit, nextToken)
+}
+
+// PromoteDiscoveredSchema returns the numbers of the quarantined events posted and dropped.
+func (uc *EventUseCase) PromoteDiscoveredSchema(
+	ctx context.Context, source string, sType string, spec []byte, compatibility v1.SchemaCompatibility,
+) (uint32, uint32, error) {
 	err := EventSchemaSyntaxCheck(spec)
 	if err != nil {
-		return v1.ErrorSchemaSyntaxError(
+		return 0, 0, v1.ErrorSchemaSyntaxError(
 			"syntax error: %s", err,
 		)
 	}
-	return uc.repo.CreateSchema(ctx, source, sType, busName, spec)
+	return uc.repo.PromoteDiscoveredSchema(ctx, source, sType, spec, compatibility)
 }
 
-func (uc *EventUseCase) UpdateSchema(
-	ctx context.Context, source string, sType string, busName *string, spec []byte,
-) error {
-	err := EventSchemaSyntaxCheck(spec)
+// GenerateSchemaCode generates the code of the JSON schemas selected like ListSchema in the language,
+// the binary schemas are skipped. The Go code is in the package.
+func (uc *EventUseCase) GenerateSchemaCode(
+	ctx context.Context, language v1.SchemaCodeLanguage, source *string, sType *string, busName *string, pkg string,
+) (string, error) {
+	ss, err := uc.repo.ListSchema(ctx, source, sType, busName, nil)
 	if err != nil {
-		return v1.ErrorSchemaSyntaxError(
+		return "", err
+	}
+	schemas := make([]*rule.CodeSchema, 0, len(ss))
+	for _, s := range ss {
+		if s.Format != v1.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA {
+			continue
+		}
+		schemas = append(schemas, &rule.CodeSchema{
+			Source:  s.Source,
+			Type:    s.Type,
+			Version: s.Version,
+			Spec:    s.Spec,
+		})
+	}
+	if len(schemas) == 0 {
+		return "", v1.ErrorSchemaNotFound("no JSON schema found")
+	}
+
+	var code string
+	switch language {
+	case v1.SchemaCodeLanguage_SCHEMA_CODE_LANGUAGE_OPENAPI:
+		title := "EventBridge events"
+		if busName != nil {
+			title = *busName + " events"
+		}
+		code, err = rule.GenerateOpenAPI(title, schemas)
+	default:
+		code, err = rule.GenerateGoCode(pkg, schemas)
+	}
+	if err != nil {
+		return "", v1.ErrorSchemaSyntaxError("generate code error: %s", err)
+	}
+	return code, nil
+}
+
+func (uc *EventUseCase) ListProtoDescriptor(ctx context.Context, prefix *string) ([]*ProtoDescriptor, error) {
+	pds, err := uc.repo.ListProtoDescriptor(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, pd := range pds {
+		pd.Messages, err = ProtoDescriptorSyntaxCheck(pd.DescriptorSet)
+		if err != nil {
+			uc.log.WithContext(ctx).Errorf("parse protobuf descriptor(%s) err: %s", pd.Name, err)
+		}
+	}
+	return pds, nil
+}
+
+func (uc *EventUseCase) CreateProtoDescriptor(ctx context.Context, name string, descriptorSet []byte) error {
+	_, err := ProtoDescriptorSyntaxCheck(descriptorSet)
+	if err != nil {
+		return v1.ErrorDescriptorSyntaxError(
 			"syntax error: %s", err,
 		)
 	}
-	return uc.repo.UpdateSchema(ctx, source, sType, busName, spec)
+	return uc.repo.CreateProtoDescriptor(ctx, name, descriptorSet)
 }
 
-func (uc *EventUseCase) DeleteSchema(ctx context.Context, source string, sType *string) error {
-	return uc.repo.DeleteSchema(ctx, source, sType)
+func (uc *EventUseCase) DeleteProtoDescriptor(ctx context.Context, name string) error {
+	return uc.repo.DeleteProtoDescriptor(ctx, name)
 }
@@ -0,0 +1,208 @@
+package biz
+
+import (
+	"context"
+
+	"github.com/go-kratos/kratos/v2/log"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+// actions and kinds of the changes applied by a manifest
+const (
+	ChangeActionCreate = "CREATE"
+	ChangeActionUpdate = "UPDATE"
+	ChangeActionDelete = "DELETE"
+
+	ChangeKindBus    = "BUS"
+	ChangeKindSchema = "SCHEMA"
+	ChangeKindRule   = "RULE"
+)
+
+// Manifest is the declared state of the buses, with the schemas and the rules of them.
+type Manifest struct {
+	Buses []*ManifestBus
+}
+
+// ManifestBus the BusName of the schemas and the rules is the name of the bus.
+type ManifestBus struct {
+	Bus     *Bus
+	Schemas []*Schema
+	Rules   []*rule.Rule
+}
+
+// Change is a change applied by a manifest, Name is the name of the bus or the rule, or source/type of the schema.
+type Change struct {
+	Action  string
+	Kind    string
+	BusName string
+	Name    string
+}
+
+type ManifestRepo interface {
+	// ExportManifest exports the bus, or all buses if bus is nil.
+	ExportManifest(ctx context.Context, bus *string) (*Manifest, error)
+	// ApplyManifest reconciles the bus, or all buses if bus is nil, with the manifest in a transaction.
+	// The changes are rolled back after they are planned if dryRun.
+	ApplyManifest(ctx context.Context, bus *string, manifest *Manifest, dryRun bool) ([]*Change, error)
+}
+
+type ManifestUseCase struct {
+	repo ManifestRepo
+	rc   *RuleUseCase
+
+	log *log.Helper
+}
+
+func NewManifestUseCase(repo ManifestRepo, rc *RuleUseCase, logger log.Logger) *ManifestUseCase {
+	return &ManifestUseCase{
+		repo: repo,
+		rc:   rc,
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "usecase/manifest",
+			"caller", log.DefaultCaller,
+		)),
+	}
+}
+
+func (uc *ManifestUseCase) Export(ctx context.Context, bus *string) (*Manifest, error) {
+	return uc.repo.ExportManifest(ctx, bus)
+}
+
+// Apply checks the whole manifest before anything is applied,
+// the targets are checked against the schemas in the manifest rather than the stored ones.
+func (uc *ManifestUseCase) Apply(
+	ctx context.Context, bus *string, manifest *Manifest, dryRun bool,
+) ([]*Change, error) {
+	busSchemas, err := uc.checkManifest(bus, manifest)
+	if err != nil {
+		return nil, err
+	}
+	listBusSchema := func(ctx context.Context, busName string) ([]*Schema, error) {
+		if schemas, ok := busSchemas[busName]; ok {
+			return schemas, nil
+		}
+		if bus == nil {
+			// the bus is deleted by the manifest
+			return nil, nil
+		}
+		return uc.rc.repo.ListBusSchema(ctx, busName)
+	}
+	for _, mb := range manifest.Buses {
+		for _, r := range mb.Rules {
+			err = RulePatternSyntaxCheck(ctx, []byte(r.Pattern))
+			if err != nil {
+				return nil, v1.ErrorPatternSyntaxError(
+					"rule(%s:%s) syntax error: %s", mb.Bus.Name, r.Name, err,
+				)
+			}
+			err = uc.checkTargets(ctx, mb.Bus.Name, r, listBusSchema)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return uc.repo.ApplyManifest(ctx, bus, manifest, dryRun)
+}
+
+// checkTargets the signing of a target without secrets keeps the stored secrets, so it is not checked.
+// Neither is the key of a HASH redaction without the key.
+func (uc *ManifestUseCase) checkTargets(
+	ctx context.Context, bus string, r *rule.Rule, listBusSchema listBusSchemaFunc,
+) error {
+	kept := make(map[*rule.Target]*rule.Signing)
+	var keptKeys []*rule.Redaction
+	for _, t := range r.Targets {
+		if t.Signing != nil && len(t.Signing.Secrets) == 0 {
+			kept[t] = t.Signing
+			t.Signing = nil
+		}
+		for _, rd := range t.Redactions {
+			if rd.Action == "HASH" && rd.Key == "" {
+				// a placeholder of the stored key
+				rd.Key = "stored"
+				keptKeys = append(keptKeys, rd)
+			}
+		}
+	}
+	defer func() {
+		for t, signing := range kept {
+			t.Signing = signing
+		}
+		for _, rd := range keptKeys {
+			rd.Key = ""
+			rd.HMACKey = ""
+		}
+	}()
+	return uc.rc.checkTargetsWithSchemas(ctx, bus, []byte(r.Pattern), r.Targets, listBusSchema)
+}
+
+// checkManifest checks the names in the manifest are unique and the schemas are valid,
+// and returns the schemas of each bus, including the schemas published to it as an extra bus.
+func (uc *ManifestUseCase) checkManifest(bus *string, manifest *Manifest) (map[string][]*Schema, error) {
+	busSchemas := make(map[string][]*Schema, len(manifest.Buses))
+	declared := make(map[string]struct{}, len(manifest.Buses))
+	schemaKeys := make(map[string]struct{})
+	for _, mb := range manifest.Buses {
+		if bus != nil && mb.Bus.Name != *bus {
+			return nil, v1.ErrorManifestSyntaxError(
+				"bus %s is not the applied bus %s", mb.Bus.Name, *bus,
+			)
+		}
+		if _, ok := declared[mb.Bus.Name]; ok {
+			return nil, v1.ErrorManifestSyntaxError("bus name repeat. name: %s", mb.Bus.Name)
+		}
+		declared[mb.Bus.Name] = struct{}{}
+		for _, s := range mb.Schemas {
+			key := s.Source + "/" + s.Type
+			if _, ok := schemaKeys[key]; ok {
+				return nil, v1.ErrorManifestSyntaxError(
+					"under each source, ensure that the type is unique.source: %s, type: %s",
+					s.Source, s.Type,
+				)
+			}
+			schemaKeys[key] = struct{}{}
+			if s.Format == v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF {
+				// checked in the repo after its descriptor is resolved
+				continue
+			}
+			err := SchemaSpecCheck(s.Format, s.Compatibility, []byte(s.Spec), nil)
+			if err != nil {
+				return nil, v1.ErrorSchemaSyntaxError(
+					"schema(%s) syntax error: %s", key, err,
+				)
+			}
+		}
+		ruleNames := make(map[string]struct{}, len(mb.Rules))
+		for _, r := range mb.Rules {
+			if _, ok := ruleNames[r.Name]; ok {
+				return nil, v1.ErrorManifestSyntaxError(
+					"rule name repeat. bus name: %s, rule name: %s",
+					mb.Bus.Name, r.Name,
+				)
+			}
+			ruleNames[r.Name] = struct{}{}
+		}
+		busSchemas[mb.Bus.Name] = append(busSchemas[mb.Bus.Name], mb.Schemas...)
+	}
+
+	// a schema is also a schema of its extra buses
+	for _, mb := range manifest.Buses {
+		for _, s := range mb.Schemas {
+			for _, name := range s.ExtraBusNames {
+				if _, ok := declared[name]; ok {
+					busSchemas[name] = append(busSchemas[name], s)
+					continue
+				}
+				if bus == nil {
+					return nil, v1.ErrorManifestSyntaxError(
+						"extra bus %s of schema %s/%s is not in the manifest", name, s.Source, s.Type,
+					)
+				}
+			}
+		}
+	}
+	return busSchemas, nil
+}
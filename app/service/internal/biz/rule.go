@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	"github.com/tianping526/eventbridge/app/internal/rule"
@@ -14,6 +15,14 @@ type DispatcherSchema struct {
 	ParamsSchema string
 }
 
+// RuleRevision is the rule after an operation.
+type RuleRevision struct {
+	Revision  uint64
+	Operation string
+	Rule      *rule.Rule
+	Time      *timestamppb.Timestamp
+}
+
 type RuleRepo interface {
 	ListRule(
 		ctx context.Context, bus string, prefix *string, status v1.RuleStatus, limit int32, nextToken uint64,
@@ -24,8 +33,21 @@ type RuleRepo interface {
 	UpdateRule(ctx context.Context, bus string, name string, status v1.RuleStatus, pattern []byte) error
 	DeleteRule(ctx context.Context, bus string, name string) error
 	CreateTargets(ctx context.Context, bus string, ruleName string, targets []*rule.Target) error
+	UpdateTargets(ctx context.Context, bus string, ruleName string, targets []*rule.Target) error
 	DeleteTargets(ctx context.Context, bus string, ruleName string, targetIDs []uint64) error
+	ListRuleRevisions(
+		ctx context.Context, bus string, ruleName string, limit int32, nextToken uint64,
+	) ([]*RuleRevision, uint64, error)
+	GetRuleRevision(ctx context.Context, bus string, ruleName string, revision uint64) (*RuleRevision, error)
+	// RollbackRule restores the rule to the revision and returns the new revision.
+	RollbackRule(ctx context.Context, bus string, ruleName string, revision uint64) (uint64, error)
 	ListDispatcherSchema(ctx context.Context, types []string) ([]*DispatcherSchema, error)
+	GetProtoDescriptorSet(ctx context.Context, name string) ([]byte, error)
+	GetRulePattern(ctx context.Context, bus string, name string) ([]byte, error)
+	ListBusSchema(ctx context.Context, bus string) ([]*Schema, error)
+	// GetConnection returns the connection without the secret,
+	// except the client certificate of TLS_CLIENT_CERT which the HTTP client is built with.
+	GetConnection(ctx context.Context, name string) (*rule.Connection, error)
 }
 
 type RuleUseCase struct {
@@ -60,13 +82,9 @@ func (uc *RuleUseCase) CreateRule(
 			"syntax error: %s", err,
 		)
 	}
-	for _, t := range targets {
-		errCheck := RuleTargetSyntaxCheck(ctx, t)
-		if errCheck != nil {
-			return 0, v1.ErrorTargetParamSyntaxError(
-				"parameter syntax error: %s", errCheck,
-			)
-		}
+	err = uc.checkTargets(ctx, bus, pattern, targets)
+	if err != nil {
+		return 0, err
 	}
 	return uc.repo.CreateRule(ctx, bus, name, status, pattern, targets)
 }
@@ -90,21 +108,180 @@ func (uc *RuleUseCase) DeleteRule(ctx context.Context, bus string, name string)
 }
 
 func (uc *RuleUseCase) CreateTargets(ctx context.Context, bus string, ruleName string, targets []*rule.Target) error {
+	pattern, err := uc.repo.GetRulePattern(ctx, bus, ruleName)
+	if err != nil {
+		return err
+	}
+	err = uc.checkTargets(ctx, bus, pattern, targets)
+	if err != nil {
+		return err
+	}
+	return uc.repo.CreateTargets(ctx, bus, ruleName, targets)
+}
+
+func (uc *RuleUseCase) UpdateTargets(ctx context.Context, bus string, ruleName string, targets []*rule.Target) error {
+	pattern, err := uc.repo.GetRulePattern(ctx, bus, ruleName)
+	if err != nil {
+		return err
+	}
+	err = uc.checkTargets(ctx, bus, pattern, targets)
+	if err != nil {
+		return err
+	}
+	return uc.repo.UpdateTargets(ctx, bus, ruleName, targets)
+}
+
+func (uc *RuleUseCase) DeleteTargets(ctx context.Context, bus string, ruleName string, targetIDs []uint64) error {
+	return uc.repo.DeleteTargets(ctx, bus, ruleName, targetIDs)
+}
+
+func (uc *RuleUseCase) ListRuleRevisions(
+	ctx context.Context, bus string, ruleName string, limit int32, nextToken uint64,
+) ([]*RuleRevision, uint64, error) {
+	return uc.repo.ListRuleRevisions(ctx, bus, ruleName, limit, nextToken)
+}
+
+// RollbackRule checks the connections and the descriptors used by the revision still exist,
+// then restores the rule to the revision.
+func (uc *RuleUseCase) RollbackRule(ctx context.Context, bus string, ruleName string, revision uint64) (uint64, error) {
+	rev, err := uc.repo.GetRuleRevision(ctx, bus, ruleName, revision)
+	if err != nil {
+		return 0, err
+	}
+	err = uc.resolveConnections(ctx, rev.Rule.Targets)
+	if err != nil {
+		return 0, err
+	}
+	err = uc.resolveProtoDescriptors(ctx, rev.Rule.Targets)
+	if err != nil {
+		return 0, err
+	}
+	return uc.repo.RollbackRule(ctx, bus, ruleName, revision)
+}
+
+func (uc *RuleUseCase) ListDispatcherSchema(ctx context.Context, types []string) ([]*DispatcherSchema, error) {
+	return uc.repo.ListDispatcherSchema(ctx, types)
+}
+
+// listBusSchemaFunc lists the schemas of the bus, by which the targets are checked.
+type listBusSchemaFunc func(ctx context.Context, bus string) ([]*Schema, error)
+
+// checkTargets checks the syntax of the targets,
+// and checks the transformed sample events of the bus schemas against the target params schema.
+func (uc *RuleUseCase) checkTargets(ctx context.Context, bus string, pattern []byte, targets []*rule.Target) error {
+	return uc.checkTargetsWithSchemas(ctx, bus, pattern, targets, uc.repo.ListBusSchema)
+}
+
+// checkTargetsWithSchemas is checkTargets with the schemas listed by listBusSchema rather than the stored ones.
+func (uc *RuleUseCase) checkTargetsWithSchemas(
+	ctx context.Context, bus string, pattern []byte, targets []*rule.Target, listBusSchema listBusSchemaFunc,
+) error {
+	err := uc.resolveProtoDescriptors(ctx, targets)
+	if err != nil {
+		return err
+	}
+	err = uc.resolveConnections(ctx, targets)
+	if err != nil {
+		return err
+	}
 	for _, t := range targets {
+		if t.Pattern != "" {
+			errCheck := RulePatternSyntaxCheck(ctx, []byte(t.Pattern))
+			if errCheck != nil {
+				return v1.ErrorPatternSyntaxError(
+					"target(id: %d) pattern syntax error: %s", t.ID, errCheck,
+				)
+			}
+		}
+		if t.Signing != nil {
+			errCheck := RuleTargetSigningCheck(t.Signing)
+			if errCheck != nil {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) signing error: %s", t.ID, errCheck,
+				)
+			}
+			t.Signing.Keys = t.Signing.Secrets
+		}
+		for _, r := range t.Redactions {
+			r.HMACKey = r.Key
+		}
 		errCheck := RuleTargetSyntaxCheck(ctx, t)
 		if errCheck != nil {
 			return v1.ErrorTargetParamSyntaxError(
 				"parameter syntax error: %s", errCheck,
 			)
 		}
+		if t.OnSuccess != nil {
+			errCheck = uc.checkOnSuccess(ctx, t.ID, t.OnSuccess, listBusSchema)
+			if errCheck != nil {
+				return errCheck
+			}
+		}
 	}
-	return uc.repo.CreateTargets(ctx, bus, ruleName, targets)
+	schemas, err := listBusSchema(ctx, bus)
+	if err != nil {
+		return err
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+	for _, t := range targets {
+		errCheck := RuleTargetOutputCheck(ctx, bus, pattern, t, schemas)
+		if errCheck != nil {
+			return v1.ErrorTargetParamSyntaxError(
+				"target(id: %d) output error: %s", t.ID, errCheck,
+			)
+		}
+	}
+	return nil
 }
 
-func (uc *RuleUseCase) DeleteTargets(ctx context.Context, bus string, ruleName string, targetIDs []uint64) error {
-	return uc.repo.DeleteTargets(ctx, bus, ruleName, targetIDs)
+// checkOnSuccess the response events are published as the events of a schema of the bus,
+// like the events posted into the bus.
+func (uc *RuleUseCase) checkOnSuccess(
+	ctx context.Context, id uint64, onSuccess *rule.OnSuccess, listBusSchema listBusSchemaFunc,
+) error {
+	schemas, err := listBusSchema(ctx, onSuccess.BusName)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemas {
+		if s.Source == onSuccess.Source && s.Type == onSuccess.Type {
+			return nil
+		}
+	}
+	return v1.ErrorTargetParamSyntaxError(
+		"target(id: %d) on success source(%s) + type(%s) not found in bus(%s)",
+		id, onSuccess.Source, onSuccess.Type, onSuccess.BusName,
+	)
 }
 
-func (uc *RuleUseCase) ListDispatcherSchema(ctx context.Context, types []string) ([]*DispatcherSchema, error) {
-	return uc.repo.ListDispatcherSchema(ctx, types)
+// resolveProtoDescriptors loads the descriptor sets used by the target encodings for the syntax check.
+func (uc *RuleUseCase) resolveProtoDescriptors(ctx context.Context, targets []*rule.Target) error {
+	for _, t := range targets {
+		if t.Encoding == nil || t.Encoding.Descriptor == "" {
+			continue
+		}
+		descriptorSet, err := uc.repo.GetProtoDescriptorSet(ctx, t.Encoding.Descriptor)
+		if err != nil {
+			return err
+		}
+		t.Encoding.DescriptorSet = descriptorSet
+	}
+	return nil
+}
+
+// resolveConnections loads the connections referenced by the targets for the syntax check.
+func (uc *RuleUseCase) resolveConnections(ctx context.Context, targets []*rule.Target) error {
+	for _, t := range targets {
+		if t.Connection == "" {
+			continue
+		}
+		conn, err := uc.repo.GetConnection(ctx, t.Connection)
+		if err != nil {
+			return err
+		}
+		t.Auth = conn
+	}
+	return nil
 }
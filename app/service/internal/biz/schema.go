@@ -1,7 +1,19 @@
 package biz
 
 import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"slices"
+
 	"github.com/xeipuuv/gojsonschema"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
 )
 
 func EventSchemaSyntaxCheck(spec []byte) error {
@@ -15,3 +27,97 @@ func EventSchemaSyntaxCheck(spec []byte) error {
 	}
 	return nil
 }
+
+// ProtobufSpec is the spec of a PROTOBUF schema, Descriptor is the name of the registered protobuf descriptor
+// and Message is the full name of the message in it.
+type ProtobufSpec struct {
+	Descriptor string `json:"descriptor"`
+	Message    string `json:"message"`
+}
+
+func ParseProtobufSpec(spec []byte) (*ProtobufSpec, error) {
+	ps := &ProtobufSpec{}
+	err := json.Unmarshal(spec, ps, json.RejectUnknownMembers(true))
+	if err != nil {
+		return nil, err
+	}
+	if ps.Descriptor == "" || ps.Message == "" {
+		return nil, errors.New("descriptor and message of the protobuf spec are required")
+	}
+	return ps, nil
+}
+
+// SchemaSpecCheck checks the spec by its format, descriptorSet is the registered descriptor of a PROTOBUF spec.
+// Only the JSON schemas are checked by the compatibility, so the compatibility of the others must be NONE.
+func SchemaSpecCheck(
+	format v1.SchemaFormat, compatibility v1.SchemaCompatibility, spec []byte, descriptorSet []byte,
+) error {
+	if format != v1.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA &&
+		compatibility != v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE {
+		return fmt.Errorf("the compatibility of a %s schema must be NONE", format)
+	}
+	switch format {
+	case v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF:
+		ps, err := ParseProtobufSpec(spec)
+		if err != nil {
+			return err
+		}
+		_, err = rule.NewProtobufDecoder(descriptorSet, ps.Message)
+		return err
+	case v1.SchemaFormat_SCHEMA_FORMAT_AVRO:
+		_, err := rule.NewAvroDecoder(string(spec))
+		return err
+	default:
+		return EventSchemaSyntaxCheck(spec)
+	}
+}
+
+// ExtraBusNames deduplicates and sorts the extra bus names of a schema, and removes its bus from them.
+// The names are kept nil if they are nil, which means they are not updated.
+func ExtraBusNames(busName string, names []string) []string {
+	if names == nil {
+		return nil
+	}
+	extra := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != busName {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	return slices.Compact(extra)
+}
+
+// ProtoDescriptorSyntaxCheck parses the serialized FileDescriptorSet and returns the full names of its messages.
+func ProtoDescriptorSyntaxCheck(descriptorSet []byte) ([]string, error) {
+	if len(descriptorSet) == 0 {
+		return nil, errors.New("descriptor set is empty")
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	err := proto.Unmarshal(descriptorSet, fds)
+	if err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]string, 0)
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		messages = appendMessages(messages, fd.Messages())
+		return true
+	})
+	return messages, nil
+}
+
+func appendMessages(messages []string, mds protoreflect.MessageDescriptors) []string {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		messages = append(messages, string(md.FullName()))
+		messages = appendMessages(messages, md.Messages())
+	}
+	return messages
+}
@@ -2,10 +2,17 @@ package biz
 
 import (
 	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"slices"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/rule/pattern"
 	"github.com/tianping526/eventbridge/app/internal/rule/target"
 	"github.com/tianping526/eventbridge/app/internal/rule/transform"
 )
@@ -22,3 +29,127 @@ func RuleTargetSyntaxCheck(ctx context.Context, t *rule.Target) error {
 	}
 	return nil
 }
+
+// RuleTargetSigningCheck at most two secrets are active to rotate them.
+func RuleTargetSigningCheck(signing *rule.Signing) error {
+	if len(signing.Secrets) == 0 || len(signing.Secrets) > 2 {
+		return fmt.Errorf("signing requires 1 or 2 secrets, got %d", len(signing.Secrets))
+	}
+	for _, s := range signing.Secrets {
+		if s == "" {
+			return errors.New("signing secret should not be empty")
+		}
+	}
+	return nil
+}
+
+// RuleTargetOutputCheck transforms a sample event of each schema that the rule and the target pattern may match,
+// and validates the output by the params schema of the target type.
+// The schemas whose sample event is not valid are skipped, so are the binary schemas without a sample event,
+// and the targets with ENRICH params because the endpoint should not be called here.
+func RuleTargetOutputCheck(
+	ctx context.Context, bus string, rulePattern []byte, t *rule.Target, schemas []*Schema,
+) error {
+	if slices.ContainsFunc(t.Params, func(p *rule.TargetParam) bool { return p.Form == "ENRICH" }) {
+		return nil
+	}
+	logger := log.DefaultLogger
+	matcher, err := newSchemaMatcher(ctx, rulePattern)
+	if err != nil {
+		return err
+	}
+	var targetMatcher rule.Matcher
+	if t.Pattern != "" {
+		targetMatcher, err = newSchemaMatcher(ctx, []byte(t.Pattern))
+		if err != nil {
+			return err
+		}
+	}
+	tfr, err := transform.NewTransformer(ctx, logger, t)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemas {
+		if s.Format == v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF || s.Format == v1.SchemaFormat_SCHEMA_FORMAT_AVRO {
+			continue
+		}
+		data, errSample := rule.NewSampleData(s.Spec)
+		if errSample != nil {
+			continue
+		}
+		evt := &rule.EventExt{
+			EventExt: &v1.EventExt{
+				Event: &v1.Event{
+					Id:              1,
+					Source:          s.Source,
+					Type:            s.Type,
+					Time:            timestamppb.Now(),
+					Data:            data,
+					Datacontenttype: rule.ContentTypeJSON,
+				},
+				BusName: bus,
+			},
+		}
+		if !schemaMatched(ctx, evt, matcher, targetMatcher) {
+			continue
+		}
+		if s.GetValidator() == nil && s.ParseSpec() != nil {
+			continue
+		}
+		if evt.ValidateEventData(s.GetValidator()) != nil {
+			continue
+		}
+		res, errTransform := tfr.Transform(ctx, evt)
+		if errTransform != nil {
+			if rule.IsRetryableError(errTransform) {
+				continue
+			}
+			return fmt.Errorf(
+				"transform sample event(source: %s, type: %s) err: %s", s.Source, s.Type, errTransform,
+			)
+		}
+		errValidate := target.ValidateDispatcherParams(t.Type, res.Event.Data)
+		if errValidate != nil {
+			return fmt.Errorf(
+				"transformed sample event(source: %s, type: %s) err: %s", s.Source, s.Type, errValidate,
+			)
+		}
+	}
+	return nil
+}
+
+// schemaMatched reports whether the sample event of a schema is matched by all the matchers,
+// the target receives it only if both the rule pattern and the target pattern match.
+func schemaMatched(ctx context.Context, evt *rule.EventExt, matchers ...rule.Matcher) bool {
+	for _, m := range matchers {
+		if m == nil {
+			continue
+		}
+		matched, err := m.Pattern(ctx, evt)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// newSchemaMatcher returns the matcher of the source and type in the pattern,
+// since the schema only determines those two fields of the event.
+// It returns nil if the pattern doesn't restrict them.
+func newSchemaMatcher(ctx context.Context, spec []byte) (rule.Matcher, error) {
+	filterPattern := make(map[string]interface{})
+	err := json.Unmarshal(spec, &filterPattern)
+	if err != nil {
+		return nil, err
+	}
+	schemaPattern := make(map[string]interface{}, 2)
+	for _, key := range []string{"source", "type"} {
+		if val, ok := filterPattern[key]; ok {
+			schemaPattern[key] = val
+		}
+	}
+	if len(schemaPattern) == 0 {
+		return nil, nil
+	}
+	return pattern.NewMatcher(ctx, log.DefaultLogger, schemaPattern)
+}
@@ -3,7 +3,9 @@ package data
 import (
 	"context"
 	"encoding/json/v2"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/redis/go-redis/v9"
@@ -17,6 +19,69 @@ import (
 	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
 )
 
+const (
+	// topic types of the draining topics, the same as the topic types of the job
+	topicTypeSource         = "Source"
+	topicTypeSourceDelay    = "SourceDelay"
+	topicTypeTargetExpDecay = "TargetExpDecay"
+	topicTypeTargetBackoff  = "TargetBackoff"
+
+	// drainRetention is how long a draining topic is kept at least, the job stops draining it once it is empty.
+	drainRetention = 24 * time.Hour
+	// maxDrainingTopicsLen is the max length of the draining_topics column.
+	maxDrainingTopicsLen = 4096
+	// busDelayUntilKey is the sorted set of the latest publish time of the events in each source delay topic.
+	busDelayUntilKey = "eb:bus:delay:until"
+	// maxDelayHorizon is the default max delay of the delay messages of RocketMQ,
+	// the events can't be delayed longer than it.
+	maxDelayHorizon = 72 * time.Hour
+)
+
+// drainingTopic is the old topic of the bus which is drained by the job after the bus updated.
+type drainingTopic struct {
+	TopicType string         `json:"topicType"`
+	Topic     biz.MQTopic    `json:"topic"`
+	Mode      v1.BusWorkMode `json:"mode"`
+	Since     int64          `json:"since"` // unix time the topic was replaced
+	Until     int64          `json:"until"` // unix time of the latest delayed event in the topic
+}
+
+func delayUntilMember(topic biz.MQTopic) string {
+	return topic.Endpoints + "/" + topic.Topic
+}
+
+// delayDrainUntil returns the unix time the old delay topic is drained until, the latest delayed event in it.
+// It is the max delay horizon after the topic was replaced at least, since the service instances
+// which haven't seen the update may still publish the delayed events into it.
+func delayDrainUntil(latest int64, since time.Time) int64 {
+	return max(latest, since.Add(maxDelayHorizon).Unix())
+}
+
+// replaceDrainingTopic adds the old topic to the draining topics,
+// and the new topic is not drained any more if it was replaced before.
+func replaceDrainingTopic(draining []drainingTopic, old drainingTopic, topic biz.MQTopic) []drainingTopic {
+	kept := draining[:0]
+	for _, d := range draining {
+		if d.TopicType == old.TopicType && (d.Topic == old.Topic || d.Topic == topic) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return append(kept, old)
+}
+
+// removeDrainedTopics removes the topics drained long enough.
+func removeDrainedTopics(draining []drainingTopic, now time.Time) []drainingTopic {
+	kept := draining[:0]
+	for _, d := range draining {
+		if now.Unix() > max(d.Since+int64(drainRetention/time.Second), d.Until) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
 type busRepo struct {
 	log *log.Helper
 	db  *ent.Client
@@ -57,34 +122,44 @@ func (repo *busRepo) ListBus(
 	}
 	buses := make([]*biz.Bus, 0, len(bs))
 	for _, b := range bs {
-		var source, sourceDelay, targetExpDecay, targetBackoff biz.MQTopic
-		if err = json.Unmarshal([]byte(b.SourceTopic), &source); err != nil {
-			return nil, 0, fmt.Errorf("unmarshal source topic: %w", err)
-		}
-		if err = json.Unmarshal([]byte(b.SourceDelayTopic), &sourceDelay); err != nil {
-			return nil, 0, fmt.Errorf("unmarshal source delay topic: %w", err)
-		}
-		if err = json.Unmarshal([]byte(b.TargetExpDecayTopic), &targetExpDecay); err != nil {
-			return nil, 0, fmt.Errorf("unmarshal target exp decay topic: %w", err)
-		}
-		if err = json.Unmarshal([]byte(b.TargetBackoffTopic), &targetBackoff); err != nil {
-			return nil, 0, fmt.Errorf("unmarshal target backoff topic: %w", err)
+		bus, err := busFromEnt(b)
+		if err != nil {
+			return nil, 0, err
 		}
-		buses = append(buses, &biz.Bus{
-			Name:           b.Name,
-			Source:         source,
-			SourceDelay:    sourceDelay,
-			TargetExpDecay: targetExpDecay,
-			TargetBackoff:  targetBackoff,
-			Mode:           v1.BusWorkMode(b.Mode),
-		})
+		buses = append(buses, bus)
 	}
 	return buses, next, nil
 }
 
+func busFromEnt(b *ent.Bus) (*biz.Bus, error) {
+	var source, sourceDelay, targetExpDecay, targetBackoff biz.MQTopic
+	if err := json.Unmarshal([]byte(b.SourceTopic), &source); err != nil {
+		return nil, fmt.Errorf("unmarshal source topic: %w", err)
+	}
+	if err := json.Unmarshal([]byte(b.SourceDelayTopic), &sourceDelay); err != nil {
+		return nil, fmt.Errorf("unmarshal source delay topic: %w", err)
+	}
+	if err := json.Unmarshal([]byte(b.TargetExpDecayTopic), &targetExpDecay); err != nil {
+		return nil, fmt.Errorf("unmarshal target exp decay topic: %w", err)
+	}
+	if err := json.Unmarshal([]byte(b.TargetBackoffTopic), &targetBackoff); err != nil {
+		return nil, fmt.Errorf("unmarshal target backoff topic: %w", err)
+	}
+	return &biz.Bus{
+		Name:           b.Name,
+		Source:         source,
+		SourceDelay:    sourceDelay,
+		TargetExpDecay: targetExpDecay,
+		TargetBackoff:  targetBackoff,
+		Mode:           v1.BusWorkMode(b.Mode),
+		Status:         v1.BusStatus(b.Status),
+		Discovery:      b.Discovery,
+	}, nil
+}
+
 func (repo *busRepo) CreateBus(
 	ctx context.Context, bus string, mode v1.BusWorkMode, source biz.MQTopic,
-	sourceDelay biz.MQTopic, targetExpDecay biz.MQTopic, targetBackoff biz.MQTopic,
+	sourceDelay biz.MQTopic, targetExpDecay biz.MQTopic, targetBackoff biz.MQTopic, discovery bool,
 ) (uint64, error) {
 	var id uint64
 	sourceTopic, _ := json.Marshal(source)
@@ -99,6 +174,7 @@ func (repo *busRepo) CreateBus(
 			SetSourceDelayTopic(string(sourceDelayTopic)).
 			SetTargetExpDecayTopic(string(targetExpDecayTopic)).
 			SetTargetBackoffTopic(string(targetBackoffTopic)).
+			SetDiscovery(discovery).
 			Save(ctx)
 		if te != nil {
 			if ent.IsConstraintError(te) {
@@ -123,6 +199,40 @@ func (repo *busRepo) CreateBus(
 	return id, err
 }
 
+func (repo *busRepo) UpdateBus(
+	ctx context.Context, busName string, mode *v1.BusWorkMode, status *v1.BusStatus, source *biz.MQTopic,
+	sourceDelay *biz.MQTopic, targetExpDecay *biz.MQTopic, targetBackoff *biz.MQTopic, discovery *bool,
+) error {
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		// query bus and lock
+		b, te := tx.Bus.Query().
+			Where(
+				entBus.Name(busName),
+			).
+			ForUpdate().
+			Only(ctx)
+		if te != nil {
+			if ent.IsNotFound(te) {
+				return v1.ErrorDataBusNotFound(
+					"can't find the data bus. name: %s",
+					busName,
+				)
+			}
+			return te
+		}
+
+		te = updateBus(
+			ctx, tx, repo.rc, b, mode, status, source, sourceDelay, targetExpDecay, targetBackoff, discovery,
+		)
+		if te != nil {
+			return te
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.BusesVersionID).AddVersion(1).Exec(ctx)
+	})
+}
+
 func (repo *busRepo) DeleteBus(ctx context.Context, busName string) error {
 	var schemaIDs []uint64
 	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
@@ -166,12 +276,51 @@ func (repo *busRepo) DeleteBus(ctx context.Context, busName string) error {
 			return te
 		}
 
+		// save versions of the schemas
+		ss, te := tx.EventSchema.Query().Where(eventschema.IDIn(schemaIDs...)).All(ctx)
+		if te != nil {
+			return te
+		}
+		for _, s := range ss {
+			te = saveSchemaVersion(ctx, tx, s)
+			if te != nil {
+				return te
+			}
+		}
+
+		// remove the bus from the extra buses of the schemas
+		ess, te := removeExtraBus(ctx, tx, busName)
+		if te != nil {
+			return te
+		}
+		for _, s := range ess {
+			schemaIDs = append(schemaIDs, s.ID)
+		}
+
+		// save revisions of the rules, they keep the deleted rules
+		rs, te := tx.Rule.Query().Where(rule.BusName(busName)).ForUpdate().All(ctx)
+		if te != nil {
+			return te
+		}
+		for _, r := range rs {
+			_, te = saveRuleRevision(ctx, tx, r, ruleOperationDelete)
+			if te != nil {
+				return te
+			}
+		}
+
 		// delete rule
 		_, te = tx.Rule.Delete().Where(rule.BusName(busName)).Exec(ctx)
 		if te != nil {
 			return te
 		}
 
+		// delete the discovered schemas and their quarantined events
+		te = deleteDiscoveredSchemas(ctx, tx, busName)
+		if te != nil {
+			return te
+		}
+
 		// delete data bus
 		te = tx.Bus.DeleteOneID(bid).Exec(ctx)
 		if te != nil {
@@ -209,3 +358,76 @@ func (repo *busRepo) DeleteBus(ctx context.Context, busName string) error {
 
 	return nil
 }
+
+// updateBus updates the locked bus in the transaction, the old topics replaced are drained.
+func updateBus(
+	ctx context.Context, tx *ent.Tx, rc redis.Cmdable, b *ent.Bus, mode *v1.BusWorkMode, status *v1.BusStatus,
+	source *biz.MQTopic, sourceDelay *biz.MQTopic, targetExpDecay *biz.MQTopic, targetBackoff *biz.MQTopic,
+	discovery *bool,
+) error {
+	var draining []drainingTopic
+	if err := json.Unmarshal([]byte(b.DrainingTopics), &draining); err != nil {
+		return fmt.Errorf("unmarshal draining topics: %w", err)
+	}
+	now := time.Now()
+	stmt := tx.Bus.UpdateOneID(b.ID)
+	for _, t := range []struct {
+		topicType string
+		old       string
+		new       *biz.MQTopic
+		set       func(string) *ent.BusUpdateOne
+	}{
+		{topicTypeSource, b.SourceTopic, source, stmt.SetSourceTopic},
+		{topicTypeSourceDelay, b.SourceDelayTopic, sourceDelay, stmt.SetSourceDelayTopic},
+		{topicTypeTargetExpDecay, b.TargetExpDecayTopic, targetExpDecay, stmt.SetTargetExpDecayTopic},
+		{topicTypeTargetBackoff, b.TargetBackoffTopic, targetBackoff, stmt.SetTargetBackoffTopic},
+	} {
+		if t.new == nil {
+			continue
+		}
+		var old biz.MQTopic
+		if err := json.Unmarshal([]byte(t.old), &old); err != nil {
+			return fmt.Errorf("unmarshal %s topic: %w", t.topicType, err)
+		}
+		if old == *t.new {
+			continue
+		}
+		topic, _ := json.Marshal(t.new)
+		t.set(string(topic))
+
+		// the old topic is drained
+		dt := drainingTopic{
+			TopicType: t.topicType,
+			Topic:     old,
+			Mode:      v1.BusWorkMode(b.Mode),
+			Since:     now.Unix(),
+		}
+		if t.topicType == topicTypeSourceDelay {
+			until, err := rc.ZScore(ctx, busDelayUntilKey, delayUntilMember(old)).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return fmt.Errorf("query the latest delayed event of topic %s: %w", old.Topic, err)
+			}
+			dt.Until = delayDrainUntil(int64(until), now)
+		}
+		draining = replaceDrainingTopic(draining, dt, *t.new)
+	}
+
+	drainingTopics, _ := json.Marshal(removeDrainedTopics(draining, now))
+	if len(drainingTopics) > maxDrainingTopicsLen {
+		return v1.ErrorBusTopicsDraining(
+			"too many old topics of the bus are draining, try again later. name: %s",
+			b.Name,
+		)
+	}
+	stmt.SetDrainingTopics(string(drainingTopics))
+	if mode != nil {
+		stmt.SetMode(uint8(*mode))
+	}
+	if status != nil {
+		stmt.SetStatus(uint8(*status))
+	}
+	if discovery != nil {
+		stmt.SetDiscovery(*discovery)
+	}
+	return stmt.Exec(ctx)
+}
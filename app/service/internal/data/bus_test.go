@@ -0,0 +1,103 @@
+package data
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
+)
+
+func TestDelayDrainUntil(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	horizon := since.Add(maxDelayHorizon).Unix()
+	untilTests := []struct {
+		name   string
+		latest int64
+		until  int64
+	}{
+		{name: "nothing recorded", latest: 0, until: horizon},
+		{name: "delayed within the horizon", latest: since.Add(time.Hour).Unix(), until: horizon},
+		{name: "delayed beyond the horizon", latest: horizon + 60, until: horizon + 60},
+	}
+	for _, tt := range untilTests {
+		t.Run(tt.name, func(t *testing.T) {
+			until := delayDrainUntil(tt.latest, since)
+			if until != tt.until {
+				t.Fatalf("expect until: %d, got: %d", tt.until, until)
+			}
+		})
+	}
+}
+
+func TestDrainingTopics(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	topicA := biz.MQTopic{Endpoints: "127.0.0.1:8081", Topic: "A"}
+	topicB := biz.MQTopic{Endpoints: "127.0.0.1:8081", Topic: "B"}
+	topicC := biz.MQTopic{Endpoints: "127.0.0.1:8081", Topic: "C"}
+	drainingTests := []struct {
+		name     string
+		draining []drainingTopic
+		old      drainingTopic
+		topic    biz.MQTopic
+		topics   []string // TopicType/Topic of the draining topics kept
+	}{
+		{
+			name:   "the old topic is drained",
+			old:    drainingTopic{TopicType: topicTypeSourceDelay, Topic: topicA, Since: now.Unix()},
+			topic:  topicB,
+			topics: []string{"SourceDelay/A"},
+		},
+		{
+			name: "the new topic is not drained any more",
+			draining: []drainingTopic{
+				{TopicType: topicTypeSourceDelay, Topic: topicB, Since: now.Unix() - 60},
+				{TopicType: topicTypeSource, Topic: topicB, Since: now.Unix() - 60},
+			},
+			old:    drainingTopic{TopicType: topicTypeSourceDelay, Topic: topicA, Since: now.Unix()},
+			topic:  topicB,
+			topics: []string{"Source/B", "SourceDelay/A"},
+		},
+		{
+			name: "the delay topic is drained until its latest delayed event",
+			draining: []drainingTopic{
+				{
+					TopicType: topicTypeSourceDelay,
+					Topic:     topicC,
+					Since:     now.Add(-2 * drainRetention).Unix(),
+					Until:     now.Add(time.Hour).Unix(),
+				},
+				{TopicType: topicTypeSource, Topic: topicC, Since: now.Add(-2 * drainRetention).Unix()},
+			},
+			old:    drainingTopic{TopicType: topicTypeSource, Topic: topicA, Since: now.Unix()},
+			topic:  topicB,
+			topics: []string{"SourceDelay/C", "Source/A"},
+		},
+		{
+			name: "the delay topic drained long enough is removed",
+			draining: []drainingTopic{
+				{
+					TopicType: topicTypeSourceDelay,
+					Topic:     topicC,
+					Since:     now.Add(-maxDelayHorizon - time.Hour).Unix(),
+					Until:     delayDrainUntil(0, now.Add(-maxDelayHorizon-time.Hour)),
+				},
+			},
+			old:    drainingTopic{TopicType: topicTypeSource, Topic: topicA, Since: now.Unix()},
+			topic:  topicB,
+			topics: []string{"Source/A"},
+		},
+	}
+	for _, tt := range drainingTests {
+		t.Run(tt.name, func(t *testing.T) {
+			draining := removeDrainedTopics(replaceDrainingTopic(tt.draining, tt.old, tt.topic), now)
+			topics := make([]string, 0, len(draining))
+			for _, d := range draining {
+				topics = append(topics, d.TopicType+"/"+d.Topic.Topic)
+			}
+			if !slices.Equal(topics, tt.topics) {
+				t.Fatalf("expect draining topics: %v, got: %v", tt.topics, topics)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	ir "github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/secret"
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
+	"github.com/tianping526/eventbridge/app/service/internal/conf"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/connection"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
+)
+
+func NewCipher(bc *conf.Bootstrap) (*secret.Cipher, error) {
+	return secret.NewCipher(bc.GetData().GetSecretKey())
+}
+
+type connectionRepo struct {
+	log    *log.Helper
+	db     *ent.Client
+	cipher *secret.Cipher
+}
+
+func NewConnectionRepo(logger log.Logger, db *ent.Client, cipher *secret.Cipher) biz.ConnectionRepo {
+	return &connectionRepo{
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "repo/connection",
+			"caller", log.DefaultCaller,
+		)),
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+func (repo *connectionRepo) ListConnection(ctx context.Context, prefix *string) ([]*biz.Connection, error) {
+	stmt := repo.db.Connection.Query()
+	if prefix != nil {
+		stmt.Where(connection.NameHasPrefix(*prefix))
+	}
+	cs, err := stmt.Order(ent.Asc(connection.FieldName)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conns := make([]*biz.Connection, 0, len(cs))
+	for _, c := range cs {
+		conns = append(conns, &biz.Connection{
+			Connection: ir.Connection{
+				Name:     c.Name,
+				AuthType: c.AuthType,
+				Key:      c.Key,
+				TokenURL: c.TokenURL,
+				Scopes:   c.Scopes,
+			},
+			Time: timestamppb.New(c.CreateTime),
+		})
+	}
+	return conns, nil
+}
+
+func (repo *connectionRepo) CreateConnection(ctx context.Context, conn *ir.Connection) error {
+	encrypted, err := repo.encrypt(conn.Secret)
+	if err != nil {
+		return err
+	}
+	err = repo.db.Connection.Create().
+		SetName(conn.Name).
+		SetAuthType(conn.AuthType).
+		SetKey(conn.Key).
+		SetSecret(encrypted).
+		SetTokenURL(conn.TokenURL).
+		SetScopes(conn.Scopes).
+		Exec(ctx)
+	if err != nil {
+		if ent.IsConstraintError(err) {
+			return v1.ErrorConnectionNameRepeat(
+				"connection name repeat. name: %s",
+				conn.Name,
+			)
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateConnection the rules version is updated so that the job reloads the targets using the connection.
+func (repo *connectionRepo) UpdateConnection(ctx context.Context, conn *ir.Connection, plainSecret *string) error {
+	var encrypted *string
+	if plainSecret != nil {
+		val, err := repo.encrypt(*plainSecret)
+		if err != nil {
+			return err
+		}
+		encrypted = &val
+	}
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		stmt := tx.Connection.Update().
+			Where(connection.Name(conn.Name)).
+			SetAuthType(conn.AuthType).
+			SetKey(conn.Key).
+			SetTokenURL(conn.TokenURL).
+			SetScopes(conn.Scopes)
+		if encrypted != nil {
+			stmt.SetSecret(*encrypted)
+		}
+		ar, te := stmt.Save(ctx)
+		if te != nil {
+			return te
+		}
+		if ar == 0 {
+			return v1.ErrorConnectionNotFound(
+				"can't find the connection. name: %s",
+				conn.Name,
+			)
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+	})
+}
+
+// DeleteConnection fails if any target references the connection.
+func (repo *connectionRepo) DeleteConnection(ctx context.Context, name string) error {
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		rs, te := tx.Rule.Query().
+			Where(rule.TargetsContains(name)).
+			Select(rule.FieldBusName, rule.FieldName, rule.FieldTargets).
+			All(ctx)
+		if te != nil {
+			return te
+		}
+		for _, r := range rs {
+			var targets []*ir.Target
+			te = json.Unmarshal([]byte(r.Targets), &targets)
+			if te != nil {
+				return te
+			}
+			for _, t := range targets {
+				if t.Connection == name {
+					return v1.ErrorConnectionInUse(
+						"connection is used by the target(id: %d) of the rule. bus name: %s, rule name: %s",
+						t.ID, r.BusName, r.Name,
+					)
+				}
+			}
+		}
+
+		ar, te := tx.Connection.Delete().
+			Where(connection.Name(name)).
+			Exec(ctx)
+		if te != nil {
+			return te
+		}
+		if ar == 0 {
+			return v1.ErrorConnectionNotFound(
+				"can't find the connection. name: %s",
+				name,
+			)
+		}
+		return nil
+	})
+}
+
+func (repo *connectionRepo) encrypt(plaintext string) (string, error) {
+	encrypted, err := repo.cipher.Encrypt(plaintext)
+	if errors.Is(err, secret.ErrNoKey) {
+		return "", v1.ErrorConnectionSyntaxError("connections are disabled: %s", err)
+	}
+	return encrypted, err
+}
@@ -20,4 +20,7 @@ var ProviderSet = wire.NewSet(
 	NewBusRepo,
 	NewEventRepo,
 	NewRuleRepo,
+	NewManifestRepo,
+	NewCipher,
+	NewConnectionRepo,
 )
@@ -0,0 +1,453 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/patrickmn/go-cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent"
+	entBus "github.com/tianping526/eventbridge/app/service/internal/data/ent/bus"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/discoveredschema"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschema"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/predicate"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/quarantinedevent"
+	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
+)
+
+const (
+	// maxQuarantinedEvents is the max number of the quarantined events of a source and type.
+	maxQuarantinedEvents = 1000
+	// maxDiscoveredSchemas is the max number of the discovered schemas of a bus.
+	maxDiscoveredSchemas = 100
+	// promoteBatchSize is the number of the quarantined events posted in a batch when the schema is promoted.
+	promoteBatchSize = 100
+)
+
+// quarantineEvent merges the schema inferred from the event data into the discovered schema,
+// and keeps the event until the discovered schema is promoted. The discovered schema belongs to the bus
+// of its first event. The schema is returned instead if it has been promoted, so the event is posted as usual.
+func (repo *eventRepo) quarantineEvent(
+	ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp, busName string,
+) (*biz.EventInfo, *biz.Schema, error) {
+	source, sType := eventExt.Event.Source, eventExt.Event.Type
+	discovery, err := repo.db.Bus.Query().
+		Where(
+			entBus.Name(busName),
+			entBus.Discovery(true),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !discovery {
+		return nil, nil, v1.ErrorSourceTypeNotFound(
+			"source(%s) + type(%s) not found, and the discovery of bus(%s) is not enabled.",
+			source, sType, busName,
+		)
+	}
+
+	var promoted *ent.EventSchema
+	quarantine := func(tx *ent.Tx) error {
+		// query discovered schema and lock
+		ds, te := tx.DiscoveredSchema.Query().
+			Where(
+				discoveredschema.Source(source),
+				discoveredschema.Type(sType),
+			).
+			ForUpdate().
+			Only(ctx)
+		if te != nil && !ent.IsNotFound(te) {
+			return te
+		}
+		// the schema is promoted while the local cache is not updated yet
+		promoted, te = tx.EventSchema.Query().
+			Where(
+				eventschema.Source(source),
+				eventschema.Type(sType),
+			).
+			Only(ctx)
+		if te == nil || !ent.IsNotFound(te) {
+			return te
+		}
+		promoted = nil
+		current := ""
+		if ds == nil {
+			// query data bus and lock, so the discovered schemas of the bus are counted exactly
+			_, te = tx.Bus.Query().
+				Where(entBus.Name(busName)).
+				ForUpdate().
+				OnlyID(ctx)
+			if te != nil {
+				if ent.IsNotFound(te) {
+					return v1.ErrorDataBusNotFound(
+						"can't find the data bus. name: %s",
+						busName,
+					)
+				}
+				return te
+			}
+			n, te := tx.DiscoveredSchema.Query().
+				Where(discoveredschema.BusName(busName)).
+				Count(ctx)
+			if te != nil {
+				return te
+			}
+			if n >= maxDiscoveredSchemas {
+				return v1.ErrorQuarantineFull(
+					"too many schemas are discovered in bus(%s), promote them first. source: %s, type: %s",
+					busName, source, sType,
+				)
+			}
+		} else {
+			if ds.Samples >= maxQuarantinedEvents {
+				return v1.ErrorQuarantineFull(
+					"too many events are quarantined, promote the discovered schema first. source: %s, type: %s",
+					source, sType,
+				)
+			}
+			current = ds.Spec
+		}
+		spec, te := rule.MergeSchemaSample(current, eventExt.Event.Data)
+		if te != nil {
+			return v1.ErrorEventDataNotValid(
+				"event data is not valid. see err: %s", te,
+			)
+		}
+
+		// save discovered schema
+		if ds == nil {
+			te = tx.DiscoveredSchema.Create().
+				SetSource(source).
+				SetType(sType).
+				SetBusName(busName).
+				SetSpec(spec).
+				SetSamples(1).
+				Exec(ctx)
+		} else {
+			te = tx.DiscoveredSchema.UpdateOne(ds).
+				SetSpec(spec).
+				AddSamples(1).
+				Exec(ctx)
+		}
+		if te != nil {
+			return te
+		}
+
+		// save event
+		stmt := tx.QuarantinedEvent.Create().
+			SetSource(source).
+			SetType(sType).
+			SetEvent(eventExt.Value())
+		if pubTime.IsValid() {
+			stmt.SetPubTime(pubTime.AsTime())
+		}
+		return stmt.Exec(ctx)
+	}
+	err = entext.WithTx(ctx, repo.db, quarantine)
+	if ent.IsConstraintError(err) {
+		// the discovered schema is created by another event concurrently
+		err = entext.WithTx(ctx, repo.db, quarantine)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if promoted != nil {
+		schema := schemaFromEnt(promoted)
+		err = schema.ParseSpec()
+		if err != nil {
+			return nil, nil, err
+		}
+		repo.sc.Set(fmt.Sprintf("%s:%s", source, sType), schema, cache.DefaultExpiration)
+		return nil, schema, nil
+	}
+	repo.m.PostEventCount.Add(
+		ctx, 1,
+		metric.WithAttributes(
+			attribute.String(metricPostEventBusName, busName),
+			attribute.String(metricPostEventType, "quarantined_event"),
+			attribute.String(metricPostEventResult, "ok"),
+		),
+	)
+
+	return &biz.EventInfo{
+		ID:          eventExt.Event.Id,
+		MessageKey:  eventExt.Key(),
+		Quarantined: true,
+	}, nil, nil
+}
+
+func (repo *eventRepo) ListDiscoveredSchemas(
+	ctx context.Context, busName *string, limit int32, nextToken uint64,
+) ([]*biz.DiscoveredSchema, uint64, error) {
+	stmt := repo.db.DiscoveredSchema.Query()
+	if busName != nil {
+		stmt.Where(discoveredschema.BusName(*busName))
+	}
+	if nextToken > 0 {
+		stmt.Where(discoveredschema.IDGTE(nextToken))
+	}
+	convertedLimit := int(limit)
+	dss, err := stmt.Order(ent.Asc(discoveredschema.FieldID)).Limit(convertedLimit + 1).All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := uint64(0)
+	if len(dss) > convertedLimit {
+		next = dss[convertedLimit].ID
+		dss = dss[:convertedLimit]
+	}
+	schemas := make([]*biz.DiscoveredSchema, 0, len(dss))
+	for _, ds := range dss {
+		schemas = append(schemas, &biz.DiscoveredSchema{
+			Source:    ds.Source,
+			Type:      ds.Type,
+			BusName:   ds.BusName,
+			Spec:      ds.Spec,
+			Samples:   ds.Samples,
+			FirstTime: timestamppb.New(ds.CreateTime),
+			LastTime:  timestamppb.New(ds.UpdateTime),
+		})
+	}
+	return schemas, next, nil
+}
+
+// PromoteDiscoveredSchema the discovered schema is deleted after all of its quarantined events are handled,
+// so a failed promotion is resumed by promoting it again, and the schema created before is kept.
+func (repo *eventRepo) PromoteDiscoveredSchema(
+	ctx context.Context, source string, sType string, spec []byte, compatibility v1.SchemaCompatibility,
+) (uint32, uint32, error) {
+	var s *ent.EventSchema
+	var dsID uint64
+	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		// query discovered schema and lock
+		ds, te := tx.DiscoveredSchema.Query().
+			Where(
+				discoveredschema.Source(source),
+				discoveredschema.Type(sType),
+			).
+			ForUpdate().
+			Only(ctx)
+		if te != nil {
+			if ent.IsNotFound(te) {
+				return v1.ErrorDiscoveredSchemaNotFound(
+					"discovered schema not found. source: %s, type: %s",
+					source, sType,
+				)
+			}
+			return te
+		}
+		dsID = ds.ID
+
+		s, te = tx.EventSchema.Query().
+			Where(
+				eventschema.Source(source),
+				eventschema.Type(sType),
+			).
+			Only(ctx)
+		if te == nil || !ent.IsNotFound(te) {
+			return te
+		}
+
+		// query data bus and lock
+		_, te = tx.Bus.Query().
+			Where(entBus.Name(ds.BusName)).
+			ForUpdate().
+			OnlyID(ctx)
+		if te != nil {
+			if ent.IsNotFound(te) {
+				return v1.ErrorDataBusNotFound(
+					"can't find the data bus. name: %s",
+					ds.BusName,
+				)
+			}
+			return te
+		}
+
+		// save schema
+		if spec == nil {
+			spec = []byte(ds.Spec)
+		}
+		s, te = tx.EventSchema.Create().
+			SetSource(source).
+			SetType(sType).
+			SetBusName(ds.BusName).
+			SetSpec(string(spec)).
+			SetVersion(1).
+			SetCompatibility(uint8(compatibility)).
+			Save(ctx)
+		if te != nil {
+			return te
+		}
+		return saveSchemaVersion(ctx, tx, s)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// update cache
+	err = SetCacheSchema(ctx, repo.rc, source, sType, s)
+	if err != nil {
+		repo.log.WithContext(ctx).Errorf("SetCacheSchema: %v, schema: %v", err, s)
+	}
+
+	var posted, dropped uint32
+	for {
+		p, d, err := repo.postQuarantinedEvents(ctx, s)
+		posted, dropped = posted+p, dropped+d
+		if err != nil {
+			return posted, dropped, err
+		}
+		deleted, err := deleteHandledDiscoveredSchema(ctx, repo.db, dsID, source, sType)
+		if err != nil {
+			return posted, dropped, err
+		}
+		if deleted {
+			return posted, dropped, nil
+		}
+	}
+}
+
+// deleteHandledDiscoveredSchema deletes the discovered schema if it has no quarantined events,
+// the events quarantined before the schema is promoted are posted again if it has.
+func deleteHandledDiscoveredSchema(
+	ctx context.Context, db *ent.Client, dsID uint64, source string, sType string,
+) (bool, error) {
+	deleted := false
+	err := entext.WithTx(ctx, db, func(tx *ent.Tx) error {
+		// query discovered schema and lock
+		_, te := tx.DiscoveredSchema.Query().
+			Where(discoveredschema.ID(dsID)).
+			ForUpdate().
+			OnlyID(ctx)
+		if ent.IsNotFound(te) {
+			deleted = true
+			return nil
+		}
+		if te != nil {
+			return te
+		}
+		quarantined, te := tx.QuarantinedEvent.Query().
+			Where(
+				quarantinedevent.Source(source),
+				quarantinedevent.Type(sType),
+			).
+			Exist(ctx)
+		if te != nil || quarantined {
+			return te
+		}
+		deleted = true
+		return tx.DiscoveredSchema.DeleteOneID(dsID).Exec(ctx)
+	})
+	return deleted, err
+}
+
+// postQuarantinedEvents posts the quarantined events valid under the schema to its buses in the order they are
+// quarantined, and drops the others. The events handled are deleted batch by batch.
+func (repo *eventRepo) postQuarantinedEvents(ctx context.Context, s *ent.EventSchema) (uint32, uint32, error) {
+	schema := schemaFromEnt(s)
+	err := schema.ParseSpec()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(schema.BusNames()) == 0 {
+		return 0, 0, v1.ErrorDataBusRemoved(
+			"data bus has been removed. source: %s, type: %s",
+			s.Source, s.Type,
+		)
+	}
+
+	var posted, dropped uint32
+	for {
+		qes, err := repo.db.QuarantinedEvent.Query().
+			Where(
+				quarantinedevent.Source(s.Source),
+				quarantinedevent.Type(s.Type),
+			).
+			Order(ent.Asc(quarantinedevent.FieldID)).
+			Limit(promoteBatchSize).
+			All(ctx)
+		if err != nil {
+			return posted, dropped, err
+		}
+		if len(qes) == 0 {
+			return posted, dropped, nil
+		}
+
+		handled := make([]uint64, 0, len(qes))
+		for _, qe := range qes {
+			err = repo.postQuarantinedEvent(ctx, schema, qe)
+			if v1.IsEventDataNotValid(err) {
+				repo.log.WithContext(ctx).Warnf("drop quarantined event(%d): %v", qe.ID, err)
+				dropped++
+				handled = append(handled, qe.ID)
+				continue
+			}
+			if err != nil {
+				break
+			}
+			posted++
+			handled = append(handled, qe.ID)
+		}
+		_, de := repo.db.QuarantinedEvent.Delete().Where(quarantinedevent.IDIn(handled...)).Exec(ctx)
+		if err != nil {
+			return posted, dropped, err
+		}
+		if de != nil {
+			return posted, dropped, de
+		}
+	}
+}
+
+func (repo *eventRepo) postQuarantinedEvent(
+	ctx context.Context, schema *biz.Schema, qe *ent.QuarantinedEvent,
+) error {
+	eventExt, err := rule.NewEventExtFromBytes(qe.Event)
+	if err != nil {
+		return v1.ErrorEventDataNotValid("event is not valid. see err: %s", err)
+	}
+	err = schema.ValidateEventData(eventExt)
+	if err != nil {
+		return err
+	}
+	var pubTime *timestamppb.Timestamp
+	if qe.PubTime != nil {
+		pubTime = timestamppb.New(*qe.PubTime)
+	}
+	_, err = repo.publish(ctx, schema.BusNames(), eventExt, pubTime)
+	return err
+}
+
+// deleteDiscoveredSchemas deletes the discovered schemas of the buses and their quarantined events.
+func deleteDiscoveredSchemas(ctx context.Context, tx *ent.Tx, busNames ...string) error {
+	dss, err := tx.DiscoveredSchema.Query().
+		Where(discoveredschema.BusNameIn(busNames...)).
+		All(ctx)
+	if err != nil {
+		return err
+	}
+	if len(dss) == 0 {
+		return nil
+	}
+	ps := make([]predicate.QuarantinedEvent, 0, len(dss))
+	ids := make([]uint64, 0, len(dss))
+	for _, ds := range dss {
+		ps = append(ps, quarantinedevent.And(
+			quarantinedevent.Source(ds.Source),
+			quarantinedevent.Type(ds.Type),
+		))
+		ids = append(ids, ds.ID)
+	}
+	_, err = tx.QuarantinedEvent.Delete().Where(quarantinedevent.Or(ps...)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.DiscoveredSchema.Delete().Where(discoveredschema.IDIn(ids...)).Exec(ctx)
+	return err
+}
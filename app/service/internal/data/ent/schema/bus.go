@@ -33,6 +33,9 @@ func (Bus) Fields() []ent.Field {
 			Comment("event bus name"),
 		field.Uint8("mode").
 			Comment("event bus mode. 1-concurrently, 2-orderly"),
+		field.Uint8("status").
+			Default(1).
+			Comment("event bus status. 1-active, 2-paused"),
 		field.String("source_topic").
 			MaxLen(256).
 			Comment("source event topic"),
@@ -45,6 +48,13 @@ func (Bus) Fields() []ent.Field {
 		field.String("target_backoff_topic").
 			MaxLen(256).
 			Comment("target event backoff topic"),
+		field.String("draining_topics").
+			MaxLen(4096).
+			Default("[]").
+			Comment("old topics drained by the job after the bus updated"),
+		field.Bool("discovery").
+			Default(false).
+			Comment("whether the events of the unknown sources and types are quarantined to discover their schemas"),
 	}
 }
 
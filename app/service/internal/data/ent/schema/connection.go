@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+type Connection struct {
+	ent.Schema
+}
+
+func (Connection) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (Connection) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.Time{},
+	}
+}
+
+func (Connection) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			MaxLen(64).
+			Comment("connection name"),
+		field.String("auth_type").
+			MaxLen(32).
+			Comment("auth type. API_KEY, BASIC, OAUTH2_CLIENT_CREDENTIALS, DSN or TLS_CLIENT_CERT"),
+		field.String("key").
+			MaxLen(255).
+			Comment("header name of API key, username of basic auth, client ID of OAuth2 or driver of DSN"),
+		field.String("secret").
+			MaxLen(16384).
+			Sensitive().
+			Comment("encrypted API key, password of basic auth, client secret of OAuth2, DSN or client certificate"),
+		field.String("token_url").
+			MaxLen(1024).
+			Default("").
+			Comment("token URL of OAuth2"),
+		field.Strings("scopes").
+			Optional().
+			Comment("scopes of OAuth2"),
+	}
+}
+
+func (Connection) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (Connection) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("name").Unique(),
+	}
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// DiscoveredSchema is the JSON schema inferred from the quarantined events of an unknown source and type.
+type DiscoveredSchema struct {
+	ent.Schema
+}
+
+func (DiscoveredSchema) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (DiscoveredSchema) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.Time{},
+	}
+}
+
+func (DiscoveredSchema) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("source").
+			MaxLen(64).
+			Comment("source of the event"),
+		field.String("type").
+			MaxLen(64).
+			Comment("type of the event"),
+		field.String("bus_name").
+			MaxLen(64).
+			Comment("event bus name whose discovery quarantines the events"),
+		field.String("spec").
+			MaxLen(1024).
+			Comment("JSON schema inferred from the quarantined events"),
+		field.Uint32("samples").
+			Default(0).
+			Comment("number of the quarantined events"),
+	}
+}
+
+func (DiscoveredSchema) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (DiscoveredSchema) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("source", "type").Unique(),
+		index.Fields("bus_name"),
+	}
+}
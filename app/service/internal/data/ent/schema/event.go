@@ -39,9 +39,22 @@ func (EventSchema) Fields() []ent.Field {
 			Comment("event bus name"),
 		field.String("spec").
 			MaxLen(1024).
-			Comment("JSON schema of the event"),
+			Comment("schema of the event data in the format"),
 		field.Uint32("version").
-			Comment("version of the event schema"),
+			Comment("version of the event schema, it increases on every update and each version is saved"),
+		field.Uint8("compatibility").
+			Default(1).
+			Comment("compatibility of a new spec with the current one, 1-none, 2-backward, 3-forward, 4-full"),
+		field.Strings("extra_bus_names").
+			Optional().
+			Comment("event bus names the events are published to besides bus_name"),
+		field.Uint8("format").
+			Default(1).
+			Comment("format of the spec and the event data, 1-JSON schema, 2-protobuf, 3-avro"),
+		field.Bytes("descriptor_set").
+			Optional().
+			MaxLen(65535).
+			Comment("serialized FileDescriptorSet of the protobuf spec, copied from the registered descriptor"),
 	}
 }
 
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// QuarantinedEvent is an event of an unknown source and type,
+// which is kept until its discovered schema is promoted.
+type QuarantinedEvent struct {
+	ent.Schema
+}
+
+func (QuarantinedEvent) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (QuarantinedEvent) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.CreateTime{},
+	}
+}
+
+func (QuarantinedEvent) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("source").
+			MaxLen(64).
+			Immutable().
+			Comment("source of the event"),
+		field.String("type").
+			MaxLen(64).
+			Immutable().
+			Comment("type of the event"),
+		field.Bytes("event").
+			MaxLen(65535).
+			Immutable().
+			Comment("serialized EventExt"),
+		field.Time("pub_time").
+			Optional().
+			Nillable().
+			Immutable().
+			Comment("time to deliver the event, now if it is not set"),
+	}
+}
+
+func (QuarantinedEvent) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (QuarantinedEvent) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("source", "type"),
+	}
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// RuleRevision is the append-only history of the rules,
+// a revision is the rule after the operation and written in the transaction of the operation.
+type RuleRevision struct {
+	ent.Schema
+}
+
+func (RuleRevision) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (RuleRevision) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.CreateTime{},
+	}
+}
+
+func (RuleRevision) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("bus_name").
+			MaxLen(64).
+			Immutable().
+			Comment("event bus name"),
+		field.String("rule_name").
+			MaxLen(64).
+			Immutable().
+			Comment("rule name"),
+		field.Uint64("revision").
+			Immutable().
+			Comment("revision of the rule, it increases from 1 for each rule name"),
+		field.String("operation").
+			MaxLen(32).
+			Immutable().
+			Comment("operation of the revision. CREATE, UPDATE, DELETE, CREATE_TARGETS, UPDATE_TARGETS, " +
+				"DELETE_TARGETS, ROLLBACK or APPLY"),
+		field.Uint8("status").
+			Immutable().
+			Comment("rule status, 1-enabled, 2-disabled, 3-paused"),
+		field.String("pattern").
+			MaxLen(1024).
+			Immutable().
+			Comment("rule pattern"),
+		field.String("targets").
+			MaxLen(4096).
+			Immutable().
+			Comment("rule targets"),
+	}
+}
+
+func (RuleRevision) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (RuleRevision) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("bus_name", "rule_name", "revision").Unique(),
+	}
+}
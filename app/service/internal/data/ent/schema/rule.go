@@ -35,7 +35,7 @@ func (Rule) Fields() []ent.Field {
 			MaxLen(64).
 			Comment("event bus name"),
 		field.Uint8("status").
-			Comment("rule status, 1-enabled, 2-disabled"),
+			Comment("rule status, 1-enabled, 2-disabled, 3-paused"),
 		field.String("pattern").
 			MaxLen(1024).
 			Comment("rule pattern"),
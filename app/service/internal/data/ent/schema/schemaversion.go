@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+)
+
+// EventSchemaVersion is the history of the event schemas,
+// a version is the event schema after the update and written in the transaction of the update.
+type EventSchemaVersion struct {
+	ent.Schema
+}
+
+func (EventSchemaVersion) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.WithComments(true),
+	}
+}
+
+func (EventSchemaVersion) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		IDMixin{},
+		mixin.CreateTime{},
+	}
+}
+
+func (EventSchemaVersion) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("source").
+			MaxLen(64).
+			Immutable().
+			Comment("source of the event"),
+		field.String("type").
+			MaxLen(64).
+			Immutable().
+			Comment("type of the event"),
+		field.Uint32("version").
+			Immutable().
+			Comment("version of the event schema"),
+		field.String("bus_name").
+			MaxLen(64).
+			Immutable().
+			Comment("event bus name"),
+		field.String("spec").
+			MaxLen(1024).
+			Immutable().
+			Comment("schema of the event data in the format"),
+		field.Uint8("compatibility").
+			Immutable().
+			Comment("compatibility of a new spec with the current one, 1-none, 2-backward, 3-forward, 4-full"),
+		field.Strings("extra_bus_names").
+			Optional().
+			Immutable().
+			Comment("event bus names the events are published to besides bus_name"),
+		field.Uint8("format").
+			Default(1).
+			Immutable().
+			Comment("format of the spec and the event data, 1-JSON schema, 2-protobuf, 3-avro"),
+		field.Bytes("descriptor_set").
+			Optional().
+			MaxLen(65535).
+			Immutable().
+			Comment("serialized FileDescriptorSet of the protobuf spec"),
+	}
+}
+
+func (EventSchemaVersion) Edges() []ent.Edge {
+	return []ent.Edge{}
+}
+
+func (EventSchemaVersion) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("source", "type", "version").Unique(),
+	}
+}
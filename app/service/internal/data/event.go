@@ -7,8 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqljson"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/patrickmn/go-cache"
 	"github.com/redis/go-redis/v9"
@@ -23,6 +29,9 @@ import (
 	"github.com/tianping526/eventbridge/app/service/internal/data/ent"
 	entBus "github.com/tianping526/eventbridge/app/service/internal/data/ent/bus"
 	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschema"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschemaversion"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/predicate"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/protodescriptor"
 	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
 )
 
@@ -69,73 +78,161 @@ func NewEventRepo(
 }
 
 func (repo *eventRepo) PostEvent(
-	ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp,
+	ctx context.Context, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp, schemaVersion *uint32,
+	busName *string, busNames []string,
 ) (*biz.EventInfo, error) {
 	// validate eventExt
 	schema, err := repo.GetLocalCacheSchema(ctx, eventExt.Event.Source, eventExt.Event.Type)
 	if err != nil {
 		return nil, err
 	}
+	if schema == nil && busName != nil {
+		var info *biz.EventInfo
+		info, schema, err = repo.quarantineEvent(ctx, eventExt, pubTime, *busName)
+		if err != nil || schema == nil {
+			return info, err
+		}
+	}
 	if schema == nil {
 		return nil, v1.ErrorSourceTypeNotFound(
 			"source(%s) + type(%s) not found.",
 			eventExt.Event.Source, eventExt.Event.Type,
 		)
 	}
-	if schema.BusName == "" {
+	buses := schema.BusNames()
+	if len(buses) == 0 {
 		return nil, v1.ErrorDataBusRemoved(
 			"data bus has been removed. source: %s, type: %s",
 			eventExt.Event.Source, eventExt.Event.Type,
 		)
 	}
-	err = eventExt.ValidateEventData(schema.GetValidator())
+	if len(busNames) > 0 {
+		for _, name := range busNames {
+			if !slices.Contains(buses, name) {
+				return nil, v1.ErrorDataBusNotFound(
+					"data bus(%s) is not a bus of the schema. source: %s, type: %s",
+					name, eventExt.Event.Source, eventExt.Event.Type,
+				)
+			}
+		}
+		buses = slices.DeleteFunc(buses, func(name string) bool {
+			return !slices.Contains(busNames, name)
+		})
+	}
+	validator := schema
+	if schemaVersion != nil && *schemaVersion != schema.Version {
+		validator, err = repo.GetLocalCacheSchemaVersion(ctx, schema, *schemaVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = validator.ValidateEventData(eventExt)
 	if err != nil {
 		return nil, err
 	}
 
-	// send a message in sync
-	eventExt.BusName = schema.BusName
-	var eventType, messageID string
-	startTime := time.Now()
-	if pubTime.IsValid() && time.Until(pubTime.AsTime()) >= time.Second { // delay
+	results, err := repo.publish(ctx, buses, eventExt, pubTime)
+	if err != nil {
+		return nil, err
+	}
+	return &biz.EventInfo{
+		ID:         eventExt.Event.Id,
+		MessageID:  results[0].MessageID,
+		MessageKey: eventExt.Key(),
+		Results:    results,
+	}, nil
+}
+
+// publish sends the event to the source topics of the buses concurrently and in sync.
+// If some of the buses fail, the event is regarded as not posted and EVENT_PARTIALLY_POSTED is returned,
+// whose metadata tells the buses posted and failed, so that the failed ones can be retried with the same event id.
+func (repo *eventRepo) publish(
+	ctx context.Context, buses []string, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp,
+) ([]*biz.BusResult, error) {
+	if !pubTime.IsValid() || time.Until(pubTime.AsTime()) < time.Second {
+		pubTime = nil
+	}
+	exts := make([]*rule.EventExt, len(buses))
+	for i, bus := range buses {
+		exts[i] = eventExt
+		if i > 0 {
+			exts[i] = rule.CloneEventExt(eventExt)
+		}
+		exts[i].BusName = bus
+	}
+	results := make([]*biz.BusResult, len(buses))
+	errs := make([]error, len(buses))
+	var wg sync.WaitGroup
+	for i, bus := range buses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var messageID string
+			messageID, errs[i] = repo.send(ctx, bus, exts[i], pubTime)
+			results[i] = &biz.BusResult{BusName: bus, MessageID: messageID}
+		}()
+	}
+	wg.Wait()
+
+	posted := make([]string, 0, len(buses))
+	failed := make([]string, 0)
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, buses[i])
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		posted = append(posted, buses[i])
+	}
+	if firstErr == nil {
+		return results, nil
+	}
+	if len(posted) == 0 {
+		return nil, firstErr
+	}
+	return nil, v1.ErrorEventPartiallyPosted(
+		"event(%d) is posted to bus(%s) but failed on bus(%s), retry the failed buses with the same event id. see err: %s",
+		eventExt.Event.Id, strings.Join(posted, ","), strings.Join(failed, ","), firstErr,
+	).WithMetadata(map[string]string{
+		"id":     strconv.FormatUint(eventExt.Event.Id, 10),
+		"posted": strings.Join(posted, ","),
+		"failed": strings.Join(failed, ","),
+	})
+}
+
+// send sends the event to the source topic of the bus, or the source delay topic if pubTime is set.
+func (repo *eventRepo) send(
+	ctx context.Context, busName string, eventExt *rule.EventExt, pubTime *timestamppb.Timestamp,
+) (string, error) {
+	eventType := "source_event"
+	if pubTime != nil {
 		eventType = "source_delay_event"
-		messageID, err = repo.sender.Send(ctx, schema.BusName, eventExt, pubTime)
-	} else {
-		eventType = "source_event"
-		messageID, err = repo.sender.Send(ctx, schema.BusName, eventExt, nil)
 	}
+	startTime := time.Now()
+	messageID, err := repo.sender.Send(ctx, busName, eventExt, pubTime)
 	repo.m.PostEventDurationSec.Record(
 		ctx, time.Since(startTime).Seconds(),
 		metric.WithAttributes(
-			attribute.String(metricPostEventBusName, schema.BusName),
+			attribute.String(metricPostEventBusName, busName),
 			attribute.String(metricPostEventType, eventType),
 		),
 	)
+	result := "ok"
 	if err != nil {
-		repo.m.PostEventCount.Add(
-			ctx, 1,
-			metric.WithAttributes(
-				attribute.String(metricPostEventBusName, schema.BusName),
-				attribute.String(metricPostEventType, eventType),
-				attribute.String(metricPostEventResult, fmt.Sprintf("%T", err)),
-			),
-		)
-		return nil, err
+		result = fmt.Sprintf("%T", err)
 	}
 	repo.m.PostEventCount.Add(
 		ctx, 1,
 		metric.WithAttributes(
-			attribute.String(metricPostEventBusName, schema.BusName),
+			attribute.String(metricPostEventBusName, busName),
 			attribute.String(metricPostEventType, eventType),
-			attribute.String(metricPostEventResult, "ok"),
+			attribute.String(metricPostEventResult, result),
 		),
 	)
-
-	return &biz.EventInfo{
-		ID:         eventExt.Event.Id,
-		MessageID:  messageID,
-		MessageKey: eventExt.Key(),
-	}, nil
+	return messageID, err
 }
 
 func (repo *eventRepo) GetLocalCacheSchema(ctx context.Context, source string, sType string) (*biz.Schema, error) {
@@ -155,13 +252,7 @@ func (repo *eventRepo) GetLocalCacheSchema(ctx context.Context, source string, s
 
 	var schema *biz.Schema
 	if s != nil {
-		schema = &biz.Schema{
-			Source:  s.Source,
-			Type:    s.Type,
-			BusName: s.BusName,
-			Spec:    s.Spec,
-			Time:    timestamppb.New(s.CreateTime),
-		}
+		schema = schemaFromEnt(s)
 		err = schema.ParseSpec()
 		if err != nil {
 			return nil, err
@@ -173,6 +264,41 @@ func (repo *eventRepo) GetLocalCacheSchema(ctx context.Context, source string, s
 	return schema, nil
 }
 
+// GetLocalCacheSchemaVersion the versions are immutable, so they are cached locally without the redis cache.
+func (repo *eventRepo) GetLocalCacheSchemaVersion(
+	ctx context.Context, schema *biz.Schema, version uint32,
+) (*biz.Schema, error) {
+	lcKey := fmt.Sprintf("%s:%s:%d", schema.Source, schema.Type, version)
+	val, ok := repo.sc.Get(lcKey)
+	if ok {
+		return val.(*biz.Schema), nil
+	}
+
+	sv, err := repo.GetSchemaVersion(ctx, schema.Source, schema.Type, version)
+	if err != nil {
+		return nil, err
+	}
+	pinned := &biz.Schema{
+		Source:        schema.Source,
+		Type:          schema.Type,
+		BusName:       sv.BusName,
+		Spec:          sv.Spec,
+		Version:       sv.Version,
+		Compatibility: sv.Compatibility,
+		Format:        sv.Format,
+		DescriptorSet: sv.DescriptorSet,
+		Time:          sv.Time,
+	}
+	err = pinned.ParseSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	repo.sc.Set(lcKey, pinned, cache.DefaultExpiration)
+
+	return pinned, nil
+}
+
 func (repo *eventRepo) ListSchema(
 	ctx context.Context, source *string, sType *string, busName *string, time *timestamppb.Timestamp,
 ) ([]*biz.Schema, error) {
@@ -185,15 +311,7 @@ func (repo *eventRepo) ListSchema(
 		if s == nil {
 			return nil, nil
 		}
-		return []*biz.Schema{
-			{
-				Source:  s.Source,
-				Type:    s.Type,
-				BusName: s.BusName,
-				Spec:    s.Spec,
-				Time:    timestamppb.New(s.CreateTime),
-			},
-		}, nil
+		return []*biz.Schema{schemaFromEnt(s)}, nil
 	}
 
 	// slow path
@@ -205,7 +323,10 @@ func (repo *eventRepo) ListSchema(
 		stmt.Where(eventschema.Type(*sType))
 	}
 	if busName != nil {
-		stmt.Where(eventschema.BusName(*busName))
+		stmt.Where(eventschema.Or(
+			eventschema.BusName(*busName),
+			extraBusNamesContain(*busName),
+		))
 	}
 	if time.IsValid() {
 		stmt.Where(eventschema.CreateTimeGTE(time.AsTime()))
@@ -217,17 +338,118 @@ func (repo *eventRepo) ListSchema(
 
 	schemas := make([]*biz.Schema, 0, len(ss))
 	for _, s := range ss {
-		schemas = append(schemas, &biz.Schema{
-			Source:  s.Source,
-			Type:    s.Type,
-			BusName: s.BusName,
-			Spec:    s.Spec,
-			Time:    timestamppb.New(s.CreateTime),
-		})
+		schemas = append(schemas, schemaFromEnt(s))
 	}
 	return schemas, nil
 }
 
+func schemaFromEnt(s *ent.EventSchema) *biz.Schema {
+	return &biz.Schema{
+		Source:        s.Source,
+		Type:          s.Type,
+		BusName:       s.BusName,
+		Spec:          s.Spec,
+		Version:       s.Version,
+		Compatibility: v1.SchemaCompatibility(s.Compatibility),
+		ExtraBusNames: s.ExtraBusNames,
+		Format:        v1.SchemaFormat(s.Format),
+		DescriptorSet: s.DescriptorSet,
+		Time:          timestamppb.New(s.CreateTime),
+	}
+}
+
+// extraBusNamesContain matches the schemas whose extra buses contain the bus.
+func extraBusNamesContain(busName string) predicate.EventSchema {
+	return func(s *sql.Selector) {
+		s.Where(sqljson.ValueContains(eventschema.FieldExtraBusNames, busName))
+	}
+}
+
+// removeExtraBus removes the bus from the extra buses of the schemas, and saves their versions.
+// The schemas changed are returned.
+func removeExtraBus(ctx context.Context, tx *ent.Tx, busName string) ([]*ent.EventSchema, error) {
+	ss, err := tx.EventSchema.Query().
+		Where(extraBusNamesContain(busName)).
+		ForUpdate().
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range ss {
+		s, err = tx.EventSchema.UpdateOne(s).
+			SetExtraBusNames(slices.DeleteFunc(slices.Clone(s.ExtraBusNames), func(name string) bool {
+				return name == busName
+			})).
+			AddVersion(1).
+			Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = saveSchemaVersion(ctx, tx, s)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = s
+	}
+	return ss, nil
+}
+
+// lockBuses locks the buses in the transaction, DATA_BUS_NOT_FOUND is returned if one of them doesn't exist.
+func lockBuses(ctx context.Context, tx *ent.Tx, busNames ...string) error {
+	if len(busNames) == 0 {
+		return nil
+	}
+	found, err := tx.Bus.Query().
+		Where(entBus.NameIn(busNames...)).
+		ForUpdate().
+		Select(entBus.FieldName).
+		Strings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range busNames {
+		if !slices.Contains(found, name) {
+			return v1.ErrorDataBusNotFound(
+				"can't find the data bus. name: %s",
+				name,
+			)
+		}
+	}
+	return nil
+}
+
+// resolveSchemaSpec checks the spec by its format and the compatibility,
+// and returns the descriptor set of the PROTOBUF spec, which is copied to the schema.
+func resolveSchemaSpec(
+	ctx context.Context, tx *ent.Tx, format v1.SchemaFormat, compatibility v1.SchemaCompatibility, spec []byte,
+) ([]byte, error) {
+	var descriptorSet []byte
+	if format == v1.SchemaFormat_SCHEMA_FORMAT_PROTOBUF {
+		ps, err := biz.ParseProtobufSpec(spec)
+		if err != nil {
+			return nil, v1.ErrorSchemaSyntaxError("syntax error: %s", err)
+		}
+		pd, err := tx.ProtoDescriptor.Query().
+			Where(protodescriptor.Name(ps.Descriptor)).
+			Only(ctx)
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return nil, v1.ErrorDescriptorNotFound(
+					"can't find the protobuf descriptor. name: %s",
+					ps.Descriptor,
+				)
+			}
+			return nil, err
+		}
+		descriptorSet = pd.DescriptorSet
+	}
+	err := biz.SchemaSpecCheck(format, compatibility, spec, descriptorSet)
+	if err != nil {
+		return nil, v1.ErrorSchemaSyntaxError("syntax error: %s", err)
+	}
+	return descriptorSet, nil
+}
+
 // FetchSchema from cache; if missing, calls the source method and then adds it to the cache.
 func (repo *eventRepo) FetchSchema(ctx context.Context, source string, sType string) (*ent.EventSchema, error) {
 	s, err := repo.FetchCacheSchema(ctx, source, sType)
@@ -314,31 +536,32 @@ func SetCacheSchema(ctx context.Context, rc redis.Cmdable, source string, sType
 
 func (repo *eventRepo) CreateSchema(
 	ctx context.Context, source string, sType string, busName string, spec []byte,
+	compatibility v1.SchemaCompatibility, extraBusNames []string, format v1.SchemaFormat,
 ) error {
 	var s *ent.EventSchema
+	extraBusNames = biz.ExtraBusNames(busName, extraBusNames)
 	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-		// query data bus and lock
-		_, te := tx.Bus.Query().
-			Where(entBus.Name(busName)).
-			ForUpdate().
-			OnlyID(ctx)
+		// query data buses and lock
+		te := lockBuses(ctx, tx, append([]string{busName}, extraBusNames...)...)
+		if te != nil {
+			return te
+		}
+		descriptorSet, te := resolveSchemaSpec(ctx, tx, format, compatibility, spec)
 		if te != nil {
-			if ent.IsNotFound(te) {
-				return v1.ErrorDataBusNotFound(
-					"can't find the data bus. name: %s",
-					busName,
-				)
-			}
 			return te
 		}
 
 		// save schema
-		s, te = repo.db.EventSchema.Create().
+		s, te = tx.EventSchema.Create().
 			SetSource(source).
 			SetType(sType).
 			SetBusName(busName).
 			SetSpec(string(spec)).
 			SetVersion(1).
+			SetCompatibility(uint8(compatibility)).
+			SetExtraBusNames(extraBusNames).
+			SetFormat(uint8(format)).
+			SetDescriptorSet(descriptorSet).
 			Save(ctx)
 		if te != nil {
 			if ent.IsConstraintError(te) {
@@ -349,7 +572,7 @@ func (repo *eventRepo) CreateSchema(
 			}
 			return te
 		}
-		return nil
+		return saveSchemaVersion(ctx, tx, s)
 	})
 	if err != nil {
 		return err
@@ -370,85 +593,117 @@ func (repo *eventRepo) CreateSchema(
 	return nil
 }
 
+// UpdateSchema the new spec is checked by the format and against the current one by the compatibility,
+// which are the new ones if they are set.
 func (repo *eventRepo) UpdateSchema(
 	ctx context.Context, source string, sType string, busName *string, spec []byte,
-) error {
-	stmt := repo.db.EventSchema.Update().
-		Where(
-			eventschema.Source(source),
-			eventschema.Type(sType),
-		)
-	stmt.AddVersion(1)
-	if spec != nil {
-		stmt.SetSpec(string(spec))
-	}
-	var ar int
-	var err error
-	if busName != nil {
-		stmt.SetBusName(*busName)
-		err = entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-			// query data bus and lock
-			_, te := tx.Bus.Query().
-				Where(entBus.Name(*busName)).
-				ForUpdate().
-				OnlyID(ctx)
-			if te != nil {
-				if ent.IsNotFound(te) {
-					return v1.ErrorDataBusNotFound(
-						"can't find the data bus. name: %s",
-						*busName,
-					)
-				}
-				return te
+	compatibility *v1.SchemaCompatibility, extraBusNames []string, format *v1.SchemaFormat,
+) (uint32, error) {
+	var s *ent.EventSchema
+	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		// query data buses and lock
+		buses := slices.Clone(extraBusNames)
+		if busName != nil {
+			buses = append(buses, *busName)
+		}
+		te := lockBuses(ctx, tx, buses...)
+		if te != nil {
+			return te
+		}
+
+		// query schema and lock
+		cs, te := tx.EventSchema.Query().
+			Where(
+				eventschema.Source(source),
+				eventschema.Type(sType),
+			).
+			ForUpdate().
+			Only(ctx)
+		if te != nil {
+			if ent.IsNotFound(te) {
+				return v1.ErrorSchemaNotFound(
+					"can't find the schema to update.source: %s, type: %s",
+					source, sType,
+				)
 			}
+			return te
+		}
 
-			// update schema
-			ar, te = stmt.Save(ctx)
+		// update schema
+		stmt := tx.EventSchema.UpdateOne(cs).AddVersion(1)
+		mode := v1.SchemaCompatibility(cs.Compatibility)
+		if compatibility != nil {
+			mode = *compatibility
+			stmt.SetCompatibility(uint8(mode))
+		}
+		newFormat := v1.SchemaFormat(cs.Format)
+		if format != nil {
+			newFormat = *format
+			stmt.SetFormat(uint8(newFormat))
+		}
+		if spec != nil {
+			descriptorSet, re := resolveSchemaSpec(ctx, tx, newFormat, mode, spec)
+			if re != nil {
+				return re
+			}
+			te = checkSchemaCompatibility(source, sType, mode, cs, newFormat, string(spec))
 			if te != nil {
 				return te
 			}
-			return nil
-		})
-	} else {
-		ar, err = stmt.Save(ctx)
-	}
+			stmt.SetSpec(string(spec)).SetDescriptorSet(descriptorSet)
+		} else if compatibility != nil {
+			te = biz.SchemaSpecCheck(newFormat, mode, []byte(cs.Spec), cs.DescriptorSet)
+			if te != nil {
+				return v1.ErrorSchemaSyntaxError("syntax error: %s", te)
+			}
+		}
+		primary := cs.BusName
+		if busName != nil {
+			primary = *busName
+			stmt.SetBusName(primary)
+		}
+		if extraBusNames == nil && busName != nil {
+			// the new bus is not an extra bus any more
+			extraBusNames = cs.ExtraBusNames
+		}
+		if extraBusNames != nil {
+			stmt.SetExtraBusNames(biz.ExtraBusNames(primary, extraBusNames))
+		}
+		s, te = stmt.Save(ctx)
+		if te != nil {
+			return te
+		}
+		return saveSchemaVersion(ctx, tx, s)
+	})
 	if err != nil {
-		return err
-	}
-	if ar != 1 {
-		return v1.ErrorSchemaNotFound(
-			"can't find the schema to update.source: %s, type: %s",
-			source, sType,
-		)
+		return 0, err
 	}
 
 	// update cache
-	s, err := repo.db.EventSchema.Query().
-		Where(
-			eventschema.Source(source),
-			eventschema.Type(sType),
-		).
-		Only(ctx)
-	if err != nil {
-		if !ent.IsNotFound(err) {
-			repo.log.WithContext(ctx).Errorf("FetchDBSchema: %v", err)
-			return nil
-		}
-	}
 	err = SetCacheSchema(ctx, repo.rc, source, sType, s)
 	if err != nil {
 		repo.log.WithContext(ctx).Errorf("SetCacheSchema: %v, schema: %v", err, s)
 	}
 
-	return nil
+	return s.Version, nil
 }
 
+// DeleteSchema the versions are deleted with the schemas.
 func (repo *eventRepo) DeleteSchema(ctx context.Context, source string, sType *string) error {
-	stmt := repo.db.EventSchema.Delete().Where(eventschema.Source(source))
-	if sType != nil {
-		stmt.Where(eventschema.Type(*sType))
-	}
-	_, err := stmt.Exec(ctx)
+	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		stmt := tx.EventSchema.Delete().Where(eventschema.Source(source))
+		vStmt := tx.EventSchemaVersion.Delete().Where(eventschemaversion.Source(source))
+		if sType != nil {
+			stmt.Where(eventschema.Type(*sType))
+			vStmt.Where(eventschemaversion.Type(*sType))
+		}
+		_, te := stmt.Exec(ctx)
+		if te != nil {
+			return te
+		}
+		_, te = vStmt.Exec(ctx)
+		return te
+	})
 	if err != nil {
 		return err
 	}
@@ -499,3 +754,183 @@ func (repo *eventRepo) DeleteSchema(ctx context.Context, source string, sType *s
 
 	return nil
 }
+
+func (repo *eventRepo) ListSchemaVersions(
+	ctx context.Context, source string, sType string, limit int32, nextToken uint32,
+) ([]*biz.SchemaVersion, uint32, error) {
+	convertedLimit := int(limit)
+	stmt := repo.db.EventSchemaVersion.Query().
+		Where(
+			eventschemaversion.Source(source),
+			eventschemaversion.Type(sType),
+		)
+	if nextToken > 0 {
+		stmt.Where(eventschemaversion.VersionLTE(nextToken))
+	}
+	vs, err := stmt.Order(ent.Desc(eventschemaversion.FieldVersion)).Limit(convertedLimit + 1).All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := uint32(0)
+	if len(vs) > convertedLimit {
+		next = vs[convertedLimit].Version
+		vs = vs[:convertedLimit]
+	}
+	versions := make([]*biz.SchemaVersion, 0, len(vs))
+	for _, v := range vs {
+		versions = append(versions, schemaVersionFromEnt(v))
+	}
+	return versions, next, nil
+}
+
+func (repo *eventRepo) GetSchemaVersion(
+	ctx context.Context, source string, sType string, version uint32,
+) (*biz.SchemaVersion, error) {
+	v, err := repo.db.EventSchemaVersion.Query().
+		Where(
+			eventschemaversion.Source(source),
+			eventschemaversion.Type(sType),
+			eventschemaversion.Version(version),
+		).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorSchemaVersionNotFound(
+				"schema version not found. source: %s, type: %s, version: %d",
+				source, sType, version,
+			)
+		}
+		return nil, err
+	}
+	return schemaVersionFromEnt(v), nil
+}
+
+// saveSchemaVersion saves the schema updated in the transaction as its version.
+func saveSchemaVersion(ctx context.Context, tx *ent.Tx, s *ent.EventSchema) error {
+	return tx.EventSchemaVersion.Create().
+		SetSource(s.Source).
+		SetType(s.Type).
+		SetVersion(s.Version).
+		SetBusName(s.BusName).
+		SetSpec(s.Spec).
+		SetCompatibility(s.Compatibility).
+		SetExtraBusNames(s.ExtraBusNames).
+		SetFormat(s.Format).
+		SetDescriptorSet(s.DescriptorSet).
+		Exec(ctx)
+}
+
+func schemaVersionFromEnt(v *ent.EventSchemaVersion) *biz.SchemaVersion {
+	return &biz.SchemaVersion{
+		Version:       v.Version,
+		BusName:       v.BusName,
+		Spec:          v.Spec,
+		Compatibility: v1.SchemaCompatibility(v.Compatibility),
+		ExtraBusNames: v.ExtraBusNames,
+		Format:        v1.SchemaFormat(v.Format),
+		DescriptorSet: v.DescriptorSet,
+		Time:          timestamppb.New(v.CreateTime),
+	}
+}
+
+// checkSchemaCompatibility checks the new spec of the schema against the current one,
+// the format can only be changed if the compatibility is NONE.
+func checkSchemaCompatibility(
+	source string, sType string, compatibility v1.SchemaCompatibility,
+	cs *ent.EventSchema, format v1.SchemaFormat, spec string,
+) error {
+	current := cs.Spec
+	if spec == current && uint8(format) == cs.Format {
+		return nil
+	}
+	if uint8(format) != cs.Format && compatibility != v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE {
+		return v1.ErrorSchemaIncompatible(
+			"the format of the schema can't be changed unless the compatibility is NONE. source: %s, type: %s",
+			source, sType,
+		)
+	}
+	var err error
+	switch compatibility {
+	case v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_BACKWARD:
+		err = rule.SchemaAccepts(spec, current)
+	case v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_FORWARD:
+		err = rule.SchemaAccepts(current, spec)
+	case v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_FULL:
+		err = rule.SchemaAccepts(spec, current)
+		if err == nil {
+			err = rule.SchemaAccepts(current, spec)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return v1.ErrorSchemaIncompatible(
+			"the spec of the schema is not %s compatible. source: %s, type: %s, %s",
+			strings.ToLower(strings.TrimPrefix(compatibility.String(), "SCHEMA_COMPATIBILITY_")),
+			source, sType, err,
+		)
+	}
+	return nil
+}
+
+func (repo *eventRepo) ListProtoDescriptor(ctx context.Context, prefix *string) ([]*biz.ProtoDescriptor, error) {
+	stmt := repo.db.ProtoDescriptor.Query()
+	if prefix != nil {
+		stmt.Where(protodescriptor.NameHasPrefix(*prefix))
+	}
+	pds, err := stmt.Order(ent.Asc(protodescriptor.FieldName)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]*biz.ProtoDescriptor, 0, len(pds))
+	for _, pd := range pds {
+		descriptors = append(descriptors, &biz.ProtoDescriptor{
+			Name:          pd.Name,
+			DescriptorSet: pd.DescriptorSet,
+			Time:          timestamppb.New(pd.CreateTime),
+		})
+	}
+	return descriptors, nil
+}
+
+// CreateProtoDescriptor the rules version is updated so that the job reloads the targets using the descriptor.
+func (repo *eventRepo) CreateProtoDescriptor(ctx context.Context, name string, descriptorSet []byte) error {
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		te := tx.ProtoDescriptor.Create().
+			SetName(name).
+			SetDescriptorSet(descriptorSet).
+			Exec(ctx)
+		if te != nil {
+			if ent.IsConstraintError(te) {
+				return v1.ErrorDescriptorNameRepeat(
+					"protobuf descriptor name repeat. name: %s",
+					name,
+				)
+			}
+			return te
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+	})
+}
+
+func (repo *eventRepo) DeleteProtoDescriptor(ctx context.Context, name string) error {
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		ar, te := tx.ProtoDescriptor.Delete().
+			Where(protodescriptor.Name(name)).
+			Exec(ctx)
+		if te != nil {
+			return te
+		}
+		if ar == 0 {
+			return v1.ErrorDescriptorNotFound(
+				"can't find the protobuf descriptor. name: %s",
+				name,
+			)
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+	})
+}
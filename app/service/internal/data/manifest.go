@@ -0,0 +1,643 @@
+package data
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	ir "github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/internal/secret"
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent"
+	entBus "github.com/tianping526/eventbridge/app/service/internal/data/ent/bus"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschema"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschemaversion"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
+)
+
+// errDryRun rolls back the transaction of a dry run after the changes are planned.
+var errDryRun = errors.New("dry run")
+
+type schemaKey struct {
+	source string
+	sType  string
+}
+
+type manifestRepo struct {
+	log    *log.Helper
+	db     *ent.Client
+	rc     redis.Cmdable
+	cipher *secret.Cipher
+}
+
+func NewManifestRepo(logger log.Logger, db *ent.Client, rc redis.Cmdable, cipher *secret.Cipher) biz.ManifestRepo {
+	return &manifestRepo{
+		log: log.NewHelper(log.With(
+			logger,
+			"module", "repo/manifest",
+			"caller", log.DefaultCaller,
+		)),
+		db:     db,
+		rc:     rc,
+		cipher: cipher,
+	}
+}
+
+func (repo *manifestRepo) ExportManifest(ctx context.Context, bus *string) (*biz.Manifest, error) {
+	stmt := repo.db.Bus.Query()
+	if bus != nil {
+		stmt.Where(entBus.Name(*bus))
+	}
+	bs, err := stmt.Order(ent.Asc(entBus.FieldName)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if bus != nil && len(bs) == 0 {
+		return nil, v1.ErrorDataBusNotFound(
+			"can't find the data bus. name: %s",
+			*bus,
+		)
+	}
+	manifest := &biz.Manifest{Buses: make([]*biz.ManifestBus, 0, len(bs))}
+	buses := make(map[string]*biz.ManifestBus, len(bs))
+	names := make([]string, 0, len(bs))
+	for _, b := range bs {
+		mb := &biz.ManifestBus{}
+		mb.Bus, err = busFromEnt(b)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Buses = append(manifest.Buses, mb)
+		buses[b.Name] = mb
+		names = append(names, b.Name)
+	}
+
+	ss, err := repo.db.EventSchema.Query().
+		Where(eventschema.BusNameIn(names...)).
+		Order(ent.Asc(eventschema.FieldSource), ent.Asc(eventschema.FieldType)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		mb := buses[s.BusName]
+		mb.Schemas = append(mb.Schemas, &biz.Schema{
+			Source:        s.Source,
+			Type:          s.Type,
+			BusName:       s.BusName,
+			ExtraBusNames: s.ExtraBusNames,
+			Spec:          s.Spec,
+			Compatibility: v1.SchemaCompatibility(s.Compatibility),
+			Format:        v1.SchemaFormat(s.Format),
+		})
+	}
+
+	rs, err := repo.db.Rule.Query().
+		Where(rule.BusNameIn(names...)).
+		Order(ent.Asc(rule.FieldName)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		var targets []*ir.Target
+		err = json.Unmarshal([]byte(r.Targets), &targets)
+		if err != nil {
+			return nil, err
+		}
+		sortTargets(targets)
+		mb := buses[r.BusName]
+		mb.Rules = append(mb.Rules, &ir.Rule{
+			Name:    r.Name,
+			BusName: r.BusName,
+			Status:  v1.RuleStatus(r.Status),
+			Pattern: r.Pattern,
+			Targets: targets,
+		})
+	}
+	return manifest, nil
+}
+
+func (repo *manifestRepo) ApplyManifest(
+	ctx context.Context, bus *string, manifest *biz.Manifest, dryRun bool,
+) ([]*biz.Change, error) {
+	var changes []*biz.Change
+	var savedSchemas, deletedSchemas []schemaKey
+	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		a := &manifestApplier{tx: tx, rc: repo.rc, cipher: repo.cipher}
+		te := a.apply(ctx, bus, manifest)
+		if te != nil {
+			return te
+		}
+		changes, savedSchemas, deletedSchemas = a.changes, a.savedSchemas, a.deletedSchemas
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	// update cache
+	for _, k := range savedSchemas {
+		s, err := repo.db.EventSchema.Query().
+			Where(
+				eventschema.Source(k.source),
+				eventschema.Type(k.sType),
+			).
+			Only(ctx)
+		if err != nil {
+			repo.log.WithContext(ctx).Errorf("query schema(%s/%s): %v", k.source, k.sType, err)
+			continue
+		}
+		err = SetCacheSchema(ctx, repo.rc, s.Source, s.Type, s)
+		if err != nil {
+			repo.log.WithContext(ctx).Errorf("SetCacheSchema: %v, schema: %v", err, s)
+		}
+	}
+	for _, k := range deletedSchemas {
+		key := fmt.Sprintf("eb:event:schema:{%s}:%s", k.source, k.sType)
+		verKey := fmt.Sprintf("%s:version", key)
+		err = repo.rc.Del(ctx, verKey, key).Err()
+		if err != nil {
+			repo.log.WithContext(ctx).Errorf(
+				"delete schema cache keys(%v): %v",
+				[]string{verKey, key}, err,
+			)
+		}
+	}
+
+	return changes, nil
+}
+
+// manifestApplier applies a manifest in the transaction and records the changes.
+type manifestApplier struct {
+	tx     *ent.Tx
+	rc     redis.Cmdable
+	cipher *secret.Cipher
+
+	changes        []*biz.Change
+	savedSchemas   []schemaKey
+	deletedSchemas []schemaKey
+}
+
+func (a *manifestApplier) record(action string, kind string, busName string, name string) {
+	a.changes = append(a.changes, &biz.Change{
+		Action:  action,
+		Kind:    kind,
+		BusName: busName,
+		Name:    name,
+	})
+}
+
+// apply creates and updates the buses first, then the schemas and the rules, and deletes the buses at last.
+func (a *manifestApplier) apply(ctx context.Context, bus *string, manifest *biz.Manifest) error {
+	// query buses and lock
+	stmt := a.tx.Bus.Query()
+	if bus != nil {
+		stmt.Where(entBus.Name(*bus))
+	}
+	bs, err := stmt.Order(ent.Asc(entBus.FieldName)).ForUpdate().All(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]*ent.Bus, len(bs))
+	scope := make([]string, 0, len(bs)+len(manifest.Buses))
+	for _, b := range bs {
+		current[b.Name] = b
+		scope = append(scope, b.Name)
+	}
+	desired := make(map[string]struct{}, len(manifest.Buses))
+	for _, mb := range manifest.Buses {
+		desired[mb.Bus.Name] = struct{}{}
+		if _, ok := current[mb.Bus.Name]; !ok {
+			scope = append(scope, mb.Bus.Name)
+		}
+	}
+
+	busesChanged := false
+	for _, mb := range manifest.Buses {
+		changed, err := a.applyBus(ctx, current[mb.Bus.Name], mb.Bus)
+		if err != nil {
+			return err
+		}
+		busesChanged = busesChanged || changed
+	}
+	err = a.applySchemas(ctx, scope, manifest)
+	if err != nil {
+		return err
+	}
+	rulesChanged, err := a.applyRules(ctx, scope, manifest)
+	if err != nil {
+		return err
+	}
+	for _, b := range bs {
+		if _, ok := desired[b.Name]; ok {
+			continue
+		}
+		err = a.tx.Bus.DeleteOne(b).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = deleteDiscoveredSchemas(ctx, a.tx, b.Name)
+		if err != nil {
+			return err
+		}
+		ess, err := removeExtraBus(ctx, a.tx, b.Name)
+		if err != nil {
+			return err
+		}
+		for _, s := range ess {
+			a.savedSchemas = append(a.savedSchemas, schemaKey{source: s.Source, sType: s.Type})
+		}
+		a.record(biz.ChangeActionDelete, biz.ChangeKindBus, b.Name, b.Name)
+		busesChanged = true
+	}
+
+	// update version
+	if busesChanged {
+		err = a.tx.Version.UpdateOneID(entext.BusesVersionID).AddVersion(1).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if rulesChanged {
+		err = a.tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyBus creates the bus if current is nil, or updates it like UpdateBus if it is changed.
+func (a *manifestApplier) applyBus(ctx context.Context, current *ent.Bus, b *biz.Bus) (bool, error) {
+	if current == nil {
+		sourceTopic, _ := json.Marshal(b.Source)
+		sourceDelayTopic, _ := json.Marshal(b.SourceDelay)
+		targetExpDecayTopic, _ := json.Marshal(b.TargetExpDecay)
+		targetBackoffTopic, _ := json.Marshal(b.TargetBackoff)
+		err := a.tx.Bus.Create().
+			SetName(b.Name).
+			SetMode(uint8(b.Mode)).
+			SetStatus(uint8(b.Status)).
+			SetSourceTopic(string(sourceTopic)).
+			SetSourceDelayTopic(string(sourceDelayTopic)).
+			SetTargetExpDecayTopic(string(targetExpDecayTopic)).
+			SetTargetBackoffTopic(string(targetBackoffTopic)).
+			SetDiscovery(b.Discovery).
+			Exec(ctx)
+		if err != nil {
+			if ent.IsConstraintError(err) {
+				err = v1.ErrorBusNameRepeat(
+					"bus name repeat. name: %s",
+					b.Name,
+				)
+			}
+			return false, err
+		}
+		a.record(biz.ChangeActionCreate, biz.ChangeKindBus, b.Name, b.Name)
+		return true, nil
+	}
+
+	cb, err := busFromEnt(current)
+	if err != nil {
+		return false, err
+	}
+	if *cb == *b {
+		return false, nil
+	}
+	err = updateBus(
+		ctx, a.tx, a.rc, current, &b.Mode, &b.Status,
+		&b.Source, &b.SourceDelay, &b.TargetExpDecay, &b.TargetBackoff, &b.Discovery,
+	)
+	if err != nil {
+		return false, err
+	}
+	a.record(biz.ChangeActionUpdate, biz.ChangeKindBus, b.Name, b.Name)
+	return true, nil
+}
+
+// applySchemas a schema of another bus is moved to the bus declaring it, like UpdateSchema,
+// and its spec is checked by the compatibility declared. The extra buses of a schema must exist
+// after the buses are applied.
+func (a *manifestApplier) applySchemas(ctx context.Context, scope []string, manifest *biz.Manifest) error {
+	ss, err := a.tx.EventSchema.Query().
+		Where(eventschema.BusNameIn(scope...)).
+		Order(ent.Asc(eventschema.FieldSource), ent.Asc(eventschema.FieldType)).
+		ForUpdate().
+		All(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[schemaKey]*ent.EventSchema, len(ss))
+	for _, s := range ss {
+		current[schemaKey{source: s.Source, sType: s.Type}] = s
+	}
+	desired := make(map[schemaKey]struct{})
+	for _, mb := range manifest.Buses {
+		for _, s := range mb.Schemas {
+			k := schemaKey{source: s.Source, sType: s.Type}
+			desired[k] = struct{}{}
+			cs, ok := current[k]
+			if !ok {
+				cs, err = a.tx.EventSchema.Query().
+					Where(
+						eventschema.Source(s.Source),
+						eventschema.Type(s.Type),
+					).
+					ForUpdate().
+					Only(ctx)
+				if err != nil && !ent.IsNotFound(err) {
+					return err
+				}
+			}
+			extraBusNames := biz.ExtraBusNames(mb.Bus.Name, s.ExtraBusNames)
+			err = lockBuses(ctx, a.tx, extraBusNames...)
+			if err != nil {
+				return err
+			}
+			descriptorSet, err := resolveSchemaSpec(ctx, a.tx, s.Format, s.Compatibility, []byte(s.Spec))
+			if err != nil {
+				return err
+			}
+			name := s.Source + "/" + s.Type
+			var saved *ent.EventSchema
+			switch {
+			case cs == nil:
+				saved, err = a.tx.EventSchema.Create().
+					SetSource(s.Source).
+					SetType(s.Type).
+					SetBusName(mb.Bus.Name).
+					SetSpec(s.Spec).
+					SetVersion(1).
+					SetCompatibility(uint8(s.Compatibility)).
+					SetExtraBusNames(extraBusNames).
+					SetFormat(uint8(s.Format)).
+					SetDescriptorSet(descriptorSet).
+					Save(ctx)
+				if err != nil {
+					return err
+				}
+				a.record(biz.ChangeActionCreate, biz.ChangeKindSchema, mb.Bus.Name, name)
+			case cs.BusName != mb.Bus.Name || cs.Spec != s.Spec || cs.Compatibility != uint8(s.Compatibility) ||
+				!slices.Equal(cs.ExtraBusNames, extraBusNames) || cs.Format != uint8(s.Format) ||
+				!bytes.Equal(cs.DescriptorSet, descriptorSet):
+				err = checkSchemaCompatibility(s.Source, s.Type, s.Compatibility, cs, s.Format, s.Spec)
+				if err != nil {
+					return err
+				}
+				saved, err = a.tx.EventSchema.UpdateOne(cs).
+					SetBusName(mb.Bus.Name).
+					SetSpec(s.Spec).
+					SetCompatibility(uint8(s.Compatibility)).
+					SetExtraBusNames(extraBusNames).
+					SetFormat(uint8(s.Format)).
+					SetDescriptorSet(descriptorSet).
+					AddVersion(1).
+					Save(ctx)
+				if err != nil {
+					return err
+				}
+				a.record(biz.ChangeActionUpdate, biz.ChangeKindSchema, mb.Bus.Name, name)
+			default:
+				continue
+			}
+			err = saveSchemaVersion(ctx, a.tx, saved)
+			if err != nil {
+				return err
+			}
+			a.savedSchemas = append(a.savedSchemas, k)
+		}
+	}
+	for _, s := range ss {
+		k := schemaKey{source: s.Source, sType: s.Type}
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		err = a.tx.EventSchema.DeleteOne(s).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = a.tx.EventSchemaVersion.Delete().
+			Where(
+				eventschemaversion.Source(s.Source),
+				eventschemaversion.Type(s.Type),
+			).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		a.record(biz.ChangeActionDelete, biz.ChangeKindSchema, s.BusName, s.Source+"/"+s.Type)
+		a.deletedSchemas = append(a.deletedSchemas, k)
+	}
+	return nil
+}
+
+// applyRules each rule changed is saved as a revision, like the other rule mutations.
+func (a *manifestApplier) applyRules(ctx context.Context, scope []string, manifest *biz.Manifest) (bool, error) {
+	rs, err := a.tx.Rule.Query().
+		Where(rule.BusNameIn(scope...)).
+		Order(ent.Asc(rule.FieldBusName), ent.Asc(rule.FieldName)).
+		ForUpdate().
+		All(ctx)
+	if err != nil {
+		return false, err
+	}
+	current := make(map[string]*ent.Rule, len(rs))
+	for _, r := range rs {
+		current[r.BusName+"/"+r.Name] = r
+	}
+	changed := false
+	for _, mb := range manifest.Buses {
+		for _, r := range mb.Rules {
+			cr := current[mb.Bus.Name+"/"+r.Name]
+			delete(current, mb.Bus.Name+"/"+r.Name)
+			var currentTargets []*ir.Target
+			if cr != nil {
+				err = json.Unmarshal([]byte(cr.Targets), &currentTargets)
+				if err != nil {
+					return false, err
+				}
+				sortTargets(currentTargets)
+			}
+			err = resolveTargetSecrets(a.cipher, currentTargets, r.Targets)
+			if err != nil {
+				return false, err
+			}
+			sortTargets(r.Targets)
+			var bts, cts []byte
+			bts, err = json.Marshal(r.Targets)
+			if err != nil {
+				return false, err
+			}
+			var saved *ent.Rule
+			var action string
+			if cr == nil {
+				saved, err = a.tx.Rule.Create().
+					SetBusName(mb.Bus.Name).
+					SetName(r.Name).
+					SetStatus(uint8(r.Status)).
+					SetPattern(r.Pattern).
+					SetTargets(string(bts)).
+					Save(ctx)
+				action = biz.ChangeActionCreate
+			} else {
+				cts, err = json.Marshal(currentTargets)
+				if err != nil {
+					return false, err
+				}
+				if cr.Status == uint8(r.Status) && cr.Pattern == r.Pattern && string(cts) == string(bts) {
+					continue
+				}
+				saved, err = a.tx.Rule.UpdateOne(cr).
+					SetStatus(uint8(r.Status)).
+					SetPattern(r.Pattern).
+					SetTargets(string(bts)).
+					Save(ctx)
+				action = biz.ChangeActionUpdate
+			}
+			if err != nil {
+				return false, err
+			}
+			_, err = saveRuleRevision(ctx, a.tx, saved, ruleOperationApply)
+			if err != nil {
+				return false, err
+			}
+			a.record(action, biz.ChangeKindRule, mb.Bus.Name, r.Name)
+			changed = true
+		}
+	}
+	for _, r := range rs {
+		if _, ok := current[r.BusName+"/"+r.Name]; !ok {
+			continue
+		}
+		err = a.tx.Rule.DeleteOne(r).Exec(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, err = saveRuleRevision(ctx, a.tx, r, ruleOperationDelete)
+		if err != nil {
+			return false, err
+		}
+		a.record(biz.ChangeActionDelete, biz.ChangeKindRule, r.BusName, r.Name)
+		changed = true
+	}
+	return changed, nil
+}
+
+// resolveTargetSecrets a signing without secrets keeps the stored secrets of the target with the same id,
+// so does a HASH redaction without the key keep the stored key of the same target and path.
+// The stored secrets are kept if they are not changed, so applying the same manifest again changes nothing.
+// The other secrets are encrypted.
+func resolveTargetSecrets(cipher *secret.Cipher, current []*ir.Target, targets []*ir.Target) error {
+	stored := make(map[uint64][]string, len(current))
+	storedKeys := make(map[uint64]map[string]string, len(current))
+	for _, t := range current {
+		if t.Signing != nil {
+			stored[t.ID] = t.Signing.Secrets
+		}
+		for _, r := range t.Redactions {
+			if r.Key == "" {
+				continue
+			}
+			if storedKeys[t.ID] == nil {
+				storedKeys[t.ID] = make(map[string]string)
+			}
+			storedKeys[t.ID][r.Path] = r.Key
+		}
+	}
+	for _, t := range targets {
+		err := resolveRedactionKeys(cipher, storedKeys[t.ID], t)
+		if err != nil {
+			return err
+		}
+		if t.Signing == nil {
+			continue
+		}
+		secrets := stored[t.ID]
+		if len(t.Signing.Secrets) == 0 {
+			if len(secrets) == 0 {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) signing secrets are required", t.ID,
+				)
+			}
+			t.Signing.Secrets = secrets
+			continue
+		}
+		if sameSecrets(cipher, secrets, t.Signing.Secrets) {
+			t.Signing.Secrets = secrets
+			continue
+		}
+		err = encryptSigningSecrets(cipher, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveRedactionKeys(cipher *secret.Cipher, storedKeys map[string]string, t *ir.Target) error {
+	for _, r := range t.Redactions {
+		if r.Action != "HASH" {
+			continue
+		}
+		key, ok := storedKeys[r.Path]
+		if r.Key == "" {
+			if !ok {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) redaction(%s) key is required", t.ID, r.Path,
+				)
+			}
+			r.Key = key
+			continue
+		}
+		if ok && sameSecrets(cipher, []string{key}, []string{r.Key}) {
+			r.Key = key
+			continue
+		}
+		encrypted, err := cipher.Encrypt(r.Key)
+		if err != nil {
+			if errors.Is(err, secret.ErrNoKey) {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) redaction key is disabled: %s", t.ID, err,
+				)
+			}
+			return err
+		}
+		r.Key = encrypted
+	}
+	return nil
+}
+
+func sameSecrets(cipher *secret.Cipher, encrypted []string, plaintexts []string) bool {
+	if len(encrypted) != len(plaintexts) {
+		return false
+	}
+	for i, e := range encrypted {
+		plaintext, err := cipher.Decrypt(e)
+		if err != nil || plaintext != plaintexts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortTargets(targets []*ir.Target) {
+	slices.SortFunc(targets, func(a, b *ir.Target) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -3,32 +3,54 @@ package data
 import (
 	"context"
 	"encoding/json/v2"
+	"errors"
+	"fmt"
 
 	"github.com/go-kratos/kratos/v2/log"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	ir "github.com/tianping526/eventbridge/app/internal/rule"
 	"github.com/tianping526/eventbridge/app/internal/rule/target"
+	"github.com/tianping526/eventbridge/app/internal/secret"
 	"github.com/tianping526/eventbridge/app/service/internal/biz"
 	"github.com/tianping526/eventbridge/app/service/internal/data/ent"
 	entBus "github.com/tianping526/eventbridge/app/service/internal/data/ent/bus"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/connection"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/eventschema"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/protodescriptor"
 	"github.com/tianping526/eventbridge/app/service/internal/data/ent/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/data/ent/rulerevision"
 	"github.com/tianping526/eventbridge/app/service/internal/data/entext"
 )
 
+// operations of the rule revisions
+const (
+	ruleOperationCreate        = "CREATE"
+	ruleOperationUpdate        = "UPDATE"
+	ruleOperationDelete        = "DELETE"
+	ruleOperationCreateTargets = "CREATE_TARGETS"
+	ruleOperationUpdateTargets = "UPDATE_TARGETS"
+	ruleOperationDeleteTargets = "DELETE_TARGETS"
+	ruleOperationRollback      = "ROLLBACK"
+	ruleOperationApply         = "APPLY"
+)
+
 type ruleRepo struct {
 	logger *log.Helper
 	db     *ent.Client
+	cipher *secret.Cipher
 }
 
-func NewRuleRepo(logger log.Logger, db *ent.Client) biz.RuleRepo {
+func NewRuleRepo(logger log.Logger, db *ent.Client, cipher *secret.Cipher) biz.RuleRepo {
 	return &ruleRepo{
 		logger: log.NewHelper(log.With(
 			logger,
 			"module", "repo/rule",
 			"caller", log.DefaultCaller,
 		)),
-		db: db,
+		db:     db,
+		cipher: cipher,
 	}
 }
 
@@ -76,8 +98,12 @@ func (repo *ruleRepo) ListRule(
 func (repo *ruleRepo) CreateRule(
 	ctx context.Context, busName string, name string, status v1.RuleStatus, pattern []byte, targets []*ir.Target,
 ) (uint64, error) {
+	err := encryptTargetSecrets(repo.cipher, targets)
+	if err != nil {
+		return 0, err
+	}
 	var r *ent.Rule
-	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+	err = entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
 		// query data bus and lock
 		_, te := tx.Bus.Query().
 			Where(entBus.Name(busName)).
@@ -115,6 +141,12 @@ func (repo *ruleRepo) CreateRule(
 			return te
 		}
 
+		// save revision
+		_, te = saveRuleRevision(ctx, tx, r, ruleOperationCreate)
+		if te != nil {
+			return te
+		}
+
 		// update version
 		te = tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
 		if te != nil {
@@ -132,28 +164,27 @@ func (repo *ruleRepo) CreateRule(
 func (repo *ruleRepo) UpdateRule(
 	ctx context.Context, bus string, name string, status v1.RuleStatus, pattern []byte,
 ) error {
-	stmt := repo.db.Rule.Update().
-		Where(
-			rule.BusName(bus),
-			rule.Name(name),
-		)
-	if status != v1.RuleStatus_RULE_STATUS_UNSPECIFIED {
-		stmt.SetStatus(uint8(status))
-	}
-	if pattern != nil {
-		stmt.SetPattern(string(pattern))
-	}
-
 	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-		ar, err := stmt.Save(ctx)
+		r, err := queryRuleForUpdate(ctx, tx, bus, name)
 		if err != nil {
 			return err
 		}
-		if ar != 1 {
-			return v1.ErrorRuleNotFound(
-				"rule not found. bus name: %s, rule name: %s",
-				bus, name,
-			)
+		stmt := tx.Rule.UpdateOne(r)
+		if status != v1.RuleStatus_RULE_STATUS_UNSPECIFIED {
+			stmt.SetStatus(uint8(status))
+		}
+		if pattern != nil {
+			stmt.SetPattern(string(pattern))
+		}
+		r, err = stmt.Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		// save revision
+		_, err = saveRuleRevision(ctx, tx, r, ruleOperationUpdate)
+		if err != nil {
+			return err
 		}
 
 		// update version
@@ -168,20 +199,19 @@ func (repo *ruleRepo) UpdateRule(
 
 func (repo *ruleRepo) DeleteRule(ctx context.Context, bus string, name string) error {
 	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-		dr, err := repo.db.Rule.Delete().
-			Where(
-				rule.BusName(bus),
-				rule.Name(name),
-			).
-			Exec(ctx)
+		r, err := queryRuleForUpdate(ctx, tx, bus, name)
 		if err != nil {
 			return err
 		}
-		if dr != 1 {
-			return v1.ErrorRuleNotFound(
-				"rule not found. bus name: %s, rule name: %s",
-				bus, name,
-			)
+		err = tx.Rule.DeleteOne(r).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		// save revision, it keeps the deleted rule
+		_, err = saveRuleRevision(ctx, tx, r, ruleOperationDelete)
+		if err != nil {
+			return err
 		}
 
 		// update version
@@ -195,25 +225,13 @@ func (repo *ruleRepo) DeleteRule(ctx context.Context, bus string, name string) e
 }
 
 func (repo *ruleRepo) CreateTargets(ctx context.Context, bus string, ruleName string, targets []*ir.Target) error {
-	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-		r, te := tx.Rule.Query().
-			Where(
-				rule.BusName(bus),
-				rule.Name(ruleName),
-			).
-			Select(
-				rule.FieldID,
-				rule.FieldTargets,
-			).
-			ForUpdate().
-			Only(ctx)
+	err := encryptTargetSecrets(repo.cipher, targets)
+	if err != nil {
+		return err
+	}
+	err = entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		r, te := queryRuleForUpdate(ctx, tx, bus, ruleName)
 		if te != nil {
-			if ent.IsNotFound(te) {
-				return v1.ErrorRuleNotFound(
-					"rule not found. bus name: %s, rule name: %s",
-					bus, ruleName,
-				)
-			}
 			return te
 		}
 		var ts []*ir.Target
@@ -234,7 +252,13 @@ func (repo *ruleRepo) CreateTargets(ctx context.Context, bus string, ruleName st
 		if te != nil {
 			return te
 		}
-		_, te = tx.Rule.UpdateOneID(r.ID).SetTargets(string(bts)).Save(ctx)
+		r, te = tx.Rule.UpdateOneID(r.ID).SetTargets(string(bts)).Save(ctx)
+		if te != nil {
+			return te
+		}
+
+		// save revision
+		_, te = saveRuleRevision(ctx, tx, r, ruleOperationCreateTargets)
 		if te != nil {
 			return te
 		}
@@ -253,30 +277,63 @@ func (repo *ruleRepo) CreateTargets(ctx context.Context, bus string, ruleName st
 	return nil
 }
 
+func (repo *ruleRepo) UpdateTargets(ctx context.Context, bus string, ruleName string, targets []*ir.Target) error {
+	err := encryptTargetSecrets(repo.cipher, targets)
+	if err != nil {
+		return err
+	}
+	return entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		r, te := queryRuleForUpdate(ctx, tx, bus, ruleName)
+		if te != nil {
+			return te
+		}
+		var ts []*ir.Target
+		te = json.Unmarshal([]byte(r.Targets), &ts)
+		if te != nil {
+			return te
+		}
+		idx := make(map[uint64]int, len(ts))
+		for i, t := range ts {
+			idx[t.ID] = i
+		}
+		for _, t := range targets {
+			i, ok := idx[t.ID]
+			if !ok {
+				return v1.ErrorTargetNotFound(
+					"target not found. bus name: %s, rule name: %s, target id: %d",
+					bus, ruleName, t.ID,
+				)
+			}
+			ts[i] = t
+		}
+		bts, te := json.Marshal(ts)
+		if te != nil {
+			return te
+		}
+		r, te = tx.Rule.UpdateOneID(r.ID).SetTargets(string(bts)).Save(ctx)
+		if te != nil {
+			return te
+		}
+
+		// save revision
+		_, te = saveRuleRevision(ctx, tx, r, ruleOperationUpdateTargets)
+		if te != nil {
+			return te
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+	})
+}
+
 func (repo *ruleRepo) DeleteTargets(ctx context.Context, bus string, ruleName string, targetIDs []uint64) error {
 	tdm := make(map[uint64]bool)
 	for _, tid := range targetIDs {
 		tdm[tid] = true
 	}
 	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
-		r, te := tx.Rule.Query().
-			Where(
-				rule.BusName(bus),
-				rule.Name(ruleName),
-			).
-			Select(
-				rule.FieldID,
-				rule.FieldTargets,
-			).
-			ForUpdate().
-			Only(ctx)
+		r, te := queryRuleForUpdate(ctx, tx, bus, ruleName)
 		if te != nil {
-			if ent.IsNotFound(te) {
-				return v1.ErrorRuleNotFound(
-					"rule not found. bus name: %s, rule name: %s",
-					bus, ruleName,
-				)
-			}
 			return te
 		}
 		var ts []*ir.Target
@@ -294,7 +351,13 @@ func (repo *ruleRepo) DeleteTargets(ctx context.Context, bus string, ruleName st
 		if te != nil {
 			return te
 		}
-		_, te = tx.Rule.UpdateOneID(r.ID).SetTargets(string(bts)).Save(ctx)
+		r, te = tx.Rule.UpdateOneID(r.ID).SetTargets(string(bts)).Save(ctx)
+		if te != nil {
+			return te
+		}
+
+		// save revision
+		_, te = saveRuleRevision(ctx, tx, r, ruleOperationDeleteTargets)
 		if te != nil {
 			return te
 		}
@@ -313,6 +376,211 @@ func (repo *ruleRepo) DeleteTargets(ctx context.Context, bus string, ruleName st
 	return nil
 }
 
+func (repo *ruleRepo) ListRuleRevisions(
+	ctx context.Context, bus string, ruleName string, limit int32, nextToken uint64,
+) ([]*biz.RuleRevision, uint64, error) {
+	convertedLimit := int(limit)
+	stmt := repo.db.RuleRevision.Query().
+		Where(
+			rulerevision.BusName(bus),
+			rulerevision.RuleName(ruleName),
+		)
+	if nextToken > 0 {
+		stmt.Where(rulerevision.RevisionLTE(nextToken))
+	}
+	rs, err := stmt.Order(ent.Desc(rulerevision.FieldRevision)).Limit(convertedLimit + 1).All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := uint64(0)
+	if len(rs) > convertedLimit {
+		next = rs[convertedLimit].Revision
+		rs = rs[:convertedLimit]
+	}
+	revisions := make([]*biz.RuleRevision, 0, len(rs))
+	for _, r := range rs {
+		rev, err := ruleRevisionFromEnt(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, next, nil
+}
+
+func (repo *ruleRepo) GetRuleRevision(
+	ctx context.Context, bus string, ruleName string, revision uint64,
+) (*biz.RuleRevision, error) {
+	r, err := repo.db.RuleRevision.Query().
+		Where(
+			rulerevision.BusName(bus),
+			rulerevision.RuleName(ruleName),
+			rulerevision.Revision(revision),
+		).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorRuleRevisionNotFound(
+				"rule revision not found. bus name: %s, rule name: %s, revision: %d",
+				bus, ruleName, revision,
+			)
+		}
+		return nil, err
+	}
+	return ruleRevisionFromEnt(r)
+}
+
+// RollbackRule the rule is recreated if it has been deleted.
+func (repo *ruleRepo) RollbackRule(ctx context.Context, bus string, ruleName string, revision uint64) (uint64, error) {
+	var newRevision uint64
+	err := entext.WithTx(ctx, repo.db, func(tx *ent.Tx) error {
+		rev, te := tx.RuleRevision.Query().
+			Where(
+				rulerevision.BusName(bus),
+				rulerevision.RuleName(ruleName),
+				rulerevision.Revision(revision),
+			).
+			Only(ctx)
+		if te != nil {
+			if ent.IsNotFound(te) {
+				return v1.ErrorRuleRevisionNotFound(
+					"rule revision not found. bus name: %s, rule name: %s, revision: %d",
+					bus, ruleName, revision,
+				)
+			}
+			return te
+		}
+		if rev.Operation == ruleOperationDelete {
+			return v1.ErrorRuleRevisionNotFound(
+				"rule revision %d deletes the rule, roll back to an earlier revision. bus name: %s, rule name: %s",
+				revision, bus, ruleName,
+			)
+		}
+
+		r, te := queryRuleForUpdate(ctx, tx, bus, ruleName)
+		switch {
+		case te == nil:
+			r, te = tx.Rule.UpdateOne(r).
+				SetStatus(rev.Status).
+				SetPattern(rev.Pattern).
+				SetTargets(rev.Targets).
+				Save(ctx)
+		case v1.IsRuleNotFound(te):
+			// query data bus and lock
+			_, te = tx.Bus.Query().
+				Where(entBus.Name(bus)).
+				ForUpdate().
+				OnlyID(ctx)
+			if te != nil {
+				if ent.IsNotFound(te) {
+					return v1.ErrorDataBusNotFound(
+						"can't find the data bus. name: %s",
+						bus,
+					)
+				}
+				return te
+			}
+			r, te = tx.Rule.Create().
+				SetBusName(bus).
+				SetName(ruleName).
+				SetStatus(rev.Status).
+				SetPattern(rev.Pattern).
+				SetTargets(rev.Targets).
+				Save(ctx)
+		}
+		if te != nil {
+			return te
+		}
+
+		// save revision
+		newRevision, te = saveRuleRevision(ctx, tx, r, ruleOperationRollback)
+		if te != nil {
+			return te
+		}
+
+		// update version
+		return tx.Version.UpdateOneID(entext.RulesVersionID).AddVersion(1).Exec(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newRevision, nil
+}
+
+// queryRuleForUpdate queries the rule and locks it in the transaction.
+func queryRuleForUpdate(ctx context.Context, tx *ent.Tx, bus string, name string) (*ent.Rule, error) {
+	r, err := tx.Rule.Query().
+		Where(
+			rule.BusName(bus),
+			rule.Name(name),
+		).
+		ForUpdate().
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorRuleNotFound(
+				"rule not found. bus name: %s, rule name: %s",
+				bus, name,
+			)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// saveRuleRevision appends the rule after the operation as its next revision,
+// it must be called in the transaction of the operation.
+func saveRuleRevision(ctx context.Context, tx *ent.Tx, r *ent.Rule, operation string) (uint64, error) {
+	revision := uint64(1)
+	last, err := tx.RuleRevision.Query().
+		Where(
+			rulerevision.BusName(r.BusName),
+			rulerevision.RuleName(r.Name),
+		).
+		Order(ent.Desc(rulerevision.FieldRevision)).
+		Select(rulerevision.FieldRevision).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return 0, err
+	}
+	if last != nil {
+		revision = last.Revision + 1
+	}
+	err = tx.RuleRevision.Create().
+		SetBusName(r.BusName).
+		SetRuleName(r.Name).
+		SetRevision(revision).
+		SetOperation(operation).
+		SetStatus(r.Status).
+		SetPattern(r.Pattern).
+		SetTargets(r.Targets).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+func ruleRevisionFromEnt(r *ent.RuleRevision) (*biz.RuleRevision, error) {
+	var targets []*ir.Target
+	err := json.Unmarshal([]byte(r.Targets), &targets)
+	if err != nil {
+		return nil, err
+	}
+	return &biz.RuleRevision{
+		Revision:  r.Revision,
+		Operation: r.Operation,
+		Rule: &ir.Rule{
+			Name:    r.RuleName,
+			BusName: r.BusName,
+			Status:  v1.RuleStatus(r.Status),
+			Pattern: r.Pattern,
+			Targets: targets,
+		},
+		Time: timestamppb.New(r.CreateTime),
+	}, nil
+}
+
 func (repo *ruleRepo) ListDispatcherSchema(_ context.Context, types []string) ([]*biz.DispatcherSchema, error) {
 	schemaMap := target.ListAllDispatcherParamsSchema()
 
@@ -338,3 +606,148 @@ func (repo *ruleRepo) ListDispatcherSchema(_ context.Context, types []string) ([
 
 	return schemas, nil
 }
+
+func (repo *ruleRepo) GetProtoDescriptorSet(ctx context.Context, name string) ([]byte, error) {
+	pd, err := repo.db.ProtoDescriptor.Query().
+		Where(protodescriptor.Name(name)).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorDescriptorNotFound(
+				"can't find the protobuf descriptor. name: %s",
+				name,
+			)
+		}
+		return nil, err
+	}
+	return pd.DescriptorSet, nil
+}
+
+func (repo *ruleRepo) GetRulePattern(ctx context.Context, bus string, name string) ([]byte, error) {
+	r, err := repo.db.Rule.Query().
+		Where(
+			rule.BusName(bus),
+			rule.Name(name),
+		).
+		Select(rule.FieldPattern).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorRuleNotFound(
+				"rule not found. bus name: %s, rule name: %s",
+				bus, name,
+			)
+		}
+		return nil, err
+	}
+	return []byte(r.Pattern), nil
+}
+
+func (repo *ruleRepo) ListBusSchema(ctx context.Context, bus string) ([]*biz.Schema, error) {
+	ss, err := repo.db.EventSchema.Query().
+		Where(eventschema.Or(
+			eventschema.BusName(bus),
+			extraBusNamesContain(bus),
+		)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]*biz.Schema, 0, len(ss))
+	for _, s := range ss {
+		schemas = append(schemas, &biz.Schema{
+			Source:        s.Source,
+			Type:          s.Type,
+			BusName:       s.BusName,
+			ExtraBusNames: s.ExtraBusNames,
+			Spec:          s.Spec,
+			Format:        v1.SchemaFormat(s.Format),
+			Time:          timestamppb.New(s.CreateTime),
+		})
+	}
+	return schemas, nil
+}
+
+func (repo *ruleRepo) GetConnection(ctx context.Context, name string) (*ir.Connection, error) {
+	c, err := repo.db.Connection.Query().
+		Where(connection.Name(name)).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, v1.ErrorConnectionNotFound(
+				"can't find the connection. name: %s",
+				name,
+			)
+		}
+		return nil, err
+	}
+	conn := &ir.Connection{
+		Name:     c.Name,
+		AuthType: c.AuthType,
+		Key:      c.Key,
+		TokenURL: c.TokenURL,
+		Scopes:   c.Scopes,
+	}
+	if c.AuthType == ir.AuthTypeTLS {
+		conn.Secret, err = repo.cipher.Decrypt(c.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt the secret of the connection(%s) err: %w", name, err)
+		}
+	}
+	return conn, nil
+}
+
+// encryptTargetSecrets encrypts the signing secrets and the redaction keys of the new targets before they are stored.
+func encryptTargetSecrets(cipher *secret.Cipher, targets []*ir.Target) error {
+	for _, t := range targets {
+		err := encryptSigningSecrets(cipher, t)
+		if err != nil {
+			return err
+		}
+		err = encryptRedactionKeys(cipher, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encryptSigningSecrets(cipher *secret.Cipher, t *ir.Target) error {
+	if t.Signing == nil {
+		return nil
+	}
+	secrets := make([]string, 0, len(t.Signing.Secrets))
+	for _, plaintext := range t.Signing.Secrets {
+		encrypted, err := cipher.Encrypt(plaintext)
+		if err != nil {
+			if errors.Is(err, secret.ErrNoKey) {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) signing is disabled: %s", t.ID, err,
+				)
+			}
+			return err
+		}
+		secrets = append(secrets, encrypted)
+	}
+	t.Signing.Secrets = secrets
+	return nil
+}
+
+func encryptRedactionKeys(cipher *secret.Cipher, t *ir.Target) error {
+	for _, r := range t.Redactions {
+		if r.Key == "" {
+			continue
+		}
+		encrypted, err := cipher.Encrypt(r.Key)
+		if err != nil {
+			if errors.Is(err, secret.ErrNoKey) {
+				return v1.ErrorTargetParamSyntaxError(
+					"target(id: %d) redaction key is disabled: %s", t.ID, err,
+				)
+			}
+			return err
+		}
+		r.Key = encrypted
+	}
+	return nil
+}
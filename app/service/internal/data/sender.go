@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware/tracing"
+	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/propagation"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -50,6 +51,7 @@ type bus struct {
 
 type sender struct {
 	log *log.Helper
+	rc  redis.Cmdable
 
 	buses sync.Map // map[busName]*bus
 }
@@ -57,6 +59,7 @@ type sender struct {
 func NewSender(
 	logger log.Logger,
 	reflector informer.Reflector,
+	rc redis.Cmdable,
 ) (Sender, func(), error) {
 	s := &sender{
 		log: log.NewHelper(log.With(
@@ -64,6 +67,7 @@ func NewSender(
 			"module", "sender",
 			"caller", log.DefaultCaller,
 		)),
+		rc: rc,
 	}
 	h := newHandler(logger, reflector, s)
 	i := informer.NewInformer(logger, reflector, h)
@@ -113,6 +117,15 @@ func (s *sender) Send(
 
 	b := v.(*bus)
 	if pubTime.IsValid() {
+		// record the latest delayed event before sending it, the topic is drained until then if it is replaced
+		// by UpdateBus, so the event isn't sent if it can't be recorded.
+		err := s.rc.ZAddGT(ctx, busDelayUntilKey, redis.Z{
+			Score:  float64(pubTime.GetSeconds()),
+			Member: delayUntilMember(b.sourceDelay),
+		}).Err()
+		if err != nil {
+			return "", fmt.Errorf("record the delayed event of topic %s err: %w", b.sourceDelay.Topic, err)
+		}
 		return b.sourceDelayMQProducer.Send(ctx, b.sourceDelay.Topic, b.mode, eventExt, pubTime)
 	}
 	return b.sourceMQProducer.Send(ctx, b.source.Topic, b.mode, eventExt, pubTime)
@@ -21,28 +21,14 @@ func (s *EventBridgeService) ListBus(ctx context.Context, request *v1.ListBusReq
 	buses := make([]*v1.ListBusResponse_Bus, 0, len(bs))
 	for _, b := range bs {
 		bus := &v1.ListBusResponse_Bus{
-			Name: b.Name,
-			Mode: b.Mode,
-			Source: &v1.MQTopic{
-				MqType:    b.Source.Type,
-				Endpoints: strings.Split(b.Source.Endpoints, ";"),
-				Topic:     b.Source.Topic,
-			},
-			SourceDelay: &v1.MQTopic{
-				MqType:    b.SourceDelay.Type,
-				Endpoints: strings.Split(b.SourceDelay.Endpoints, ";"),
-				Topic:     b.SourceDelay.Topic,
-			},
-			TargetExpDecay: &v1.MQTopic{
-				MqType:    b.TargetExpDecay.Type,
-				Endpoints: strings.Split(b.TargetExpDecay.Endpoints, ";"),
-				Topic:     b.TargetExpDecay.Topic,
-			},
-			TargetBackoff: &v1.MQTopic{
-				MqType:    b.TargetBackoff.Type,
-				Endpoints: strings.Split(b.TargetBackoff.Endpoints, ";"),
-				Topic:     b.TargetBackoff.Topic,
-			},
+			Name:           b.Name,
+			Mode:           b.Mode,
+			Source:         mqTopicToProto(b.Source),
+			SourceDelay:    mqTopicToProto(b.SourceDelay),
+			TargetExpDecay: mqTopicToProto(b.TargetExpDecay),
+			TargetBackoff:  mqTopicToProto(b.TargetBackoff),
+			Status:         b.Status,
+			Discovery:      b.Discovery,
 		}
 		buses = append(buses, bus)
 	}
@@ -64,56 +50,43 @@ func deduplicateStrings(input []string) []string {
 	return result
 }
 
-func (s *EventBridgeService) CreateBus(
-	ctx context.Context, request *v1.CreateBusRequest,
-) (*v1.CreateBusResponse, error) {
-	if request.Source.MqType == v1.MQType_MQ_TYPE_UNSPECIFIED {
-		request.Source.MqType = v1.MQType_MQ_TYPE_ROCKETMQ
+// mqTopic returns the topic of RocketMQ by default, and its endpoints are deduplicated and sorted.
+func mqTopic(t *v1.MQTopic) biz.MQTopic {
+	if t.MqType == v1.MQType_MQ_TYPE_UNSPECIFIED {
+		t.MqType = v1.MQType_MQ_TYPE_ROCKETMQ
 	}
-	if request.SourceDelay.MqType == v1.MQType_MQ_TYPE_UNSPECIFIED {
-		request.SourceDelay.MqType = v1.MQType_MQ_TYPE_ROCKETMQ
+	t.Endpoints = deduplicateStrings(t.Endpoints)
+	sort.Strings(t.Endpoints)
+	return biz.MQTopic{
+		Type:      t.MqType,
+		Endpoints: strings.Join(t.Endpoints, ";"),
+		Topic:     t.Topic,
 	}
-	if request.TargetExpDecay.MqType == v1.MQType_MQ_TYPE_UNSPECIFIED {
-		request.TargetExpDecay.MqType = v1.MQType_MQ_TYPE_ROCKETMQ
-	}
-	if request.TargetBackoff.MqType == v1.MQType_MQ_TYPE_UNSPECIFIED {
-		request.TargetBackoff.MqType = v1.MQType_MQ_TYPE_ROCKETMQ
+}
+
+func mqTopicToProto(t biz.MQTopic) *v1.MQTopic {
+	return &v1.MQTopic{
+		MqType:    t.Type,
+		Endpoints: strings.Split(t.Endpoints, ";"),
+		Topic:     t.Topic,
 	}
+}
+
+func (s *EventBridgeService) CreateBus(
+	ctx context.Context, request *v1.CreateBusRequest,
+) (*v1.CreateBusResponse, error) {
 	if request.Mode == v1.BusWorkMode_BUS_WORK_MODE_UNSPECIFIED {
 		request.Mode = v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY
 	}
-	request.Source.Endpoints = deduplicateStrings(request.Source.Endpoints)
-	sort.Strings(request.Source.Endpoints)
-	request.SourceDelay.Endpoints = deduplicateStrings(request.SourceDelay.Endpoints)
-	sort.Strings(request.SourceDelay.Endpoints)
-	request.TargetExpDecay.Endpoints = deduplicateStrings(request.TargetExpDecay.Endpoints)
-	sort.Strings(request.TargetExpDecay.Endpoints)
-	request.TargetBackoff.Endpoints = deduplicateStrings(request.TargetBackoff.Endpoints)
-	sort.Strings(request.TargetBackoff.Endpoints)
 	id, err := s.bc.CreateBus(
 		ctx,
 		request.Name,
 		request.Mode,
-		biz.MQTopic{
-			Type:      request.Source.MqType,
-			Endpoints: strings.Join(request.Source.Endpoints, ";"),
-			Topic:     request.Source.Topic,
-		},
-		biz.MQTopic{
-			Type:      request.SourceDelay.MqType,
-			Endpoints: strings.Join(request.SourceDelay.Endpoints, ";"),
-			Topic:     request.SourceDelay.Topic,
-		},
-		biz.MQTopic{
-			Type:      request.TargetExpDecay.MqType,
-			Endpoints: strings.Join(request.TargetExpDecay.Endpoints, ";"),
-			Topic:     request.TargetExpDecay.Topic,
-		},
-		biz.MQTopic{
-			Type:      request.TargetBackoff.MqType,
-			Endpoints: strings.Join(request.TargetBackoff.Endpoints, ";"),
-			Topic:     request.TargetBackoff.Topic,
-		},
+		mqTopic(request.Source),
+		mqTopic(request.SourceDelay),
+		mqTopic(request.TargetExpDecay),
+		mqTopic(request.TargetBackoff),
+		request.Discovery,
 	)
 	if err != nil {
 		return nil, err
@@ -123,6 +96,35 @@ func (s *EventBridgeService) CreateBus(
 	}, nil
 }
 
+func (s *EventBridgeService) UpdateBus(
+	ctx context.Context, request *v1.UpdateBusRequest,
+) (*v1.UpdateBusResponse, error) {
+	if request.Mode != nil && *request.Mode == v1.BusWorkMode_BUS_WORK_MODE_UNSPECIFIED {
+		request.Mode = nil
+	}
+	if request.Status != nil && *request.Status == v1.BusStatus_BUS_STATUS_UNSPECIFIED {
+		request.Status = nil
+	}
+	topics := make([]*biz.MQTopic, 0, 4)
+	for _, t := range []*v1.MQTopic{
+		request.Source, request.SourceDelay, request.TargetExpDecay, request.TargetBackoff,
+	} {
+		if t == nil {
+			topics = append(topics, nil)
+			continue
+		}
+		topic := mqTopic(t)
+		topics = append(topics, &topic)
+	}
+	err := s.bc.UpdateBus(
+		ctx, request.Name, request.Mode, request.Status, topics[0], topics[1], topics[2], topics[3], request.Discovery,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.UpdateBusResponse{}, nil
+}
+
 func (s *EventBridgeService) DeleteBus(
 	ctx context.Context, request *v1.DeleteBusRequest,
 ) (*v1.DeleteBusResponse, error) {
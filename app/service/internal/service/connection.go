@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+)
+
+func (s *EventBridgeService) ListConnection(
+	ctx context.Context, request *v1.ListConnectionRequest,
+) (*v1.ListConnectionResponse, error) {
+	conns, err := s.cc.ListConnection(ctx, request.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	connections := make([]*v1.Connection, 0, len(conns))
+	for _, c := range conns {
+		connections = append(connections, &v1.Connection{
+			Name:     c.Name,
+			AuthType: c.AuthType,
+			Key:      c.Key,
+			TokenUrl: c.TokenURL,
+			Scopes:   c.Scopes,
+			Time:     c.Time,
+		})
+	}
+	return &v1.ListConnectionResponse{
+		Connections: connections,
+	}, nil
+}
+
+func (s *EventBridgeService) CreateConnection(
+	ctx context.Context, request *v1.CreateConnectionRequest,
+) (*v1.CreateConnectionResponse, error) {
+	err := s.cc.CreateConnection(ctx, &rule.Connection{
+		Name:     request.Name,
+		AuthType: request.AuthType,
+		Key:      request.Key,
+		Secret:   request.Secret,
+		TokenURL: request.TokenUrl,
+		Scopes:   request.Scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &v1.CreateConnectionResponse{}, nil
+}
+
+func (s *EventBridgeService) UpdateConnection(
+	ctx context.Context, request *v1.UpdateConnectionRequest,
+) (*v1.UpdateConnectionResponse, error) {
+	err := s.cc.UpdateConnection(ctx, &rule.Connection{
+		Name:     request.Name,
+		AuthType: request.AuthType,
+		Key:      request.Key,
+		TokenURL: request.TokenUrl,
+		Scopes:   request.Scopes,
+	}, request.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.UpdateConnectionResponse{}, nil
+}
+
+func (s *EventBridgeService) DeleteConnection(
+	ctx context.Context, request *v1.DeleteConnectionRequest,
+) (*v1.DeleteConnectionResponse, error) {
+	err := s.cc.DeleteConnection(ctx, request.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.DeleteConnectionResponse{}, nil
+}
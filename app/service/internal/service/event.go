@@ -7,6 +7,7 @@ import (
 
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
 )
 
 func (s *EventBridgeService) PostEvent(
@@ -19,15 +20,26 @@ func (s *EventBridgeService) PostEvent(
 	if err != nil {
 		return nil, fmt.Errorf("failed to create event extension: %w", err)
 	}
-	event, err := s.ec.PostEvent(ctx, eventExt, request.PubTime)
+	event, err := s.ec.PostEvent(
+		ctx, eventExt, request.PubTime, request.SchemaVersion, request.BusName, request.BusNames,
+	)
 	if err != nil {
 		return nil, err
 	}
+	results := make([]*v1.PostEventResponse_BusResult, 0, len(event.Results))
+	for _, r := range event.Results {
+		results = append(results, &v1.PostEventResponse_BusResult{
+			BusName:   r.BusName,
+			MessageId: r.MessageID,
+		})
+	}
 	return &v1.PostEventResponse{
-		Id:         event.ID,
-		MessageId:  event.MessageID,
-		MessageKey: event.MessageKey,
-		TraceId:    event.TraceID,
+		Id:          event.ID,
+		MessageId:   event.MessageID,
+		MessageKey:  event.MessageKey,
+		TraceId:     event.TraceID,
+		Quarantined: event.Quarantined,
+		Results:     results,
 	}, nil
 }
 
@@ -41,11 +53,15 @@ func (s *EventBridgeService) ListSchema(
 	schemas := make([]*v1.Schema, 0, len(ss))
 	for _, scm := range ss {
 		schema := &v1.Schema{
-			Source:  scm.Source,
-			Type:    scm.Type,
-			BusName: scm.BusName,
-			Spec:    scm.Spec,
-			Time:    scm.Time,
+			Source:        scm.Source,
+			Type:          scm.Type,
+			BusName:       scm.BusName,
+			ExtraBusNames: scm.ExtraBusNames,
+			Format:        scm.Format,
+			Spec:          scm.Spec,
+			Time:          scm.Time,
+			Version:       scm.Version,
+			Compatibility: scm.Compatibility,
 		}
 		schemas = append(schemas, schema)
 	}
@@ -62,7 +78,17 @@ func (s *EventBridgeService) CreateSchema(
 	if err != nil {
 		return nil, v1.ErrorSchemaSyntaxError("syntax error: %s", err)
 	}
-	err = s.ec.CreateSchema(ctx, request.Source, request.Type, request.BusName, specBytes)
+	compatibility := request.Compatibility
+	if compatibility == v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_UNSPECIFIED {
+		compatibility = v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE
+	}
+	format := request.Format
+	if format == v1.SchemaFormat_SCHEMA_FORMAT_UNSPECIFIED {
+		format = v1.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA
+	}
+	err = s.ec.CreateSchema(
+		ctx, request.Source, request.Type, request.BusName, specBytes, compatibility, request.ExtraBusNames, format,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -80,11 +106,25 @@ func (s *EventBridgeService) UpdateSchema(
 			return nil, v1.ErrorSchemaSyntaxError("syntax error: %s", err)
 		}
 	}
-	err := s.ec.UpdateSchema(ctx, request.Source, request.Type, request.BusName, specBytes)
+	compatibility := request.Compatibility
+	if compatibility != nil && *compatibility == v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_UNSPECIFIED {
+		compatibility = nil
+	}
+	var extraBusNames []string
+	if request.ExtraBusNames != nil {
+		extraBusNames = append([]string{}, request.ExtraBusNames.Names...)
+	}
+	format := request.Format
+	if format != nil && *format == v1.SchemaFormat_SCHEMA_FORMAT_UNSPECIFIED {
+		format = nil
+	}
+	version, err := s.ec.UpdateSchema(
+		ctx, request.Source, request.Type, request.BusName, specBytes, compatibility, extraBusNames, format,
+	)
 	if err != nil {
 		return nil, err
 	}
-	return &v1.UpdateSchemaResponse{}, nil
+	return &v1.UpdateSchemaResponse{Version: version}, nil
 }
 
 func (s *EventBridgeService) DeleteSchema(
@@ -96,3 +136,164 @@ func (s *EventBridgeService) DeleteSchema(
 	}
 	return &v1.DeleteSchemaResponse{}, nil
 }
+
+func (s *EventBridgeService) ListSchemaVersions(
+	ctx context.Context, request *v1.ListSchemaVersionsRequest,
+) (*v1.ListSchemaVersionsResponse, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	vs, next, err := s.ec.ListSchemaVersions(ctx, request.Source, request.Type, limit, request.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*v1.SchemaVersion, 0, len(vs))
+	for _, v := range vs {
+		versions = append(versions, schemaVersionToProto(v))
+	}
+	return &v1.ListSchemaVersionsResponse{
+		Versions:  versions,
+		NextToken: next,
+	}, nil
+}
+
+func (s *EventBridgeService) GetSchemaVersion(
+	ctx context.Context, request *v1.GetSchemaVersionRequest,
+) (*v1.GetSchemaVersionResponse, error) {
+	v, err := s.ec.GetSchemaVersion(ctx, request.Source, request.Type, request.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.GetSchemaVersionResponse{
+		Version: schemaVersionToProto(v),
+	}, nil
+}
+
+func schemaVersionToProto(v *biz.SchemaVersion) *v1.SchemaVersion {
+	return &v1.SchemaVersion{
+		Version:       v.Version,
+		BusName:       v.BusName,
+		ExtraBusNames: v.ExtraBusNames,
+		Format:        v.Format,
+		Spec:          v.Spec,
+		Compatibility: v.Compatibility,
+		Time:          v.Time,
+	}
+}
+
+func (s *EventBridgeService) ListDiscoveredSchemas(
+	ctx context.Context, request *v1.ListDiscoveredSchemasRequest,
+) (*v1.ListDiscoveredSchemasResponse, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	dss, next, err := s.ec.ListDiscoveredSchemas(ctx, request.BusName, limit, request.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make([]*v1.DiscoveredSchema, 0, len(dss))
+	for _, ds := range dss {
+		schemas = append(schemas, &v1.DiscoveredSchema{
+			Source:    ds.Source,
+			Type:      ds.Type,
+			BusName:   ds.BusName,
+			Spec:      ds.Spec,
+			Samples:   ds.Samples,
+			FirstTime: ds.FirstTime,
+			LastTime:  ds.LastTime,
+		})
+	}
+	return &v1.ListDiscoveredSchemasResponse{
+		Schemas:   schemas,
+		NextToken: next,
+	}, nil
+}
+
+func (s *EventBridgeService) PromoteDiscoveredSchema(
+	ctx context.Context, request *v1.PromoteDiscoveredSchemaRequest,
+) (*v1.PromoteDiscoveredSchemaResponse, error) {
+	var specBytes []byte
+	if request.Spec != nil {
+		specBytes = []byte(*request.Spec)
+		err := (*jsontext.Value)(&specBytes).Compact()
+		if err != nil {
+			return nil, v1.ErrorSchemaSyntaxError("syntax error: %s", err)
+		}
+	}
+	compatibility := request.Compatibility
+	if compatibility == v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_UNSPECIFIED {
+		compatibility = v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE
+	}
+	posted, dropped, err := s.ec.PromoteDiscoveredSchema(
+		ctx, request.Source, request.Type, specBytes, compatibility,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.PromoteDiscoveredSchemaResponse{
+		Posted:  posted,
+		Dropped: dropped,
+	}, nil
+}
+
+func (s *EventBridgeService) GenerateSchemaCode(
+	ctx context.Context, request *v1.GenerateSchemaCodeRequest,
+) (*v1.GenerateSchemaCodeResponse, error) {
+	language := request.Language
+	if language == v1.SchemaCodeLanguage_SCHEMA_CODE_LANGUAGE_UNSPECIFIED {
+		language = v1.SchemaCodeLanguage_SCHEMA_CODE_LANGUAGE_GO
+	}
+	pkg := request.Package
+	if pkg == "" {
+		pkg = "events"
+	}
+	code, err := s.ec.GenerateSchemaCode(ctx, language, request.Source, request.Type, request.BusName, pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.GenerateSchemaCodeResponse{Code: code}, nil
+}
+
+func (s *EventBridgeService) ListProtoDescriptor(
+	ctx context.Context, request *v1.ListProtoDescriptorRequest,
+) (*v1.ListProtoDescriptorResponse, error) {
+	pds, err := s.ec.ListProtoDescriptor(ctx, request.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]*v1.ProtoDescriptor, 0, len(pds))
+	for _, pd := range pds {
+		descriptor := &v1.ProtoDescriptor{
+			Name:          pd.Name,
+			DescriptorSet: pd.DescriptorSet,
+			Messages:      pd.Messages,
+			Time:          pd.Time,
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return &v1.ListProtoDescriptorResponse{
+		Descriptors: descriptors,
+	}, nil
+}
+
+func (s *EventBridgeService) CreateProtoDescriptor(
+	ctx context.Context, request *v1.CreateProtoDescriptorRequest,
+) (*v1.CreateProtoDescriptorResponse, error) {
+	err := s.ec.CreateProtoDescriptor(ctx, request.Name, request.DescriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.CreateProtoDescriptorResponse{}, nil
+}
+
+func (s *EventBridgeService) DeleteProtoDescriptor(
+	ctx context.Context, request *v1.DeleteProtoDescriptorRequest,
+) (*v1.DeleteProtoDescriptorResponse, error) {
+	err := s.ec.DeleteProtoDescriptor(ctx, request.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.DeleteProtoDescriptorResponse{}, nil
+}
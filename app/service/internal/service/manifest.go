@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json/jsontext"
+
+	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
+	"github.com/tianping526/eventbridge/app/internal/rule"
+	"github.com/tianping526/eventbridge/app/service/internal/biz"
+)
+
+func (s *EventBridgeService) Apply(ctx context.Context, request *v1.ApplyRequest) (*v1.ApplyResponse, error) {
+	manifest, err := manifestFromProto(request.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := s.mc.Apply(ctx, request.BusName, manifest, request.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*v1.ApplyResponse_Change, 0, len(cs))
+	for _, c := range cs {
+		changes = append(changes, &v1.ApplyResponse_Change{
+			Action:  c.Action,
+			Kind:    c.Kind,
+			BusName: c.BusName,
+			Name:    c.Name,
+		})
+	}
+	return &v1.ApplyResponse{
+		Changes: changes,
+	}, nil
+}
+
+func (s *EventBridgeService) Export(ctx context.Context, request *v1.ExportRequest) (*v1.ExportResponse, error) {
+	m, err := s.mc.Export(ctx, request.BusName)
+	if err != nil {
+		return nil, err
+	}
+	buses := make([]*v1.Manifest_Bus, 0, len(m.Buses))
+	for _, mb := range m.Buses {
+		schemas := make([]*v1.Manifest_Schema, 0, len(mb.Schemas))
+		for _, sc := range mb.Schemas {
+			schemas = append(schemas, &v1.Manifest_Schema{
+				Source:        sc.Source,
+				Type:          sc.Type,
+				ExtraBusNames: sc.ExtraBusNames,
+				Spec:          sc.Spec,
+				Compatibility: sc.Compatibility,
+				Format:        sc.Format,
+			})
+		}
+		rules := make([]*v1.Manifest_Rule, 0, len(mb.Rules))
+		for _, r := range mb.Rules {
+			targets := make([]*v1.Target, 0, len(r.Targets))
+			for _, t := range r.Targets {
+				targets = append(targets, targetToProto(t))
+			}
+			rules = append(rules, &v1.Manifest_Rule{
+				Name:    r.Name,
+				Status:  r.Status,
+				Pattern: r.Pattern,
+				Targets: targets,
+			})
+		}
+		buses = append(buses, &v1.Manifest_Bus{
+			Name:           mb.Bus.Name,
+			Mode:           mb.Bus.Mode,
+			Status:         mb.Bus.Status,
+			Source:         mqTopicToProto(mb.Bus.Source),
+			SourceDelay:    mqTopicToProto(mb.Bus.SourceDelay),
+			TargetExpDecay: mqTopicToProto(mb.Bus.TargetExpDecay),
+			TargetBackoff:  mqTopicToProto(mb.Bus.TargetBackoff),
+			Discovery:      mb.Bus.Discovery,
+			Schemas:        schemas,
+			Rules:          rules,
+		})
+	}
+	return &v1.ExportResponse{
+		Manifest: &v1.Manifest{Buses: buses},
+	}, nil
+}
+
+// manifestFromProto the unspecified mode, status, compatibility and format are defaulted like CreateBus,
+// CreateRule and CreateSchema, and the specs and the patterns are compacted.
+func manifestFromProto(m *v1.Manifest) (*biz.Manifest, error) {
+	manifest := &biz.Manifest{}
+	if m == nil {
+		return manifest, nil
+	}
+	manifest.Buses = make([]*biz.ManifestBus, 0, len(m.Buses))
+	for _, b := range m.Buses {
+		bus := &biz.Bus{
+			Name:      b.Name,
+			Mode:      b.Mode,
+			Status:    b.Status,
+			Discovery: b.Discovery,
+		}
+		if bus.Mode == v1.BusWorkMode_BUS_WORK_MODE_UNSPECIFIED {
+			bus.Mode = v1.BusWorkMode_BUS_WORK_MODE_CONCURRENTLY
+		}
+		if bus.Status == v1.BusStatus_BUS_STATUS_UNSPECIFIED {
+			bus.Status = v1.BusStatus_BUS_STATUS_ACTIVE
+		}
+		for _, t := range []struct {
+			name  string
+			topic *v1.MQTopic
+			set   *biz.MQTopic
+		}{
+			{"source", b.Source, &bus.Source},
+			{"source delay", b.SourceDelay, &bus.SourceDelay},
+			{"target exp decay", b.TargetExpDecay, &bus.TargetExpDecay},
+			{"target backoff", b.TargetBackoff, &bus.TargetBackoff},
+		} {
+			if t.topic == nil {
+				return nil, v1.ErrorManifestSyntaxError("bus(%s) %s topic is required", b.Name, t.name)
+			}
+			*t.set = mqTopic(t.topic)
+		}
+
+		schemas := make([]*biz.Schema, 0, len(b.Schemas))
+		for _, sc := range b.Schemas {
+			spec := []byte(sc.Spec)
+			err := (*jsontext.Value)(&spec).Compact()
+			if err != nil {
+				return nil, v1.ErrorSchemaSyntaxError(
+					"schema(%s/%s) syntax error: %s", sc.Source, sc.Type, err,
+				)
+			}
+			compatibility := sc.Compatibility
+			if compatibility == v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_UNSPECIFIED {
+				compatibility = v1.SchemaCompatibility_SCHEMA_COMPATIBILITY_NONE
+			}
+			format := sc.Format
+			if format == v1.SchemaFormat_SCHEMA_FORMAT_UNSPECIFIED {
+				format = v1.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA
+			}
+			schemas = append(schemas, &biz.Schema{
+				Source:        sc.Source,
+				Type:          sc.Type,
+				BusName:       b.Name,
+				ExtraBusNames: biz.ExtraBusNames(b.Name, sc.ExtraBusNames),
+				Spec:          string(spec),
+				Compatibility: compatibility,
+				Format:        format,
+			})
+		}
+
+		rules := make([]*rule.Rule, 0, len(b.Rules))
+		for _, r := range b.Rules {
+			status := r.Status
+			if status == v1.RuleStatus_RULE_STATUS_UNSPECIFIED {
+				status = v1.RuleStatus_RULE_STATUS_ENABLE
+			}
+			pattern := []byte(r.Pattern)
+			err := (*jsontext.Value)(&pattern).Compact()
+			if err != nil {
+				return nil, v1.ErrorPatternSyntaxError(
+					"rule(%s:%s) syntax error: %s", b.Name, r.Name, err,
+				)
+			}
+			targets, err := targetsFromProto(r.Targets)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, &rule.Rule{
+				Name:    r.Name,
+				BusName: b.Name,
+				Status:  status,
+				Pattern: string(pattern),
+				Targets: targets,
+			})
+		}
+
+		manifest.Buses = append(manifest.Buses, &biz.ManifestBus{
+			Bus:     bus,
+			Schemas: schemas,
+			Rules:   rules,
+		})
+	}
+	return manifest, nil
+}
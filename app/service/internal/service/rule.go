@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json/jsontext"
 
+	"google.golang.org/protobuf/types/known/durationpb"
+
 	v1 "github.com/tianping526/eventbridge/apis/api/eventbridge/service/v1"
 	"github.com/tianping526/eventbridge/app/internal/rule"
 )
@@ -21,23 +23,7 @@ func (s *EventBridgeService) ListRule(ctx context.Context, request *v1.ListRuleR
 	for _, r := range rs {
 		targets := make([]*v1.Target, 0, len(r.Targets))
 		for _, t := range r.Targets {
-			params := make([]*v1.TargetParam, 0, len(t.Params))
-			for _, p := range t.Params {
-				param := &v1.TargetParam{
-					Key:      p.Key,
-					Form:     p.Form,
-					Value:    p.Value,
-					Template: p.Template,
-				}
-				params = append(params, param)
-			}
-			target := &v1.Target{
-				Id:            t.ID,
-				Type:          t.Type,
-				Params:        params,
-				RetryStrategy: t.RetryStrategy,
-			}
-			targets = append(targets, target)
+			targets = append(targets, targetToProto(t))
 		}
 		rr := &v1.ListRuleResponse_Rule{
 			Name:    r.Name,
@@ -61,32 +47,12 @@ func (s *EventBridgeService) CreateRule(
 	if request.Status == v1.RuleStatus_RULE_STATUS_UNSPECIFIED {
 		status = v1.RuleStatus_RULE_STATUS_ENABLE
 	}
-	targetMapping := make(map[uint64]*rule.Target, len(request.Targets))
-	for _, t := range request.Targets {
-		params := make([]*rule.TargetParam, 0, len(t.Params))
-		for _, p := range t.Params {
-			param := &rule.TargetParam{
-				Key:      p.Key,
-				Form:     p.Form,
-				Value:    p.Value,
-				Template: p.Template,
-			}
-			params = append(params, param)
-		}
-		target := &rule.Target{
-			ID:            t.Id,
-			Type:          t.Type,
-			Params:        params,
-			RetryStrategy: t.RetryStrategy,
-		}
-		targetMapping[t.Id] = target
-	}
-	targets := make([]*rule.Target, 0, len(targetMapping))
-	for _, t := range targetMapping {
-		targets = append(targets, t)
+	targets, err := targetsFromProto(request.Targets)
+	if err != nil {
+		return nil, err
 	}
 	pattern := []byte(request.Pattern)
-	err := (*jsontext.Value)(&pattern).Compact()
+	err = (*jsontext.Value)(&pattern).Compact()
 	if err != nil {
 		return nil, v1.ErrorPatternSyntaxError(
 			"syntax error: %s", err,
@@ -134,37 +100,31 @@ func (s *EventBridgeService) DeleteRule(
 func (s *EventBridgeService) CreateTargets(
 	ctx context.Context, request *v1.CreateTargetsRequest,
 ) (*v1.CreateTargetsResponse, error) {
-	targetMapping := make(map[uint64]*rule.Target, len(request.Targets))
-	for _, t := range request.Targets {
-		params := make([]*rule.TargetParam, 0, len(t.Params))
-		for _, p := range t.Params {
-			param := &rule.TargetParam{
-				Key:      p.Key,
-				Form:     p.Form,
-				Value:    p.Value,
-				Template: p.Template,
-			}
-			params = append(params, param)
-		}
-		target := &rule.Target{
-			ID:            t.Id,
-			Type:          t.Type,
-			Params:        params,
-			RetryStrategy: t.RetryStrategy,
-		}
-		targetMapping[t.Id] = target
-	}
-	targets := make([]*rule.Target, 0, len(targetMapping))
-	for _, t := range targetMapping {
-		targets = append(targets, t)
+	targets, err := targetsFromProto(request.Targets)
+	if err != nil {
+		return nil, err
 	}
-	err := s.rc.CreateTargets(ctx, request.BusName, request.RuleName, targets)
+	err = s.rc.CreateTargets(ctx, request.BusName, request.RuleName, targets)
 	if err != nil {
 		return nil, err
 	}
 	return &v1.CreateTargetsResponse{}, nil
 }
 
+func (s *EventBridgeService) UpdateTargets(
+	ctx context.Context, request *v1.UpdateTargetsRequest,
+) (*v1.UpdateTargetsResponse, error) {
+	targets, err := targetsFromProto(request.Targets)
+	if err != nil {
+		return nil, err
+	}
+	err = s.rc.UpdateTargets(ctx, request.BusName, request.RuleName, targets)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.UpdateTargetsResponse{}, nil
+}
+
 func (s *EventBridgeService) DeleteTargets(
 	ctx context.Context, request *v1.DeleteTargetsRequest,
 ) (*v1.DeleteTargetsResponse, error) {
@@ -175,6 +135,50 @@ func (s *EventBridgeService) DeleteTargets(
 	return &v1.DeleteTargetsResponse{}, nil
 }
 
+func (s *EventBridgeService) ListRuleRevisions(
+	ctx context.Context, request *v1.ListRuleRevisionsRequest,
+) (*v1.ListRuleRevisionsResponse, error) {
+	limit := request.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	rs, nt, err := s.rc.ListRuleRevisions(ctx, request.BusName, request.RuleName, limit, request.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*v1.ListRuleRevisionsResponse_RuleRevision, 0, len(rs))
+	for _, r := range rs {
+		targets := make([]*v1.Target, 0, len(r.Rule.Targets))
+		for _, t := range r.Rule.Targets {
+			targets = append(targets, targetToProto(t))
+		}
+		revisions = append(revisions, &v1.ListRuleRevisionsResponse_RuleRevision{
+			Revision:  r.Revision,
+			Operation: r.Operation,
+			Status:    r.Rule.Status,
+			Pattern:   r.Rule.Pattern,
+			Targets:   targets,
+			Time:      r.Time,
+		})
+	}
+	return &v1.ListRuleRevisionsResponse{
+		Revisions: revisions,
+		NextToken: nt,
+	}, nil
+}
+
+func (s *EventBridgeService) RollbackRule(
+	ctx context.Context, request *v1.RollbackRuleRequest,
+) (*v1.RollbackRuleResponse, error) {
+	revision, err := s.rc.RollbackRule(ctx, request.BusName, request.RuleName, request.Revision)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.RollbackRuleResponse{
+		Revision: revision,
+	}, nil
+}
+
 func (s *EventBridgeService) ListDispatcherSchema(
 	ctx context.Context, request *v1.ListDispatcherSchemaRequest,
 ) (*v1.ListDispatcherSchemaResponse, error) {
@@ -194,3 +198,183 @@ func (s *EventBridgeService) ListDispatcherSchema(
 		DispatcherSchemas: dispatcherSchemas,
 	}, nil
 }
+
+// targetsFromProto converts the targets of request, the later target overrides the earlier one with the same id.
+func targetsFromProto(ts []*v1.Target) ([]*rule.Target, error) {
+	targetMapping := make(map[uint64]*rule.Target, len(ts))
+	for _, t := range ts {
+		params := make([]*rule.TargetParam, 0, len(t.Params))
+		for _, p := range t.Params {
+			param := &rule.TargetParam{
+				Key:      p.Key,
+				Form:     p.Form,
+				Value:    p.Value,
+				Template: p.Template,
+			}
+			params = append(params, param)
+		}
+		var redactions []*rule.Redaction
+		for _, r := range t.Redactions {
+			redaction := &rule.Redaction{
+				Path:     r.Path,
+				Action:   r.Action,
+				KeepLast: r.KeepLast,
+				Key:      r.Key,
+			}
+			redactions = append(redactions, redaction)
+		}
+		var encoding *rule.Encoding
+		if t.Encoding != nil {
+			encoding = &rule.Encoding{
+				Format:     t.Encoding.Format,
+				Field:      t.Encoding.Field,
+				Root:       t.Encoding.Root,
+				Descriptor: t.Encoding.DescriptorName,
+				Message:    t.Encoding.Message,
+			}
+		}
+		var pattern []byte
+		if t.Pattern != "" {
+			pattern = []byte(t.Pattern)
+			err := (*jsontext.Value)(&pattern).Compact()
+			if err != nil {
+				return nil, v1.ErrorPatternSyntaxError(
+					"target(id: %d) pattern syntax error: %s", t.Id, err,
+				)
+			}
+		}
+		target := &rule.Target{
+			ID:            t.Id,
+			Type:          t.Type,
+			Params:        params,
+			RetryStrategy: t.RetryStrategy,
+			Redactions:    redactions,
+			Encoding:      encoding,
+			Pattern:       string(pattern),
+			Connection:    t.Connection,
+
+			MaxRequestsPerSecond: t.MaxRequestsPerSecond,
+			MaxConcurrency:       t.MaxConcurrency,
+		}
+		if t.Signing != nil {
+			target.Signing = &rule.Signing{Secrets: t.Signing.Secrets}
+		}
+		if t.Batch != nil {
+			target.Batch = &rule.Batch{
+				MaxSize:   t.Batch.MaxSize,
+				MaxBytes:  t.Batch.MaxBytes,
+				MaxLinger: t.Batch.MaxLinger.AsDuration(),
+			}
+		}
+		if t.OnSuccess != nil {
+			target.OnSuccess = &rule.OnSuccess{
+				BusName: t.OnSuccess.BusName,
+				Source:  t.OnSuccess.Source,
+				Type:    t.OnSuccess.Type,
+			}
+		}
+		if t.HttpClient != nil {
+			target.HTTPClient = &rule.HTTPClient{
+				Timeout:      t.HttpClient.Timeout.AsDuration(),
+				SuccessCodes: t.HttpClient.SuccessCodes,
+				Proxy:        t.HttpClient.Proxy,
+				MaxConns:     t.HttpClient.MaxConns,
+				MaxRedirects: t.HttpClient.MaxRedirects,
+			}
+			if t.HttpClient.Tls != nil {
+				target.HTTPClient.TLS = &rule.HTTPClientTLS{
+					CACert:             t.HttpClient.Tls.CaCert,
+					ServerName:         t.HttpClient.Tls.ServerName,
+					InsecureSkipVerify: t.HttpClient.Tls.InsecureSkipVerify,
+				}
+			}
+		}
+		targetMapping[t.Id] = target
+	}
+	targets := make([]*rule.Target, 0, len(targetMapping))
+	for _, t := range targetMapping {
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func targetToProto(t *rule.Target) *v1.Target {
+	params := make([]*v1.TargetParam, 0, len(t.Params))
+	for _, p := range t.Params {
+		param := &v1.TargetParam{
+			Key:      p.Key,
+			Form:     p.Form,
+			Value:    p.Value,
+			Template: p.Template,
+		}
+		params = append(params, param)
+	}
+	redactions := make([]*v1.Redaction, 0, len(t.Redactions))
+	for _, r := range t.Redactions {
+		redaction := &v1.Redaction{
+			Path:     r.Path,
+			Action:   r.Action,
+			KeepLast: r.KeepLast,
+			// the key is write-only
+		}
+		redactions = append(redactions, redaction)
+	}
+	var encoding *v1.Encoding
+	if t.Encoding != nil {
+		encoding = &v1.Encoding{
+			Format:         t.Encoding.Format,
+			Field:          t.Encoding.Field,
+			Root:           t.Encoding.Root,
+			DescriptorName: t.Encoding.Descriptor,
+			Message:        t.Encoding.Message,
+		}
+	}
+	target := &v1.Target{
+		Id:            t.ID,
+		Type:          t.Type,
+		Params:        params,
+		RetryStrategy: t.RetryStrategy,
+		Redactions:    redactions,
+		Encoding:      encoding,
+		Pattern:       t.Pattern,
+		Connection:    t.Connection,
+
+		MaxRequestsPerSecond: t.MaxRequestsPerSecond,
+		MaxConcurrency:       t.MaxConcurrency,
+	}
+	if t.Signing != nil {
+		// the secrets are write-only
+		target.Signing = &v1.Signing{}
+	}
+	if t.Batch != nil {
+		target.Batch = &v1.Batch{
+			MaxSize:   t.Batch.MaxSize,
+			MaxBytes:  t.Batch.MaxBytes,
+			MaxLinger: durationpb.New(t.Batch.MaxLinger),
+		}
+	}
+	if t.OnSuccess != nil {
+		target.OnSuccess = &v1.OnSuccess{
+			BusName: t.OnSuccess.BusName,
+			Source:  t.OnSuccess.Source,
+			Type:    t.OnSuccess.Type,
+		}
+	}
+	if t.HTTPClient != nil {
+		target.HttpClient = &v1.HTTPClient{
+			Timeout:      durationpb.New(t.HTTPClient.Timeout),
+			SuccessCodes: t.HTTPClient.SuccessCodes,
+			Proxy:        t.HTTPClient.Proxy,
+			MaxConns:     t.HTTPClient.MaxConns,
+			MaxRedirects: t.HTTPClient.MaxRedirects,
+		}
+		if t.HTTPClient.TLS != nil {
+			target.HttpClient.Tls = &v1.HTTPClientTLS{
+				CaCert:             t.HTTPClient.TLS.CACert,
+				ServerName:         t.HTTPClient.TLS.ServerName,
+				InsecureSkipVerify: t.HTTPClient.TLS.InsecureSkipVerify,
+			}
+		}
+	}
+	return target
+}
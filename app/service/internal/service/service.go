@@ -19,6 +19,8 @@ type EventBridgeService struct {
 	ec *biz.EventUseCase
 	bc *biz.BusUseCase
 	rc *biz.RuleUseCase
+	cc *biz.ConnectionUseCase
+	mc *biz.ManifestUseCase
 
 	log *log.Helper
 }
@@ -27,6 +29,8 @@ func NewEventBridgeService(
 	ec *biz.EventUseCase,
 	bc *biz.BusUseCase,
 	rc *biz.RuleUseCase,
+	cc *biz.ConnectionUseCase,
+	mc *biz.ManifestUseCase,
 	logger log.Logger,
 ) *EventBridgeService {
 	return &EventBridgeService{
@@ -38,5 +42,7 @@ func NewEventBridgeService(
 		ec: ec,
 		bc: bc,
 		rc: rc,
+		cc: cc,
+		mc: mc,
 	}
 }
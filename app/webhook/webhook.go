@@ -0,0 +1,224 @@
+// Package webhook signs the requests of the HTTP dispatcher, and verifies them for the receivers.
+//
+// A signed request carries the unix timestamp of the delivery in the TimestampHeader,
+// and the HMAC-SHA256 signatures of "<timestamp>.<body>" in the SignatureHeader,
+// formatted as "v1=<hex>[,v1=<hex>]", one for each active secret of the target
+// so that the secret can be rotated without dropping the requests.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TimestampHeader = "X-EventBridge-Timestamp"
+	SignatureHeader = "X-EventBridge-Signature"
+
+	// DefaultTolerance is the default maximum age of the requests accepted by the Verifier.
+	DefaultTolerance = 5 * time.Minute
+
+	signatureScheme = "v1"
+)
+
+var (
+	ErrNoSignature       = errors.New("webhook: no signature")
+	ErrInvalidTimestamp  = errors.New("webhook: invalid timestamp")
+	ErrTimestampExpired  = errors.New("webhook: timestamp is out of the tolerance")
+	ErrInvalidSignature  = errors.New("webhook: signature doesn't match")
+	ErrReplayedSignature = errors.New("webhook: signature has been used")
+)
+
+// Sign returns the value of the SignatureHeader signed by each of the secrets.
+func Sign(secrets []string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		signatures = append(signatures, signatureScheme+"="+hex.EncodeToString(sign([]byte(secret), ts, body)))
+	}
+	return strings.Join(signatures, ",")
+}
+
+// SetHeaders signs the body and sets the TimestampHeader and the SignatureHeader of the request.
+func SetHeaders(header http.Header, secrets []string, timestamp time.Time, body []byte) {
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(SignatureHeader, Sign(secrets, timestamp, body))
+}
+
+func sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+type Option func(*Verifier)
+
+// WithTolerance sets the maximum age of the requests, and the requests from the future by the same amount.
+func WithTolerance(tolerance time.Duration) Option {
+	return func(v *Verifier) {
+		v.tolerance = tolerance
+	}
+}
+
+// WithoutReplayCheck disables rejecting the signatures already verified within the tolerance,
+// e.g. when the receiver deduplicates the events by itself.
+func WithoutReplayCheck() Option {
+	return func(v *Verifier) {
+		v.seen = nil
+	}
+}
+
+// Verifier verifies the signed requests. The receiver configures all the active secrets of the target,
+// and the request is accepted if any of its signatures matches any of the secrets.
+// The verified signatures are remembered within the tolerance to reject replays,
+// note that they are remembered in memory, so replays to other instances of the receiver are not detected.
+type Verifier struct {
+	secrets   [][]byte
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // timestamp.signature -> expiry
+	nextSweep time.Time
+}
+
+func NewVerifier(secrets []string, opts ...Option) *Verifier {
+	v := &Verifier{
+		secrets:   make([][]byte, 0, len(secrets)),
+		tolerance: DefaultTolerance,
+		now:       time.Now,
+		seen:      make(map[string]time.Time),
+	}
+	for _, secret := range secrets {
+		v.secrets = append(v.secrets, []byte(secret))
+	}
+	for _, o := range opts {
+		o(v)
+	}
+	return v
+}
+
+// Verify verifies the signature headers of the request against the body.
+// The signatures are remembered once verified, use Begin if the receiver may fail to handle the request.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	done, err := v.Begin(header, body)
+	if err != nil {
+		return err
+	}
+	done(true)
+	return nil
+}
+
+// Begin verifies the request like Verify, but the signatures are only remembered if the receiver handles it.
+// done(true) remembers the signatures to reject the replays, and done(false) forgets them,
+// so that the retry of the sender with the same signature is accepted. The request with the same signature
+// is rejected as a replay until done is called.
+func (v *Verifier) Begin(header http.Header, body []byte) (done func(handled bool), err error) {
+	ts := header.Get(TimestampHeader)
+	sigHeader := header.Get(SignatureHeader)
+	if ts == "" || sigHeader == "" {
+		return nil, ErrNoSignature
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+	now := v.now()
+	age := now.Sub(time.Unix(unix, 0))
+	if age > v.tolerance || age < -v.tolerance {
+		return nil, ErrTimestampExpired
+	}
+
+	// the replays are keyed by the timestamp and the matched signatures rather than the raw header,
+	// so reordering the signatures or appending other ones doesn't make a replay look new.
+	var matched []string
+	for _, item := range strings.Split(sigHeader, ",") {
+		scheme, sigHex, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || scheme != signatureScheme {
+			continue
+		}
+		sig, errDecode := hex.DecodeString(sigHex)
+		if errDecode != nil {
+			continue
+		}
+		for _, secret := range v.secrets {
+			if hmac.Equal(sig, sign(secret, ts, body)) {
+				matched = append(matched, strconv.FormatInt(unix, 10)+"."+hex.EncodeToString(sig))
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	return v.checkReplay(matched, now, time.Unix(unix, 0).Add(v.tolerance))
+}
+
+// VerifyRequest reads and verifies the body of the request, the body is restored for the later reads.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, done, err := v.BeginRequest(r)
+	if err != nil {
+		return body, err
+	}
+	done(true)
+	return body, nil
+}
+
+// BeginRequest reads the body of the request and verifies it like Begin, the body is restored for the later reads.
+func (v *Verifier) BeginRequest(r *http.Request) (body []byte, done func(handled bool), err error) {
+	body, err = io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	done, err = v.Begin(r.Header, body)
+	return body, done, err
+}
+
+// checkReplay rejects the request if any of its matched signatures has been verified, otherwise remembers all of them
+// until done(false) is called.
+func (v *Verifier) checkReplay(signatures []string, now time.Time, expiry time.Time) (func(handled bool), error) {
+	if v.seen == nil {
+		return func(bool) {}, nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.After(v.nextSweep) {
+		for sig, exp := range v.seen {
+			if now.After(exp) {
+				delete(v.seen, sig)
+			}
+		}
+		v.nextSweep = now.Add(v.tolerance)
+	}
+	for _, sig := range signatures {
+		if exp, ok := v.seen[sig]; ok && !now.After(exp) {
+			return nil, ErrReplayedSignature
+		}
+	}
+	for _, sig := range signatures {
+		v.seen[sig] = expiry
+	}
+	return func(handled bool) {
+		if handled {
+			return
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		for _, sig := range signatures {
+			delete(v.seen, sig)
+		}
+	}, nil
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"a":1}`)
+	signed := func(secrets []string, ts time.Time, b []byte) http.Header {
+		header := http.Header{}
+		SetHeaders(header, secrets, ts, b)
+		return header
+	}
+	invalidTimestamp := http.Header{}
+	invalidTimestamp.Set(TimestampHeader, "abc")
+	invalidTimestamp.Set(SignatureHeader, "v1=00")
+	verifyTests := []struct {
+		header http.Header
+		body   []byte
+		err    error
+	}{
+		{header: signed([]string{"old"}, now, body), body: body},
+		{header: signed([]string{"new", "old"}, now, body), body: body},
+		{header: signed([]string{"new", "other"}, now.Add(-time.Minute), body), body: body},
+		{header: signed([]string{"other"}, now, body), body: body, err: ErrInvalidSignature},
+		{header: signed([]string{"old"}, now, body), body: []byte(`{"a":2}`), err: ErrInvalidSignature},
+		{header: signed([]string{"old"}, now.Add(-10*time.Minute), body), body: body, err: ErrTimestampExpired},
+		{header: signed([]string{"old"}, now.Add(10*time.Minute), body), body: body, err: ErrTimestampExpired},
+		{header: http.Header{}, body: body, err: ErrNoSignature},
+		{header: invalidTimestamp, body: body, err: ErrInvalidTimestamp},
+	}
+	for idx, tt := range verifyTests {
+		v := NewVerifier([]string{"new", "old"})
+		v.now = func() time.Time { return now }
+		err := v.Verify(tt.header, tt.body)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("case(index=%d) expect err: %v, actual: %v", idx, tt.err, err)
+		}
+	}
+
+	// replay
+	v := NewVerifier([]string{"old"})
+	v.now = func() time.Time { return now }
+	header := signed([]string{"old"}, now, body)
+	if err := v.Verify(header, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(header, body); !errors.Is(err, ErrReplayedSignature) {
+		t.Fatalf("expect replayed signature err, actual: %v", err)
+	}
+	// the same signature reordered, padded or in another case is still a replay
+	sig := header.Get(SignatureHeader)
+	for _, replayed := range []string{
+		"v1=00," + sig,
+		sig + ",v1=00,v2=abc",
+		" v1=" + strings.ToUpper(strings.TrimPrefix(sig, "v1=")),
+	} {
+		h := header.Clone()
+		h.Set(SignatureHeader, replayed)
+		if err := v.Verify(h, body); !errors.Is(err, ErrReplayedSignature) {
+			t.Fatalf("signature(%s) expect replayed signature err, actual: %v", replayed, err)
+		}
+	}
+	// any of the rotated signatures has been seen
+	rotated := NewVerifier([]string{"new", "old"})
+	rotated.now = func() time.Time { return now }
+	if err := rotated.Verify(header, body); err != nil {
+		t.Fatal(err)
+	}
+	h := signed([]string{"new", "old"}, now, body)
+	if err := rotated.Verify(h, body); !errors.Is(err, ErrReplayedSignature) {
+		t.Fatalf("expect replayed signature err, actual: %v", err)
+	}
+	noReplay := NewVerifier([]string{"old"}, WithoutReplayCheck(), WithTolerance(time.Hour))
+	noReplay.now = func() time.Time { return now.Add(30 * time.Minute) }
+	for range 2 {
+		if err := noReplay.Verify(header, body); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBegin(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"a":1}`)
+	header := http.Header{}
+	SetHeaders(header, []string{"secret1"}, now, body)
+	v := NewVerifier([]string{"secret1"})
+	v.now = func() time.Time { return now }
+
+	done, err := v.Begin(header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the request being handled is a replay
+	if _, err = v.Begin(header, body); !errors.Is(err, ErrReplayedSignature) {
+		t.Fatalf("expect replayed signature err, actual: %v", err)
+	}
+	// the retry of the request the receiver failed to handle is accepted
+	done(false)
+	done, err = v.Begin(header, body)
+	if err != nil {
+		t.Fatalf("expect the retry accepted, actual: %v", err)
+	}
+	done(true)
+	if _, err = v.Begin(header, body); !errors.Is(err, ErrReplayedSignature) {
+		t.Fatalf("expect replayed signature err, actual: %v", err)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"a":1}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	SetHeaders(r.Header, []string{"secret1"}, time.Now(), []byte(body))
+	if _, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64); err != nil {
+		t.Fatalf("timestamp header err: %v", err)
+	}
+	got, err := NewVerifier([]string{"secret1"}).VerifyRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Fatalf("expect body: %s, actual: %s", body, got)
+	}
+	buf := make([]byte, len(body))
+	n, _ := r.Body.Read(buf)
+	if string(buf[:n]) != body {
+		t.Fatal("body is not restored")
+	}
+}